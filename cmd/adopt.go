@@ -0,0 +1,79 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jlandowner/psp-util/cmd/options"
+	"github.com/jlandowner/psp-util/pkg/managed"
+	"github.com/jlandowner/psp-util/pkg/printers"
+	"github.com/jlandowner/psp-util/pkg/relations"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(adoptCmd)
+	adoptCmd.Flags().StringVar(&ad.DryRun, "dry-run", options.DryRunNone, "print the resources to be adopted without persisting. One of: none|client|server")
+}
+
+var (
+	ad = &options.AdoptOptions{}
+
+	adoptCmd = &cobra.Command{
+		Use:               "adopt [PSP-NAME]",
+		Short:             "Take over existing ClusterRoles and bindings granting PSP as managed resources",
+		PersistentPreRunE: ad.PreRunE,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			k8sclient, err := newClient(kubeconfigPath, kubecontext)
+			if err != nil {
+				return fmt.Errorf("Failed to load kubeconfig %v: %v", kubeconfigPath, err.Error())
+			}
+
+			psps, err := relations.GetRelationalPSPs(ctx, k8sclient)
+			if err != nil {
+				return err
+			}
+			if ad.PSPName != "" {
+				psp, ok := relations.FindRelationalPSP(psps, ad.PSPName)
+				if !ok {
+					return fmt.Errorf("PSP %s is not found. See `psp-util tree`", ad.PSPName)
+				}
+				psps = []relations.RelationalPodSecurityPolicy{*psp}
+			}
+
+			adoptions, skipped := managed.FindAdoptions(psps)
+			for _, reason := range skipped {
+				fmt.Fprintf(os.Stderr, "Skipped %s\n", reason)
+			}
+			if len(adoptions) == 0 {
+				fmt.Println("No resources to adopt. See `psp-util tree`")
+				return nil
+			}
+
+			for _, adoption := range adoptions {
+				if err := adoption.Adopt(ctx, k8sclient, ad.DryRun); err != nil {
+					return err
+				}
+				printers.PrintAdoption(os.Stdout, adoption, ad.DryRun)
+			}
+			return nil
+		},
+	}
+)
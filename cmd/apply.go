@@ -0,0 +1,66 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jlandowner/psp-util/cmd/options"
+	"github.com/jlandowner/psp-util/pkg/managed"
+	"github.com/jlandowner/psp-util/pkg/printers"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+	applyCmd.Flags().StringVarP(&ap.Filename, "filename", "f", "", "PSP assignment file")
+	applyCmd.Flags().BoolVar(&ap.Prune, "prune", false, "detach subjects not declared in the file from managed bindings")
+}
+
+var (
+	ap = &options.PlanApplyOptions{}
+
+	applyCmd = &cobra.Command{
+		Use:               "apply -f FILENAME",
+		Short:             "Reconcile managed RBACs with the PSP assignment file",
+		PersistentPreRunE: ap.PreRunE,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			k8sclient, err := newClient(kubeconfigPath, kubecontext)
+			if err != nil {
+				return fmt.Errorf("Failed to load kubeconfig %v: %v", kubeconfigPath, err.Error())
+			}
+
+			plan, err := newAssignmentPlan(ctx, k8sclient, ap)
+			if err != nil {
+				return err
+			}
+			printers.PrintPlan(os.Stdout, plan)
+			if plan.IsEmpty() {
+				return nil
+			}
+
+			if err := plan.Apply(ctx, k8sclient, managed.DryRunNone); err != nil {
+				return err
+			}
+			create, update, destroy := plan.Counts()
+			fmt.Printf("\nApply complete! Resources: %d added, %d changed, %d destroyed.\n", create, update, destroy)
+			return nil
+		},
+	}
+)
@@ -18,27 +18,34 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"os"
 
 	"github.com/jlandowner/psp-util/cmd/options"
-	"github.com/jlandowner/psp-util/pkg/client"
+	"github.com/jlandowner/psp-util/pkg/managed"
 	"github.com/jlandowner/psp-util/pkg/policy"
-	"github.com/jlandowner/psp-util/pkg/rbac"
-	"github.com/jlandowner/psp-util/pkg/utils"
+	"github.com/jlandowner/psp-util/pkg/printers"
 	"github.com/spf13/cobra"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
 )
 
 func init() {
 	rootCmd.AddCommand(attachCmd)
 	attachCmd.Flags().StringVarP(&a.Group, "group", "g", "", "set Subject's Name and use Kind Group")
 	attachCmd.Flags().StringVarP(&a.User, "user", "u", "", "set Subject's Name and use Kind User")
-	attachCmd.Flags().StringVarP(&a.ServiceAccount, "sa", "s", "", "set Subject's Name and use Kind ServiceAccount")
+	attachCmd.Flags().StringVarP(&a.ServiceAccount, "sa", "s", "", "set Subject's Name (NAME or NAMESPACE/NAME) and use Kind ServiceAccount")
 
 	attachCmd.Flags().StringVar(&a.SubjectKind, "kind", "", "set Subject's Kind")
 	attachCmd.Flags().StringVar(&a.SubjectName, "name", "", "set Subject's Name")
 	attachCmd.Flags().StringVar(&a.SubjectAPIGroup, "api-group", "", "set Subject's APIGroup")
 
 	attachCmd.Flags().StringVarP(&a.SubjectNamespace, "namespace", "n", "", "set Subject's Namespace (only used when kind is ServiceAccount)")
+
+	attachCmd.Flags().StringVar(&a.Scope, "scope", options.ScopeCluster, "scope where the PSP is granted. One of: cluster|namespace")
+	attachCmd.Flags().StringVarP(&a.BindingNamespace, "binding-namespace", "N", "", "namespace of the managed RoleBinding (only used when scope is namespace)")
+	attachCmd.Flags().StringVar(&a.DryRun, "dry-run", options.DryRunNone, "print the changes without persisting. One of: none|client|server")
+	attachCmd.Flags().StringVarP(&a.Output, "output", "o", "", "print the resulting managed manifests instead of applying them. One of: json|yaml")
+	attachCmd.Flags().StringVar(&a.FromFiles, "from-files", "", "load the current managed resources from manifest file or directory instead of cluster (requires --output)")
 }
 
 var (
@@ -46,70 +53,54 @@ var (
 
 	attachCmd = &cobra.Command{
 		Use:               "attach PSP-NAME [ --group | --user | --sa ] SUBJECT-NAME",
-		Short:             "Attach PSP to RBAC Subject (Auto generate managed ClusterRole and ClusterRoleBinding)",
+		Short:             "Attach PSP to RBAC Subject (Auto generate managed ClusterRole and ClusterRoleBinding or RoleBinding)",
 		PersistentPreRunE: a.PreRunE,
 
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := context.Background()
-			k8sclient, err := client.NewClient(&kubeconfigPath, &kubecontext)
-			if err != nil {
-				return fmt.Errorf("Failed to load kubeconfig %v: %v", kubeconfigPath, err.Error())
-			}
-
 			sub, err := a.GenerateSubject(&kubeconfigPath)
 			if err != nil {
 				return fmt.Errorf("Invalid options: %v", err.Error())
 			}
 
-			// Get PodSecurityPolicy
-			psp, err := policy.GetPSP(ctx, k8sclient, a.PSPName)
-			if apierrs.IsNotFound(err) {
-				return fmt.Errorf("PSP %s is not found. See `psp-util tree`", a.PSPName)
-			}
-			if err != nil {
-				return fmt.Errorf("Failed to get PSP: %s", err.Error())
-			}
-			resourceName := utils.GenerateName(psp.Name)
-
-			// Get or Create ClusterRole
-			cr, err := rbac.GetClusterRole(ctx, k8sclient, resourceName)
-			if apierrs.IsNotFound(err) {
-				fmt.Printf("Managed ClusterRole is not found...")
-				cr, err = rbac.CreatePSPRole(ctx, k8sclient, psp)
+			var k8sclient kubernetes.Interface
+			if a.FromFiles == "" {
+				k8sclient, err = newClient(kubeconfigPath, kubecontext)
 				if err != nil {
-					return fmt.Errorf("Failed to create ClusterRole: %s", err.Error())
+					return fmt.Errorf("Failed to load kubeconfig %v: %v", kubeconfigPath, err.Error())
 				}
-				fmt.Printf("Created\n")
-			}
-			if cr == nil || err != nil {
-				return fmt.Errorf("Failed to get ClusterRole: %s", err.Error())
-			}
 
-			// Get or Create ClusterRoleBinding
-			crb, err := rbac.GetClusterRoleBinding(ctx, k8sclient, resourceName)
-			if apierrs.IsNotFound(err) {
-				fmt.Printf("Managed ClusterRoleBinding is not found...")
-				crb, err = rbac.CreatePSPRoleBinding(ctx, k8sclient, psp)
+				// Get PodSecurityPolicy
+				_, err = policy.GetPSP(ctx, k8sclient, a.PSPName)
+				if apierrs.IsNotFound(err) {
+					return fmt.Errorf("PSP %s is not found. See `psp-util tree`", a.PSPName)
+				}
 				if err != nil {
-					return fmt.Errorf("Failed to create ClusterRoleBinding: %s", err.Error())
+					return fmt.Errorf("Failed to get PSP: %s", err.Error())
 				}
-				fmt.Printf("Created\n")
 			}
-			if err != nil {
-				return fmt.Errorf("Failed to get ClusterRoleBinding: %s", err.Error())
+
+			// Create ClusterRole and ClusterRoleBinding or RoleBinding if not found, and add Subject to it
+			newPlan := func(res *managed.Resources) (*managed.Plan, error) {
+				return managed.NewAttachPlan(res, a.PSPName, *sub, a.GetBindingNamespace()), nil
 			}
-			// Add Subject to ClusterRoleBinding
-			if rbac.AttachSubjectToClusterRoleBinding(crb, *sub) {
-				fmt.Printf("psp '%s' has already been attached to %s. See `psp-util tree`\n", psp.Name, sub.String())
-				return nil
+			if a.Output != "" {
+				return printManagedManifests(ctx, k8sclient, a.PSPName, a.FromFiles, newPlan, a.Output)
 			}
 
-			// Update ClusterRoleBinding to attach subjects
-			_, err = rbac.UpdateClusterRoleBinding(ctx, k8sclient, crb)
+			plan, err := applyManagedChanges(ctx, k8sclient, a.PSPName, newPlan, a.DryRun)
 			if err != nil {
-				return fmt.Errorf("Failed to update ClusterRoleBinding: %s", err.Error())
+				return err
+			}
+			if plan.IsEmpty() {
+				if a.IsNamespaceScoped() {
+					fmt.Printf("psp '%s' has already been attached to %s in namespace %s. See `psp-util tree`\n", a.PSPName, sub.String(), a.BindingNamespace)
+				} else {
+					fmt.Printf("psp '%s' has already been attached to %s. See `psp-util tree`\n", a.PSPName, sub.String())
+				}
+				return nil
 			}
-			return nil
+			return printers.PrintChanges(os.Stdout, plan, a.DryRun)
 		},
 	}
 )
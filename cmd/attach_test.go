@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jlandowner/psp-util/pkg/rbac"
+	"github.com/stretchr/testify/assert"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAttach(t *testing.T) {
+	tests := []struct {
+		title     string
+		args      []string
+		expectOut string
+		// expectContains is checked instead of expectOut if not empty
+		expectContains []string
+		expectErr      bool
+		expectCRB      []rbacv1.Subject
+		expectRBs      map[string][]rbacv1.Subject
+	}{
+		{
+			title: "attach group in cluster scope",
+			args:  []string{"attach", "restricted", "--group", "system:authenticated"},
+			expectOut: `clusterrole.rbac.authorization.k8s.io/psp-util.restricted created
+clusterrolebinding.rbac.authorization.k8s.io/psp-util.restricted created
+`,
+			expectCRB: []rbacv1.Subject{testGroup},
+			expectRBs: map[string][]rbacv1.Subject{},
+		},
+		{
+			title: "attach ServiceAccount in namespace scope",
+			args:  []string{"attach", "restricted", "--sa", "team-a/app", "--scope", "namespace", "-N", "team-a"},
+			expectOut: `rolebinding.rbac.authorization.k8s.io/team-a/psp-util.restricted created
+`,
+			expectCRB: []rbacv1.Subject{testGroup},
+			expectRBs: map[string][]rbacv1.Subject{"team-a": {testSA}},
+		},
+		{
+			title: "attach user with client dry-run",
+			args:  []string{"attach", "restricted", "--user", "alice", "--dry-run", "client"},
+			expectOut: `clusterrolebinding.rbac.authorization.k8s.io/psp-util.restricted configured (dry run)
+    + User/alice
+`,
+			expectCRB: []rbacv1.Subject{testGroup},
+			expectRBs: map[string][]rbacv1.Subject{"team-a": {testSA}},
+		},
+		{
+			title:          "print the resulting manifests",
+			args:           []string{"attach", "restricted", "--kind", "User", "--name", "alice", "--api-group", "rbac.authorization.k8s.io", "-o", "json"},
+			expectContains: []string{`"kind": "ClusterRoleBinding"`, `"name": "system:authenticated"`, `"name": "alice"`, `"kind": "RoleBinding"`},
+			expectCRB:      []rbacv1.Subject{testGroup},
+			expectRBs:      map[string][]rbacv1.Subject{"team-a": {testSA}},
+		},
+		{
+			title:     "PSP not found",
+			args:      []string{"attach", "missing", "--user", "alice"},
+			expectErr: true,
+			expectCRB: []rbacv1.Subject{testGroup},
+			expectRBs: map[string][]rbacv1.Subject{"team-a": {testSA}},
+		},
+		{
+			title:     "invalid scope",
+			args:      []string{"attach", "restricted", "--user", "alice", "-N", "team-a"},
+			expectErr: true,
+			expectCRB: []rbacv1.Subject{testGroup},
+			expectRBs: map[string][]rbacv1.Subject{"team-a": {testSA}},
+		},
+	}
+
+	// the steps are applied in order to the same cluster
+	k8sclient := newTestClient()
+	for _, test := range tests {
+		t.Log(test.title)
+		out, _, err := execute(t, k8sclient, test.args...)
+		if test.expectErr {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+			assertOutput(t, test.expectOut, test.expectContains, out)
+		}
+
+		crb, rbs := getSubjects(t, k8sclient)
+		assert.Equal(t, test.expectCRB, crb)
+		assert.Equal(t, test.expectRBs, rbs)
+	}
+}
+
+func TestAttachAfterAdopt(t *testing.T) {
+	roleRef := rbacv1.RoleRef{APIGroup: rbac.APIGroup, Kind: "ClusterRole", Name: "psp:restricted"}
+	k8sclient := newTestClient(
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "psp:restricted"},
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{"policy"}, Resources: []string{"podsecuritypolicies"}, ResourceNames: []string{"restricted"}, Verbs: []string{"use"}},
+			},
+		},
+		&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "psp:restricted"}, RoleRef: roleRef, Subjects: []rbacv1.Subject{testGroup}},
+	)
+
+	tests := []struct {
+		title     string
+		args      []string
+		expectOut string
+		expectCRB []rbacv1.Subject
+		expectRBs map[string][]rbacv1.Subject
+	}{
+		{
+			title: "adopt",
+			args:  []string{"adopt", "restricted"},
+			expectOut: `clusterrole.rbac.authorization.k8s.io/psp:restricted adopted
+clusterrolebinding.rbac.authorization.k8s.io/psp:restricted adopted
+`,
+			expectCRB: []rbacv1.Subject{testGroup},
+			expectRBs: map[string][]rbacv1.Subject{},
+		},
+		{
+			title: "attach to the adopted ClusterRoleBinding",
+			args:  []string{"attach", "restricted", "--user", "alice"},
+			expectOut: `clusterrolebinding.rbac.authorization.k8s.io/psp:restricted configured
+`,
+			expectCRB: []rbacv1.Subject{testGroup, testUser},
+			expectRBs: map[string][]rbacv1.Subject{},
+		},
+		{
+			title: "attach in namespace scope bound to the adopted ClusterRole",
+			args:  []string{"attach", "restricted", "--sa", "team-a/app", "--scope", "namespace", "-N", "team-a"},
+			expectOut: `rolebinding.rbac.authorization.k8s.io/team-a/psp-util.restricted created
+`,
+			expectCRB: []rbacv1.Subject{testGroup, testUser},
+			expectRBs: map[string][]rbacv1.Subject{"team-a": {testSA}},
+		},
+	}
+
+	// the steps are applied in order to the same cluster
+	for _, test := range tests {
+		t.Log(test.title)
+		out, _, err := execute(t, k8sclient, test.args...)
+		assert.NoError(t, err)
+		assertOutput(t, test.expectOut, nil, out)
+
+		crb, rbs := getSubjects(t, k8sclient)
+		assert.Equal(t, test.expectCRB, crb)
+		assert.Equal(t, test.expectRBs, rbs)
+	}
+
+	ctx := context.Background()
+	_, err := rbac.GetClusterRole(ctx, k8sclient, "psp-util.restricted")
+	assert.True(t, apierrs.IsNotFound(err))
+	_, err = rbac.GetClusterRoleBinding(ctx, k8sclient, "psp-util.restricted")
+	assert.True(t, apierrs.IsNotFound(err))
+	rb, err := rbac.GetRoleBinding(ctx, k8sclient, "team-a", "psp-util.restricted")
+	assert.NoError(t, err)
+	assert.Equal(t, roleRef, rb.RoleRef)
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jlandowner/psp-util/cmd/options"
+	"github.com/jlandowner/psp-util/pkg/audit"
+	"github.com/jlandowner/psp-util/pkg/printers"
+	"github.com/jlandowner/psp-util/pkg/relations"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+	auditCmd.Flags().StringVar(&au.FailOn, "fail-on", string(audit.SeverityHigh), "exit with non-zero code if any finding not waived is at or above the severity. One of: info|low|medium|high|critical")
+	auditCmd.Flags().StringVar(&au.Config, "config", "", "audit config file to enable or disable rules, override severities and suppress findings")
+	auditCmd.Flags().BoolVar(&au.NoHeader, "no-headers", false, "output without header")
+	auditCmd.Flags().StringVarP(&au.Output, "output", "o", "", "output format. One of: json|yaml")
+	auditCmd.Flags().StringVar(&au.FromFiles, "from-files", "", "load PSPs and RBACs from manifest file or directory instead of cluster. \"-\" reads from stdin")
+	addClusterFlags(auditCmd, &au.ClusterOptions)
+}
+
+var (
+	au = &options.AuditOptions{}
+
+	auditCmd = &cobra.Command{
+		Use:               "audit",
+		Short:             "Score PSPs on dangerous settings weighted by who can use them and exit with non-zero code above the threshold",
+		PersistentPreRunE: au.PreRunE,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			config := &audit.Config{}
+			if au.Config != "" {
+				var err error
+				config, err = audit.LoadConfig(au.Config)
+				if err != nil {
+					return fmt.Errorf("Failed to load %s: %v", au.Config, err)
+				}
+			}
+
+			if au.IsMultiCluster() {
+				return auditClusters(ctx, cmd, config)
+			}
+
+			psps, err := getRelationalPSPs(ctx, au.FromFiles)
+			if err != nil {
+				return err
+			}
+
+			report := audit.AuditWithConfig(psps, config, time.Now())
+			if printers.IsStructuredOutput(au.Output) {
+				if err := printers.PrintObject(os.Stdout, printers.NewAuditReport(report), au.Output); err != nil {
+					return err
+				}
+			} else {
+				printers.PrintAuditReport(os.Stdout, report, au.NoHeader)
+			}
+
+			if n := report.CountAtLeast(au.FailOnSeverity); n > 0 {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("Found %d findings at or above severity %s", n, au.FailOnSeverity)
+			}
+			return nil
+		},
+	}
+)
+
+// auditClusters audits PSPs in every cluster with the same config and the cluster column.
+// The findings of every cluster are counted against the threshold
+func auditClusters(ctx context.Context, cmd *cobra.Command, config *audit.Config) error {
+	clusters, err := getClustersObjects(ctx, &au.ClusterOptions, false)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	results := make([]printers.ClusterResult, len(clusters))
+	reports := make([]printers.ClusterAuditReport, 0, len(clusters))
+	count := 0
+	for i, c := range clusters {
+		if c.Err != nil {
+			results[i] = printers.NewClusterError(c.Cluster, c.Err)
+			continue
+		}
+		report := audit.AuditWithConfig(relations.GetRelationalPSPsFromManifests(c.Objects), config, now)
+		results[i] = printers.ClusterResult{Cluster: c.Cluster, Result: printers.NewAuditReport(report)}
+		reports = append(reports, printers.ClusterAuditReport{Cluster: c.Cluster, Report: report})
+		count += report.CountAtLeast(au.FailOnSeverity)
+	}
+
+	if printers.IsStructuredOutput(au.Output) {
+		if err := printers.PrintObject(os.Stdout, printers.NewClusterResultList(results), au.Output); err != nil {
+			return err
+		}
+	} else {
+		printers.PrintClusterAuditReports(os.Stdout, reports, au.NoHeader)
+	}
+
+	if err := clusterErrors(cmd, results); err != nil {
+		return err
+	}
+	if count > 0 {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("Found %d findings at or above severity %s", count, au.FailOnSeverity)
+	}
+	return nil
+}
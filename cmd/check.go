@@ -0,0 +1,69 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jlandowner/psp-util/cmd/options"
+	"github.com/jlandowner/psp-util/pkg/printers"
+	"github.com/jlandowner/psp-util/pkg/relations"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+	checkCmd.Flags().BoolVar(&ck.NoHeader, "no-headers", false, "output without header")
+	checkCmd.Flags().StringVarP(&ck.Output, "output", "o", "", "output format. One of: json|yaml")
+	checkCmd.Flags().StringVar(&ck.FromFiles, "from-files", "", "load PSPs and RBACs from manifest file or directory instead of cluster. \"-\" reads from stdin")
+}
+
+var (
+	ck = &options.CheckOptions{}
+
+	checkCmd = &cobra.Command{
+		Use:               "check",
+		Short:             "Check RBACs referring to missing PSPs or roles and exit with non-zero code if found",
+		PersistentPreRunE: ck.PreRunE,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			objs, err := getObjects(ctx, ck.FromFiles)
+			if err != nil {
+				return err
+			}
+
+			dangling := relations.GetDanglingReferences(objs)
+			if printers.IsStructuredOutput(ck.Output) {
+				if err := printers.PrintObject(os.Stdout, printers.NewDanglingReferenceList(dangling), ck.Output); err != nil {
+					return err
+				}
+			} else if !dangling.IsEmpty() {
+				printers.PrintDanglingReferences(os.Stdout, dangling, ck.NoHeader)
+			}
+
+			if !dangling.IsEmpty() {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("Found %d dangling references", dangling.Count())
+			}
+			if !printers.IsStructuredOutput(ck.Output) {
+				fmt.Println("No dangling references found.")
+			}
+			return nil
+		},
+	}
+)
@@ -18,46 +18,57 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"os"
 
 	"github.com/jlandowner/psp-util/cmd/options"
-	"github.com/jlandowner/psp-util/pkg/client"
-	"github.com/jlandowner/psp-util/pkg/rbac"
-	"github.com/jlandowner/psp-util/pkg/utils"
+	"github.com/jlandowner/psp-util/pkg/managed"
+	"github.com/jlandowner/psp-util/pkg/printers"
 	"github.com/spf13/cobra"
-	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
 )
 
 func init() {
 	rootCmd.AddCommand(cleanCmd)
+	cleanCmd.Flags().StringVar(&c.DryRun, "dry-run", options.DryRunNone, "print the objects to be deleted without persisting. One of: none|client|server")
+	cleanCmd.Flags().StringVarP(&c.Output, "output", "o", "", "print the manifests of the resources to be deleted instead of deleting them. One of: json|yaml")
+	cleanCmd.Flags().StringVar(&c.FromFiles, "from-files", "", "load the current managed resources from manifest file or directory instead of cluster (requires --output)")
 }
 
 var (
 	c        = &options.CleanOptions{}
 	cleanCmd = &cobra.Command{
 		Use:               "clean PSP-NAME",
-		Short:             "Clean managed ClusterRole and ClusterRoleBinding",
+		Short:             "Clean managed ClusterRole, ClusterRoleBinding and RoleBindings",
 		PersistentPreRunE: c.PreRunE,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := context.Background()
-			k8sclient, err := client.NewClient(&kubeconfigPath, &kubecontext)
-			if err != nil {
-				return fmt.Errorf("Failed to load kubeconfig %v: %v", kubeconfigPath, err.Error())
+			var k8sclient kubernetes.Interface
+			if c.FromFiles == "" {
+				var err error
+				k8sclient, err = newClient(kubeconfigPath, kubecontext)
+				if err != nil {
+					return fmt.Errorf("Failed to load kubeconfig %v: %v", kubeconfigPath, err.Error())
+				}
 			}
 
-			name := utils.GenerateName(c.PSPName)
-			err = rbac.DeleteClusterRoleBindings(ctx, k8sclient, name)
-			if apierrs.IsNotFound(err) {
-				return fmt.Errorf("Managed ClusterRole is not found. See `psp-util tree`")
-			}
+			// Managed RoleBindings are created by attach --scope namespace
+			res, err := getManagedResources(ctx, k8sclient, c.PSPName, c.FromFiles)
 			if err != nil {
 				return err
 			}
+			if res.IsEmpty() {
+				return fmt.Errorf("Managed ClusterRole is not found. See `psp-util tree`")
+			}
 
-			err = rbac.DeleteClusterRole(ctx, k8sclient, name)
-			if err != nil {
+			plan := managed.NewCleanPlan(res, c.PSPName)
+			if c.Output != "" {
+				// List of the resources to delete. e.g. kubectl delete -f
+				return printers.PrintObjects(os.Stdout, plan.Deletions(), c.Output)
+			}
+			if err := plan.Apply(ctx, k8sclient, c.DryRun); err != nil {
 				return err
 			}
-			return nil
+			return printers.PrintChanges(os.Stdout, plan, c.DryRun)
 		},
 	}
 )
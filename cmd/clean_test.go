@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func TestClean(t *testing.T) {
+	tests := []struct {
+		title          string
+		args           []string
+		expectOut      string
+		expectContains []string
+		expectErr      bool
+		expectCRB      []rbacv1.Subject
+		expectRBs      map[string][]rbacv1.Subject
+	}{
+		{
+			title: "clean with client dry-run",
+			args:  []string{"clean", "restricted", "--dry-run", "client"},
+			expectOut: `clusterrolebinding.rbac.authorization.k8s.io/psp-util.restricted deleted (dry run)
+    - Group/system:authenticated
+rolebinding.rbac.authorization.k8s.io/team-a/psp-util.restricted deleted (dry run)
+    - ServiceAccount/team-a/app
+clusterrole.rbac.authorization.k8s.io/psp-util.restricted deleted (dry run)
+`,
+			expectCRB: []rbacv1.Subject{testGroup},
+			expectRBs: map[string][]rbacv1.Subject{"team-a": {testSA}},
+		},
+		{
+			title:          "print the manifests to be deleted",
+			args:           []string{"clean", "restricted", "-o", "yaml"},
+			expectContains: []string{"kind: ClusterRoleBinding", "kind: RoleBinding", "namespace: team-a", "kind: ClusterRole\n"},
+			expectCRB:      []rbacv1.Subject{testGroup},
+			expectRBs:      map[string][]rbacv1.Subject{"team-a": {testSA}},
+		},
+		{
+			title: "clean",
+			args:  []string{"clean", "restricted"},
+			expectOut: `clusterrolebinding.rbac.authorization.k8s.io/psp-util.restricted deleted
+rolebinding.rbac.authorization.k8s.io/team-a/psp-util.restricted deleted
+clusterrole.rbac.authorization.k8s.io/psp-util.restricted deleted
+`,
+			expectCRB: nil,
+			expectRBs: map[string][]rbacv1.Subject{},
+		},
+		{
+			title:     "already cleaned",
+			args:      []string{"clean", "restricted"},
+			expectErr: true,
+			expectCRB: nil,
+			expectRBs: map[string][]rbacv1.Subject{},
+		},
+		{
+			title:     "output with dry-run",
+			args:      []string{"clean", "restricted", "-o", "yaml", "--dry-run", "server"},
+			expectErr: true,
+			expectCRB: nil,
+			expectRBs: map[string][]rbacv1.Subject{},
+		},
+	}
+
+	// the steps are applied in order to the same cluster
+	k8sclient := newTestClient(newManagedObjects()...)
+	for _, test := range tests {
+		t.Log(test.title)
+		out, _, err := execute(t, k8sclient, test.args...)
+		if test.expectErr {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+			assertOutput(t, test.expectOut, test.expectContains, out)
+		}
+
+		crb, rbs := getSubjects(t, k8sclient)
+		assert.Equal(t, test.expectCRB, crb)
+		assert.Equal(t, test.expectRBs, rbs)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/jlandowner/psp-util/cmd/options"
+	"github.com/jlandowner/psp-util/pkg/client"
+	"github.com/jlandowner/psp-util/pkg/manifests"
+	"github.com/jlandowner/psp-util/pkg/printers"
+	"github.com/jlandowner/psp-util/pkg/relations"
+	"github.com/spf13/cobra"
+)
+
+// addClusterFlags adds the flags to run the read-only command against multiple clusters
+func addClusterFlags(cmd *cobra.Command, o *options.ClusterOptions) {
+	cmd.Flags().BoolVar(&o.AllContexts, "all-contexts", false, "run against the clusters of all contexts in kubeconfig concurrently")
+	cmd.Flags().StringSliceVar(&o.Contexts, "contexts", nil, "run against the clusters of the comma separated contexts concurrently")
+}
+
+// clusterObjects is PSPs and RBAC resources in a cluster, or the error failed to get them
+type clusterObjects struct {
+	Cluster  string
+	Objects  *manifests.Objects
+	Expander *relations.SubjectExpander
+	Err      error
+}
+
+// getContexts returns the contexts selected by the options
+func getContexts(o *options.ClusterOptions) ([]string, error) {
+	if !o.AllContexts {
+		return o.Contexts, nil
+	}
+	kubeconfig := kubeconfigPath
+	contexts, err := client.ListContexts(&kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load kubeconfig %v: %v", kubeconfig, err.Error())
+	}
+	if len(contexts) == 0 {
+		return nil, fmt.Errorf("No contexts found in kubeconfig %v", kubeconfig)
+	}
+	return contexts, nil
+}
+
+// getClustersObjects gets PSPs and RBAC resources in the clusters of the contexts concurrently,
+// and the ServiceAccounts if expand is true. The clusters failed are returned with the errors rather than aborting the others
+func getClustersObjects(ctx context.Context, o *options.ClusterOptions, expand bool) ([]clusterObjects, error) {
+	contexts, err := getContexts(o)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]clusterObjects, len(contexts))
+	var wg sync.WaitGroup
+	for i, kubectx := range contexts {
+		wg.Add(1)
+		go func(i int, kubectx string) {
+			defer wg.Done()
+			res := clusterObjects{Cluster: kubectx}
+			res.Objects, res.Err = getClusterObjects(ctx, kubeconfigPath, kubectx)
+			if res.Err == nil && expand {
+				res.Expander, res.Err = getClusterSubjectExpander(ctx, kubeconfigPath, kubectx)
+			}
+			results[i] = res
+		}(i, kubectx)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// clusterErrors prints the errors of the clusters failed and returns an error if any cluster failed
+func clusterErrors(cmd *cobra.Command, results []printers.ClusterResult) error {
+	failed := printers.PrintClusterErrors(cmd.ErrOrStderr(), results)
+	if failed > 0 {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("Failed in %d of %d clusters", failed, len(results))
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/jlandowner/psp-util/pkg/client/fake"
+	"github.com/jlandowner/psp-util/pkg/managed"
+	"github.com/jlandowner/psp-util/pkg/rbac"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	policyv1 "k8s.io/api/policy/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	testGroup = rbacv1.Subject{Kind: "Group", APIGroup: rbac.APIGroup, Name: "system:authenticated"}
+	testUser  = rbacv1.Subject{Kind: "User", APIGroup: rbac.APIGroup, Name: "alice"}
+	testSA    = rbacv1.Subject{Kind: "ServiceAccount", Namespace: "team-a", Name: "app"}
+)
+
+// newTestClient returns a fake clientset with the PSP restricted and the given objects
+func newTestClient(objects ...runtime.Object) *fake.Clientset {
+	objects = append(objects, &policyv1.PodSecurityPolicy{ObjectMeta: metav1.ObjectMeta{Name: "restricted"}})
+	return fake.NewClientset(objects...)
+}
+
+// newManagedObjects returns the managed ClusterRole, ClusterRoleBinding with the group and RoleBinding in team-a with the ServiceAccount
+func newManagedObjects() []runtime.Object {
+	crb := rbac.NewPSPRoleBinding("restricted")
+	crb.Subjects = []rbacv1.Subject{testGroup}
+	rb := rbac.NewPSPNamespacedRoleBinding("restricted", "team-a")
+	rb.Subjects = []rbacv1.Subject{testSA}
+	return []runtime.Object{rbac.NewPSPRole("restricted"), crb, rb}
+}
+
+// getSubjects returns the subjects of the managed ClusterRoleBinding, nil if not found, and the managed RoleBindings by namespace
+func getSubjects(t *testing.T, k8sclient kubernetes.Interface) (crb []rbacv1.Subject, rbs map[string][]rbacv1.Subject) {
+	t.Helper()
+	res, err := managed.GetResources(context.Background(), k8sclient, "restricted")
+	assert.NoError(t, err)
+	if res.ClusterRoleBinding != nil {
+		crb = res.ClusterRoleBinding.Subjects
+	}
+	rbs = make(map[string][]rbacv1.Subject)
+	for ns, rb := range res.RoleBindings {
+		rbs[ns] = rb.Subjects
+	}
+	return crb, rbs
+}
+
+// assertOutput asserts the output without colors equals to expect, or contains all of expectContains if not empty
+func assertOutput(t *testing.T, expect string, expectContains []string, out string) {
+	t.Helper()
+	out = stripColors(out)
+	if len(expectContains) == 0 {
+		assert.Equal(t, expect, out)
+		return
+	}
+	for _, s := range expectContains {
+		assert.Contains(t, out, s)
+	}
+}
+
+var colorPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// stripColors removes the color escape sequences of the printers
+func stripColors(s string) string {
+	return colorPattern.ReplaceAllString(s, "")
+}
+
+// execute runs psp-util with the args against the clientset and returns the output to stdout and stderr
+func execute(t *testing.T, k8sclient kubernetes.Interface, args ...string) (stdout, stderr string, err error) {
+	t.Helper()
+	defer func(f func(kubeconfig, kubectx string) (kubernetes.Interface, error)) { newClient = f }(newClient)
+	newClient = func(kubeconfig, kubectx string) (kubernetes.Interface, error) {
+		return k8sclient, nil
+	}
+
+	// the options are bound to the flags of the global commands, so reset the ones given in the previous runs
+	resetFlags(rootCmd)
+
+	stdout, stderr = captureOutput(t, func() {
+		rootCmd.SetArgs(args)
+		err = rootCmd.Execute()
+	})
+	return stdout, stderr, err
+}
+
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if v, ok := f.Value.(pflag.SliceValue); ok {
+			v.Replace([]string{})
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	for _, c := range cmd.Commands() {
+		resetFlags(c)
+	}
+}
+
+// captureOutput returns the output to os.Stdout and os.Stderr while running f, as the commands print to them directly
+func captureOutput(t *testing.T, f func()) (stdout, stderr string) {
+	t.Helper()
+	outR, outW, err := os.Pipe()
+	assert.NoError(t, err)
+	errR, errW, err := os.Pipe()
+	assert.NoError(t, err)
+
+	origOut, origErr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = outW, errW
+	rootCmd.SetOut(errW)
+	rootCmd.SetErr(errW)
+	defer func() {
+		os.Stdout, os.Stderr = origOut, origErr
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+	}()
+
+	outC, errC := readAll(outR), readAll(errR)
+	f()
+	outW.Close()
+	errW.Close()
+	return <-outC, <-errC
+}
+
+func readAll(r io.Reader) <-chan string {
+	c := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		c <- buf.String()
+	}()
+	return c
+}
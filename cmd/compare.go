@@ -0,0 +1,91 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jlandowner/psp-util/cmd/options"
+	"github.com/jlandowner/psp-util/pkg/diff"
+	"github.com/jlandowner/psp-util/pkg/manifests"
+	"github.com/jlandowner/psp-util/pkg/printers"
+	"github.com/jlandowner/psp-util/pkg/relations"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(compareCmd)
+	// --context and --kubeconfig override the global flags to be given twice
+	compareCmd.Flags().StringArrayVar(&cp.Contexts, "context", nil, "kube-context of the cluster compared. Given twice, or once with two --kubeconfig")
+	compareCmd.Flags().StringArrayVar(&cp.Kubeconfigs, "kubeconfig", nil, "kubeconfig file path of the cluster compared. Given twice, or once with two --context (default: $HOME/.kube/config)")
+	compareCmd.Flags().StringArrayVar(&cp.FromFiles, "from-files", nil, "load PSPs and RBACs from manifest file or directory instead of cluster. Given twice")
+	compareCmd.Flags().BoolVar(&cp.NoHeader, "no-headers", false, "output without header")
+	compareCmd.Flags().StringVarP(&cp.Output, "output", "o", "", "output format. One of: json|yaml")
+}
+
+var (
+	cp = &options.CompareOptions{}
+
+	compareCmd = &cobra.Command{
+		Use:   "compare --context CONTEXT-A --context CONTEXT-B",
+		Short: "Compare PSPs and the Subjects granted them between two clusters and exit with non-zero code if different",
+		Long: `Compare PSPs and the Subjects granted them between two clusters and exit with non-zero code if different.
+
+The clusters are given by --context twice, --kubeconfig twice, or both twice in the same order.
+A single --context or --kubeconfig is shared by the two clusters.
+--from-files given twice compares manifests instead of clusters.`,
+		PersistentPreRunE: cp.PreRunE,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			var psps [2][]relations.RelationalPodSecurityPolicy
+			for i, src := range cp.Sources {
+				objs, err := getSourceObjects(ctx, src)
+				if err != nil {
+					return fmt.Errorf("%s: %v", src.Name, err)
+				}
+				psps[i] = relations.GetRelationalPSPsFromManifests(objs)
+			}
+
+			c := diff.CompareClusters(cp.Sources[0].Name, cp.Sources[1].Name, psps[0], psps[1])
+			if printers.IsStructuredOutput(cp.Output) {
+				if err := printers.PrintObject(os.Stdout, printers.NewClusterComparison(c), cp.Output); err != nil {
+					return err
+				}
+			} else if !c.IsEmpty() {
+				printers.PrintClusterComparison(os.Stdout, c, cp.NoHeader)
+			}
+
+			if !c.IsEmpty() {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("Found differences in %d PSPs between %s and %s", c.Count(), c.A, c.B)
+			}
+			if !printers.IsStructuredOutput(cp.Output) {
+				fmt.Println("No differences found.")
+			}
+			return nil
+		},
+	}
+)
+
+// getSourceObjects returns PSPs and RBAC resources from the manifest files if given, otherwise from the cluster
+func getSourceObjects(ctx context.Context, src options.CompareSource) (*manifests.Objects, error) {
+	if src.FromFiles != "" {
+		return getObjects(ctx, src.FromFiles)
+	}
+	return getClusterObjects(ctx, src.Kubeconfig, src.Context)
+}
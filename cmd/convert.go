@@ -0,0 +1,70 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jlandowner/psp-util/cmd/options"
+	"github.com/jlandowner/psp-util/pkg/convert"
+	"github.com/jlandowner/psp-util/pkg/printers"
+	"github.com/jlandowner/psp-util/pkg/relations"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(convertCmd)
+	convertCmd.Flags().StringVar(&cv.To, "to", "", "policy engine to convert to. One of: kyverno|gatekeeper")
+	convertCmd.Flags().StringVarP(&cv.Output, "output", "o", "", "output format. One of: json|yaml (default: yaml)")
+	convertCmd.Flags().StringVar(&cv.FromFiles, "from-files", "", "load PSPs and RBACs from manifest file or directory instead of cluster. \"-\" reads from stdin")
+}
+
+var (
+	cv = &options.ConvertOptions{}
+
+	convertCmd = &cobra.Command{
+		Use:               "convert --to kyverno|gatekeeper PSP-NAME",
+		Short:             "Convert PSP into Kyverno or OPA Gatekeeper policies scoped by the Subjects using it",
+		PersistentPreRunE: cv.PreRunE,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			psps, err := getRelationalPSPs(ctx, cv.FromFiles)
+			if err != nil {
+				return err
+			}
+
+			psp, ok := relations.FindRelationalPSP(psps, cv.PSPName)
+			if !ok {
+				return fmt.Errorf("PSP %s is not found. See `psp-util tree`", cv.PSPName)
+			}
+
+			res, err := convert.Convert(*psp, cv.To)
+			if err != nil {
+				return err
+			}
+
+			if res.Scope.IsEmpty() {
+				fmt.Fprintf(os.Stderr, "Warning: no ServiceAccount or Namespace is permitted to use PSP %s, so the policy matches no Pod\n", psp.Name)
+			}
+			for _, u := range res.Unsupported {
+				fmt.Fprintf(os.Stderr, "Warning: not converted %s\n", u)
+			}
+			return printers.PrintObjects(os.Stdout, res.Objects, cv.Output)
+		},
+	}
+)
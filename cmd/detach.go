@@ -18,27 +18,34 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"os"
 
 	"github.com/jlandowner/psp-util/cmd/options"
-	"github.com/jlandowner/psp-util/pkg/client"
+	"github.com/jlandowner/psp-util/pkg/managed"
 	"github.com/jlandowner/psp-util/pkg/policy"
-	"github.com/jlandowner/psp-util/pkg/rbac"
-	"github.com/jlandowner/psp-util/pkg/utils"
+	"github.com/jlandowner/psp-util/pkg/printers"
 	"github.com/spf13/cobra"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
 )
 
 func init() {
 	rootCmd.AddCommand(detachCmd)
 	detachCmd.Flags().StringVarP(&d.Group, "group", "g", "", "set Subject's Name and use Kind Group")
 	detachCmd.Flags().StringVarP(&d.User, "user", "u", "", "set Subject's Name and use Kind User")
-	detachCmd.Flags().StringVarP(&d.ServiceAccount, "sa", "s", "", "set Subject's Name and use Kind ServiceAccount")
+	detachCmd.Flags().StringVarP(&d.ServiceAccount, "sa", "s", "", "set Subject's Name (NAME or NAMESPACE/NAME) and use Kind ServiceAccount")
 
 	detachCmd.Flags().StringVar(&d.SubjectKind, "kind", "", "set Subject's Kind")
 	detachCmd.Flags().StringVar(&d.SubjectName, "name", "", "set Subject's Name")
 	detachCmd.Flags().StringVar(&d.SubjectAPIGroup, "api-group", "", "set Subject's APIGroup")
 
 	detachCmd.Flags().StringVarP(&d.SubjectNamespace, "namespace", "n", "", "only used when kind is namedspaced resource(e.g. ServiceAccount)")
+
+	detachCmd.Flags().StringVar(&d.Scope, "scope", options.ScopeCluster, "scope where the PSP is revoked. One of: cluster|namespace")
+	detachCmd.Flags().StringVarP(&d.BindingNamespace, "binding-namespace", "N", "", "namespace of the managed RoleBinding (only used when scope is namespace)")
+	detachCmd.Flags().StringVar(&d.DryRun, "dry-run", options.DryRunNone, "print the changes without persisting. One of: none|client|server")
+	detachCmd.Flags().StringVarP(&d.Output, "output", "o", "", "print the resulting managed manifests instead of applying them. One of: json|yaml")
+	detachCmd.Flags().StringVar(&d.FromFiles, "from-files", "", "load the current managed resources from manifest file or directory instead of cluster (requires --output)")
 }
 
 var (
@@ -51,56 +58,52 @@ var (
 
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := context.Background()
-			k8sclient, err := client.NewClient(&kubeconfigPath, &kubecontext)
-			if err != nil {
-				return fmt.Errorf("Failed to load kubeconfig %v: %v", kubeconfigPath, err.Error())
-			}
-
 			sub, err := d.GenerateSubject(&kubeconfigPath)
 			if err != nil {
 				return fmt.Errorf("Invalid options: %v", err.Error())
 			}
 
-			// Get PodSecurityPolicy
-			psp, err := policy.GetPSP(ctx, k8sclient, d.PSPName)
-			if apierrs.IsNotFound(err) {
-				return fmt.Errorf("PSP %s is not found. See `psp-util tree`", a.PSPName)
-			}
-			if err != nil {
-				return fmt.Errorf("Failed to get PSP: %s", err.Error())
+			var k8sclient kubernetes.Interface
+			if d.FromFiles == "" {
+				k8sclient, err = newClient(kubeconfigPath, kubecontext)
+				if err != nil {
+					return fmt.Errorf("Failed to load kubeconfig %v: %v", kubeconfigPath, err.Error())
+				}
+
+				// Get PodSecurityPolicy
+				_, err = policy.GetPSP(ctx, k8sclient, d.PSPName)
+				if apierrs.IsNotFound(err) {
+					return fmt.Errorf("PSP %s is not found. See `psp-util tree`", a.PSPName)
+				}
+				if err != nil {
+					return fmt.Errorf("Failed to get PSP: %s", err.Error())
+				}
 			}
-			resourceName := utils.GenerateName(psp.Name)
 
-			// Get ClusterRole
-			cr, err := rbac.GetClusterRole(ctx, k8sclient, resourceName)
-			if apierrs.IsNotFound(err) {
-				return fmt.Errorf("Managed ClusterRole is not found. Please remove subjects manually from the ClusterRoleBindings. See the resources by `psp-util tree`")
+			// Remove Subject from ClusterRoleBinding or RoleBinding
+			newPlan := func(res *managed.Resources) (*managed.Plan, error) {
+				if res.ClusterRole == nil {
+					return nil, fmt.Errorf("Managed ClusterRole is not found. Please remove subjects manually from the ClusterRoleBindings. See the resources by `psp-util tree`")
+				}
+				return managed.NewDetachPlan(res, d.PSPName, *sub, d.GetBindingNamespace()), nil
 			}
-			if cr == nil || err != nil {
-				fmt.Printf("Failed to get ClusterRole: %s\n", err.Error())
-				return fmt.Errorf("psp '%s' has NOT been attached to %s. See `psp-util tree`", psp.Name, sub.String())
+			if d.Output != "" {
+				return printManagedManifests(ctx, k8sclient, d.PSPName, d.FromFiles, newPlan, d.Output)
 			}
 
-			// Get ClusterRoleBinding
-			crb, err := rbac.GetClusterRoleBinding(ctx, k8sclient, resourceName)
+			plan, err := applyManagedChanges(ctx, k8sclient, d.PSPName, newPlan, d.DryRun)
 			if err != nil {
-				fmt.Printf("Failed to get ClusterRoleBinding: %s\n", err.Error())
-				return fmt.Errorf("psp '%s' has NOT been attached to %s. See `psp-util tree`", psp.Name, sub.String())
+				return err
 			}
-
-			// Add Subject to ClusterRoleBinding
-			hasGivenSubject := rbac.DetachSubjectToClusterRoleBinding(crb, *sub)
-			if !hasGivenSubject {
-				fmt.Printf("psp '%s' has NOT been attached to %s. See `psp-util tree`\n", psp.Name, sub.String())
+			if plan.IsEmpty() {
+				if d.IsNamespaceScoped() {
+					fmt.Printf("psp '%s' has NOT been attached to %s in namespace %s. See `psp-util tree`\n", d.PSPName, sub.String(), d.BindingNamespace)
+				} else {
+					fmt.Printf("psp '%s' has NOT been attached to %s. See `psp-util tree`\n", d.PSPName, sub.String())
+				}
 				return nil
 			}
-
-			// Update ClusterRoleBinding to attach subjects
-			_, err = rbac.UpdateClusterRoleBinding(ctx, k8sclient, crb)
-			if err != nil {
-				return fmt.Errorf("Failed to update ClusterRoleBinding: %s", err.Error())
-			}
-			return nil
+			return printers.PrintChanges(os.Stdout, plan, d.DryRun)
 		},
 	}
 )
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func TestDetach(t *testing.T) {
+	tests := []struct {
+		title          string
+		args           []string
+		expectOut      string
+		expectContains []string
+		expectErr      bool
+		expectCRB      []rbacv1.Subject
+		expectRBs      map[string][]rbacv1.Subject
+	}{
+		{
+			title: "detach group with server dry-run",
+			args:  []string{"detach", "restricted", "--group", "system:authenticated", "--dry-run", "server"},
+			expectOut: `clusterrolebinding.rbac.authorization.k8s.io/psp-util.restricted configured (server dry run)
+    - Group/system:authenticated
+`,
+			// the fake clientset does not support dry-run
+			expectCRB: []rbacv1.Subject{},
+			expectRBs: map[string][]rbacv1.Subject{"team-a": {testSA}},
+		},
+		{
+			title:          "print the resulting manifests",
+			args:           []string{"detach", "restricted", "--sa", "team-a/app", "--scope", "namespace", "-N", "team-a", "-o", "yaml"},
+			expectContains: []string{"kind: ClusterRoleBinding", "kind: ClusterRole\n"},
+			expectCRB:      []rbacv1.Subject{},
+			expectRBs:      map[string][]rbacv1.Subject{"team-a": {testSA}},
+		},
+		{
+			title: "detach the last ServiceAccount in namespace scope",
+			args:  []string{"detach", "restricted", "--sa", "app", "-n", "team-a", "--scope", "namespace", "-N", "team-a"},
+			expectOut: `rolebinding.rbac.authorization.k8s.io/team-a/psp-util.restricted deleted
+`,
+			expectCRB: []rbacv1.Subject{},
+			expectRBs: map[string][]rbacv1.Subject{},
+		},
+		{
+			title: "subject not attached",
+			args:  []string{"detach", "restricted", "--user", "alice"},
+			expectOut: `psp 'restricted' has NOT been attached to &Subject{Kind:User,APIGroup:rbac.authorization.k8s.io,Name:alice,Namespace:,}. See ` + "`psp-util tree`" + `
+`,
+			expectCRB: []rbacv1.Subject{},
+			expectRBs: map[string][]rbacv1.Subject{},
+		},
+		{
+			title:     "multiple kinds",
+			args:      []string{"detach", "restricted", "--user", "alice", "--group", "system:authenticated"},
+			expectErr: true,
+			expectCRB: []rbacv1.Subject{},
+			expectRBs: map[string][]rbacv1.Subject{},
+		},
+	}
+
+	// the steps are applied in order to the same cluster
+	k8sclient := newTestClient(newManagedObjects()...)
+	for _, test := range tests {
+		t.Log(test.title)
+		out, _, err := execute(t, k8sclient, test.args...)
+		if test.expectErr {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+			assertOutput(t, test.expectOut, test.expectContains, out)
+		}
+
+		crb, rbs := getSubjects(t, k8sclient)
+		assert.Equal(t, test.expectCRB, crb)
+		assert.Equal(t, test.expectRBs, rbs)
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jlandowner/psp-util/cmd/options"
+	"github.com/jlandowner/psp-util/pkg/diff"
+	"github.com/jlandowner/psp-util/pkg/printers"
+	"github.com/jlandowner/psp-util/pkg/relations"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().BoolVar(&df.NoHeader, "no-headers", false, "output without header")
+	diffCmd.Flags().StringVarP(&df.Output, "output", "o", "", "output format. One of: json|yaml")
+	diffCmd.Flags().StringVar(&df.FromFiles, "from-files", "", "load PSPs and RBACs from manifest file or directory instead of cluster. \"-\" reads from stdin")
+}
+
+var (
+	df = &options.DiffOptions{}
+
+	diffCmd = &cobra.Command{
+		Use:               "diff PSP-NAME-A PSP-NAME-B",
+		Short:             "Compare two PSPs semantically, tell which is more permissive and which Subjects each is granted to",
+		PersistentPreRunE: df.PreRunE,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			psps, err := getRelationalPSPs(ctx, df.FromFiles)
+			if err != nil {
+				return err
+			}
+
+			pspA, ok := relations.FindRelationalPSP(psps, df.PSPNameA)
+			if !ok {
+				return fmt.Errorf("PSP %s is not found. See `psp-util tree`", df.PSPNameA)
+			}
+			pspB, ok := relations.FindRelationalPSP(psps, df.PSPNameB)
+			if !ok {
+				return fmt.Errorf("PSP %s is not found. See `psp-util tree`", df.PSPNameB)
+			}
+
+			c := diff.ComparePSPs(*pspA, *pspB)
+			if printers.IsStructuredOutput(df.Output) {
+				return printers.PrintObject(os.Stdout, printers.NewPSPComparison(c), df.Output)
+			}
+			printers.PrintComparison(os.Stdout, c, df.NoHeader)
+			return nil
+		},
+	}
+)
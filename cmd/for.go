@@ -0,0 +1,71 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jlandowner/psp-util/cmd/options"
+	"github.com/jlandowner/psp-util/pkg/printers"
+	"github.com/jlandowner/psp-util/pkg/relations"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(forCmd)
+	forCmd.Flags().StringVarP(&f.Group, "group", "g", "", "set Subject's Name and use Kind Group")
+	forCmd.Flags().StringVarP(&f.User, "user", "u", "", "set Subject's Name and use Kind User")
+	forCmd.Flags().StringVarP(&f.ServiceAccount, "sa", "s", "", "set Subject's Name (NAME or NAMESPACE/NAME) and use Kind ServiceAccount")
+
+	forCmd.Flags().StringVar(&f.SubjectKind, "kind", "", "set Subject's Kind")
+	forCmd.Flags().StringVar(&f.SubjectName, "name", "", "set Subject's Name")
+	forCmd.Flags().StringVar(&f.SubjectAPIGroup, "api-group", "", "set Subject's APIGroup")
+
+	forCmd.Flags().StringVarP(&f.SubjectNamespace, "namespace", "n", "", "set Subject's Namespace (only used when kind is ServiceAccount)")
+
+	forCmd.Flags().BoolVar(&f.NoHeader, "no-headers", false, "output without header")
+	forCmd.Flags().StringVarP(&f.Output, "output", "o", "", "output format. One of: json|yaml")
+	forCmd.Flags().StringVar(&f.FromFiles, "from-files", "", "load PSPs and RBACs from manifest file or directory instead of cluster. \"-\" reads from stdin")
+}
+
+var (
+	f = &options.ForOptions{}
+
+	forCmd = &cobra.Command{
+		Use:               "for [ --group | --user | --sa ] SUBJECT-NAME",
+		Short:             "List PSPs the Subject is permitted to use including via implicit groups",
+		PersistentPreRunE: f.PreRunE,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			sub, err := f.GenerateSubject(&kubeconfigPath)
+			if err != nil {
+				return fmt.Errorf("Invalid options: %v", err.Error())
+			}
+
+			psps, err := getRelationalPSPs(ctx, f.FromFiles)
+			if err != nil {
+				return err
+			}
+
+			if printers.IsStructuredOutput(f.Output) {
+				return printers.PrintObject(os.Stdout, printers.NewPSPGrantList(psps, *sub), f.Output)
+			}
+			return printers.PrintPSPGrants(os.Stdout, relations.GetPSPGrantsForSubject(psps, *sub), f.NoHeader)
+		},
+	}
+)
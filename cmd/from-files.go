@@ -0,0 +1,78 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jlandowner/psp-util/pkg/core"
+	"github.com/jlandowner/psp-util/pkg/manifests"
+	"github.com/jlandowner/psp-util/pkg/relations"
+)
+
+// getRelationalPSPs returns relational PSPs from the manifest files if given, otherwise from cluster
+func getRelationalPSPs(ctx context.Context, fromFiles string) ([]relations.RelationalPodSecurityPolicy, error) {
+	objs, err := getObjects(ctx, fromFiles)
+	if err != nil {
+		return nil, err
+	}
+	return relations.GetRelationalPSPsFromManifests(objs), nil
+}
+
+// getObjects returns PSPs and RBAC resources from the manifest files if given, otherwise from cluster
+func getObjects(ctx context.Context, fromFiles string) (*manifests.Objects, error) {
+	if fromFiles != "" {
+		objs, err := manifests.Load(fromFiles)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load %s: %v", fromFiles, err)
+		}
+		return objs, nil
+	}
+
+	return getClusterObjects(ctx, kubeconfigPath, kubecontext)
+}
+
+// getClusterObjects returns PSPs and RBAC resources in the cluster of the kubeconfig and context
+func getClusterObjects(ctx context.Context, kubeconfig, kubectx string) (*manifests.Objects, error) {
+	k8sclient, err := newClient(kubeconfig, kubectx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load kubeconfig %v: %v", kubeconfig, err.Error())
+	}
+	return relations.GetObjects(ctx, k8sclient)
+}
+
+// getSubjectExpander returns SubjectExpander with the ServiceAccounts in the manifest objects if loaded from files, otherwise in cluster
+func getSubjectExpander(ctx context.Context, fromFiles string, objs *manifests.Objects) (*relations.SubjectExpander, error) {
+	if fromFiles != "" {
+		return relations.NewSubjectExpander(objs.ServiceAccounts.Items), nil
+	}
+
+	return getClusterSubjectExpander(ctx, kubeconfigPath, kubecontext)
+}
+
+// getClusterSubjectExpander returns SubjectExpander with the ServiceAccounts in the cluster of the kubeconfig and context
+func getClusterSubjectExpander(ctx context.Context, kubeconfig, kubectx string) (*relations.SubjectExpander, error) {
+	k8sclient, err := newClient(kubeconfig, kubectx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load kubeconfig %v: %v", kubeconfig, err.Error())
+	}
+	sas, err := core.ListServiceAccounts(ctx, k8sclient)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list ServiceAccounts: %v", err.Error())
+	}
+	return relations.NewSubjectExpander(sas.Items), nil
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jlandowner/psp-util/cmd/options"
+	"github.com/jlandowner/psp-util/pkg/managed"
+	"github.com/jlandowner/psp-util/pkg/printers"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(gcCmd)
+	gcCmd.Flags().BoolVarP(&g.Yes, "yes", "y", false, "delete without confirmation")
+	gcCmd.Flags().StringVar(&g.DryRun, "dry-run", options.DryRunNone, "print the garbage without deleting. One of: none|client|server")
+}
+
+var (
+	g = &options.GCOptions{}
+
+	gcCmd = &cobra.Command{
+		Use:               "gc",
+		Short:             "Delete managed RBACs of deleted PSPs, bindings without subjects and subjects of deleted ServiceAccounts or namespaces",
+		PersistentPreRunE: g.PreRunE,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			k8sclient, err := newClient(kubeconfigPath, kubecontext)
+			if err != nil {
+				return fmt.Errorf("Failed to load kubeconfig %v: %v", kubeconfigPath, err.Error())
+			}
+
+			plan, err := managed.GetGCPlan(ctx, k8sclient)
+			if err != nil {
+				return err
+			}
+			printers.PrintPlan(os.Stdout, plan)
+			if plan.IsEmpty() || g.DryRun == options.DryRunClient {
+				return nil
+			}
+
+			if !g.Yes && g.DryRun != options.DryRunServer {
+				if !confirm(cmd.InOrStdin(), os.Stdout, "\nDo you want to delete them?") {
+					fmt.Println("GC cancelled.")
+					return nil
+				}
+
+				// the subjects may be attached while waiting for the confirmation
+				latest, err := managed.GetGCPlan(ctx, k8sclient)
+				if err != nil {
+					return err
+				}
+				if !latest.HasSameChanges(plan) {
+					cmd.SilenceUsage = true
+					return fmt.Errorf("GC aborted as the managed resources were modified while waiting for the confirmation. Please run gc again")
+				}
+				plan = latest
+			}
+
+			if err := plan.Apply(ctx, k8sclient, g.DryRun); err != nil {
+				if managed.IsConflict(err) {
+					cmd.SilenceUsage = true
+					return fmt.Errorf("%v\nGC aborted as the managed resources were modified concurrently. Please run gc again", err)
+				}
+				return err
+			}
+			fmt.Println()
+			return printers.PrintChanges(os.Stdout, plan, g.DryRun)
+		},
+	}
+)
+
+// confirm asks the question and returns true if it is answered with yes
+func confirm(in io.Reader, out io.Writer, question string) bool {
+	fmt.Fprintf(out, "%s [y/N]: ", question)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
@@ -22,9 +22,9 @@ import (
 	"strconv"
 
 	"github.com/jlandowner/psp-util/cmd/options"
-	"github.com/jlandowner/psp-util/pkg/client"
 	"github.com/jlandowner/psp-util/pkg/printers"
 	"github.com/jlandowner/psp-util/pkg/relations"
+	"github.com/jlandowner/psp-util/pkg/utils"
 	"github.com/spf13/cobra"
 )
 
@@ -33,6 +33,9 @@ func init() {
 	listCmd.Flags().BoolVar(&l.NoHeader, "no-headers", false, "output without header")
 	listCmd.Flags().BoolVarP(&l.ClusterRole, "cluster-role", "c", false, "output only clusterroles associated with PSP")
 	listCmd.Flags().BoolVarP(&l.Role, "role", "r", false, "output only roles associated with PSP")
+	listCmd.Flags().StringVarP(&l.Output, "output", "o", "", "output format. One of: json|yaml|wide|name")
+	listCmd.Flags().StringVar(&l.FromFiles, "from-files", "", "load PSPs and RBACs from manifest file or directory instead of cluster. \"-\" reads from stdin")
+	addClusterFlags(listCmd, &l.ClusterOptions)
 }
 
 var (
@@ -44,92 +47,187 @@ var (
 		PersistentPreRunE: l.PreRunE,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := context.Background()
-			k8sclient, err := client.NewClient(&kubeconfigPath, &kubecontext)
-			if err != nil {
-				return fmt.Errorf("Failed to load kubeconfig %v: %v", kubeconfigPath, err.Error())
+			if l.IsMultiCluster() {
+				return listClusters(ctx, cmd)
 			}
 
-			psps, err := relations.GetRelationalPSPs(ctx, k8sclient)
+			objs, err := getObjects(ctx, l.FromFiles)
 			if err != nil {
 				return err
 			}
-
-			printOpt := printers.ListPrinterOptions{true, true, true, true, true, true}
-			if l.ClusterRole {
-				printOpt.Role = false
-				printOpt.PSPUtilManaged = false
-			}
-			if l.Role {
-				printOpt.ClusterRole = false
-				printOpt.ClusterRoleBinding = false
-				printOpt.PSPUtilManaged = false
+			psps := relations.GetRelationalPSPsFromManifests(objs)
+			dangling := relations.GetDanglingReferences(objs)
+
+			switch {
+			case printers.IsStructuredOutput(l.Output):
+				list := printers.NewRelationList(psps, !l.Role, !l.ClusterRole)
+				list.Dangling = dangling
+				return printers.PrintObject(os.Stdout, list, l.Output)
+
+			case l.Output == printers.OutputFormatName:
+				for _, psp := range psps {
+					fmt.Fprintf(os.Stdout, "podsecuritypolicy.policy/%s\n", psp.Name)
+				}
+				return nil
 			}
 
+			printOpt := listPrinterOptions(false)
 			printer := printers.NewListPrinter(os.Stdout, printOpt)
-			defer printer.Flush()
 
 			if !l.NoHeader {
 				printer.PrintHeader()
 			}
+			printRelations(printer, printOpt, "", psps)
+			printer.Flush()
+
+			// roles and bindings not shown above as the PSPs or roles they refer to are missing
+			if !dangling.IsEmpty() {
+				fmt.Fprintln(os.Stdout, "\nDangling references:")
+				printers.PrintDanglingReferences(os.Stdout, dangling, l.NoHeader)
+			}
+			return nil
+		},
+	}
+)
+
+// listClusters lists PSPs and the related RBACs in every cluster with the cluster column
+func listClusters(ctx context.Context, cmd *cobra.Command) error {
+	clusters, err := getClustersObjects(ctx, &l.ClusterOptions, false)
+	if err != nil {
+		return err
+	}
+
+	results := make([]printers.ClusterResult, len(clusters))
+	for i, c := range clusters {
+		if c.Err != nil {
+			results[i] = printers.NewClusterError(c.Cluster, c.Err)
+			continue
+		}
+		list := printers.NewRelationList(relations.GetRelationalPSPsFromManifests(c.Objects), !l.Role, !l.ClusterRole)
+		list.Dangling = relations.GetDanglingReferences(c.Objects)
+		results[i] = printers.ClusterResult{Cluster: c.Cluster, Result: list}
+	}
+
+	if printers.IsStructuredOutput(l.Output) {
+		if err := printers.PrintObject(os.Stdout, printers.NewClusterResultList(results), l.Output); err != nil {
+			return err
+		}
+		return clusterErrors(cmd, results)
+	}
+
+	printOpt := listPrinterOptions(true)
+	printer := printers.NewListPrinter(os.Stdout, printOpt)
+
+	if !l.NoHeader {
+		printer.PrintHeader()
+	}
+	for _, c := range clusters {
+		if c.Err == nil {
+			printRelations(printer, printOpt, c.Cluster, relations.GetRelationalPSPsFromManifests(c.Objects))
+		}
+	}
+	printer.Flush()
+
+	for _, c := range clusters {
+		if c.Err != nil {
+			continue
+		}
+		if dangling := relations.GetDanglingReferences(c.Objects); !dangling.IsEmpty() {
+			fmt.Fprintf(os.Stdout, "\nDangling references in %s:\n", c.Cluster)
+			printers.PrintDanglingReferences(os.Stdout, dangling, l.NoHeader)
+		}
+	}
+	return clusterErrors(cmd, results)
+}
+
+// listPrinterOptions returns the columns printed by the options
+func listPrinterOptions(cluster bool) printers.ListPrinterOptions {
+	printOpt := printers.ListPrinterOptions{
+		Cluster:            cluster,
+		PSP:                true,
+		ClusterRole:        true,
+		ClusterRoleBinding: true,
+		Role:               true,
+		RoleBinding:        true,
+		PSPUtilManaged:     true,
+		Subjects:           l.Output == printers.OutputFormatWide,
+	}
+	if l.ClusterRole {
+		printOpt.Role = false
+		printOpt.PSPUtilManaged = false
+	}
+	if l.Role {
+		printOpt.ClusterRole = false
+		printOpt.ClusterRoleBinding = false
+		printOpt.PSPUtilManaged = false
+	}
+	return printOpt
+}
 
-			for _, psp := range psps {
-				if len(psp.ClusterRoles) == 0 && len(psp.Roles) == 0 {
-					printer.PrintLine(printers.ListPrinterLine{})
+// printRelations prints a line per binding of the roles granting each PSP
+func printRelations(printer *printers.ListPrinter, printOpt printers.ListPrinterOptions, cluster string, psps []relations.RelationalPodSecurityPolicy) {
+	printLine := func(line printers.ListPrinterLine) {
+		line.Cluster = cluster
+		printer.PrintLine(line)
+	}
+
+	for _, psp := range psps {
+		if len(psp.ClusterRoles) == 0 && len(psp.Roles) == 0 {
+			printLine(printers.ListPrinterLine{})
+			continue
+		}
+
+		if printOpt.ClusterRole {
+			// clusterrole can bind to either clusterrolebinding or rolebinding
+			for _, cr := range psp.ClusterRoles {
+				if len(cr.ClusterRoleBindings) == 0 && len(cr.RoleBindings) == 0 {
+					printLine(printers.ListPrinterLine{
+						PSP:            psp.Name,
+						ClusterRole:    cr.Name + printers.WildcardMark(cr.Wildcard),
+						PSPUtilManaged: strconv.FormatBool(cr.IsManaged())})
 					continue
 				}
-
-				if printOpt.ClusterRole {
-					// clusterrole can bind to either clusterrolebinding or rolebinding
-					for _, cr := range psp.ClusterRoles {
-						if len(cr.ClusterRoleBindings) == 0 && len(cr.RoleBindings) == 0 {
-							printer.PrintLine(printers.ListPrinterLine{
-								PSP:            psp.Name,
-								ClusterRole:    cr.Name,
-								PSPUtilManaged: strconv.FormatBool(cr.IsManaged())})
-							continue
-						}
-						for _, crb := range cr.ClusterRoleBindings {
-							printer.PrintLine(printers.ListPrinterLine{
-								PSP:                psp.Name,
-								ClusterRole:        cr.Name,
-								ClusterRoleBinding: crb.Name,
-								PSPUtilManaged:     strconv.FormatBool(cr.IsManaged())})
-						}
-						for _, rb := range cr.RoleBindings {
-							rbname := fmt.Sprintf("%v/%v", rb.Namespace, rb.Name)
-							printer.PrintLine(printers.ListPrinterLine{
-								PSP:            psp.Name,
-								ClusterRole:    cr.Name,
-								RoleBinding:    rbname,
-								PSPUtilManaged: strconv.FormatBool(false)})
-						}
-					}
+				for _, crb := range cr.ClusterRoleBindings {
+					printLine(printers.ListPrinterLine{
+						PSP:                psp.Name,
+						ClusterRole:        cr.Name + printers.WildcardMark(cr.Wildcard),
+						ClusterRoleBinding: crb.Name,
+						PSPUtilManaged:     strconv.FormatBool(cr.IsManaged()),
+						Subjects:           printers.FormatSubjects(crb.Subjects)})
 				}
-
-				if printOpt.Role {
-					// role can only bind to rolebinding
-					for _, r := range psp.Roles {
-						rname := fmt.Sprintf("%v/%v", r.Namespace, r.Name)
-						if len(r.RoleBindings) == 0 {
-							printer.PrintLine(printers.ListPrinterLine{
-								PSP:            psp.Name,
-								Role:           rname,
-								PSPUtilManaged: strconv.FormatBool(false)})
-							continue
-						}
-						for _, rb := range r.RoleBindings {
-							rbname := fmt.Sprintf("%v/%v", r.Namespace, rb.Name)
-							printer.PrintLine(printers.ListPrinterLine{
-								PSP:            psp.Name,
-								Role:           rname,
-								RoleBinding:    rbname,
-								PSPUtilManaged: strconv.FormatBool(false)})
-						}
-					}
+				for _, rb := range cr.RoleBindings {
+					rbname := fmt.Sprintf("%v/%v", rb.Namespace, rb.Name)
+					printLine(printers.ListPrinterLine{
+						PSP:            psp.Name,
+						ClusterRole:    cr.Name + printers.WildcardMark(cr.Wildcard),
+						RoleBinding:    rbname,
+						PSPUtilManaged: strconv.FormatBool(utils.IsManaged(rb.Annotations)),
+						Subjects:       printers.FormatSubjects(rb.Subjects)})
 				}
-
 			}
-			return nil
-		},
+		}
+
+		if printOpt.Role {
+			// role can only bind to rolebinding
+			for _, r := range psp.Roles {
+				rname := fmt.Sprintf("%v/%v", r.Namespace, r.Name) + printers.WildcardMark(r.Wildcard)
+				if len(r.RoleBindings) == 0 {
+					printLine(printers.ListPrinterLine{
+						PSP:            psp.Name,
+						Role:           rname,
+						PSPUtilManaged: strconv.FormatBool(false)})
+					continue
+				}
+				for _, rb := range r.RoleBindings {
+					rbname := fmt.Sprintf("%v/%v", r.Namespace, rb.Name)
+					printLine(printers.ListPrinterLine{
+						PSP:            psp.Name,
+						Role:           rname,
+						RoleBinding:    rbname,
+						PSPUtilManaged: strconv.FormatBool(false),
+						Subjects:       printers.FormatSubjects(rb.Subjects)})
+				}
+			}
+		}
 	}
-)
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// newUnmanagedObjects returns a Role in team-b granting the PSP restricted and the RoleBinding to the user
+func newUnmanagedObjects() []runtime.Object {
+	return []runtime.Object{
+		&rbacv1.Role{
+			ObjectMeta: metav1.ObjectMeta{Name: "use-restricted", Namespace: "team-b"},
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{"policy"}, Resources: []string{"podsecuritypolicies"}, ResourceNames: []string{"restricted"}, Verbs: []string{"use"}},
+			},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "use-restricted", Namespace: "team-b"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "use-restricted"},
+			Subjects:   []rbacv1.Subject{testUser},
+		},
+	}
+}
+
+func TestList(t *testing.T) {
+	tests := []struct {
+		title          string
+		args           []string
+		expectOut      string
+		expectContains []string
+		expectErr      bool
+	}{
+		{
+			title: "list",
+			args:  []string{"list"},
+			expectOut: "" +
+				"PSP          ClusterRole           ClusterRoleBinding    NS/Role                 NS/RoleBinding               Managed\n" +
+				"restricted   psp-util.restricted   psp-util.restricted                                                        true\n" +
+				"restricted   psp-util.restricted                                                 team-a/psp-util.restricted   true\n" +
+				"restricted                                               team-b/use-restricted   team-b/use-restricted        false\n",
+		},
+		{
+			title: "wide",
+			args:  []string{"list", "-o", "wide"},
+			expectOut: "" +
+				"PSP          ClusterRole           ClusterRoleBinding    NS/Role                 NS/RoleBinding               Managed   Subjects\n" +
+				"restricted   psp-util.restricted   psp-util.restricted                                                        true      Group/system:authenticated\n" +
+				"restricted   psp-util.restricted                                                 team-a/psp-util.restricted   true      ServiceAccount/team-a/app\n" +
+				"restricted                                               team-b/use-restricted   team-b/use-restricted        false     User/alice\n",
+		},
+		{
+			title: "only cluster roles without headers",
+			args:  []string{"list", "-c", "--no-headers"},
+			expectOut: "" +
+				"restricted   psp-util.restricted   psp-util.restricted   \n" +
+				"restricted   psp-util.restricted                         team-a/psp-util.restricted\n",
+		},
+		{
+			title:     "name",
+			args:      []string{"list", "-o", "name"},
+			expectOut: "podsecuritypolicy.policy/restricted\n",
+		},
+		{
+			title:          "json",
+			args:           []string{"list", "-o", "json"},
+			expectContains: []string{`"kind": "RelationList"`, `"name": "psp-util.restricted"`, `"name": "use-restricted"`},
+		},
+		{
+			title:     "invalid output",
+			args:      []string{"list", "-o", "table"},
+			expectErr: true,
+		},
+	}
+
+	k8sclient := newTestClient(append(newManagedObjects(), newUnmanagedObjects()...)...)
+	for _, test := range tests {
+		t.Log(test.title)
+		out, _, err := execute(t, k8sclient, test.args...)
+		if test.expectErr {
+			assert.Error(t, err)
+			continue
+		}
+		assert.NoError(t, err)
+		assertOutput(t, test.expectOut, test.expectContains, out)
+	}
+}
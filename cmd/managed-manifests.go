@@ -0,0 +1,82 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jlandowner/psp-util/pkg/managed"
+	"github.com/jlandowner/psp-util/pkg/manifests"
+	"github.com/jlandowner/psp-util/pkg/printers"
+	"k8s.io/client-go/kubernetes"
+)
+
+// getManagedResources returns the managed resources of the PSP from the manifest files if given, otherwise from cluster
+func getManagedResources(ctx context.Context, k8sclient kubernetes.Interface, pspName, fromFiles string) (*managed.Resources, error) {
+	if fromFiles != "" {
+		objs, err := manifests.Load(fromFiles)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load %s: %v", fromFiles, err)
+		}
+		return managed.GetResourcesFromManifests(objs, pspName), nil
+	}
+
+	res, err := managed.GetResources(ctx, k8sclient, pspName)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get managed resources: %v", err)
+	}
+	return res, nil
+}
+
+// printManagedManifests prints the managed manifests after the changes instead of applying them.
+// The resources deleted by the changes are noted to stderr
+func printManagedManifests(ctx context.Context, k8sclient kubernetes.Interface, pspName, fromFiles string,
+	newPlan func(res *managed.Resources) (*managed.Plan, error), output string,
+) error {
+	res, err := getManagedResources(ctx, k8sclient, pspName, fromFiles)
+	if err != nil {
+		return err
+	}
+	plan, err := newPlan(res)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range plan.Changes {
+		if c.Action == managed.ActionDelete {
+			fmt.Fprintf(os.Stderr, "%s %s is deleted. Remove it from your manifests\n", c.Kind, c.String())
+		}
+	}
+	return printers.PrintObjects(os.Stdout, plan.Result(res).Manifests(), output)
+}
+
+// applyManagedChanges applies the changes to the managed resources of the PSP.
+// It is retried from re-reading the resources when they are modified concurrently, e.g. by parallel CI jobs
+func applyManagedChanges(ctx context.Context, k8sclient kubernetes.Interface, pspName string,
+	newPlan func(res *managed.Resources) (*managed.Plan, error), dryRun string,
+) (*managed.Plan, error) {
+	onConflict := func(err error) {
+		fmt.Fprintf(os.Stderr, "Warning: %v\nThe managed resources were modified concurrently. Retrying with the latest ones...\n", err)
+	}
+
+	plan, err := managed.ApplyWithRetry(ctx, k8sclient, pspName, newPlan, dryRun, onConflict)
+	if managed.IsConflict(err) {
+		return nil, fmt.Errorf("%v\nGave up as the managed resources kept being modified concurrently. Please retry later", err)
+	}
+	return plan, err
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jlandowner/psp-util/cmd/options"
+	"github.com/jlandowner/psp-util/pkg/core"
+	"github.com/jlandowner/psp-util/pkg/manifests"
+	"github.com/jlandowner/psp-util/pkg/printers"
+	"github.com/jlandowner/psp-util/pkg/pss"
+	"github.com/jlandowner/psp-util/pkg/relations"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	migrateCmd.AddCommand(migratePSSCmd)
+	migratePSSCmd.Flags().StringVar(&m.MaxLevel, "max-level", string(pss.LevelPrivileged), "most permissive enforce level to propose. One of: privileged|baseline|restricted")
+	migratePSSCmd.Flags().StringVarP(&m.Output, "output", "o", "", "output Namespace patches instead of the report. One of: json|yaml")
+	migratePSSCmd.Flags().StringVar(&m.FromFiles, "from-files", "", "load PSPs and RBACs from manifest file or directory instead of cluster. \"-\" reads from stdin")
+}
+
+var (
+	m = &options.MigratePSSOptions{}
+
+	migratePSSCmd = &cobra.Command{
+		Use:               "pss",
+		Short:             "Classify PSPs by Pod Security Standards and propose Pod Security Admission labels for Namespaces",
+		PersistentPreRunE: m.PreRunE,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			var psps []relations.RelationalPodSecurityPolicy
+			var namespaces []string
+			if m.FromFiles != "" {
+				objs, err := manifests.Load(m.FromFiles)
+				if err != nil {
+					return fmt.Errorf("Failed to load %s: %v", m.FromFiles, err)
+				}
+				psps = relations.GetRelationalPSPsFromManifests(objs)
+				// Namespaces are not always in manifests, so the namespaces of Roles and RoleBindings are used as well
+				namespaces = objs.NamespaceNames()
+			} else {
+				k8sclient, err := newClient(kubeconfigPath, kubecontext)
+				if err != nil {
+					return fmt.Errorf("Failed to load kubeconfig %v: %v", kubeconfigPath, err.Error())
+				}
+
+				psps, err = relations.GetRelationalPSPs(ctx, k8sclient)
+				if err != nil {
+					return err
+				}
+
+				nsList, err := core.ListNamespaces(ctx, k8sclient)
+				if err != nil {
+					return fmt.Errorf("Failed to list Namespaces: %v", err)
+				}
+				namespaces = make([]string, len(nsList.Items))
+				for i, ns := range nsList.Items {
+					namespaces[i] = ns.Name
+				}
+			}
+
+			plans, dangling := pss.PlanNamespaces(psps, namespaces, m.Level)
+
+			if printers.IsStructuredOutput(m.Output) {
+				for _, d := range dangling {
+					fmt.Fprintf(os.Stderr, "Warning: Namespace %s is not found. PSP %s is granted to its workloads\n", d.Namespace, d.PSP)
+				}
+				patches := make([]interface{}, len(plans))
+				for i, p := range plans {
+					patches[i] = p.NamespacePatch()
+				}
+				return printers.PrintObjects(os.Stdout, patches, m.Output)
+			}
+
+			classes := make([]pss.Classification, len(psps))
+			for i, psp := range psps {
+				classes[i] = pss.Classify(psp.PodSecurityPolicy)
+			}
+			printers.PrintPSSMigrationReport(os.Stdout, classes, plans, dangling)
+			return nil
+		},
+	}
+)
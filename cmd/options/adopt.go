@@ -0,0 +1,54 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+type AdoptOptions struct {
+	// PSPName limits the resources to adopt to the ones granting the PSP. All PSPs if empty
+	PSPName string
+
+	// DryRun is one of none, client or server
+	DryRun string
+}
+
+func (o *AdoptOptions) PreRunE(cmd *cobra.Command, args []string) error {
+	if err := o.Validate(cmd, args); err != nil {
+		return err
+	}
+	if err := o.Complete(cmd, args); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (o *AdoptOptions) Validate(cmd *cobra.Command, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Args is invalid. Required: `[PSP-NAME]`")
+	}
+	return validateDryRun(o.DryRun)
+}
+
+func (o *AdoptOptions) Complete(cmd *cobra.Command, args []string) error {
+	if len(args) == 1 {
+		o.PSPName = args[0]
+	}
+	return nil
+}
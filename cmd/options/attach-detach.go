@@ -18,8 +18,10 @@ package options
 import (
 	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/jlandowner/psp-util/pkg/client"
+	"github.com/jlandowner/psp-util/pkg/managed"
 	"github.com/jlandowner/psp-util/pkg/rbac"
 	"github.com/spf13/cobra"
 	rbacv1 "k8s.io/api/rbac/v1"
@@ -30,6 +32,11 @@ var (
 	subjectKindList  = []string{"Group", "User", "ServiceAccount"}
 )
 
+const (
+	ScopeCluster   = managed.ScopeCluster
+	ScopeNamespace = managed.ScopeNamespace
+)
+
 type AttachDetachOptions struct {
 	PSPName          string
 	SubjectKind      string
@@ -41,6 +48,18 @@ type AttachDetachOptions struct {
 	Group          string
 	User           string
 	ServiceAccount string
+
+	// Scope is where the PSP is granted. cluster uses ClusterRoleBinding and namespace uses RoleBinding
+	Scope            string
+	BindingNamespace string
+
+	// DryRun is one of none, client or server
+	DryRun string
+
+	// Output prints the resulting managed manifests instead of applying them
+	Output string
+	// FromFiles loads the current managed resources from manifest files instead of cluster
+	FromFiles string
 }
 
 func (o *AttachDetachOptions) PreRunE(cmd *cobra.Command, args []string) error {
@@ -57,6 +76,48 @@ func (o *AttachDetachOptions) Validate(cmd *cobra.Command, args []string) error
 	if len(args) != 1 {
 		return fmt.Errorf("Args is invalid. Required: `PSP-NAME`")
 	}
+	if err := o.validateScope(); err != nil {
+		return err
+	}
+	if err := validateDryRun(o.DryRun); err != nil {
+		return err
+	}
+	if err := validateManifestOutput(o.Output, o.DryRun, o.FromFiles); err != nil {
+		return err
+	}
+	return o.validateSubject()
+}
+
+func (o *AttachDetachOptions) validateScope() error {
+	switch o.Scope {
+	case "", ScopeCluster:
+		if use(o.BindingNamespace) {
+			return fmt.Errorf("--binding-namespace is only used with --scope %s", ScopeNamespace)
+		}
+	case ScopeNamespace:
+		if !use(o.BindingNamespace) {
+			return fmt.Errorf("--binding-namespace is required when using --scope %s", ScopeNamespace)
+		}
+	default:
+		return fmt.Errorf("Invalid --scope %s. One of: %s|%s", o.Scope, ScopeCluster, ScopeNamespace)
+	}
+	return nil
+}
+
+// IsNamespaceScoped returns true if the PSP is granted by RoleBinding in the binding namespace
+func (o *AttachDetachOptions) IsNamespaceScoped() bool {
+	return o.Scope == ScopeNamespace
+}
+
+// GetBindingNamespace returns the binding namespace if namespace scoped, otherwise empty
+func (o *AttachDetachOptions) GetBindingNamespace() string {
+	if o.IsNamespaceScoped() {
+		return o.BindingNamespace
+	}
+	return ""
+}
+
+func (o *AttachDetachOptions) validateSubject() error {
 	_, _, kindFlagCount := getValuesFromKindFlags(o)
 
 	if use(o.SubjectKind) {
@@ -81,17 +142,34 @@ func (o *AttachDetachOptions) Validate(cmd *cobra.Command, args []string) error
 			return fmt.Errorf("--api-group or --name is not allowed when using kind flags in %s", subjectKindFlags)
 		}
 
+		if strings.Contains(o.ServiceAccount, "/") && use(o.SubjectNamespace) {
+			return fmt.Errorf("--namespace is not allowed when using --sa NAMESPACE/NAME")
+		}
 	}
 	return nil
 }
 
 func (o *AttachDetachOptions) Complete(cmd *cobra.Command, args []string) error {
 	o.PSPName = args[0]
+	return o.completeSubject()
+}
+
+func (o *AttachDetachOptions) completeSubject() error {
 	kind, name, _ := getValuesFromKindFlags(o)
 	if kind != "" {
 		o.SubjectKind = kind
 		o.SubjectName = name
 	}
+
+	// --sa accepts NAMESPACE/NAME as well
+	if use(o.ServiceAccount) && strings.Contains(o.ServiceAccount, "/") {
+		s := strings.SplitN(o.ServiceAccount, "/", 2)
+		if s[0] == "" || s[1] == "" {
+			return fmt.Errorf("Invalid --sa %s. Required: NAME or NAMESPACE/NAME", o.ServiceAccount)
+		}
+		o.SubjectNamespace = s[0]
+		o.SubjectName = s[1]
+	}
 	return nil
 }
 
@@ -101,7 +179,9 @@ func (o *AttachDetachOptions) GenerateSubject(kubeconfigPath *string) (*rbacv1.S
 	sub.Name = o.SubjectName
 
 	if use(o.ServiceAccount) {
-		if o.SubjectNamespace == "" {
+		if o.SubjectNamespace == "" && o.IsNamespaceScoped() {
+			sub.Namespace = o.BindingNamespace
+		} else if o.SubjectNamespace == "" {
 			namespace, err := client.GetDefaultNamespace(kubeconfigPath)
 			if err != nil {
 				return nil, err
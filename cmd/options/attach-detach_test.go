@@ -59,3 +59,179 @@ func TestGetValuesFromKindFlags(t *testing.T) {
 		assert.Equal(t, test.expectName, name)
 	}
 }
+
+func TestCompleteSubject(t *testing.T) {
+	tests := []struct {
+		title           string
+		option          AttachDetachOptions
+		expectName      string
+		expectNamespace string
+		expectErr       bool
+	}{
+		{
+			title:      "sa name",
+			option:     AttachDetachOptions{ServiceAccount: "default"},
+			expectName: "default",
+		},
+		{
+			title:           "sa namespace and name",
+			option:          AttachDetachOptions{ServiceAccount: "team-a/default"},
+			expectName:      "default",
+			expectNamespace: "team-a",
+		},
+		{
+			title:     "sa without name",
+			option:    AttachDetachOptions{ServiceAccount: "team-a/"},
+			expectErr: true,
+		},
+		{
+			title:      "group with slash",
+			option:     AttachDetachOptions{Group: "my/group"},
+			expectName: "my/group",
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		err := test.option.completeSubject()
+		if test.expectErr {
+			assert.NotNil(t, err)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, test.expectName, test.option.SubjectName)
+		assert.Equal(t, test.expectNamespace, test.option.SubjectNamespace)
+	}
+}
+
+func TestValidateScope(t *testing.T) {
+	tests := []struct {
+		title     string
+		option    AttachDetachOptions
+		expectErr bool
+	}{
+		{
+			title:  "default cluster scope",
+			option: AttachDetachOptions{},
+		},
+		{
+			title:  "namespace scope",
+			option: AttachDetachOptions{Scope: ScopeNamespace, BindingNamespace: "team-a"},
+		},
+		{
+			title:     "namespace scope without binding namespace",
+			option:    AttachDetachOptions{Scope: ScopeNamespace},
+			expectErr: true,
+		},
+		{
+			title:     "cluster scope with binding namespace",
+			option:    AttachDetachOptions{Scope: ScopeCluster, BindingNamespace: "team-a"},
+			expectErr: true,
+		},
+		{
+			title:     "invalid scope",
+			option:    AttachDetachOptions{Scope: "global"},
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		err := test.option.validateScope()
+		if test.expectErr {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+}
+
+func TestValidateDryRun(t *testing.T) {
+	tests := []struct {
+		title     string
+		dryRun    string
+		expectErr bool
+	}{
+		{
+			title:  "not set",
+			dryRun: "",
+		},
+		{
+			title:  "none",
+			dryRun: DryRunNone,
+		},
+		{
+			title:  "client",
+			dryRun: DryRunClient,
+		},
+		{
+			title:  "server",
+			dryRun: DryRunServer,
+		},
+		{
+			title:     "invalid",
+			dryRun:    "true",
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		err := validateDryRun(test.dryRun)
+		if test.expectErr {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+}
+
+func TestValidateManifestOutput(t *testing.T) {
+	tests := []struct {
+		title     string
+		output    string
+		dryRun    string
+		fromFiles string
+		expectErr bool
+	}{
+		{
+			title: "not set",
+		},
+		{
+			title:  "output yaml",
+			output: "yaml",
+			dryRun: DryRunNone,
+		},
+		{
+			title:     "output json from files",
+			output:    "json",
+			fromFiles: "manifests",
+		},
+		{
+			title:     "unsupported output",
+			output:    "wide",
+			expectErr: true,
+		},
+		{
+			title:     "output with dry-run",
+			output:    "yaml",
+			dryRun:    DryRunServer,
+			expectErr: true,
+		},
+		{
+			title:     "from files without output",
+			fromFiles: "manifests",
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		err := validateManifestOutput(test.output, test.dryRun, test.fromFiles)
+		if test.expectErr {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+}
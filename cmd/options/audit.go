@@ -0,0 +1,67 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/jlandowner/psp-util/pkg/audit"
+	"github.com/jlandowner/psp-util/pkg/printers"
+	"github.com/spf13/cobra"
+)
+
+type AuditOptions struct {
+	FailOn    string
+	Config    string
+	NoHeader  bool
+	Output    string
+	FromFiles string
+	ClusterOptions
+
+	FailOnSeverity audit.Severity
+}
+
+func (o *AuditOptions) PreRunE(cmd *cobra.Command, args []string) error {
+	if err := o.Validate(cmd, args); err != nil {
+		return err
+	}
+	if err := o.Complete(cmd, args); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (o *AuditOptions) Validate(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("Args is invalid")
+	}
+	if _, err := audit.ParseSeverity(o.FailOn); err != nil {
+		return err
+	}
+	if err := o.validateClusters(o.FromFiles); err != nil {
+		return err
+	}
+	return validateOutput(o.Output, printers.OutputFormatJSON, printers.OutputFormatYAML)
+}
+
+func (o *AuditOptions) Complete(cmd *cobra.Command, args []string) error {
+	severity, err := audit.ParseSeverity(o.FailOn)
+	if err != nil {
+		return err
+	}
+	o.FailOnSeverity = severity
+	return nil
+}
@@ -23,6 +23,14 @@ import (
 
 type CleanOptions struct {
 	PSPName string
+
+	// DryRun is one of none, client or server
+	DryRun string
+
+	// Output prints the manifests of the resources to delete instead of deleting them
+	Output string
+	// FromFiles loads the current managed resources from manifest files instead of cluster
+	FromFiles string
 }
 
 func (o *CleanOptions) PreRunE(cmd *cobra.Command, args []string) error {
@@ -39,7 +47,10 @@ func (o *CleanOptions) Validate(cmd *cobra.Command, args []string) error {
 	if len(args) == 0 {
 		return fmt.Errorf("Args is invalid. Required: `PSP-NAME`")
 	}
-	return nil
+	if err := validateDryRun(o.DryRun); err != nil {
+		return err
+	}
+	return validateManifestOutput(o.Output, o.DryRun, o.FromFiles)
 }
 
 func (o *CleanOptions) Complete(cmd *cobra.Command, args []string) error {
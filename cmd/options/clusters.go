@@ -0,0 +1,41 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package options
+
+import (
+	"fmt"
+)
+
+// ClusterOptions selects the clusters which read-only commands run against by kube-contexts
+type ClusterOptions struct {
+	AllContexts bool
+	Contexts    []string
+}
+
+// IsMultiCluster returns true if the command runs against multiple clusters rather than the current context
+func (o *ClusterOptions) IsMultiCluster() bool {
+	return o.AllContexts || len(o.Contexts) > 0
+}
+
+func (o *ClusterOptions) validateClusters(fromFiles string) error {
+	if o.AllContexts && len(o.Contexts) > 0 {
+		return fmt.Errorf("--all-contexts and --contexts cannot be used together")
+	}
+	if o.IsMultiCluster() && fromFiles != "" {
+		return fmt.Errorf("--all-contexts and --contexts cannot be used with --from-files")
+	}
+	return nil
+}
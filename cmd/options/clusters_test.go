@@ -0,0 +1,59 @@
+package options
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterOptions(t *testing.T) {
+	tests := []struct {
+		title       string
+		option      ClusterOptions
+		fromFiles   string
+		expectMulti bool
+		wantErr     bool
+	}{
+		{
+			title:  "current context",
+			option: ClusterOptions{},
+		},
+		{
+			title:       "all contexts",
+			option:      ClusterOptions{AllContexts: true},
+			expectMulti: true,
+		},
+		{
+			title:       "contexts",
+			option:      ClusterOptions{Contexts: []string{"staging", "prod"}},
+			expectMulti: true,
+		},
+		{
+			title:     "current context with manifests",
+			option:    ClusterOptions{},
+			fromFiles: "psps.yaml",
+		},
+		{
+			title:   "all contexts and contexts",
+			option:  ClusterOptions{AllContexts: true, Contexts: []string{"staging"}},
+			wantErr: true,
+		},
+		{
+			title:     "contexts with manifests",
+			option:    ClusterOptions{Contexts: []string{"staging"}},
+			fromFiles: "psps.yaml",
+			wantErr:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		err := test.option.validateClusters(test.fromFiles)
+		if test.wantErr {
+			assert.Error(t, err)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, test.expectMulti, test.option.IsMultiCluster())
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/jlandowner/psp-util/pkg/printers"
+	"github.com/spf13/cobra"
+)
+
+// CompareSource is a cluster or manifests compared
+type CompareSource struct {
+	// Name is the context, kubeconfig or manifest path identifying the source in output
+	Name       string
+	Kubeconfig string
+	Context    string
+	FromFiles  string
+}
+
+type CompareOptions struct {
+	Contexts    []string
+	Kubeconfigs []string
+	FromFiles   []string
+	NoHeader    bool
+	Output      string
+
+	Sources [2]CompareSource
+}
+
+func (o *CompareOptions) PreRunE(cmd *cobra.Command, args []string) error {
+	if err := o.Validate(cmd, args); err != nil {
+		return err
+	}
+	if err := o.Complete(cmd, args); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (o *CompareOptions) Validate(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("Args is invalid")
+	}
+	if len(o.FromFiles) > 0 {
+		if len(o.FromFiles) != 2 || len(o.Contexts) > 0 || len(o.Kubeconfigs) > 0 {
+			return fmt.Errorf("--from-files must be given twice and cannot be used with --context or --kubeconfig")
+		}
+	} else {
+		if len(o.Contexts) > 2 || len(o.Kubeconfigs) > 2 || (len(o.Contexts) != 2 && len(o.Kubeconfigs) != 2) {
+			return fmt.Errorf("Two clusters are required. Specify --context or --kubeconfig twice")
+		}
+	}
+	return validateOutput(o.Output, printers.OutputFormatJSON, printers.OutputFormatYAML)
+}
+
+func (o *CompareOptions) Complete(cmd *cobra.Command, args []string) error {
+	for i := range o.Sources {
+		src := CompareSource{}
+		switch {
+		case len(o.FromFiles) > 0:
+			src.FromFiles = o.FromFiles[i]
+			src.Name = src.FromFiles
+		default:
+			src.Kubeconfig = nth(o.Kubeconfigs, i)
+			src.Context = nth(o.Contexts, i)
+			// contexts identify the clusters unless the same context is used in the kubeconfigs
+			src.Name = src.Context
+			if len(o.Contexts) != 2 || o.Contexts[0] == o.Contexts[1] {
+				src.Name = src.Kubeconfig
+			}
+		}
+		o.Sources[i] = src
+	}
+	if o.Sources[0].Name == o.Sources[1].Name {
+		return fmt.Errorf("Cannot compare %s with itself", o.Sources[0].Name)
+	}
+	return nil
+}
+
+// nth returns the i-th value, or the only value shared by every index
+func nth(values []string, i int) string {
+	switch len(values) {
+	case 0:
+		return ""
+	case 1:
+		return values[0]
+	}
+	return values[i]
+}
@@ -0,0 +1,79 @@
+package options
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareOptions(t *testing.T) {
+	tests := []struct {
+		title   string
+		option  CompareOptions
+		expect  [2]CompareSource
+		wantErr bool
+	}{
+		{
+			title:  "two contexts",
+			option: CompareOptions{Contexts: []string{"staging", "prod"}},
+			expect: [2]CompareSource{{Name: "staging", Context: "staging"}, {Name: "prod", Context: "prod"}},
+		},
+		{
+			title:  "two contexts in a kubeconfig",
+			option: CompareOptions{Contexts: []string{"staging", "prod"}, Kubeconfigs: []string{"config"}},
+			expect: [2]CompareSource{
+				{Name: "staging", Context: "staging", Kubeconfig: "config"},
+				{Name: "prod", Context: "prod", Kubeconfig: "config"},
+			},
+		},
+		{
+			title:  "two kubeconfigs",
+			option: CompareOptions{Kubeconfigs: []string{"staging.yaml", "prod.yaml"}},
+			expect: [2]CompareSource{{Name: "staging.yaml", Kubeconfig: "staging.yaml"}, {Name: "prod.yaml", Kubeconfig: "prod.yaml"}},
+		},
+		{
+			title:  "same context in two kubeconfigs",
+			option: CompareOptions{Contexts: []string{"admin", "admin"}, Kubeconfigs: []string{"staging.yaml", "prod.yaml"}},
+			expect: [2]CompareSource{
+				{Name: "staging.yaml", Context: "admin", Kubeconfig: "staging.yaml"},
+				{Name: "prod.yaml", Context: "admin", Kubeconfig: "prod.yaml"},
+			},
+		},
+		{
+			title:  "two manifests",
+			option: CompareOptions{FromFiles: []string{"staging/", "prod/"}},
+			expect: [2]CompareSource{{Name: "staging/", FromFiles: "staging/"}, {Name: "prod/", FromFiles: "prod/"}},
+		},
+		{
+			title:   "one context",
+			option:  CompareOptions{Contexts: []string{"staging"}},
+			wantErr: true,
+		},
+		{
+			title:   "three contexts",
+			option:  CompareOptions{Contexts: []string{"staging", "prod", "dev"}},
+			wantErr: true,
+		},
+		{
+			title:   "manifests with context",
+			option:  CompareOptions{FromFiles: []string{"staging/", "prod/"}, Contexts: []string{"staging"}},
+			wantErr: true,
+		},
+		{
+			title:   "same cluster",
+			option:  CompareOptions{Contexts: []string{"staging", "staging"}},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		err := test.option.PreRunE(nil, nil)
+		if test.wantErr {
+			assert.Error(t, err)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, test.expect, test.option.Sources)
+	}
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package options
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jlandowner/psp-util/pkg/convert"
+	"github.com/jlandowner/psp-util/pkg/printers"
+	"github.com/spf13/cobra"
+)
+
+type ConvertOptions struct {
+	PSPName   string
+	To        string
+	Output    string
+	FromFiles string
+}
+
+func (o *ConvertOptions) PreRunE(cmd *cobra.Command, args []string) error {
+	if err := o.Validate(cmd, args); err != nil {
+		return err
+	}
+	if err := o.Complete(cmd, args); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (o *ConvertOptions) Validate(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Args is invalid. Required: `PSP-NAME`")
+	}
+	if !use(o.To) {
+		return fmt.Errorf("--to is required. One of: %s", strings.Join(convert.Targets, "|"))
+	}
+	valid := false
+	for _, t := range convert.Targets {
+		if o.To == t {
+			valid = true
+		}
+	}
+	if !valid {
+		return fmt.Errorf("Unsupported target %s. One of: %s", o.To, strings.Join(convert.Targets, "|"))
+	}
+	return validateOutput(o.Output, printers.OutputFormatJSON, printers.OutputFormatYAML)
+}
+
+func (o *ConvertOptions) Complete(cmd *cobra.Command, args []string) error {
+	o.PSPName = args[0]
+	if o.Output == "" {
+		o.Output = printers.OutputFormatYAML
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/jlandowner/psp-util/pkg/managed"
+	"github.com/jlandowner/psp-util/pkg/printers"
+)
+
+const (
+	DryRunNone   = managed.DryRunNone
+	DryRunClient = managed.DryRunClient
+	DryRunServer = managed.DryRunServer
+)
+
+func validateDryRun(dryRun string) error {
+	switch dryRun {
+	case "", DryRunNone, DryRunClient, DryRunServer:
+		return nil
+	}
+	return fmt.Errorf("Invalid --dry-run %s. One of: %s|%s|%s", dryRun, DryRunNone, DryRunClient, DryRunServer)
+}
+
+// validateManifestOutput validates the options to print the resulting manifests instead of applying them
+func validateManifestOutput(output, dryRun, fromFiles string) error {
+	if err := validateOutput(output, printers.OutputFormatJSON, printers.OutputFormatYAML); err != nil {
+		return err
+	}
+	if use(output) && use(dryRun) && dryRun != DryRunNone {
+		return fmt.Errorf("--dry-run is not allowed when using --output as nothing is applied")
+	}
+	if use(fromFiles) && !use(output) {
+		return fmt.Errorf("--output is required when using --from-files")
+	}
+	return nil
+}
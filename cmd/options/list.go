@@ -16,6 +16,9 @@ limitations under the License.
 package options
 
 import (
+	"fmt"
+
+	"github.com/jlandowner/psp-util/pkg/printers"
 	"github.com/spf13/cobra"
 )
 
@@ -23,6 +26,9 @@ type ListOptions struct {
 	NoHeader    bool
 	ClusterRole bool
 	Role        bool
+	Output      string
+	FromFiles   string
+	ClusterOptions
 }
 
 func (o *ListOptions) PreRunE(cmd *cobra.Command, args []string) error {
@@ -36,7 +42,14 @@ func (o *ListOptions) PreRunE(cmd *cobra.Command, args []string) error {
 }
 
 func (o *ListOptions) Validate(cmd *cobra.Command, args []string) error {
-	return nil
+	if err := o.validateClusters(o.FromFiles); err != nil {
+		return err
+	}
+	if o.IsMultiCluster() && o.Output == printers.OutputFormatName {
+		return fmt.Errorf("Output format name cannot be used with multiple clusters")
+	}
+	return validateOutput(o.Output,
+		printers.OutputFormatJSON, printers.OutputFormatYAML, printers.OutputFormatWide, printers.OutputFormatName)
 }
 
 func (o *ListOptions) Complete(cmd *cobra.Command, args []string) error {
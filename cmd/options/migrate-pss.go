@@ -0,0 +1,61 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/jlandowner/psp-util/pkg/printers"
+	"github.com/jlandowner/psp-util/pkg/pss"
+	"github.com/spf13/cobra"
+)
+
+type MigratePSSOptions struct {
+	MaxLevel  string
+	Output    string
+	FromFiles string
+
+	Level pss.Level
+}
+
+func (o *MigratePSSOptions) PreRunE(cmd *cobra.Command, args []string) error {
+	if err := o.Validate(cmd, args); err != nil {
+		return err
+	}
+	if err := o.Complete(cmd, args); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (o *MigratePSSOptions) Validate(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("Args is invalid")
+	}
+	if _, err := pss.ParseLevel(o.MaxLevel); err != nil {
+		return err
+	}
+	return validateOutput(o.Output, printers.OutputFormatJSON, printers.OutputFormatYAML)
+}
+
+func (o *MigratePSSOptions) Complete(cmd *cobra.Command, args []string) error {
+	level, err := pss.ParseLevel(o.MaxLevel)
+	if err != nil {
+		return err
+	}
+	o.Level = level
+	return nil
+}
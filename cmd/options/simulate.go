@@ -0,0 +1,102 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package options
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jlandowner/psp-util/pkg/printers"
+	"github.com/jlandowner/psp-util/pkg/rbac"
+	"github.com/spf13/cobra"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+type SimulateOptions struct {
+	Filename       string
+	FromFiles      string
+	Namespace      string
+	ServiceAccount string
+	User           string
+	Groups         []string
+	Output         string
+}
+
+func (o *SimulateOptions) PreRunE(cmd *cobra.Command, args []string) error {
+	if err := o.Validate(cmd, args); err != nil {
+		return err
+	}
+	if err := o.Complete(cmd, args); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (o *SimulateOptions) Validate(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("Args is invalid. Use -f to specify Pod manifests")
+	}
+	if !use(o.Filename) {
+		return fmt.Errorf("-f is required")
+	}
+	if len(o.Groups) > 0 && !use(o.User) {
+		return fmt.Errorf("--group is only used with --user")
+	}
+	return validateOutput(o.Output, printers.OutputFormatJSON, printers.OutputFormatYAML)
+}
+
+func (o *SimulateOptions) Complete(cmd *cobra.Command, args []string) error {
+	return nil
+}
+
+// PodNamespace returns the namespace for a pod in the given manifest namespace
+func (o *SimulateOptions) PodNamespace(namespace string) string {
+	if namespace != "" {
+		return namespace
+	}
+	if use(o.Namespace) {
+		return o.Namespace
+	}
+	return "default"
+}
+
+// ServiceAccountSubject returns --sa or the service account of the pod
+func (o *SimulateOptions) ServiceAccountSubject(namespace, serviceAccountName string) rbacv1.Subject {
+	sub := rbacv1.Subject{Kind: "ServiceAccount", Namespace: namespace, Name: serviceAccountName}
+	if use(o.ServiceAccount) {
+		sub.Name = o.ServiceAccount
+		if s := strings.SplitN(o.ServiceAccount, "/", 2); len(s) == 2 {
+			sub.Namespace = s[0]
+			sub.Name = s[1]
+		}
+	}
+	if sub.Name == "" {
+		sub.Name = "default"
+	}
+	return sub
+}
+
+// UserSubjects returns --user and --group subjects as the user creating pods
+func (o *SimulateOptions) UserSubjects() []rbacv1.Subject {
+	subs := make([]rbacv1.Subject, 0)
+	if use(o.User) {
+		subs = append(subs, rbacv1.Subject{Kind: "User", APIGroup: rbac.APIGroup, Name: o.User})
+	}
+	for _, g := range o.Groups {
+		subs = append(subs, rbacv1.Subject{Kind: "Group", APIGroup: rbac.APIGroup, Name: g})
+	}
+	return subs
+}
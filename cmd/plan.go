@@ -0,0 +1,87 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jlandowner/psp-util/cmd/options"
+	"github.com/jlandowner/psp-util/pkg/managed"
+	"github.com/jlandowner/psp-util/pkg/policy"
+	"github.com/jlandowner/psp-util/pkg/printers"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+)
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+	planCmd.Flags().StringVarP(&p.Filename, "filename", "f", "", "PSP assignment file")
+	planCmd.Flags().BoolVar(&p.Prune, "prune", false, "detach subjects not declared in the file from managed bindings")
+}
+
+var (
+	p = &options.PlanApplyOptions{}
+
+	planCmd = &cobra.Command{
+		Use:               "plan -f FILENAME",
+		Short:             "Show changes of managed RBACs required by the PSP assignment file",
+		PersistentPreRunE: p.PreRunE,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			k8sclient, err := newClient(kubeconfigPath, kubecontext)
+			if err != nil {
+				return fmt.Errorf("Failed to load kubeconfig %v: %v", kubeconfigPath, err.Error())
+			}
+
+			plan, err := newAssignmentPlan(ctx, k8sclient, p)
+			if err != nil {
+				return err
+			}
+			printers.PrintPlan(os.Stdout, plan)
+			return nil
+		},
+	}
+)
+
+// newAssignmentPlan computes the changes from managed RBACs in cluster to the assignment file
+func newAssignmentPlan(ctx context.Context, k8sclient kubernetes.Interface, o *options.PlanApplyOptions) (*managed.Plan, error) {
+	assignment, err := managed.LoadAssignment(o.Filename)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load %s: %v", o.Filename, err)
+	}
+
+	pspList, err := policy.ListPSP(ctx, k8sclient)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list PSPs: %v", err)
+	}
+	exists := make(map[string]bool)
+	for _, psp := range pspList.Items {
+		exists[psp.Name] = true
+	}
+	for _, name := range assignment.PSPNames() {
+		if !exists[name] {
+			return nil, fmt.Errorf("PSP %s is not found. See `psp-util tree`", name)
+		}
+	}
+
+	state, err := managed.GetState(ctx, k8sclient)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get managed RBACs: %v", err)
+	}
+	return managed.NewPlan(state, assignment.Desired(), o.Prune), nil
+}
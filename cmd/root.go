@@ -19,13 +19,20 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/jlandowner/psp-util/pkg/client"
 	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
 )
 
 var (
 	kubeconfigPath string
 	kubecontext    string
 
+	// newClient returns the client of the kubeconfig and context. It is replaced with a fake clientset in tests
+	newClient = func(kubeconfig, kubectx string) (kubernetes.Interface, error) {
+		return client.NewClient(&kubeconfig, &kubectx)
+	}
+
 	rootCmd = &cobra.Command{
 		Use:   "psp-util",
 		Short: "Utility to manage Pod Security Policy(PSP) and the related RBAC Resources",
@@ -0,0 +1,126 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jlandowner/psp-util/cmd/options"
+	"github.com/jlandowner/psp-util/pkg/admission"
+	"github.com/jlandowner/psp-util/pkg/manifests"
+	"github.com/jlandowner/psp-util/pkg/printers"
+	"github.com/jlandowner/psp-util/pkg/relations"
+	"github.com/spf13/cobra"
+	policyv1 "k8s.io/api/policy/v1beta1"
+)
+
+func init() {
+	rootCmd.AddCommand(simulateCmd)
+	simulateCmd.Flags().StringVarP(&s.Filename, "filename", "f", "", "Pod or workload manifest file or directory to simulate")
+	simulateCmd.Flags().StringVar(&s.FromFiles, "from-files", "", "load PSPs and RBACs from manifest file or directory instead of cluster. \"-\" reads from stdin")
+	simulateCmd.Flags().StringVarP(&s.Namespace, "namespace", "n", "", "namespace of Pods without namespace in the manifests (default: default)")
+	simulateCmd.Flags().StringVarP(&s.ServiceAccount, "sa", "s", "", "ServiceAccount (NAME or NAMESPACE/NAME) of Pods (default: spec.serviceAccountName)")
+	simulateCmd.Flags().StringVarP(&s.User, "user", "u", "", "User creating Pods")
+	simulateCmd.Flags().StringSliceVarP(&s.Groups, "group", "g", nil, "Groups of the User creating Pods")
+	simulateCmd.Flags().StringVarP(&s.Output, "output", "o", "", "output format. One of: json|yaml")
+}
+
+var (
+	s = &options.SimulateOptions{}
+
+	simulateCmd = &cobra.Command{
+		Use:               "simulate -f FILENAME [ --sa SERVICEACCOUNT ] [ --user USER --group GROUP ]",
+		Short:             "Simulate which PSP would admit Pods in manifests and the mutations it applies",
+		PersistentPreRunE: s.PreRunE,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			objs, err := manifests.Load(s.Filename)
+			if err != nil {
+				return fmt.Errorf("Failed to load %s: %v", s.Filename, err)
+			}
+			targets, err := admission.ExtractTargets(objs.Others)
+			if err != nil {
+				return err
+			}
+			if len(targets) == 0 {
+				return fmt.Errorf("No Pod or Pod template is found in %s", s.Filename)
+			}
+
+			psps, err := getRelationalPSPs(ctx, s.FromFiles)
+			if err != nil {
+				return err
+			}
+
+			results := make([]printers.SimulationResult, 0, len(targets))
+			rejected := 0
+			for _, target := range targets {
+				target.Namespace = s.PodNamespace(target.Namespace)
+				target.Pod.Namespace = target.Namespace
+
+				serviceAccountName := target.Pod.Spec.ServiceAccountName
+				if serviceAccountName == "" {
+					serviceAccountName = target.Pod.Spec.DeprecatedServiceAccount
+				}
+				sa := s.ServiceAccountSubject(target.Namespace, serviceAccountName)
+				subjects := append(s.UserSubjects(), sa)
+
+				usable := relations.GetUsablePSPs(psps, subjects, target.Namespace)
+				usablePSPs := make([]policyv1.PodSecurityPolicy, len(usable))
+				usableNames := make(map[string]bool)
+				for i, psp := range usable {
+					usablePSPs[i] = psp.PodSecurityPolicy
+					usableNames[psp.Name] = true
+				}
+				notPermitted := make([]string, 0)
+				for _, psp := range psps {
+					if !usableNames[psp.Name] {
+						notPermitted = append(notPermitted, psp.Name)
+					}
+				}
+
+				result := admission.Simulate(target.Pod, usablePSPs)
+				if !result.Allowed {
+					rejected++
+				}
+				results = append(results, printers.SimulationResult{
+					Target:           target,
+					ServiceAccount:   fmt.Sprintf("%s/%s", sa.Namespace, sa.Name),
+					User:             s.User,
+					Groups:           s.Groups,
+					NotPermittedPSPs: notPermitted,
+					Result:           result,
+				})
+			}
+
+			if printers.IsStructuredOutput(s.Output) {
+				if err := printers.PrintObject(os.Stdout, printers.NewSimulationResultList(results), s.Output); err != nil {
+					return err
+				}
+			} else {
+				printers.PrintSimulationResults(os.Stdout, results)
+			}
+
+			if rejected > 0 {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("%d of %d Pods would be rejected", rejected, len(results))
+			}
+			return nil
+		},
+	}
+)
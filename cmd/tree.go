@@ -21,69 +21,188 @@ import (
 	"os"
 
 	"github.com/disiqueira/gotree"
-	"github.com/jlandowner/psp-util/pkg/client"
+	"github.com/jlandowner/psp-util/cmd/options"
 	"github.com/jlandowner/psp-util/pkg/printers"
 	"github.com/jlandowner/psp-util/pkg/relations"
+	"github.com/jlandowner/psp-util/pkg/utils"
 	"github.com/spf13/cobra"
+	rbacv1 "k8s.io/api/rbac/v1"
 )
 
 func init() {
 	rootCmd.AddCommand(treeCmd)
+	treeCmd.Flags().StringVarP(&t.Output, "output", "o", "", "output format. One of: json|yaml")
+	treeCmd.Flags().StringVar(&t.FromFiles, "from-files", "", "load PSPs and RBACs from manifest file or directory instead of cluster. \"-\" reads from stdin")
+	treeCmd.Flags().BoolVar(&t.Expand, "expand", false, "list ServiceAccounts included in system:serviceaccounts groups and warn grants to every user")
+	addClusterFlags(treeCmd, &t.ClusterOptions)
 }
 
-var treeCmd = &cobra.Command{
-	Use:   "tree",
-	Short: "View a relational tree between PSP and Subjects in cluster",
-	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx := context.Background()
-		k8sclient, err := client.NewClient(&kubeconfigPath, &kubecontext)
-		if err != nil {
-			return fmt.Errorf("Failed to load kubeconfig %v: %v", kubeconfigPath, err.Error())
-		}
+var (
+	t = &options.TreeOptions{}
 
-		psps, err := relations.GetRelationalPSPs(ctx, k8sclient)
-		if err != nil {
-			return err
-		}
+	treeCmd = &cobra.Command{
+		Use:               "tree",
+		Short:             "View a relational tree between PSP and Subjects in cluster",
+		PersistentPreRunE: t.PreRunE,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			if t.IsMultiCluster() {
+				return treeClusters(ctx, cmd)
+			}
+
+			objs, err := getObjects(ctx, t.FromFiles)
+			if err != nil {
+				return err
+			}
+			psps := relations.GetRelationalPSPsFromManifests(objs)
+			dangling := relations.GetDanglingReferences(objs)
 
-		w := os.Stdout
-		for _, psp := range psps {
-			pspTree := gotree.New(fmt.Sprintf("📙 PSP "+printers.GreenString, psp.Name))
-			for _, cr := range psp.ClusterRoles {
-				crTree := gotree.New(fmt.Sprintf("📕 ClusterRole "+printers.GreenString, cr.Name))
-				for _, crb := range cr.ClusterRoleBindings {
-					crbTree := gotree.New(fmt.Sprintf("📘 ClusterRoleBinding "+printers.GreenString, crb.Name))
-					for _, sub := range crb.Subjects {
-						crbTree.Add(fmt.Sprintf("📗 Subject{Kind: "+printers.CianString+", Name: "+printers.RedString+", Namespace: "+printers.BlueString+"}", sub.Kind, sub.Name, sub.Namespace))
-					}
-					crTree.AddTree(crbTree)
+			var e *relations.SubjectExpander
+			if t.Expand {
+				e, err = getSubjectExpander(ctx, t.FromFiles, objs)
+				if err != nil {
+					return err
 				}
-				for _, rb := range cr.RoleBindings {
-					rbname := fmt.Sprintf("%v/%v", rb.Namespace, rb.Name)
-					rbTree := gotree.New(fmt.Sprintf("📓 RoleBinding "+printers.GreenString, rbname))
-					for _, sub := range rb.Subjects {
-						rbTree.Add(fmt.Sprintf("📗 Subject{Kind: "+printers.CianString+", Name: "+printers.RedString+", Namespace: "+printers.BlueString+"}", sub.Kind, sub.Name, sub.Namespace))
-					}
-					crTree.AddTree(rbTree)
+			}
+
+			if printers.IsStructuredOutput(t.Output) {
+				list := printers.NewRelationList(psps, true, true)
+				list.Dangling = dangling
+				if e != nil {
+					list.Expand(e)
 				}
-				pspTree.AddTree(crTree)
+				return printers.PrintObject(os.Stdout, list, t.Output)
+			}
+
+			for _, tree := range relationTrees(psps, dangling, e) {
+				fmt.Fprintln(os.Stdout, tree.Print())
 			}
-			for _, r := range psp.Roles {
-				rname := fmt.Sprintf("%v/%v", r.Namespace, r.Name)
-				rTree := gotree.New(fmt.Sprintf("📓 Role "+printers.GreenString, rname))
-				for _, rb := range r.RoleBindings {
-					rbname := fmt.Sprintf("%v/%v", r.Namespace, rb.Name)
-					rbTree := gotree.New(fmt.Sprintf("📓 RoleBinding "+printers.GreenString, rbname))
-					for _, sub := range rb.Subjects {
-						rbTree.Add(fmt.Sprintf("📗 Subject{Kind: "+printers.CianString+", Name: "+printers.RedString+", Namespace: "+printers.BlueString+"}", sub.Kind, sub.Name, sub.Namespace))
-					}
-					rTree.AddTree(rbTree)
+			return nil
+		},
+	}
+)
+
+// relationTrees returns a tree per PSP and the tree of the dangling references if any
+func relationTrees(psps []relations.RelationalPodSecurityPolicy, dangling *relations.DanglingReferences, e *relations.SubjectExpander) []gotree.Tree {
+	trees := make([]gotree.Tree, 0, len(psps)+1)
+	for _, psp := range psps {
+		pspTree := gotree.New(fmt.Sprintf("📙 PSP "+printers.GreenString, psp.Name))
+		for _, cr := range psp.ClusterRoles {
+			crTree := gotree.New(fmt.Sprintf("📕 ClusterRole "+printers.GreenString, cr.Name) + managedMark(cr.Annotations) + printers.WildcardMark(cr.Wildcard) + printers.AggregationMark(cr.AggregationPath))
+			for _, crb := range cr.ClusterRoleBindings {
+				crbTree := gotree.New(fmt.Sprintf("📘 ClusterRoleBinding "+printers.GreenString, crb.Name) + managedMark(crb.Annotations))
+				for _, sub := range crb.Subjects {
+					crbTree.AddTree(subjectTree(sub, e))
 				}
-				pspTree.AddTree(rTree)
+				crTree.AddTree(crbTree)
 			}
-			fmt.Fprintln(w, pspTree.Print())
+			for _, rb := range cr.RoleBindings {
+				rbname := fmt.Sprintf("%v/%v", rb.Namespace, rb.Name)
+				rbTree := gotree.New(fmt.Sprintf("📓 RoleBinding "+printers.GreenString, rbname) + managedMark(rb.Annotations))
+				for _, sub := range rb.Subjects {
+					rbTree.AddTree(subjectTree(sub, e))
+				}
+				crTree.AddTree(rbTree)
+			}
+			pspTree.AddTree(crTree)
+		}
+		for _, r := range psp.Roles {
+			rname := fmt.Sprintf("%v/%v", r.Namespace, r.Name)
+			rTree := gotree.New(fmt.Sprintf("📓 Role "+printers.GreenString, rname) + printers.WildcardMark(r.Wildcard))
+			for _, rb := range r.RoleBindings {
+				rbname := fmt.Sprintf("%v/%v", r.Namespace, rb.Name)
+				rbTree := gotree.New(fmt.Sprintf("📓 RoleBinding "+printers.GreenString, rbname))
+				for _, sub := range rb.Subjects {
+					rbTree.AddTree(subjectTree(sub, e))
+				}
+				rTree.AddTree(rbTree)
+			}
+			pspTree.AddTree(rTree)
+		}
+		trees = append(trees, pspTree)
+	}
+
+	if !dangling.IsEmpty() {
+		danglingTree := gotree.New("⚠️  Dangling references")
+		for _, l := range printers.DanglingLines(dangling) {
+			danglingTree.Add(fmt.Sprintf("%s "+printers.GreenString+" -> missing "+printers.RedString, l[0], l[1], l[2]))
 		}
-		return nil
+		trees = append(trees, danglingTree)
+	}
+	return trees
+}
+
+// treeClusters prints the relational trees under the node of each cluster
+func treeClusters(ctx context.Context, cmd *cobra.Command) error {
+	clusters, err := getClustersObjects(ctx, &t.ClusterOptions, t.Expand)
+	if err != nil {
+		return err
+	}
+
+	results := make([]printers.ClusterResult, len(clusters))
+	for i, c := range clusters {
+		if c.Err != nil {
+			results[i] = printers.NewClusterError(c.Cluster, c.Err)
+			continue
+		}
+		list := printers.NewRelationList(relations.GetRelationalPSPsFromManifests(c.Objects), true, true)
+		list.Dangling = relations.GetDanglingReferences(c.Objects)
+		if c.Expander != nil {
+			list.Expand(c.Expander)
+		}
+		results[i] = printers.ClusterResult{Cluster: c.Cluster, Result: list}
+	}
+
+	if printers.IsStructuredOutput(t.Output) {
+		if err := printers.PrintObject(os.Stdout, printers.NewClusterResultList(results), t.Output); err != nil {
+			return err
+		}
+		return clusterErrors(cmd, results)
+	}
+
+	for _, c := range clusters {
+		clusterTree := gotree.New(fmt.Sprintf("☸️  Cluster "+printers.GreenString, c.Cluster))
+		if c.Err != nil {
+			clusterTree.Add(fmt.Sprintf("⚠️  "+printers.RedString, c.Err.Error()))
+		} else {
+			psps := relations.GetRelationalPSPsFromManifests(c.Objects)
+			for _, tree := range relationTrees(psps, relations.GetDanglingReferences(c.Objects), c.Expander) {
+				clusterTree.AddTree(tree)
+			}
+		}
+		fmt.Fprintln(os.Stdout, clusterTree.Print())
+	}
+	return clusterErrors(cmd, results)
+}
+
+// managedMark returns a marker for resources generated by psp-util
+func managedMark(annotations map[string]string) string {
+	if utils.IsManaged(annotations) {
+		return " (managed)"
+	}
+	return ""
+}
+
+// subjectTree returns the subject node. If the expander is given,
+// the ServiceAccounts included in the group are added as the children and every user groups are warned
+func subjectTree(sub rbacv1.Subject, e *relations.SubjectExpander) gotree.Tree {
+	if e == nil {
+		return gotree.New(formatSubjectNode(sub))
+	}
+
+	text := formatSubjectNode(sub)
+	if warning := relations.ImplicitGroupWarning(sub); warning != "" {
+		text += fmt.Sprintf(" ⚠️  "+printers.RedString, warning)
+	}
+	subTree := gotree.New(text)
+	if sas, ok := e.Expand(sub); ok {
+		for _, sa := range sas {
+			subTree.Add(formatSubjectNode(sa))
+		}
+	}
+	return subTree
+}
 
-	},
+func formatSubjectNode(sub rbacv1.Subject) string {
+	return fmt.Sprintf("📗 Subject{Kind: "+printers.CianString+", Name: "+printers.RedString+", Namespace: "+printers.BlueString+"}", sub.Kind, sub.Name, sub.Namespace)
 }
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTree(t *testing.T) {
+	tests := []struct {
+		title          string
+		args           []string
+		expectOut      string
+		expectContains []string
+		expectErr      bool
+	}{
+		{
+			title: "tree",
+			args:  []string{"tree"},
+			expectOut: `📙 PSP restricted
+└── 📕 ClusterRole psp-util.restricted (managed)
+│   ├── 📘 ClusterRoleBinding psp-util.restricted (managed)
+│   │   ├── 📗 Subject{Kind: Group, Name: system:authenticated, Namespace: }
+│   ├── 📓 RoleBinding team-a/psp-util.restricted (managed)
+│       └── 📗 Subject{Kind: ServiceAccount, Name: app, Namespace: team-a}
+└── 📓 Role team-b/use-restricted
+    └── 📓 RoleBinding team-b/use-restricted
+        └── 📗 Subject{Kind: User, Name: alice, Namespace: }
+
+`,
+		},
+		{
+			title:          "yaml",
+			args:           []string{"tree", "-o", "yaml"},
+			expectContains: []string{"kind: RelationList", "name: psp-util.restricted", "namespace: team-b", "name: alice"},
+		},
+		{
+			title:     "invalid output",
+			args:      []string{"tree", "-o", "wide"},
+			expectErr: true,
+		},
+	}
+
+	k8sclient := newTestClient(append(newManagedObjects(), newUnmanagedObjects()...)...)
+	for _, test := range tests {
+		t.Log(test.title)
+		out, _, err := execute(t, k8sclient, test.args...)
+		if test.expectErr {
+			assert.Error(t, err)
+			continue
+		}
+		assert.NoError(t, err)
+		assertOutput(t, test.expectOut, test.expectContains, out)
+	}
+}
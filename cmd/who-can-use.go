@@ -0,0 +1,116 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jlandowner/psp-util/cmd/options"
+	"github.com/jlandowner/psp-util/pkg/printers"
+	"github.com/jlandowner/psp-util/pkg/relations"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(whoCanUseCmd)
+	whoCanUseCmd.Flags().BoolVar(&w.NoHeader, "no-headers", false, "output without header")
+	whoCanUseCmd.Flags().StringVarP(&w.Output, "output", "o", "", "output format. One of: json|yaml")
+	whoCanUseCmd.Flags().StringVar(&w.FromFiles, "from-files", "", "load PSPs and RBACs from manifest file or directory instead of cluster. \"-\" reads from stdin")
+	whoCanUseCmd.Flags().BoolVar(&w.Expand, "expand", false, "list ServiceAccounts included in system:serviceaccounts groups and warn grants to every user")
+	addClusterFlags(whoCanUseCmd, &w.ClusterOptions)
+}
+
+var (
+	w = &options.WhoCanUseOptions{}
+
+	whoCanUseCmd = &cobra.Command{
+		Use:               "who-can-use PSP-NAME",
+		Short:             "List Subjects permitted to use the PSP and the RBACs granting it",
+		PersistentPreRunE: w.PreRunE,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			if w.IsMultiCluster() {
+				return whoCanUseClusters(ctx, cmd)
+			}
+
+			objs, err := getObjects(ctx, w.FromFiles)
+			if err != nil {
+				return err
+			}
+			psps := relations.GetRelationalPSPsFromManifests(objs)
+
+			psp, ok := relations.FindRelationalPSP(psps, w.PSPName)
+			if !ok {
+				return fmt.Errorf("PSP %s is not found. See `psp-util tree`", w.PSPName)
+			}
+
+			list := printers.NewSubjectGrantList(*psp)
+			if w.Expand {
+				e, err := getSubjectExpander(ctx, w.FromFiles, objs)
+				if err != nil {
+					return err
+				}
+				list.Items = e.ExpandGrants(list.Items)
+			}
+
+			if printers.IsStructuredOutput(w.Output) {
+				return printers.PrintObject(os.Stdout, list, w.Output)
+			}
+			return printers.PrintSubjectGrants(os.Stdout, list.Items, w.NoHeader)
+		},
+	}
+)
+
+// whoCanUseClusters lists the subjects permitted to use the PSP in every cluster with the cluster column.
+// The clusters without the PSP are reported as failed
+func whoCanUseClusters(ctx context.Context, cmd *cobra.Command) error {
+	clusters, err := getClustersObjects(ctx, &w.ClusterOptions, w.Expand)
+	if err != nil {
+		return err
+	}
+
+	results := make([]printers.ClusterResult, len(clusters))
+	grants := make([]printers.ClusterSubjectGrants, 0, len(clusters))
+	for i, c := range clusters {
+		if c.Err != nil {
+			results[i] = printers.NewClusterError(c.Cluster, c.Err)
+			continue
+		}
+		psp, ok := relations.FindRelationalPSP(relations.GetRelationalPSPsFromManifests(c.Objects), w.PSPName)
+		if !ok {
+			results[i] = printers.NewClusterError(c.Cluster, fmt.Errorf("PSP %s is not found", w.PSPName))
+			continue
+		}
+
+		list := printers.NewSubjectGrantList(*psp)
+		if c.Expander != nil {
+			list.Items = c.Expander.ExpandGrants(list.Items)
+		}
+		results[i] = printers.ClusterResult{Cluster: c.Cluster, Result: list}
+		grants = append(grants, printers.ClusterSubjectGrants{Cluster: c.Cluster, Grants: list.Items})
+	}
+
+	if printers.IsStructuredOutput(w.Output) {
+		if err := printers.PrintObject(os.Stdout, printers.NewClusterResultList(results), w.Output); err != nil {
+			return err
+		}
+	} else if err := printers.PrintClusterSubjectGrants(os.Stdout, grants, w.NoHeader); err != nil {
+		return err
+	}
+	return clusterErrors(cmd, results)
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admission
+
+import (
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1beta1"
+)
+
+// Mutation is a field of the pod defaulted by a PSP
+type Mutation struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+// Evaluation is the result of validating a pod against a PSP
+type Evaluation struct {
+	PSP        string     `json:"psp"`
+	Allowed    bool       `json:"allowed"`
+	Mutations  []Mutation `json:"mutations"`
+	Violations []string   `json:"violations"`
+}
+
+// Result is the result of simulating admission of a pod
+type Result struct {
+	Allowed bool `json:"allowed"`
+	// PSP is the name of the PSP selected to admit the pod
+	PSP         string       `json:"psp,omitempty"`
+	Mutations   []Mutation   `json:"mutations"`
+	Evaluations []Evaluation `json:"evaluations"`
+
+	// MutatedPod is the pod with the defaults of the selected PSP
+	MutatedPod *corev1.Pod `json:"-"`
+}
+
+// Evaluate defaults and validates the pod against the PSP
+func Evaluate(psp *policyv1.PodSecurityPolicy, pod *corev1.Pod) (Evaluation, *corev1.Pod) {
+	p := newProvider(psp)
+	mutated := pod.DeepCopy()
+	p.DefaultPod(mutated)
+	errs := p.ValidatePod(mutated)
+
+	e := Evaluation{
+		PSP:        psp.Name,
+		Allowed:    len(errs) == 0,
+		Mutations:  p.mutations,
+		Violations: make([]string, len(errs)),
+	}
+	for i, err := range errs {
+		e.Violations[i] = err.Error()
+	}
+	return e, mutated
+}
+
+// Simulate selects the PSP to admit the pod from the given PSPs the pod is authorized to use,
+// in the same order as the PodSecurityPolicy admission controller.
+// A PSP allowing the pod without mutation is preferred, or the first PSP by name allowing the pod is selected.
+func Simulate(pod *corev1.Pod, psps []policyv1.PodSecurityPolicy) *Result {
+	sorted := make([]policyv1.PodSecurityPolicy, len(psps))
+	copy(sorted, psps)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	result := &Result{
+		Mutations:   make([]Mutation, 0),
+		Evaluations: make([]Evaluation, 0, len(sorted)),
+	}
+
+	mutating := -1
+	var mutatingPod *corev1.Pod
+	for i := range sorted {
+		e, mutated := Evaluate(&sorted[i], pod)
+		result.Evaluations = append(result.Evaluations, e)
+
+		if !e.Allowed || result.Allowed {
+			continue
+		}
+		if len(e.Mutations) == 0 {
+			result.Allowed = true
+			result.PSP = e.PSP
+			result.MutatedPod = mutated
+			continue
+		}
+		if mutating < 0 {
+			mutating = i
+			mutatingPod = mutated
+		}
+	}
+
+	if !result.Allowed && mutating >= 0 {
+		result.Allowed = true
+		result.PSP = result.Evaluations[mutating].PSP
+		result.Mutations = result.Evaluations[mutating].Mutations
+		result.MutatedPod = mutatingPod
+	}
+	return result
+}
@@ -0,0 +1,230 @@
+package admission
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func boolPtr(b bool) *bool    { return &b }
+func int64Ptr(i int64) *int64 { return &i }
+
+func restrictedPSP(name string) policyv1.PodSecurityPolicy {
+	return policyv1.PodSecurityPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: policyv1.PodSecurityPolicySpec{
+			AllowPrivilegeEscalation: boolPtr(false),
+			RequiredDropCapabilities: []corev1.Capability{"ALL"},
+			Volumes:                  []policyv1.FSType{policyv1.ConfigMap, policyv1.Secret, policyv1.EmptyDir},
+			RunAsUser:                policyv1.RunAsUserStrategyOptions{Rule: policyv1.RunAsUserStrategyMustRunAs, Ranges: []policyv1.IDRange{{Min: 1000, Max: 2000}}},
+			SELinux:                  policyv1.SELinuxStrategyOptions{Rule: policyv1.SELinuxStrategyRunAsAny},
+			SupplementalGroups:       policyv1.SupplementalGroupsStrategyOptions{Rule: policyv1.SupplementalGroupsStrategyRunAsAny},
+			FSGroup:                  policyv1.FSGroupStrategyOptions{Rule: policyv1.FSGroupStrategyRunAsAny},
+			ReadOnlyRootFilesystem:   true,
+		},
+	}
+}
+
+func privilegedPSP(name string) policyv1.PodSecurityPolicy {
+	return policyv1.PodSecurityPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: policyv1.PodSecurityPolicySpec{
+			Privileged:          true,
+			AllowedCapabilities: []corev1.Capability{"*"},
+			Volumes:             []policyv1.FSType{policyv1.All},
+			HostNetwork:         true,
+			HostPorts:           []policyv1.HostPortRange{{Min: 0, Max: 65535}},
+			RunAsUser:           policyv1.RunAsUserStrategyOptions{Rule: policyv1.RunAsUserStrategyRunAsAny},
+			SELinux:             policyv1.SELinuxStrategyOptions{Rule: policyv1.SELinuxStrategyRunAsAny},
+			SupplementalGroups:  policyv1.SupplementalGroupsStrategyOptions{Rule: policyv1.SupplementalGroupsStrategyRunAsAny},
+			FSGroup:             policyv1.FSGroupStrategyOptions{Rule: policyv1.FSGroupStrategyRunAsAny},
+		},
+	}
+}
+
+func testPod(mutate func(*corev1.Pod)) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "c", Image: "busybox"}},
+		},
+	}
+	if mutate != nil {
+		mutate(pod)
+	}
+	return pod
+}
+
+func TestSimulate(t *testing.T) {
+	tests := []struct {
+		title           string
+		pod             *corev1.Pod
+		psps            []policyv1.PodSecurityPolicy
+		expectAllowed   bool
+		expectPSP       string
+		expectMutations []Mutation
+		expectViolation []string
+	}{
+		{
+			title:         "no psp",
+			pod:           testPod(nil),
+			psps:          nil,
+			expectAllowed: false,
+		},
+		{
+			title:         "defaulted by restricted",
+			pod:           testPod(nil),
+			psps:          []policyv1.PodSecurityPolicy{restrictedPSP("restricted")},
+			expectAllowed: true,
+			expectPSP:     "restricted",
+			expectMutations: []Mutation{
+				{Field: "spec.containers[0].securityContext.runAsUser", Value: "1000"},
+				{Field: "spec.containers[0].securityContext.capabilities", Value: "{add: [], drop: [ALL]}"},
+				{Field: "spec.containers[0].securityContext.readOnlyRootFilesystem", Value: "true"},
+				{Field: "spec.containers[0].securityContext.allowPrivilegeEscalation", Value: "false"},
+			},
+		},
+		{
+			title:         "non mutating psp is preferred over the first psp by name",
+			pod:           testPod(nil),
+			psps:          []policyv1.PodSecurityPolicy{restrictedPSP("a-restricted"), privilegedPSP("z-privileged")},
+			expectAllowed: true,
+			expectPSP:     "z-privileged",
+		},
+		{
+			title: "first mutating psp by name",
+			pod: testPod(func(pod *corev1.Pod) {
+				pod.Spec.Containers[0].SecurityContext = &corev1.SecurityContext{RunAsUser: int64Ptr(1500)}
+			}),
+			psps:          []policyv1.PodSecurityPolicy{restrictedPSP("b"), restrictedPSP("a")},
+			expectAllowed: true,
+			expectPSP:     "a",
+			expectMutations: []Mutation{
+				{Field: "spec.containers[0].securityContext.capabilities", Value: "{add: [], drop: [ALL]}"},
+				{Field: "spec.containers[0].securityContext.readOnlyRootFilesystem", Value: "true"},
+				{Field: "spec.containers[0].securityContext.allowPrivilegeEscalation", Value: "false"},
+			},
+		},
+		{
+			title: "rejected",
+			pod: testPod(func(pod *corev1.Pod) {
+				pod.Spec.HostNetwork = true
+				pod.Spec.Volumes = []corev1.Volume{{Name: "host", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/"}}}}
+				pod.Spec.Containers[0].SecurityContext = &corev1.SecurityContext{
+					Privileged:   boolPtr(true),
+					RunAsUser:    int64Ptr(0),
+					Capabilities: &corev1.Capabilities{Add: []corev1.Capability{"NET_ADMIN"}},
+				}
+				pod.Spec.Containers[0].Ports = []corev1.ContainerPort{{ContainerPort: 80, HostPort: 80}}
+			}),
+			psps:          []policyv1.PodSecurityPolicy{restrictedPSP("restricted")},
+			expectAllowed: false,
+			expectViolation: []string{
+				`spec.securityContext.hostNetwork: Invalid value: true: Host network is not allowed to be used`,
+				`spec.volumes[0]: Invalid value: "hostPath": hostPath volumes are not allowed to be used`,
+				`spec.containers[0].securityContext.runAsUser: Invalid value: 0: must be in the ranges: [{1000 2000}]`,
+				`spec.containers[0].securityContext.privileged: Invalid value: true: Privileged containers are not allowed`,
+				`spec.containers[0].securityContext.capabilities.add: Invalid value: "NET_ADMIN": capability may not be added`,
+				`spec.containers[0].ports[0].hostPort: Invalid value: 80: Host port 80 is not allowed to be used. Allowed ports: []`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		result := Simulate(test.pod, test.psps)
+		assert.Equal(t, test.expectAllowed, result.Allowed)
+		assert.Equal(t, test.expectPSP, result.PSP)
+		if test.expectMutations == nil {
+			test.expectMutations = []Mutation{}
+		}
+		assert.Equal(t, test.expectMutations, result.Mutations)
+		if test.expectViolation != nil {
+			assert.Equal(t, test.expectViolation, result.Evaluations[0].Violations)
+		}
+	}
+}
+
+func TestEvaluateHostPath(t *testing.T) {
+	psp := privilegedPSP("hostpath")
+	psp.Spec.AllowedHostPaths = []policyv1.AllowedHostPath{
+		{PathPrefix: "/var/log", ReadOnly: true},
+		{PathPrefix: "/data"},
+	}
+
+	tests := []struct {
+		title           string
+		path            string
+		readOnly        bool
+		expectViolation []string
+	}{
+		{
+			title:    "readonly prefix",
+			path:     "/var/log/pods",
+			readOnly: true,
+		},
+		{
+			title:           "readonly prefix without readOnly mount",
+			path:            "/var/log",
+			expectViolation: []string{`spec.containers[0].volumeMounts[0].readOnly: Invalid value: false: must be read-only`},
+		},
+		{
+			title:           "not segment boundary",
+			path:            "/database",
+			expectViolation: []string{`spec.volumes[0].hostPath.pathPrefix: Invalid value: "/database": is not allowed to be used`},
+		},
+		{
+			title: "writable prefix",
+			path:  "/data/app",
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		pod := testPod(func(pod *corev1.Pod) {
+			pod.Spec.Volumes = []corev1.Volume{{Name: "host", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: test.path}}}}
+			pod.Spec.Containers[0].VolumeMounts = []corev1.VolumeMount{{Name: "host", MountPath: "/host", ReadOnly: test.readOnly}}
+		})
+		e, _ := Evaluate(&psp, pod)
+		if test.expectViolation == nil {
+			test.expectViolation = []string{}
+		}
+		assert.Equal(t, test.expectViolation, e.Violations)
+	}
+}
+
+func TestExtractTargets(t *testing.T) {
+	deploy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "team-a"},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"serviceAccountName": "web",
+					"containers":         []interface{}{map[string]interface{}{"name": "web", "image": "nginx"}},
+				},
+			},
+		},
+	}}
+	pod := testPod(nil)
+	pod.Kind = "Pod"
+	cm := &corev1.ConfigMap{TypeMeta: metav1.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"}}
+
+	targets, err := ExtractTargets([]runtime.Object{deploy, pod, cm})
+	assert.Nil(t, err)
+	assert.Len(t, targets, 2)
+
+	assert.Equal(t, "Deployment", targets[0].Kind)
+	assert.Equal(t, "spec.template", targets[0].TemplatePath)
+	assert.Equal(t, "team-a", targets[0].Pod.Namespace)
+	assert.Equal(t, "web", targets[0].Pod.Spec.ServiceAccountName)
+
+	assert.Equal(t, "Pod", targets[1].Kind)
+	assert.Equal(t, "", targets[1].TemplatePath)
+}
@@ -0,0 +1,642 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admission
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jlandowner/psp-util/pkg/policy"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// provider defaults and validates a pod against a PSP
+// in the same manner as the PodSecurityPolicy admission controller.
+type provider struct {
+	psp       *policyv1.PodSecurityPolicy
+	mutations []Mutation
+}
+
+type containerRef struct {
+	container *corev1.Container
+	path      *field.Path
+}
+
+func newProvider(psp *policyv1.PodSecurityPolicy) *provider {
+	return &provider{psp: psp, mutations: make([]Mutation, 0)}
+}
+
+func (p *provider) mutate(path fmt.Stringer, value interface{}) {
+	p.mutations = append(p.mutations, Mutation{Field: path.String(), Value: formatValue(value)})
+}
+
+func podContainers(pod *corev1.Pod) []containerRef {
+	refs := make([]containerRef, 0, len(pod.Spec.InitContainers)+len(pod.Spec.Containers))
+	for i := range pod.Spec.InitContainers {
+		refs = append(refs, containerRef{&pod.Spec.InitContainers[i], field.NewPath("spec", "initContainers").Index(i)})
+	}
+	for i := range pod.Spec.Containers {
+		refs = append(refs, containerRef{&pod.Spec.Containers[i], field.NewPath("spec", "containers").Index(i)})
+	}
+	return refs
+}
+
+// DefaultPod sets the defaults of the PSP to the pod and the containers
+func (p *provider) DefaultPod(pod *corev1.Pod) {
+	spec := p.psp.Spec
+	scPath := field.NewPath("spec", "securityContext")
+
+	podSC := func() *corev1.PodSecurityContext {
+		if pod.Spec.SecurityContext == nil {
+			pod.Spec.SecurityContext = &corev1.PodSecurityContext{}
+		}
+		return pod.Spec.SecurityContext
+	}
+
+	if pod.Spec.SecurityContext == nil || len(pod.Spec.SecurityContext.SupplementalGroups) == 0 {
+		if spec.SupplementalGroups.Rule == policyv1.SupplementalGroupsStrategyMustRunAs && len(spec.SupplementalGroups.Ranges) > 0 {
+			podSC().SupplementalGroups = []int64{spec.SupplementalGroups.Ranges[0].Min}
+			p.mutate(scPath.Child("supplementalGroups"), podSC().SupplementalGroups)
+		}
+	}
+
+	if pod.Spec.SecurityContext == nil || pod.Spec.SecurityContext.FSGroup == nil {
+		if spec.FSGroup.Rule == policyv1.FSGroupStrategyMustRunAs && len(spec.FSGroup.Ranges) > 0 {
+			fsGroup := spec.FSGroup.Ranges[0].Min
+			podSC().FSGroup = &fsGroup
+			p.mutate(scPath.Child("fsGroup"), fsGroup)
+		}
+	}
+
+	if pod.Spec.SecurityContext == nil || pod.Spec.SecurityContext.SELinuxOptions == nil {
+		if spec.SELinux.Rule == policyv1.SELinuxStrategyMustRunAs && spec.SELinux.SELinuxOptions != nil {
+			podSC().SELinuxOptions = spec.SELinux.SELinuxOptions.DeepCopy()
+			p.mutate(scPath.Child("seLinuxOptions"), podSC().SELinuxOptions)
+		}
+	}
+
+	if profile, ok := p.psp.Annotations[policy.SeccompDefaultProfileAnnotationKey]; ok {
+		if _, ok := pod.Annotations[policy.SeccompPodAnnotationKey]; !ok {
+			p.setPodAnnotation(pod, policy.SeccompPodAnnotationKey, profile)
+		}
+	}
+
+	for _, ref := range podContainers(pod) {
+		p.defaultContainer(pod, ref)
+	}
+}
+
+func (p *provider) defaultContainer(pod *corev1.Pod, ref containerRef) {
+	spec := p.psp.Spec
+	c := ref.container
+	scPath := ref.path.Child("securityContext")
+
+	sc := func() *corev1.SecurityContext {
+		if c.SecurityContext == nil {
+			c.SecurityContext = &corev1.SecurityContext{}
+		}
+		return c.SecurityContext
+	}
+
+	if effectiveRunAsUser(pod, c) == nil {
+		if spec.RunAsUser.Rule == policyv1.RunAsUserStrategyMustRunAs && len(spec.RunAsUser.Ranges) > 0 {
+			uid := spec.RunAsUser.Ranges[0].Min
+			sc().RunAsUser = &uid
+			p.mutate(scPath.Child("runAsUser"), uid)
+		}
+	}
+
+	if spec.RunAsGroup != nil && effectiveRunAsGroup(pod, c) == nil {
+		if spec.RunAsGroup.Rule == policyv1.RunAsGroupStrategyMustRunAs && len(spec.RunAsGroup.Ranges) > 0 {
+			gid := spec.RunAsGroup.Ranges[0].Min
+			sc().RunAsGroup = &gid
+			p.mutate(scPath.Child("runAsGroup"), gid)
+		}
+	}
+
+	if profile, ok := p.psp.Annotations[policy.AppArmorDefaultProfileAnnotationKey]; ok {
+		key := policy.AppArmorContainerAnnotationKeyPrefix + c.Name
+		if _, ok := pod.Annotations[key]; !ok {
+			p.setPodAnnotation(pod, key, profile)
+		}
+	}
+
+	// the marker that the container should not run as root which is checked by kubelet on the image UID
+	if effectiveRunAsNonRoot(pod, c) == nil && effectiveRunAsUser(pod, c) == nil &&
+		spec.RunAsUser.Rule == policyv1.RunAsUserStrategyMustRunAsNonRoot {
+		nonRoot := true
+		sc().RunAsNonRoot = &nonRoot
+		p.mutate(scPath.Child("runAsNonRoot"), nonRoot)
+	}
+
+	if caps := p.generateCapabilities(c); caps != nil {
+		sc().Capabilities = caps
+		p.mutate(scPath.Child("capabilities"), caps)
+	}
+
+	if spec.ReadOnlyRootFilesystem && (c.SecurityContext == nil || c.SecurityContext.ReadOnlyRootFilesystem == nil) {
+		readOnly := true
+		sc().ReadOnlyRootFilesystem = &readOnly
+		p.mutate(scPath.Child("readOnlyRootFilesystem"), readOnly)
+	}
+
+	if spec.DefaultAllowPrivilegeEscalation != nil && (c.SecurityContext == nil || c.SecurityContext.AllowPrivilegeEscalation == nil) {
+		allow := *spec.DefaultAllowPrivilegeEscalation
+		sc().AllowPrivilegeEscalation = &allow
+		p.mutate(scPath.Child("allowPrivilegeEscalation"), allow)
+	}
+	if !policy.AllowPrivilegeEscalation(spec) && (c.SecurityContext == nil || c.SecurityContext.AllowPrivilegeEscalation == nil) {
+		allow := false
+		sc().AllowPrivilegeEscalation = &allow
+		p.mutate(scPath.Child("allowPrivilegeEscalation"), allow)
+	}
+}
+
+// generateCapabilities returns capabilities with defaultAddCapabilities and requiredDropCapabilities,
+// or nil if nothing is changed
+func (p *provider) generateCapabilities(c *corev1.Container) *corev1.Capabilities {
+	defaultAdd := capSet(p.psp.Spec.DefaultAddCapabilities)
+	requiredDrop := capSet(p.psp.Spec.RequiredDropCapabilities)
+	containerAdd := sets.NewString()
+	containerDrop := sets.NewString()
+
+	if c.SecurityContext != nil && c.SecurityContext.Capabilities != nil {
+		containerAdd = capSet(c.SecurityContext.Capabilities.Add)
+		containerDrop = capSet(c.SecurityContext.Capabilities.Drop)
+	}
+
+	// remove any default adds that the container is specifically dropping
+	defaultAdd = defaultAdd.Difference(containerDrop)
+
+	combinedAdd := defaultAdd.Union(containerAdd)
+	combinedDrop := requiredDrop.Union(containerDrop)
+
+	if combinedAdd.Len() == containerAdd.Len() && combinedDrop.Len() == containerDrop.Len() {
+		return nil
+	}
+	return &corev1.Capabilities{
+		Add:  capList(combinedAdd),
+		Drop: capList(combinedDrop),
+	}
+}
+
+func (p *provider) setPodAnnotation(pod *corev1.Pod, key, value string) {
+	if pod.Annotations == nil {
+		pod.Annotations = make(map[string]string)
+	}
+	pod.Annotations[key] = value
+	p.mutate(field.NewPath("metadata", "annotations").Key(key), value)
+}
+
+// ValidatePod validates the pod and the containers against the PSP
+func (p *provider) ValidatePod(pod *corev1.Pod) field.ErrorList {
+	spec := p.psp.Spec
+	allErrs := field.ErrorList{}
+	scPath := field.NewPath("spec", "securityContext")
+
+	sc := pod.Spec.SecurityContext
+	if sc == nil {
+		sc = &corev1.PodSecurityContext{}
+	}
+
+	if !spec.HostNetwork && pod.Spec.HostNetwork {
+		allErrs = append(allErrs, field.Invalid(scPath.Child("hostNetwork"), pod.Spec.HostNetwork, "Host network is not allowed to be used"))
+	}
+	if !spec.HostPID && pod.Spec.HostPID {
+		allErrs = append(allErrs, field.Invalid(scPath.Child("hostPID"), pod.Spec.HostPID, "Host PID is not allowed to be used"))
+	}
+	if !spec.HostIPC && pod.Spec.HostIPC {
+		allErrs = append(allErrs, field.Invalid(scPath.Child("hostIPC"), pod.Spec.HostIPC, "Host IPC is not allowed to be used"))
+	}
+
+	var fsGroups []int64
+	if sc.FSGroup != nil {
+		fsGroups = []int64{*sc.FSGroup}
+	}
+	allErrs = append(allErrs, validateGroups(scPath.Child("fsGroup"), string(spec.FSGroup.Rule), spec.FSGroup.Ranges, fsGroups)...)
+	allErrs = append(allErrs, validateGroups(scPath.Child("supplementalGroups"), string(spec.SupplementalGroups.Rule), spec.SupplementalGroups.Ranges, sc.SupplementalGroups)...)
+
+	allErrs = append(allErrs, p.validatePodSeccomp(pod)...)
+
+	if sc.SELinuxOptions != nil {
+		allErrs = append(allErrs, p.validateSELinux(scPath.Child("seLinuxOptions"), sc.SELinuxOptions)...)
+	}
+
+	for i, sysctl := range sc.Sysctls {
+		allErrs = append(allErrs, p.validateSysctl(scPath.Child("sysctls").Index(i), sysctl.Name)...)
+	}
+
+	allErrs = append(allErrs, p.validateVolumes(pod)...)
+
+	for _, ref := range podContainers(pod) {
+		allErrs = append(allErrs, p.validateContainer(pod, ref)...)
+	}
+	return allErrs
+}
+
+func (p *provider) validateContainer(pod *corev1.Pod, ref containerRef) field.ErrorList {
+	spec := p.psp.Spec
+	allErrs := field.ErrorList{}
+	c := ref.container
+	scPath := ref.path.Child("securityContext")
+
+	sc := c.SecurityContext
+	if sc == nil {
+		sc = &corev1.SecurityContext{}
+	}
+
+	runAsUser := effectiveRunAsUser(pod, c)
+	runAsNonRoot := effectiveRunAsNonRoot(pod, c)
+	switch spec.RunAsUser.Rule {
+	case policyv1.RunAsUserStrategyMustRunAs:
+		if runAsUser == nil {
+			allErrs = append(allErrs, field.Required(scPath.Child("runAsUser"), ""))
+		} else if !inIDRanges(*runAsUser, spec.RunAsUser.Ranges) {
+			allErrs = append(allErrs, field.Invalid(scPath.Child("runAsUser"), *runAsUser, fmt.Sprintf("must be in the ranges: %v", spec.RunAsUser.Ranges)))
+		}
+	case policyv1.RunAsUserStrategyMustRunAsNonRoot:
+		if runAsNonRoot == nil && runAsUser == nil {
+			allErrs = append(allErrs, field.Required(scPath.Child("runAsNonRoot"), "must be true"))
+		} else if runAsNonRoot != nil && !*runAsNonRoot {
+			allErrs = append(allErrs, field.Invalid(scPath.Child("runAsNonRoot"), *runAsNonRoot, "must be true"))
+		} else if runAsUser != nil && *runAsUser == 0 {
+			allErrs = append(allErrs, field.Invalid(scPath.Child("runAsUser"), *runAsUser, "running with the root UID is forbidden"))
+		}
+	}
+
+	if spec.RunAsGroup != nil {
+		var groups []int64
+		if gid := effectiveRunAsGroup(pod, c); gid != nil {
+			groups = []int64{*gid}
+		}
+		allErrs = append(allErrs, validateGroups(scPath.Child("runAsGroup"), string(spec.RunAsGroup.Rule), spec.RunAsGroup.Ranges, groups)...)
+	}
+
+	allErrs = append(allErrs, p.validateSELinux(scPath.Child("seLinuxOptions"), effectiveSELinuxOptions(pod, c))...)
+
+	allErrs = append(allErrs, p.validateContainerSeccomp(pod, c)...)
+	allErrs = append(allErrs, p.validateAppArmor(pod, c, ref.path)...)
+
+	if !spec.Privileged && sc.Privileged != nil && *sc.Privileged {
+		allErrs = append(allErrs, field.Invalid(scPath.Child("privileged"), *sc.Privileged, "Privileged containers are not allowed"))
+	}
+
+	if sc.ProcMount != nil && *sc.ProcMount != corev1.DefaultProcMount {
+		allowed := false
+		for _, t := range spec.AllowedProcMountTypes {
+			if t == *sc.ProcMount {
+				allowed = true
+			}
+		}
+		if !allowed {
+			allErrs = append(allErrs, field.Invalid(scPath.Child("procMount"), *sc.ProcMount, "ProcMountType is not allowed"))
+		}
+	}
+
+	allErrs = append(allErrs, p.validateCapabilities(scPath.Child("capabilities"), sc.Capabilities)...)
+
+	for i, port := range c.Ports {
+		if port.HostPort != 0 && !inHostPortRanges(port.HostPort, spec.HostPorts) {
+			allErrs = append(allErrs, field.Invalid(ref.path.Child("ports").Index(i).Child("hostPort"), port.HostPort,
+				fmt.Sprintf("Host port %d is not allowed to be used. Allowed ports: %v", port.HostPort, spec.HostPorts)))
+		}
+	}
+
+	if spec.ReadOnlyRootFilesystem {
+		if sc.ReadOnlyRootFilesystem == nil {
+			allErrs = append(allErrs, field.Invalid(scPath.Child("readOnlyRootFilesystem"), sc.ReadOnlyRootFilesystem, "ReadOnlyRootFilesystem may not be nil and must be set to true"))
+		} else if !*sc.ReadOnlyRootFilesystem {
+			allErrs = append(allErrs, field.Invalid(scPath.Child("readOnlyRootFilesystem"), *sc.ReadOnlyRootFilesystem, "ReadOnlyRootFilesystem must be set to true"))
+		}
+	}
+
+	if !policy.AllowPrivilegeEscalation(spec) && (sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation) {
+		allErrs = append(allErrs, field.Invalid(scPath.Child("allowPrivilegeEscalation"), sc.AllowPrivilegeEscalation, "Allowing privilege escalation for containers is not allowed"))
+	}
+	return allErrs
+}
+
+func (p *provider) validateCapabilities(fldPath *field.Path, caps *corev1.Capabilities) field.ErrorList {
+	spec := p.psp.Spec
+	allErrs := field.ErrorList{}
+
+	if caps == nil {
+		if len(spec.DefaultAddCapabilities) == 0 && len(spec.RequiredDropCapabilities) == 0 {
+			return allErrs
+		}
+		return append(allErrs, field.Invalid(fldPath, caps, "required capabilities are not set on the securityContext"))
+	}
+
+	allowedAdd := capSet(spec.AllowedCapabilities)
+	if allowedAdd.Has(policy.AllowAllCapabilities) {
+		return allErrs
+	}
+
+	defaultAdd := capSet(spec.DefaultAddCapabilities)
+	for _, c := range caps.Add {
+		if !defaultAdd.Has(string(c)) && !allowedAdd.Has(string(c)) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("add"), string(c), "capability may not be added"))
+		}
+	}
+
+	drops := capSet(caps.Drop)
+	for _, c := range spec.RequiredDropCapabilities {
+		if !drops.Has(string(c)) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("drop"), caps.Drop, fmt.Sprintf("%s is required to be dropped but was not found", c)))
+		}
+	}
+	return allErrs
+}
+
+func (p *provider) validateSELinux(fldPath *field.Path, seLinux *corev1.SELinuxOptions) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if p.psp.Spec.SELinux.Rule != policyv1.SELinuxStrategyMustRunAs {
+		return allErrs
+	}
+	if seLinux == nil {
+		return append(allErrs, field.Required(fldPath, ""))
+	}
+
+	opts := p.psp.Spec.SELinux.SELinuxOptions
+	if opts == nil {
+		opts = &corev1.SELinuxOptions{}
+	}
+	if opts.Level != seLinux.Level {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("level"), seLinux.Level, "must be "+opts.Level))
+	}
+	if opts.Role != seLinux.Role {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("role"), seLinux.Role, "must be "+opts.Role))
+	}
+	if opts.Type != seLinux.Type {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("type"), seLinux.Type, "must be "+opts.Type))
+	}
+	if opts.User != seLinux.User {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("user"), seLinux.User, "must be "+opts.User))
+	}
+	return allErrs
+}
+
+func (p *provider) validateSysctl(fldPath *field.Path, name string) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for _, pattern := range p.psp.Spec.ForbiddenSysctls {
+		if policy.MatchSysctl(pattern, name) {
+			return append(allErrs, field.Forbidden(fldPath, fmt.Sprintf("sysctl %q is not allowed", name)))
+		}
+	}
+	if policy.IsSafeSysctl(name) {
+		return allErrs
+	}
+	for _, pattern := range p.psp.Spec.AllowedUnsafeSysctls {
+		if policy.MatchSysctl(pattern, name) {
+			return allErrs
+		}
+	}
+	return append(allErrs, field.Forbidden(fldPath, fmt.Sprintf("unsafe sysctl %q is not allowed", name)))
+}
+
+func (p *provider) validateVolumes(pod *corev1.Pod) field.ErrorList {
+	spec := p.psp.Spec
+	allErrs := field.ErrorList{}
+	volPath := field.NewPath("spec", "volumes")
+
+	for i, v := range pod.Spec.Volumes {
+		fsType, err := policy.GetVolumeFSType(v)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(volPath.Index(i), string(fsType), err.Error()))
+			continue
+		}
+		if !policy.AllowsVolume(spec, fsType) {
+			allErrs = append(allErrs, field.Invalid(volPath.Index(i), string(fsType), fmt.Sprintf("%s volumes are not allowed to be used", fsType)))
+			continue
+		}
+
+		switch fsType {
+		case policyv1.HostPath:
+			allowed, mustBeReadOnly := policy.AllowsHostPath(spec, v.HostPath.Path)
+			if !allowed {
+				allErrs = append(allErrs, field.Invalid(volPath.Index(i).Child("hostPath", "pathPrefix"), v.HostPath.Path, "is not allowed to be used"))
+			} else if mustBeReadOnly {
+				for _, ref := range podContainers(pod) {
+					for j, m := range ref.container.VolumeMounts {
+						if m.Name == v.Name && !m.ReadOnly {
+							allErrs = append(allErrs, field.Invalid(ref.path.Child("volumeMounts").Index(j).Child("readOnly"), m.ReadOnly, "must be read-only"))
+						}
+					}
+				}
+			}
+
+		case policyv1.FlexVolume:
+			if len(spec.AllowedFlexVolumes) > 0 {
+				found := false
+				for _, d := range spec.AllowedFlexVolumes {
+					if d.Driver == v.FlexVolume.Driver {
+						found = true
+					}
+				}
+				if !found {
+					allErrs = append(allErrs, field.Invalid(volPath.Index(i).Child("driver"), v.FlexVolume.Driver, "Flexvolume driver is not allowed to be used"))
+				}
+			}
+
+		case policyv1.CSI:
+			found := false
+			for _, d := range spec.AllowedCSIDrivers {
+				if d.Name == v.CSI.Driver {
+					found = true
+				}
+			}
+			if !found {
+				allErrs = append(allErrs, field.Invalid(volPath.Index(i).Child("csi", "driver"), v.CSI.Driver, "Inline CSI driver is not allowed to be used"))
+			}
+		}
+	}
+	return allErrs
+}
+
+func (p *provider) allowedProfiles(key string) (profiles sets.String, allowAny bool) {
+	v, ok := p.psp.Annotations[key]
+	if !ok {
+		return nil, false
+	}
+	profiles = sets.NewString()
+	for _, s := range strings.Split(v, ",") {
+		s = strings.TrimSpace(s)
+		if s == "*" {
+			allowAny = true
+		}
+		if s != "" {
+			profiles.Insert(s)
+		}
+	}
+	return profiles, allowAny
+}
+
+func (p *provider) validateSeccompProfile(fldPath *field.Path, profile string) field.ErrorList {
+	allErrs := field.ErrorList{}
+	allowed, allowAny := p.allowedProfiles(policy.SeccompAllowedProfilesAnnotationKey)
+	if allowAny {
+		return allErrs
+	}
+	if allowed.Len() == 0 {
+		if profile != "" {
+			allErrs = append(allErrs, field.Forbidden(fldPath, "seccomp may not be set"))
+		}
+		return allErrs
+	}
+	if !allowed.Has(profile) {
+		allErrs = append(allErrs, field.Forbidden(fldPath, fmt.Sprintf("%s is not an allowed seccomp profile. Valid values are %v", profile, allowed.List())))
+	}
+	return allErrs
+}
+
+func (p *provider) validatePodSeccomp(pod *corev1.Pod) field.ErrorList {
+	key := policy.SeccompPodAnnotationKey
+	return p.validateSeccompProfile(field.NewPath("metadata", "annotations").Key(key), pod.Annotations[key])
+}
+
+func (p *provider) validateContainerSeccomp(pod *corev1.Pod, c *corev1.Container) field.ErrorList {
+	key := policy.SeccompContainerAnnotationKeyPrefix + c.Name
+	profile, ok := pod.Annotations[key]
+	if !ok {
+		return field.ErrorList{}
+	}
+	return p.validateSeccompProfile(field.NewPath("metadata", "annotations").Key(key), profile)
+}
+
+func (p *provider) validateAppArmor(pod *corev1.Pod, c *corev1.Container, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	allowed, _ := p.allowedProfiles(policy.AppArmorAllowedProfilesAnnotationKey)
+	if allowed == nil {
+		return allErrs
+	}
+
+	profile := pod.Annotations[policy.AppArmorContainerAnnotationKeyPrefix+c.Name]
+	if profile == "" {
+		if allowed.Len() > 0 {
+			allErrs = append(allErrs, field.Forbidden(fldPath, "AppArmor profile must be set"))
+		}
+	} else if !allowed.Has(profile) {
+		allErrs = append(allErrs, field.Forbidden(fldPath, fmt.Sprintf("%s is not an allowed profile. Allowed values: %q", profile, allowed.List())))
+	}
+	return allErrs
+}
+
+func validateGroups(fldPath *field.Path, rule string, ranges []policyv1.IDRange, groups []int64) field.ErrorList {
+	allErrs := field.ErrorList{}
+	switch rule {
+	case string(policyv1.FSGroupStrategyMustRunAs):
+		if len(groups) == 0 && len(ranges) > 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath, groups, "unable to validate empty groups against required ranges"))
+		}
+	case string(policyv1.FSGroupStrategyMayRunAs):
+	default:
+		return allErrs
+	}
+
+	for _, g := range groups {
+		if !inIDRanges(g, ranges) {
+			allErrs = append(allErrs, field.Invalid(fldPath, groups, fmt.Sprintf("group %d must be in the ranges: %v", g, ranges)))
+		}
+	}
+	return allErrs
+}
+
+func inIDRanges(id int64, ranges []policyv1.IDRange) bool {
+	for _, r := range ranges {
+		if id >= r.Min && id <= r.Max {
+			return true
+		}
+	}
+	return false
+}
+
+func inHostPortRanges(port int32, ranges []policyv1.HostPortRange) bool {
+	for _, r := range ranges {
+		if port >= r.Min && port <= r.Max {
+			return true
+		}
+	}
+	return false
+}
+
+func effectiveRunAsUser(pod *corev1.Pod, c *corev1.Container) *int64 {
+	if c.SecurityContext != nil && c.SecurityContext.RunAsUser != nil {
+		return c.SecurityContext.RunAsUser
+	}
+	if pod.Spec.SecurityContext != nil {
+		return pod.Spec.SecurityContext.RunAsUser
+	}
+	return nil
+}
+
+func effectiveRunAsGroup(pod *corev1.Pod, c *corev1.Container) *int64 {
+	if c.SecurityContext != nil && c.SecurityContext.RunAsGroup != nil {
+		return c.SecurityContext.RunAsGroup
+	}
+	if pod.Spec.SecurityContext != nil {
+		return pod.Spec.SecurityContext.RunAsGroup
+	}
+	return nil
+}
+
+func effectiveRunAsNonRoot(pod *corev1.Pod, c *corev1.Container) *bool {
+	if c.SecurityContext != nil && c.SecurityContext.RunAsNonRoot != nil {
+		return c.SecurityContext.RunAsNonRoot
+	}
+	if pod.Spec.SecurityContext != nil {
+		return pod.Spec.SecurityContext.RunAsNonRoot
+	}
+	return nil
+}
+
+func effectiveSELinuxOptions(pod *corev1.Pod, c *corev1.Container) *corev1.SELinuxOptions {
+	if c.SecurityContext != nil && c.SecurityContext.SELinuxOptions != nil {
+		return c.SecurityContext.SELinuxOptions
+	}
+	if pod.Spec.SecurityContext != nil {
+		return pod.Spec.SecurityContext.SELinuxOptions
+	}
+	return nil
+}
+
+func capSet(caps []corev1.Capability) sets.String {
+	s := sets.NewString()
+	for _, c := range caps {
+		s.Insert(string(c))
+	}
+	return s
+}
+
+func capList(s sets.String) []corev1.Capability {
+	caps := make([]corev1.Capability, 0, s.Len())
+	for _, c := range s.List() {
+		caps = append(caps, corev1.Capability(c))
+	}
+	return caps
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case *corev1.SELinuxOptions:
+		return fmt.Sprintf("{user: %s, role: %s, type: %s, level: %s}", v.User, v.Role, v.Type, v.Level)
+	case *corev1.Capabilities:
+		return fmt.Sprintf("{add: %v, drop: %v}", v.Add, v.Drop)
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admission
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// podTemplatePaths are the fields of pod templates in workloads.
+// e.g. Deployment, StatefulSet, DaemonSet, ReplicaSet, Job and CronJob
+var podTemplatePaths = [][]string{
+	{"spec", "template"},
+	{"spec", "jobTemplate", "spec", "template"},
+}
+
+// Target is a pod or a pod template in a workload to simulate admission
+type Target struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	// TemplatePath is the field path of the pod template. Empty if the target is a Pod
+	TemplatePath string `json:"templatePath,omitempty"`
+
+	Pod *corev1.Pod `json:"-"`
+}
+
+// ExtractTargets returns pods and pod templates in the objects. Objects without pod templates are ignored
+func ExtractTargets(objs []runtime.Object) ([]Target, error) {
+	targets := make([]Target, 0)
+	for _, obj := range objs {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+			if err != nil {
+				return nil, err
+			}
+			u = &unstructured.Unstructured{Object: m}
+			u.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+		}
+
+		target, ok, err := extractTarget(u)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to extract pod from %s %s: %v", u.GetKind(), u.GetName(), err)
+		}
+		if ok {
+			targets = append(targets, target)
+		}
+	}
+	return targets, nil
+}
+
+func extractTarget(u *unstructured.Unstructured) (Target, bool, error) {
+	target := Target{
+		Kind:      u.GetKind(),
+		Name:      u.GetName(),
+		Namespace: u.GetNamespace(),
+	}
+
+	if u.GetKind() == "Pod" {
+		pod := &corev1.Pod{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, pod); err != nil {
+			return target, false, err
+		}
+		target.Pod = pod
+		return target, true, nil
+	}
+
+	for _, path := range podTemplatePaths {
+		m, found, err := unstructured.NestedMap(u.Object, path...)
+		if err != nil || !found {
+			continue
+		}
+		template := &corev1.PodTemplateSpec{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, template); err != nil {
+			return target, false, err
+		}
+		if len(template.Spec.Containers) == 0 {
+			continue
+		}
+
+		pod := &corev1.Pod{ObjectMeta: template.ObjectMeta, Spec: template.Spec}
+		if pod.Name == "" {
+			pod.Name = u.GetName()
+		}
+		pod.Namespace = u.GetNamespace()
+		target.Pod = pod
+		target.TemplatePath = strings.Join(path, ".")
+		return target, true, nil
+	}
+	return target, false, nil
+}
@@ -0,0 +1,266 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jlandowner/psp-util/pkg/relations"
+)
+
+// Severity is a severity of findings
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityLow      Severity = "low"
+	SeverityMedium   Severity = "medium"
+	SeverityHigh     Severity = "high"
+	SeverityCritical Severity = "critical"
+)
+
+// Severities are all the severities ordered from the least severe
+var Severities = []Severity{SeverityInfo, SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+
+// ParseSeverity returns the severity of the given name
+func ParseSeverity(s string) (Severity, error) {
+	for _, v := range Severities {
+		if string(v) == s {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("Invalid severity %s: must be one of info|low|medium|high|critical", s)
+}
+
+// Rank returns larger value for the more severe severity
+func (s Severity) Rank() int {
+	for i, v := range Severities {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// Score returns the points which a finding of the severity adds to the PSP score
+func (s Severity) Score() int {
+	switch s {
+	case SeverityLow:
+		return 1
+	case SeverityMedium:
+		return 3
+	case SeverityHigh:
+		return 7
+	case SeverityCritical:
+		return 10
+	}
+	return 0
+}
+
+// Shift returns the severity moved by the given steps within info and critical
+func (s Severity) Shift(steps int) Severity {
+	i := s.Rank() + steps
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(Severities) {
+		i = len(Severities) - 1
+	}
+	return Severities[i]
+}
+
+// Exposure is how widely a PSP is granted
+type Exposure string
+
+const (
+	// ExposureNone is the PSP granted to no subject
+	ExposureNone Exposure = "None"
+	// ExposureSubjects is the PSP granted to specific users, groups or ServiceAccounts
+	ExposureSubjects Exposure = "Subjects"
+	// ExposureAllServiceAccounts is the PSP granted to the group system:serviceaccounts
+	ExposureAllServiceAccounts Exposure = "AllServiceAccounts"
+	// ExposureAllUsers is the PSP granted to every authenticated or unauthenticated user
+	ExposureAllUsers Exposure = "AllUsers"
+)
+
+// Steps returns how many severities the exposure raises or lowers the findings
+func (e Exposure) Steps() int {
+	switch e {
+	case ExposureNone:
+		return -1
+	case ExposureAllServiceAccounts:
+		return 1
+	case ExposureAllUsers:
+		return 2
+	}
+	return 0
+}
+
+// GetExposure returns the widest exposure of the subjects permitted to use the PSP
+func GetExposure(psp relations.RelationalPodSecurityPolicy) Exposure {
+	return getExposure(psp.SubjectGrants())
+}
+
+func getExposure(grants []relations.SubjectGrant) Exposure {
+	if len(grants) == 0 {
+		return ExposureNone
+	}
+
+	exposure := ExposureSubjects
+	for _, g := range grants {
+		switch {
+		case relations.ImplicitGroupWarning(g.Subject) != "":
+			return ExposureAllUsers
+		case g.Subject.Kind == "Group" && g.Subject.Name == relations.GroupServiceAccounts:
+			exposure = ExposureAllServiceAccounts
+		}
+	}
+	return exposure
+}
+
+// Finding is a dangerous setting found in a PSP
+type Finding struct {
+	RuleID string `json:"ruleID"`
+	Title  string `json:"title"`
+	PSP    string `json:"psp"`
+	// Severity is BaseSeverity weighted by Exposure
+	Severity     Severity `json:"severity"`
+	BaseSeverity Severity `json:"baseSeverity"`
+	Exposure     Exposure `json:"exposure"`
+	Message      string   `json:"message"`
+	// Waiver is set if the finding is suppressed by the config
+	Waiver *Waiver `json:"waiver,omitempty"`
+}
+
+// IsWaived returns true if the finding is suppressed by the config
+func (f Finding) IsWaived() bool {
+	return f.Waiver != nil
+}
+
+// Result is the findings of a PSP. Score does not include the waived findings
+type Result struct {
+	PSP      string    `json:"psp"`
+	Score    int       `json:"score"`
+	Exposure Exposure  `json:"exposure"`
+	Findings []Finding `json:"findings"`
+}
+
+// Report is the audit results of all PSPs ordered from the highest score
+type Report struct {
+	Results []Result `json:"results"`
+	// Warnings are the problems of the config such as expired suppressions
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Findings returns the findings of all PSPs ordered from the most severe
+func (r Report) Findings() []Finding {
+	findings := make([]Finding, 0)
+	for _, res := range r.Results {
+		findings = append(findings, res.Findings...)
+	}
+	SortFindings(findings)
+	return findings
+}
+
+// CountAtLeast returns the number of the findings at or above the severity except the waived ones
+func (r Report) CountAtLeast(s Severity) int {
+	n := 0
+	for _, f := range r.Findings() {
+		if !f.IsWaived() && f.Severity.Rank() >= s.Rank() {
+			n++
+		}
+	}
+	return n
+}
+
+// Audit checks the PSPs by the built-in rules and weights the findings by who can use each PSP
+func Audit(psps []relations.RelationalPodSecurityPolicy) *Report {
+	return AuditWithConfig(psps, &Config{}, time.Now())
+}
+
+// AuditWithConfig checks the PSPs by the rules enabled in the config,
+// and waives the findings matching the suppressions not expired at the time
+func AuditWithConfig(psps []relations.RelationalPodSecurityPolicy, c *Config, now time.Time) *Report {
+	report := &Report{Results: make([]Result, 0, len(psps))}
+	rules := c.EnabledRules()
+	for _, psp := range psps {
+		grants := psp.SubjectGrants()
+		res := Result{
+			PSP:      psp.Name,
+			Exposure: getExposure(grants),
+			Findings: make([]Finding, 0),
+		}
+		for _, rule := range rules {
+			for _, msg := range rule.Check(psp.PodSecurityPolicy) {
+				f := Finding{
+					RuleID:       rule.ID,
+					Title:        rule.Title,
+					PSP:          psp.Name,
+					Severity:     weigh(rule.Severity, res.Exposure),
+					BaseSeverity: rule.Severity,
+					Exposure:     res.Exposure,
+					Message:      msg,
+				}
+				f.Waiver = c.waiver(f, grants, now)
+				res.Findings = append(res.Findings, f)
+			}
+		}
+		SortFindings(res.Findings)
+		for _, f := range res.Findings {
+			if !f.IsWaived() {
+				res.Score += f.Severity.Score()
+			}
+		}
+		report.Results = append(report.Results, res)
+	}
+
+	sort.SliceStable(report.Results, func(i, j int) bool {
+		if report.Results[i].Score != report.Results[j].Score {
+			return report.Results[i].Score > report.Results[j].Score
+		}
+		return report.Results[i].PSP < report.Results[j].PSP
+	})
+
+	for _, s := range c.ExpiredSuppressions(now) {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("suppression (%s) expired on %s: %s", s.String(), s.Expires, s.Justification))
+	}
+	return report
+}
+
+// weigh returns the severity weighted by the exposure.
+// Low and info findings are hardening advices rather than escalation paths, so they are never raised
+func weigh(s Severity, e Exposure) Severity {
+	if steps := e.Steps(); steps < 0 || s.Rank() >= SeverityMedium.Rank() {
+		return s.Shift(steps)
+	}
+	return s
+}
+
+// SortFindings sorts the findings from the most severe, then by PSP and rule ID
+func SortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Severity != b.Severity {
+			return a.Severity.Rank() > b.Severity.Rank()
+		}
+		if a.PSP != b.PSP {
+			return a.PSP < b.PSP
+		}
+		return a.RuleID < b.RuleID
+	})
+}
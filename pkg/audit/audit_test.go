@@ -0,0 +1,437 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jlandowner/psp-util/pkg/manifests"
+	"github.com/jlandowner/psp-util/pkg/relations"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// hardened is a PSP spec without any finding
+func hardened() policyv1.PodSecurityPolicySpec {
+	f := false
+	return policyv1.PodSecurityPolicySpec{
+		RunAsUser:                policyv1.RunAsUserStrategyOptions{Rule: policyv1.RunAsUserStrategyMustRunAsNonRoot},
+		RequiredDropCapabilities: []corev1.Capability{"ALL"},
+		AllowPrivilegeEscalation: &f,
+		ReadOnlyRootFilesystem:   true,
+		Volumes:                  []policyv1.FSType{policyv1.ConfigMap, policyv1.Secret},
+	}
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		title  string
+		modify func(spec *policyv1.PodSecurityPolicySpec)
+		expect map[string][]string
+	}{
+		{
+			title:  "hardened",
+			modify: func(spec *policyv1.PodSecurityPolicySpec) {},
+			expect: map[string][]string{},
+		},
+		{
+			title: "privileged with host namespaces",
+			modify: func(spec *policyv1.PodSecurityPolicySpec) {
+				spec.Privileged = true
+				spec.HostNetwork = true
+				spec.HostPID = true
+				spec.HostIPC = true
+			},
+			expect: map[string][]string{
+				"PSP001": {"privileged: true"},
+				"PSP002": {"hostNetwork: true"},
+				"PSP003": {"hostPID: true"},
+				"PSP004": {"hostIPC: true"},
+			},
+		},
+		{
+			title: "hostPath of any path",
+			modify: func(spec *policyv1.PodSecurityPolicySpec) {
+				spec.Volumes = append(spec.Volumes, policyv1.HostPath)
+			},
+			expect: map[string][]string{
+				"PSP005": {"hostPath volumes of any path are allowed"},
+			},
+		},
+		{
+			title: "hostPath with writable and broad prefixes",
+			modify: func(spec *policyv1.PodSecurityPolicySpec) {
+				spec.Volumes = append(spec.Volumes, policyv1.HostPath)
+				spec.AllowedHostPaths = []policyv1.AllowedHostPath{
+					{PathPrefix: "/var/log", ReadOnly: true},
+					{PathPrefix: "/data"},
+					{PathPrefix: "/var/", ReadOnly: true},
+				}
+			},
+			expect: map[string][]string{
+				"PSP005": {"allowedHostPaths: /data is not readOnly"},
+				"PSP006": {"allowedHostPaths: /var/ covers /var/lib/containerd,/var/lib/docker,/var/lib/kubelet,/var/run"},
+			},
+		},
+		{
+			title: "allowedHostPaths without hostPath volumes",
+			modify: func(spec *policyv1.PodSecurityPolicySpec) {
+				spec.AllowedHostPaths = []policyv1.AllowedHostPath{{PathPrefix: "/"}}
+			},
+			expect: map[string][]string{},
+		},
+		{
+			title: "all volumes and capabilities",
+			modify: func(spec *policyv1.PodSecurityPolicySpec) {
+				spec.Volumes = []policyv1.FSType{policyv1.All}
+				spec.AllowedHostPaths = []policyv1.AllowedHostPath{{PathPrefix: "/", ReadOnly: true}}
+				spec.AllowedCapabilities = []corev1.Capability{"*"}
+				spec.RequiredDropCapabilities = nil
+			},
+			expect: map[string][]string{
+				"PSP006": {"allowedHostPaths: / covers /"},
+				"PSP007": {"allowedCapabilities: *"},
+				"PSP009": {"requiredDropCapabilities: NET_RAW is not dropped"},
+				"PSP013": {"volumes: *"},
+			},
+		},
+		{
+			title: "dangerous capabilities",
+			modify: func(spec *policyv1.PodSecurityPolicySpec) {
+				spec.AllowedCapabilities = []corev1.Capability{"CAP_SYS_ADMIN", "net_raw"}
+				spec.DefaultAddCapabilities = []corev1.Capability{"SYS_ADMIN"}
+			},
+			expect: map[string][]string{
+				"PSP008": {"allowedCapabilities: SYS_ADMIN", "defaultAddCapabilities: SYS_ADMIN"},
+				"PSP009": {"allowedCapabilities: NET_RAW"},
+			},
+		},
+		{
+			title: "root users, privilege escalation and writable root filesystem",
+			modify: func(spec *policyv1.PodSecurityPolicySpec) {
+				spec.RunAsUser = policyv1.RunAsUserStrategyOptions{Rule: policyv1.RunAsUserStrategyMustRunAs, Ranges: []policyv1.IDRange{{Min: 0, Max: 1000}}}
+				spec.AllowPrivilegeEscalation = nil
+				spec.ReadOnlyRootFilesystem = false
+			},
+			expect: map[string][]string{
+				"PSP010": {"runAsUser: range 0-1000 includes root"},
+				"PSP011": {"allowPrivilegeEscalation: true"},
+				"PSP012": {"readOnlyRootFilesystem: false"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		psp := policyv1.PodSecurityPolicy{ObjectMeta: metav1.ObjectMeta{Name: "psp"}, Spec: hardened()}
+		test.modify(&psp.Spec)
+
+		found := make(map[string][]string)
+		for _, rule := range Rules {
+			if msgs := rule.Check(psp); len(msgs) > 0 {
+				found[rule.ID] = msgs
+			}
+		}
+		assert.Equal(t, test.expect, found)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	roleRef := rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: "use-psp"}
+	useRule := func(psps ...string) []rbacv1.PolicyRule {
+		return []rbacv1.PolicyRule{{APIGroups: []string{"policy"}, Resources: []string{"podsecuritypolicies"}, ResourceNames: psps, Verbs: []string{"use"}}}
+	}
+	privileged := func(name string) policyv1.PodSecurityPolicy {
+		spec := hardened()
+		spec.Privileged = true
+		spec.ReadOnlyRootFilesystem = false
+		return policyv1.PodSecurityPolicy{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: spec}
+	}
+
+	tests := []struct {
+		title          string
+		subjects       []rbacv1.Subject
+		expectExposure Exposure
+		expectSeverity map[string]Severity
+		expectScore    int
+	}{
+		{
+			title:          "granted to nobody",
+			subjects:       nil,
+			expectExposure: ExposureNone,
+			expectSeverity: map[string]Severity{"PSP001": SeverityMedium, "PSP012": SeverityInfo},
+			expectScore:    3,
+		},
+		{
+			title:          "granted to a ServiceAccount",
+			subjects:       []rbacv1.Subject{{Kind: "ServiceAccount", Namespace: "team-a", Name: "app"}},
+			expectExposure: ExposureSubjects,
+			expectSeverity: map[string]Severity{"PSP001": SeverityHigh, "PSP012": SeverityLow},
+			expectScore:    8,
+		},
+		{
+			title:          "granted to all ServiceAccounts",
+			subjects:       []rbacv1.Subject{{Kind: "Group", APIGroup: "rbac.authorization.k8s.io", Name: "system:serviceaccounts"}},
+			expectExposure: ExposureAllServiceAccounts,
+			expectSeverity: map[string]Severity{"PSP001": SeverityCritical, "PSP012": SeverityLow},
+			expectScore:    11,
+		},
+		{
+			title: "granted to all authenticated users",
+			subjects: []rbacv1.Subject{
+				{Kind: "Group", APIGroup: "rbac.authorization.k8s.io", Name: "system:serviceaccounts"},
+				{Kind: "Group", APIGroup: "rbac.authorization.k8s.io", Name: "system:authenticated"},
+			},
+			expectExposure: ExposureAllUsers,
+			expectSeverity: map[string]Severity{"PSP001": SeverityCritical, "PSP012": SeverityLow},
+			expectScore:    11,
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		objs := &manifests.Objects{}
+		objs.Add(&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "use-psp"}, Rules: useRule("privileged", "hardened")})
+		objs.Add(&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "use-psp"}, RoleRef: roleRef, Subjects: test.subjects})
+		hardenedPSP := policyv1.PodSecurityPolicy{ObjectMeta: metav1.ObjectMeta{Name: "hardened"}, Spec: hardened()}
+		objs.Add(&hardenedPSP)
+		p := privileged("privileged")
+		objs.Add(&p)
+
+		report := Audit(relations.GetRelationalPSPsFromManifests(objs))
+		if !assert.Len(t, report.Results, 2) {
+			continue
+		}
+
+		// ordered from the highest score
+		res := report.Results[0]
+		assert.Equal(t, "privileged", res.PSP)
+		assert.Equal(t, test.expectExposure, res.Exposure)
+		assert.Equal(t, test.expectScore, res.Score)
+		severities := make(map[string]Severity)
+		for _, f := range res.Findings {
+			severities[f.RuleID] = f.Severity
+		}
+		assert.Equal(t, test.expectSeverity, severities)
+
+		assert.Equal(t, "hardened", report.Results[1].PSP)
+		assert.Empty(t, report.Results[1].Findings)
+		assert.Equal(t, len(res.Findings), len(report.Findings()))
+	}
+}
+
+func TestCountAtLeast(t *testing.T) {
+	report := Report{Results: []Result{
+		{PSP: "a", Findings: []Finding{{Severity: SeverityCritical}, {Severity: SeverityLow}}},
+		{PSP: "b", Findings: []Finding{{Severity: SeverityHigh}, {Severity: SeverityInfo}}},
+	}}
+
+	tests := []struct {
+		title    string
+		severity Severity
+		expect   int
+	}{
+		{title: "critical", severity: SeverityCritical, expect: 1},
+		{title: "high", severity: SeverityHigh, expect: 2},
+		{title: "info", severity: SeverityInfo, expect: 4},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		assert.Equal(t, test.expect, report.CountAtLeast(test.severity))
+	}
+}
+
+func TestAuditWithConfig(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	roleRef := rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: "use-privileged"}
+	masters := rbacv1.Subject{Kind: "Group", APIGroup: "rbac.authorization.k8s.io", Name: "system:masters"}
+	sa := rbacv1.Subject{Kind: "ServiceAccount", Namespace: "kube-system", Name: "cni"}
+
+	newObjs := func() *manifests.Objects {
+		spec := hardened()
+		spec.Privileged = true
+		spec.HostNetwork = true
+		objs := &manifests.Objects{}
+		objs.Add(&policyv1.PodSecurityPolicy{ObjectMeta: metav1.ObjectMeta{Name: "privileged"}, Spec: spec})
+		objs.Add(&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "use-privileged"}, Rules: []rbacv1.PolicyRule{{APIGroups: []string{"policy"}, Resources: []string{"podsecuritypolicies"}, ResourceNames: []string{"privileged"}, Verbs: []string{"use"}}}})
+		objs.Add(&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "masters"}, RoleRef: roleRef, Subjects: []rbacv1.Subject{masters}})
+		objs.Add(&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "cni", Namespace: "kube-system"}, RoleRef: roleRef, Subjects: []rbacv1.Subject{sa}})
+		return objs
+	}
+
+	tests := []struct {
+		title          string
+		config         string
+		expectOpen     map[string]Severity
+		expectWaived   []string
+		expectWarnings int
+	}{
+		{
+			title: "no config",
+			config: `apiVersion: psp-util.k8s.jlandowner.com/v1alpha1
+kind: AuditConfig`,
+			expectOpen:   map[string]Severity{"PSP001": SeverityHigh, "PSP002": SeverityMedium},
+			expectWaived: []string{},
+		},
+		{
+			title: "disable and override rules",
+			config: `apiVersion: psp-util.k8s.jlandowner.com/v1alpha1
+kind: AuditConfig
+rules:
+- id: PSP001
+  severity: critical
+- id: PSP002
+  enabled: false`,
+			expectOpen:   map[string]Severity{"PSP001": SeverityCritical},
+			expectWaived: []string{},
+		},
+		{
+			title: "suppress by PSP and rule",
+			config: `apiVersion: psp-util.k8s.jlandowner.com/v1alpha1
+kind: AuditConfig
+suppressions:
+- rule: PSP002
+  psp: privileged
+  expires: "2024-06-01"
+  justification: CNI requires hostNetwork`,
+			expectOpen:   map[string]Severity{"PSP001": SeverityHigh},
+			expectWaived: []string{"PSP002"},
+		},
+		{
+			title: "suppress by subjects and namespaces covering all",
+			config: `apiVersion: psp-util.k8s.jlandowner.com/v1alpha1
+kind: AuditConfig
+suppressions:
+- subject: {kind: Group, name: "system:masters"}
+  expires: "2030-01-01T00:00:00Z"
+  justification: cluster admins
+- namespace: kube-system
+  expires: "2030-01-01"
+  justification: system components`,
+			expectOpen:   map[string]Severity{},
+			expectWaived: []string{"PSP001", "PSP002"},
+		},
+		{
+			title: "suppress by subject not covering all",
+			config: `apiVersion: psp-util.k8s.jlandowner.com/v1alpha1
+kind: AuditConfig
+suppressions:
+- subject: {kind: Group, name: "system:masters"}
+  expires: "2030-01-01"
+  justification: cluster admins`,
+			expectOpen:   map[string]Severity{"PSP001": SeverityHigh, "PSP002": SeverityMedium},
+			expectWaived: []string{},
+		},
+		{
+			title: "expired suppression",
+			config: `apiVersion: psp-util.k8s.jlandowner.com/v1alpha1
+kind: AuditConfig
+suppressions:
+- psp: privileged
+  expires: "2024-05-31"
+  justification: temporary`,
+			expectOpen:     map[string]Severity{"PSP001": SeverityHigh, "PSP002": SeverityMedium},
+			expectWaived:   []string{},
+			expectWarnings: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		config, err := ParseConfig([]byte(test.config))
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		report := AuditWithConfig(relations.GetRelationalPSPsFromManifests(newObjs()), config, now)
+		open := make(map[string]Severity)
+		waived := make([]string, 0)
+		for _, f := range report.Findings() {
+			if f.IsWaived() {
+				waived = append(waived, f.RuleID)
+			} else {
+				open[f.RuleID] = f.Severity
+			}
+		}
+		assert.Equal(t, test.expectOpen, open)
+		assert.Equal(t, test.expectWaived, waived)
+		assert.Len(t, report.Warnings, test.expectWarnings)
+		assert.Equal(t, len(test.expectOpen), report.CountAtLeast(SeverityInfo))
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		title       string
+		config      string
+		expectError bool
+	}{
+		{
+			title: "valid",
+			config: `apiVersion: psp-util.k8s.jlandowner.com/v1alpha1
+kind: AuditConfig
+rules:
+- id: PSP012
+  enabled: false
+suppressions:
+- rule: PSP001
+  subject: {kind: ServiceAccount, namespace: kube-system, name: cni}
+  expires: "2030-01-01"
+  justification: CNI`,
+			expectError: false,
+		},
+		{
+			title: "unknown rule",
+			config: `apiVersion: psp-util.k8s.jlandowner.com/v1alpha1
+kind: AuditConfig
+rules:
+- id: PSP999
+  enabled: false`,
+			expectError: true,
+		},
+		{
+			title: "invalid severity",
+			config: `apiVersion: psp-util.k8s.jlandowner.com/v1alpha1
+kind: AuditConfig
+rules:
+- id: PSP001
+  severity: urgent`,
+			expectError: true,
+		},
+		{
+			title: "suppression without justification",
+			config: `apiVersion: psp-util.k8s.jlandowner.com/v1alpha1
+kind: AuditConfig
+suppressions:
+- psp: privileged
+  expires: "2030-01-01"`,
+			expectError: true,
+		},
+		{
+			title: "suppression with invalid expires",
+			config: `apiVersion: psp-util.k8s.jlandowner.com/v1alpha1
+kind: AuditConfig
+suppressions:
+- psp: privileged
+  expires: next year
+  justification: temporary`,
+			expectError: true,
+		},
+		{
+			title: "unknown field",
+			config: `apiVersion: psp-util.k8s.jlandowner.com/v1alpha1
+kind: AuditConfig
+ignore: [PSP001]`,
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		_, err := ParseConfig([]byte(test.config))
+		assert.Equal(t, test.expectError, err != nil, err)
+	}
+}
@@ -0,0 +1,317 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jlandowner/psp-util/pkg/relations"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	ConfigAPIVersion = "psp-util.k8s.jlandowner.com/v1alpha1"
+	ConfigKind       = "AuditConfig"
+
+	// DateFormat is the format of the expiry date of suppressions
+	DateFormat = "2006-01-02"
+)
+
+// Config customizes the built-in rules and suppresses the accepted findings
+type Config struct {
+	APIVersion   string        `json:"apiVersion"`
+	Kind         string        `json:"kind"`
+	Rules        []RuleConfig  `json:"rules,omitempty"`
+	Suppressions []Suppression `json:"suppressions,omitempty"`
+}
+
+// RuleConfig enables or disables the built-in rule and overrides the severity
+type RuleConfig struct {
+	ID string `json:"id"`
+	// Enabled is true if not set
+	Enabled *bool `json:"enabled,omitempty"`
+	// Severity is the base severity of the rule if set
+	Severity Severity `json:"severity,omitempty"`
+}
+
+// Suppression waives the findings matching all the given conditions until the expiry date
+type Suppression struct {
+	// Rule is the rule ID. All rules match if not set
+	Rule string `json:"rule,omitempty"`
+	// PSP is the PSP name. All PSPs match if not set
+	PSP string `json:"psp,omitempty"`
+	// Subject and Namespace waive the findings of the PSPs only granted to the subject or in the namespace.
+	// Namespace covers the ServiceAccounts and the groups of ServiceAccounts in it, and the RoleBindings in it
+	Subject   *SuppressedSubject `json:"subject,omitempty"`
+	Namespace string             `json:"namespace,omitempty"`
+	// Expires is the last date of the suppression in YYYY-MM-DD or RFC3339
+	Expires       string `json:"expires"`
+	Justification string `json:"justification"`
+}
+
+// SuppressedSubject is a subject in suppressions. APIGroup is not compared
+type SuppressedSubject struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Waiver is the suppressions applied to a finding
+type Waiver struct {
+	Justification string `json:"justification"`
+	// Expires is the earliest expiry date of the suppressions
+	Expires string `json:"expires"`
+}
+
+// IsEnabled returns false if the rule is disabled
+func (r RuleConfig) IsEnabled() bool {
+	return r.Enabled == nil || *r.Enabled
+}
+
+// ExpiresAt returns the time when the suppression expires.
+// A date expires at the end of the day in UTC
+func (s Suppression) ExpiresAt() (time.Time, error) {
+	if t, err := time.Parse(DateFormat, s.Expires); err == nil {
+		return t.AddDate(0, 0, 1), nil
+	}
+	t, err := time.Parse(time.RFC3339, s.Expires)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid expires %s: must be YYYY-MM-DD or RFC3339", s.Expires)
+	}
+	return t, nil
+}
+
+// IsExpired returns true if the suppression is no longer applied at the time
+func (s Suppression) IsExpired(now time.Time) bool {
+	t, err := s.ExpiresAt()
+	return err != nil || !now.Before(t)
+}
+
+// String returns the conditions of the suppression
+func (s Suppression) String() string {
+	conds := make([]string, 0)
+	if s.Rule != "" {
+		conds = append(conds, "rule="+s.Rule)
+	}
+	if s.PSP != "" {
+		conds = append(conds, "psp="+s.PSP)
+	}
+	if s.Subject != nil {
+		conds = append(conds, "subject="+s.Subject.String())
+	}
+	if s.Namespace != "" {
+		conds = append(conds, "namespace="+s.Namespace)
+	}
+	if len(conds) == 0 {
+		conds = append(conds, "all")
+	}
+	return strings.Join(conds, ",")
+}
+
+// String returns the subject as Kind/Name or Kind/Namespace/Name
+func (s SuppressedSubject) String() string {
+	if s.Namespace != "" {
+		return fmt.Sprintf("%s/%s/%s", s.Kind, s.Namespace, s.Name)
+	}
+	return fmt.Sprintf("%s/%s", s.Kind, s.Name)
+}
+
+// Matches returns true if the subject is the same as the suppressed one
+func (s SuppressedSubject) Matches(sub rbacv1.Subject) bool {
+	return s.Kind == sub.Kind && s.Name == sub.Name && s.Namespace == sub.Namespace
+}
+
+// covers returns true if the suppression of subject or namespace covers the grant
+func (s Suppression) covers(g relations.SubjectGrant) bool {
+	if s.Subject != nil && s.Subject.Matches(g.Subject) {
+		return true
+	}
+	if s.Namespace == "" {
+		return false
+	}
+	// the user system:serviceaccount:NAMESPACE:NAME is the ServiceAccount
+	sub := relations.ImplicitSubjects(g.Subject)[0]
+	switch {
+	case sub.Kind == "ServiceAccount" && sub.Namespace == s.Namespace:
+		return true
+	case sub.Kind == "Group" && sub.Name == relations.GroupServiceAccounts+":"+s.Namespace:
+		return true
+	}
+	for _, p := range g.Paths {
+		if p.Scope != relations.GrantScopeNamespace || p.Namespace != s.Namespace {
+			return false
+		}
+	}
+	return len(g.Paths) > 0
+}
+
+func (s Suppression) validate() error {
+	if s.Rule != "" {
+		if _, ok := FindRule(s.Rule); !ok {
+			return fmt.Errorf("Unknown rule %s", s.Rule)
+		}
+	}
+	if s.Subject != nil {
+		switch s.Subject.Kind {
+		case "User", "Group":
+		case "ServiceAccount":
+			if s.Subject.Namespace == "" {
+				return fmt.Errorf("namespace is required for ServiceAccount %s", s.Subject.Name)
+			}
+		default:
+			return fmt.Errorf("Invalid kind %s: must be one of User|Group|ServiceAccount", s.Subject.Kind)
+		}
+	}
+	if s.Expires == "" {
+		return fmt.Errorf("expires is required")
+	}
+	if _, err := s.ExpiresAt(); err != nil {
+		return err
+	}
+	if s.Justification == "" {
+		return fmt.Errorf("justification is required")
+	}
+	return nil
+}
+
+// Validate returns an error if the config is malformed
+func (c *Config) Validate() error {
+	if c.APIVersion != ConfigAPIVersion || c.Kind != ConfigKind {
+		return fmt.Errorf("Unsupported apiVersion %s and kind %s: must be %s %s", c.APIVersion, c.Kind, ConfigAPIVersion, ConfigKind)
+	}
+	for _, r := range c.Rules {
+		if _, ok := FindRule(r.ID); !ok {
+			return fmt.Errorf("Unknown rule %s", r.ID)
+		}
+		if r.Severity != "" {
+			if _, err := ParseSeverity(string(r.Severity)); err != nil {
+				return fmt.Errorf("Invalid rule %s: %v", r.ID, err)
+			}
+		}
+	}
+	for i, s := range c.Suppressions {
+		if err := s.validate(); err != nil {
+			return fmt.Errorf("Invalid suppression %d (%s): %v", i, s.String(), err)
+		}
+	}
+	return nil
+}
+
+// EnabledRules returns the enabled built-in rules with the severities overridden
+func (c *Config) EnabledRules() []Rule {
+	rules := make([]Rule, 0, len(Rules))
+	for _, rule := range Rules {
+		enabled := true
+		for _, rc := range c.Rules {
+			if rc.ID != rule.ID {
+				continue
+			}
+			enabled = rc.IsEnabled()
+			if rc.Severity != "" {
+				rule.Severity = rc.Severity
+			}
+		}
+		if enabled {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// ExpiredSuppressions returns the suppressions expired at the time
+func (c *Config) ExpiredSuppressions(now time.Time) []Suppression {
+	expired := make([]Suppression, 0)
+	for _, s := range c.Suppressions {
+		if s.IsExpired(now) {
+			expired = append(expired, s)
+		}
+	}
+	return expired
+}
+
+// waiver returns the waiver of the finding if it is suppressed at the time, otherwise nil.
+// A finding of the PSP is suppressed by subjects or namespaces only if every subject granted the PSP is covered
+func (c *Config) waiver(f Finding, grants []relations.SubjectGrant, now time.Time) *Waiver {
+	candidates := make([]Suppression, 0)
+	for _, s := range c.Suppressions {
+		if s.IsExpired(now) || (s.Rule != "" && s.Rule != f.RuleID) || (s.PSP != "" && s.PSP != f.PSP) {
+			continue
+		}
+		if s.Subject == nil && s.Namespace == "" {
+			return newWaiver([]Suppression{s})
+		}
+		candidates = append(candidates, s)
+	}
+	if len(candidates) == 0 || len(grants) == 0 {
+		return nil
+	}
+
+	applied := make([]Suppression, 0)
+	used := make(map[int]bool)
+	for _, g := range grants {
+		covered := false
+		for i, s := range candidates {
+			if s.covers(g) {
+				covered = true
+				if !used[i] {
+					used[i] = true
+					applied = append(applied, s)
+				}
+			}
+		}
+		if !covered {
+			return nil
+		}
+	}
+	return newWaiver(applied)
+}
+
+func newWaiver(suppressions []Suppression) *Waiver {
+	justifications := make([]string, 0, len(suppressions))
+	expires := make([]string, 0, len(suppressions))
+	for _, s := range suppressions {
+		justifications = append(justifications, s.Justification)
+		expires = append(expires, s.Expires)
+	}
+	sort.Strings(expires)
+	return &Waiver{Justification: strings.Join(justifications, "; "), Expires: expires[0]}
+}
+
+// ParseConfig decodes the audit config yaml or json
+func ParseConfig(data []byte) (*Config, error) {
+	c := &Config{}
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// LoadConfig reads the audit config file
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(data)
+}
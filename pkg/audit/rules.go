@@ -0,0 +1,240 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/jlandowner/psp-util/pkg/policy"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Rule is a built-in check of PSP settings
+type Rule struct {
+	ID    string
+	Title string
+	// Severity is the base severity before weighted by the exposure
+	Severity Severity
+	// Check returns a message for each dangerous setting found in the PSP
+	Check func(psp policyv1.PodSecurityPolicy) []string
+}
+
+// SensitiveHostPaths are the host paths which give control of the node or the container runtime
+var SensitiveHostPaths = []string{
+	"/", "/boot", "/dev", "/etc", "/proc", "/root", "/run", "/sys",
+	"/var/lib/containerd", "/var/lib/docker", "/var/lib/kubelet", "/var/run",
+}
+
+// Rules are all the built-in rules.
+// Settings beyond the baseline Pod Security Standard are medium or higher, and the others are low
+var Rules = []Rule{
+	{
+		ID:       "PSP001",
+		Title:    "Privileged containers",
+		Severity: SeverityHigh,
+		Check: func(psp policyv1.PodSecurityPolicy) []string {
+			return messageIf(psp.Spec.Privileged, "privileged: true")
+		},
+	},
+	{
+		ID:       "PSP002",
+		Title:    "Host network",
+		Severity: SeverityMedium,
+		Check: func(psp policyv1.PodSecurityPolicy) []string {
+			return messageIf(psp.Spec.HostNetwork, "hostNetwork: true")
+		},
+	},
+	{
+		ID:       "PSP003",
+		Title:    "Host PID namespace",
+		Severity: SeverityMedium,
+		Check: func(psp policyv1.PodSecurityPolicy) []string {
+			return messageIf(psp.Spec.HostPID, "hostPID: true")
+		},
+	},
+	{
+		ID:       "PSP004",
+		Title:    "Host IPC namespace",
+		Severity: SeverityMedium,
+		Check: func(psp policyv1.PodSecurityPolicy) []string {
+			return messageIf(psp.Spec.HostIPC, "hostIPC: true")
+		},
+	},
+	{
+		ID:       "PSP005",
+		Title:    "Writable hostPath volumes",
+		Severity: SeverityHigh,
+		Check: func(psp policyv1.PodSecurityPolicy) []string {
+			if !policy.AllowsVolume(psp.Spec, policyv1.HostPath) {
+				return nil
+			}
+			if len(psp.Spec.AllowedHostPaths) == 0 {
+				return []string{"hostPath volumes of any path are allowed"}
+			}
+			msgs := make([]string, 0)
+			for _, p := range psp.Spec.AllowedHostPaths {
+				if !p.ReadOnly {
+					msgs = append(msgs, fmt.Sprintf("allowedHostPaths: %s is not readOnly", p.PathPrefix))
+				}
+			}
+			return msgs
+		},
+	},
+	{
+		ID:       "PSP006",
+		Title:    "Broad hostPath prefixes",
+		Severity: SeverityHigh,
+		Check: func(psp policyv1.PodSecurityPolicy) []string {
+			if !policy.AllowsVolume(psp.Spec, policyv1.HostPath) {
+				return nil
+			}
+			msgs := make([]string, 0)
+			for _, p := range psp.Spec.AllowedHostPaths {
+				if covered := sensitivePathsUnder(p.PathPrefix); len(covered) > 0 {
+					msgs = append(msgs, fmt.Sprintf("allowedHostPaths: %s covers %s", p.PathPrefix, strings.Join(covered, ",")))
+				}
+			}
+			return msgs
+		},
+	},
+	{
+		ID:       "PSP007",
+		Title:    "All capabilities",
+		Severity: SeverityHigh,
+		Check: func(psp policyv1.PodSecurityPolicy) []string {
+			return messageIf(capSet(psp.Spec.AllowedCapabilities).Has(policy.AllowAllCapabilities), "allowedCapabilities: *")
+		},
+	},
+	{
+		ID:       "PSP008",
+		Title:    "SYS_ADMIN capability",
+		Severity: SeverityHigh,
+		Check: func(psp policyv1.PodSecurityPolicy) []string {
+			return addedCapability(psp.Spec, "SYS_ADMIN")
+		},
+	},
+	{
+		ID:       "PSP009",
+		Title:    "NET_RAW capability",
+		Severity: SeverityLow,
+		Check: func(psp policyv1.PodSecurityPolicy) []string {
+			if msgs := addedCapability(psp.Spec, "NET_RAW"); len(msgs) > 0 {
+				return msgs
+			}
+			// NET_RAW is granted by container runtimes by default
+			dropped := capSet(psp.Spec.RequiredDropCapabilities)
+			return messageIf(!dropped.Has("ALL") && !dropped.Has("NET_RAW"), "requiredDropCapabilities: NET_RAW is not dropped")
+		},
+	},
+	{
+		ID:       "PSP010",
+		Title:    "Root users",
+		Severity: SeverityLow,
+		Check: func(psp policyv1.PodSecurityPolicy) []string {
+			opts := psp.Spec.RunAsUser
+			switch opts.Rule {
+			case policyv1.RunAsUserStrategyRunAsAny:
+				return []string{"runAsUser: rule RunAsAny"}
+			case policyv1.RunAsUserStrategyMustRunAs:
+				for _, r := range opts.Ranges {
+					if r.Min == 0 {
+						return []string{fmt.Sprintf("runAsUser: range %d-%d includes root", r.Min, r.Max)}
+					}
+				}
+			}
+			return nil
+		},
+	},
+	{
+		ID:       "PSP011",
+		Title:    "Privilege escalation",
+		Severity: SeverityLow,
+		Check: func(psp policyv1.PodSecurityPolicy) []string {
+			return messageIf(policy.AllowPrivilegeEscalation(psp.Spec), "allowPrivilegeEscalation: true")
+		},
+	},
+	{
+		ID:       "PSP012",
+		Title:    "Writable root filesystem",
+		Severity: SeverityLow,
+		Check: func(psp policyv1.PodSecurityPolicy) []string {
+			return messageIf(!psp.Spec.ReadOnlyRootFilesystem, "readOnlyRootFilesystem: false")
+		},
+	},
+	{
+		ID:       "PSP013",
+		Title:    "All volume types",
+		Severity: SeverityHigh,
+		Check: func(psp policyv1.PodSecurityPolicy) []string {
+			return messageIf(policy.AllowsAllVolumes(psp.Spec), "volumes: *")
+		},
+	},
+}
+
+// FindRule returns the built-in rule of the given ID
+func FindRule(id string) (Rule, bool) {
+	for _, r := range Rules {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+func messageIf(found bool, msg string) []string {
+	if found {
+		return []string{msg}
+	}
+	return nil
+}
+
+// sensitivePathsUnder returns the sensitive host paths which the prefix allows to mount
+func sensitivePathsUnder(prefix string) []string {
+	covered := make([]string, 0)
+	for _, p := range SensitiveHostPaths {
+		if policy.HasPathPrefix(p, prefix) {
+			covered = append(covered, p)
+		}
+	}
+	// a prefix covering "/" is reported as "/" only
+	if len(covered) > 0 && path.Clean(prefix) == "/" {
+		return []string{"/"}
+	}
+	return covered
+}
+
+func addedCapability(spec policyv1.PodSecurityPolicySpec, capability string) []string {
+	msgs := make([]string, 0)
+	if capSet(spec.AllowedCapabilities).Has(capability) {
+		msgs = append(msgs, "allowedCapabilities: "+capability)
+	}
+	if capSet(spec.DefaultAddCapabilities).Has(capability) {
+		msgs = append(msgs, "defaultAddCapabilities: "+capability)
+	}
+	return msgs
+}
+
+func capSet(caps []corev1.Capability) sets.String {
+	s := sets.NewString()
+	for _, c := range caps {
+		s.Insert(strings.ToUpper(strings.TrimPrefix(string(c), "CAP_")))
+	}
+	return s
+}
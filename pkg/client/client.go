@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
@@ -63,6 +64,24 @@ func GetDefaultNamespace(kubeconfigPath *string) (string, error) {
 	return namespace, nil
 }
 
+// ListContexts returns the names of all contexts in kubeconfig in alphabetical order
+func ListContexts(kubeconfigPath *string) ([]string, error) {
+	if *kubeconfigPath == "" {
+		*kubeconfigPath = filepath.Join(homeDir(), ".kube", "config")
+	}
+	config, err := clientcmd.LoadFromFile(*kubeconfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	contexts := make([]string, 0, len(config.Contexts))
+	for name := range config.Contexts {
+		contexts = append(contexts, name)
+	}
+	sort.Strings(contexts)
+	return contexts, nil
+}
+
 func homeDir() string {
 	if h := os.Getenv("HOME"); h != "" {
 		return h
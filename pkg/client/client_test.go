@@ -35,6 +35,17 @@ func TestGetDefaultNamespace(t *testing.T) {
 	}
 }
 
+func TestListContexts(t *testing.T) {
+	kubeconfig := "../../test/config"
+	contexts, err := ListContexts(&kubeconfig)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"docker-desktop", "docker-for-desktop"}, contexts)
+
+	kubeconfig = "../../test/notfound"
+	_, err = ListContexts(&kubeconfig)
+	assert.NotNil(t, err)
+}
+
 func getCurrentNamespaceInDefaultKubeconfig() string {
 	config, err := readKubeconfig(homeDir() + "/.kube/config")
 	if err != nil {
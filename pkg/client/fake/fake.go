@@ -0,0 +1,327 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fake provides a fake clientset for testing the packages of psp-util
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	rbacv1client "k8s.io/client-go/kubernetes/typed/rbac/v1"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// Clientset is a fake clientset which also emulates the resource versions and UIDs of the objects,
+// and the preconditions of the deletions of RBAC resources
+type Clientset struct {
+	*fake.Clientset
+}
+
+// NewClientset returns a fake clientset with the objects which also accepts server-side apply.
+// The fake clientset of client-go does not support apply patches,
+// so that it is emulated by merging the labels and annotations and replacing the other fields given in the configuration.
+// Every write assigns a new resourceVersion, and updates with a stale resourceVersion are rejected as conflicts.
+// Dry-run is not supported.
+func NewClientset(objects ...runtime.Object) *Clientset {
+	versions := &versioner{}
+	stamped := make([]runtime.Object, 0, len(objects))
+	for _, obj := range objects {
+		obj = obj.DeepCopyObject()
+		versions.stamp(obj, "")
+		stamped = append(stamped, obj)
+	}
+	c := &Clientset{fake.NewSimpleClientset(stamped...)}
+	tracker := c.Tracker()
+	c.PrependReactor("delete", "*", deleteReactor(tracker))
+	c.PrependReactor("update", "*", updateReactor(tracker, versions))
+	c.PrependReactor("create", "*", createReactor(tracker, versions))
+	c.PrependReactor("patch", "*", applyReactor(tracker, versions))
+	return c
+}
+
+// RbacV1 returns the RBAC client which sends the options of deletions to the reactors.
+// The typed fake clients of client-go drop them
+func (c *Clientset) RbacV1() rbacv1client.RbacV1Interface {
+	return &rbacV1{RbacV1Interface: c.Clientset.RbacV1(), fake: c.Clientset}
+}
+
+// versioner assigns the UIDs and resource versions of the objects
+type versioner struct {
+	last int64
+}
+
+// stamp sets a new resourceVersion to the object, and the UID if it is empty
+func (v *versioner) stamp(obj runtime.Object, uid types.UID) {
+	objMeta, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	rv := strconv.FormatInt(atomic.AddInt64(&v.last, 1), 10)
+	if uid == "" {
+		uid = objMeta.GetUID()
+	}
+	if uid == "" {
+		uid = types.UID("uid-" + rv)
+	}
+	objMeta.SetUID(uid)
+	objMeta.SetResourceVersion(rv)
+}
+
+func createReactor(tracker k8stesting.ObjectTracker, versions *versioner) k8stesting.ReactionFunc {
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		create, ok := action.(k8stesting.CreateAction)
+		if !ok || create.GetSubresource() != "" {
+			return false, nil, nil
+		}
+		obj := create.GetObject().DeepCopyObject()
+		versions.stamp(obj, "")
+		if err := tracker.Create(create.GetResource(), obj, create.GetNamespace()); err != nil {
+			return true, nil, err
+		}
+		return true, obj, nil
+	}
+}
+
+func updateReactor(tracker k8stesting.ObjectTracker, versions *versioner) k8stesting.ReactionFunc {
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		update, ok := action.(k8stesting.UpdateAction)
+		if !ok || update.GetSubresource() != "" {
+			return false, nil, nil
+		}
+		gvr := update.GetResource()
+		obj := update.GetObject().DeepCopyObject()
+		objMeta, err := meta.Accessor(obj)
+		if err != nil {
+			return true, nil, err
+		}
+		current, err := tracker.Get(gvr, update.GetNamespace(), objMeta.GetName())
+		if err != nil {
+			return true, nil, err
+		}
+		currentMeta, err := meta.Accessor(current)
+		if err != nil {
+			return true, nil, err
+		}
+		if rv := objMeta.GetResourceVersion(); rv != "" && rv != currentMeta.GetResourceVersion() {
+			return true, nil, apierrs.NewConflict(gvr.GroupResource(), objMeta.GetName(), fmt.Errorf("the object has been modified"))
+		}
+		versions.stamp(obj, currentMeta.GetUID())
+		if err := tracker.Update(gvr, obj, update.GetNamespace()); err != nil {
+			return true, nil, err
+		}
+		return true, obj, nil
+	}
+}
+
+// deleteAction is a delete action with the options of the request
+type deleteAction struct {
+	k8stesting.DeleteActionImpl
+	Options metav1.DeleteOptions
+}
+
+func (a deleteAction) DeepCopy() k8stesting.Action {
+	return deleteAction{
+		DeleteActionImpl: a.DeleteActionImpl.DeepCopy().(k8stesting.DeleteActionImpl),
+		Options:          *a.Options.DeepCopy(),
+	}
+}
+
+// deleteReactor deletes the objects, and rejects the deletions whose preconditions do not match as the API server does
+func deleteReactor(tracker k8stesting.ObjectTracker) k8stesting.ReactionFunc {
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		del, ok := action.(deleteAction)
+		if !ok {
+			return false, nil, nil
+		}
+		gvr := del.GetResource()
+		current, err := tracker.Get(gvr, del.GetNamespace(), del.GetName())
+		if err != nil {
+			return true, nil, err
+		}
+		currentMeta, err := meta.Accessor(current)
+		if err != nil {
+			return true, nil, err
+		}
+		pre := del.Options.Preconditions
+		if pre == nil {
+			pre = &metav1.Preconditions{}
+		}
+		if pre.UID != nil && *pre.UID != currentMeta.GetUID() {
+			return true, nil, apierrs.NewConflict(gvr.GroupResource(), del.GetName(),
+				fmt.Errorf("Precondition failed: UID in precondition: %v, UID in object meta: %v", *pre.UID, currentMeta.GetUID()))
+		}
+		if pre.ResourceVersion != nil && *pre.ResourceVersion != currentMeta.GetResourceVersion() {
+			return true, nil, apierrs.NewConflict(gvr.GroupResource(), del.GetName(),
+				fmt.Errorf("Precondition failed: ResourceVersion in precondition: %v, ResourceVersion in object meta: %v", *pre.ResourceVersion, currentMeta.GetResourceVersion()))
+		}
+		return true, nil, tracker.Delete(gvr, del.GetNamespace(), del.GetName())
+	}
+}
+
+func invokeDelete(f *fake.Clientset, resource, namespace, name string, opts metav1.DeleteOptions) error {
+	gvr := rbacv1.SchemeGroupVersion.WithResource(resource)
+	action := deleteAction{DeleteActionImpl: k8stesting.NewDeleteAction(gvr, namespace, name), Options: opts}
+	_, err := f.Invokes(action, &metav1.Status{})
+	return err
+}
+
+type rbacV1 struct {
+	rbacv1client.RbacV1Interface
+	fake *fake.Clientset
+}
+
+func (c *rbacV1) ClusterRoles() rbacv1client.ClusterRoleInterface {
+	return &clusterRoles{ClusterRoleInterface: c.RbacV1Interface.ClusterRoles(), fake: c.fake}
+}
+
+func (c *rbacV1) ClusterRoleBindings() rbacv1client.ClusterRoleBindingInterface {
+	return &clusterRoleBindings{ClusterRoleBindingInterface: c.RbacV1Interface.ClusterRoleBindings(), fake: c.fake}
+}
+
+func (c *rbacV1) RoleBindings(namespace string) rbacv1client.RoleBindingInterface {
+	return &roleBindings{RoleBindingInterface: c.RbacV1Interface.RoleBindings(namespace), fake: c.fake, ns: namespace}
+}
+
+type clusterRoles struct {
+	rbacv1client.ClusterRoleInterface
+	fake *fake.Clientset
+}
+
+func (c *clusterRoles) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	return invokeDelete(c.fake, "clusterroles", "", name, opts)
+}
+
+type clusterRoleBindings struct {
+	rbacv1client.ClusterRoleBindingInterface
+	fake *fake.Clientset
+}
+
+func (c *clusterRoleBindings) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	return invokeDelete(c.fake, "clusterrolebindings", "", name, opts)
+}
+
+type roleBindings struct {
+	rbacv1client.RoleBindingInterface
+	fake *fake.Clientset
+	ns   string
+}
+
+func (c *roleBindings) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	return invokeDelete(c.fake, "rolebindings", c.ns, name, opts)
+}
+
+func applyReactor(tracker k8stesting.ObjectTracker, versions *versioner) k8stesting.ReactionFunc {
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch, ok := action.(k8stesting.PatchAction)
+		if !ok || patch.GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+		gvr := patch.GetResource()
+		ns := patch.GetNamespace()
+
+		config := make(map[string]interface{})
+		if err := json.Unmarshal(patch.GetPatch(), &config); err != nil {
+			return true, nil, apierrs.NewBadRequest(err.Error())
+		}
+		gvk := schema.FromAPIVersionAndKind(stringField(config, "apiVersion"), stringField(config, "kind"))
+
+		current, err := tracker.Get(gvr, ns, patch.GetName())
+		if apierrs.IsNotFound(err) {
+			obj, err := toObject(gvk, config)
+			if err != nil {
+				return true, nil, apierrs.NewBadRequest(err.Error())
+			}
+			versions.stamp(obj, "")
+			return true, obj, tracker.Create(gvr, obj, ns)
+		}
+		if err != nil {
+			return true, nil, err
+		}
+
+		currentMeta, err := meta.Accessor(current)
+		if err != nil {
+			return true, nil, err
+		}
+		metadata, _ := config["metadata"].(map[string]interface{})
+		if rv := stringField(metadata, "resourceVersion"); rv != "" && rv != currentMeta.GetResourceVersion() {
+			return true, nil, apierrs.NewConflict(gvr.GroupResource(), patch.GetName(), fmt.Errorf("the object has been modified"))
+		}
+
+		merged, err := runtime.DefaultUnstructuredConverter.ToUnstructured(current)
+		if err != nil {
+			return true, nil, err
+		}
+		for k, v := range config {
+			if k != "metadata" {
+				merged[k] = v
+			}
+		}
+		mergedMeta, _ := merged["metadata"].(map[string]interface{})
+		for _, k := range []string{"labels", "annotations"} {
+			mergedMeta[k] = mergeMap(mergedMeta[k], metadata[k])
+		}
+
+		obj, err := toObject(gvk, merged)
+		if err != nil {
+			return true, nil, apierrs.NewBadRequest(err.Error())
+		}
+		versions.stamp(obj, currentMeta.GetUID())
+		return true, obj, tracker.Update(gvr, obj, ns)
+	}
+}
+
+func toObject(gvk schema.GroupVersionKind, u map[string]interface{}) (runtime.Object, error) {
+	obj, err := scheme.Scheme.New(gvk)
+	if err != nil {
+		return nil, err
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func stringField(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+func mergeMap(dst, src interface{}) interface{} {
+	s, ok := src.(map[string]interface{})
+	if !ok {
+		return dst
+	}
+	d, ok := dst.(map[string]interface{})
+	if !ok {
+		d = make(map[string]interface{})
+	}
+	for k, v := range s {
+		d[k] = v
+	}
+	return d
+}
@@ -0,0 +1,243 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package convert
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jlandowner/psp-util/pkg/policy"
+	"github.com/jlandowner/psp-util/pkg/relations"
+	policyv1 "k8s.io/api/policy/v1beta1"
+)
+
+const (
+	TargetKyverno    = "kyverno"
+	TargetGatekeeper = "gatekeeper"
+
+	AnnotationKeyConvertedFrom     = "psp-util.k8s.jlandowner.com/converted-from"
+	AnnotationKeyUnsupportedFields = "psp-util.k8s.jlandowner.com/unsupported-fields"
+)
+
+// Targets are the policy engines PSPs can be converted for
+var Targets = []string{TargetKyverno, TargetGatekeeper}
+
+// Scope is the set of Pods which the PSP applies to today
+type Scope struct {
+	AllNamespaces bool     `json:"allNamespaces"`
+	Namespaces    []string `json:"namespaces"`
+	// ServiceAccounts are NAMESPACE/NAME of ServiceAccounts
+	ServiceAccounts []string `json:"serviceAccounts"`
+}
+
+// IsEmpty returns true if no Pod is in the scope
+func (s Scope) IsEmpty() bool {
+	return !s.AllNamespaces && len(s.Namespaces) == 0 && len(s.ServiceAccounts) == 0
+}
+
+// UnsupportedField is a PSP field which is not converted
+type UnsupportedField struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+func (u UnsupportedField) String() string {
+	return fmt.Sprintf("%s: %s", u.Field, u.Reason)
+}
+
+// Result is the converted policies of a PSP
+type Result struct {
+	PSP         string             `json:"psp"`
+	Scope       Scope              `json:"scope"`
+	Objects     []interface{}      `json:"objects"`
+	Unsupported []UnsupportedField `json:"unsupported"`
+}
+
+// Convert translates the PSP into policies of the target policy engine scoped by the subjects using it
+func Convert(psp relations.RelationalPodSecurityPolicy, target string) (*Result, error) {
+	scope, unsupported := GetScope(psp)
+	res := &Result{PSP: psp.Name, Scope: scope}
+
+	switch target {
+	case TargetKyverno:
+		objs, u := ToKyverno(psp.PodSecurityPolicy, scope)
+		res.Objects = objs
+		unsupported = append(unsupported, u...)
+	case TargetGatekeeper:
+		objs, u, err := ToGatekeeper(psp.PodSecurityPolicy, scope)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to convert PSP %s: %v", psp.Name, err)
+		}
+		res.Objects = objs
+		unsupported = append(unsupported, u...)
+	default:
+		return nil, fmt.Errorf("Unsupported target %s: must be one of %s", target, strings.Join(Targets, "|"))
+	}
+	res.Unsupported = unsupported
+
+	if len(unsupported) > 0 {
+		for _, obj := range res.Objects {
+			annotations, ok := obj.(map[string]interface{})["metadata"].(map[string]interface{})["annotations"].(map[string]interface{})
+			if ok {
+				annotations[AnnotationKeyUnsupportedFields] = formatUnsupported(unsupported)
+			}
+		}
+	}
+	return res, nil
+}
+
+// GetScope returns the namespaces and service accounts whose Pods can use the PSP.
+// Users and Groups bound cluster-wide are reported as unsupported since Pods are not matched by the user creating them
+func GetScope(psp relations.RelationalPodSecurityPolicy) (Scope, []UnsupportedField) {
+	namespaces := make(map[string]bool)
+	serviceAccounts := make(map[string]bool)
+	scope := Scope{}
+	unsupported := make([]UnsupportedField, 0)
+
+	for _, g := range psp.SubjectGrants() {
+		for _, path := range g.Paths {
+			inScope := func(ns string) bool {
+				return path.Scope == relations.GrantScopeCluster || path.Namespace == ns
+			}
+			sub := g.Subject
+
+			if sa, ok := relations.ServiceAccountSubject(sub); ok {
+				if inScope(sa.Namespace) {
+					serviceAccounts[sa.Namespace+"/"+sa.Name] = true
+				}
+				continue
+			}
+			if sub.Kind == "Group" && strings.HasPrefix(sub.Name, relations.GroupServiceAccounts+":") {
+				if ns := strings.TrimPrefix(sub.Name, relations.GroupServiceAccounts+":"); inScope(ns) {
+					namespaces[ns] = true
+				}
+				continue
+			}
+			if path.Scope == relations.GrantScopeNamespace {
+				namespaces[path.Namespace] = true
+				continue
+			}
+			if sub.Kind == "Group" {
+				switch sub.Name {
+				case relations.GroupServiceAccounts, relations.GroupAuthenticated, relations.GroupUnauthenticated:
+					scope.AllNamespaces = true
+					continue
+				}
+			}
+			unsupported = append(unsupported, UnsupportedField{
+				Field:  "scope",
+				Reason: fmt.Sprintf("%s/%s is granted cluster-wide by %s/%s but Pods cannot be matched by the user creating them", sub.Kind, sub.Name, path.BindingKind, path.BindingName),
+			})
+		}
+	}
+
+	scope.Namespaces = sortedKeys(namespaces)
+	scope.ServiceAccounts = sortedKeys(serviceAccounts)
+	return scope, unsupported
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// commonUnsupported returns fields which neither of the policy engines can express
+func commonUnsupported(psp policyv1.PodSecurityPolicy) []UnsupportedField {
+	spec := psp.Spec
+	u := make([]UnsupportedField, 0)
+	add := func(field, reason string) {
+		u = append(u, UnsupportedField{Field: field, Reason: reason})
+	}
+
+	if len(spec.AllowedFlexVolumes) > 0 {
+		add("allowedFlexVolumes", "flexVolume drivers are not restricted")
+	}
+	if len(spec.AllowedCSIDrivers) > 0 {
+		add("allowedCSIDrivers", "inline CSI drivers are not restricted")
+	}
+	if spec.RuntimeClass != nil {
+		add("runtimeClass", "RuntimeClass is neither defaulted nor restricted")
+	}
+	if len(spec.DefaultAddCapabilities) > 0 {
+		add("defaultAddCapabilities", "capabilities are not added to containers, they are only allowed")
+	}
+	if spec.DefaultAllowPrivilegeEscalation != nil {
+		add("defaultAllowPrivilegeEscalation", "policies do not mutate Pods")
+	}
+	for _, key := range []string{
+		policy.SeccompAllowedProfilesAnnotationKey, policy.SeccompDefaultProfileAnnotationKey,
+		policy.AppArmorAllowedProfilesAnnotationKey, policy.AppArmorDefaultProfileAnnotationKey,
+	} {
+		if _, ok := psp.Annotations[key]; ok {
+			add("metadata.annotations["+key+"]", "seccomp and AppArmor profiles are not converted")
+		}
+	}
+	for _, field := range defaultedFields(spec) {
+		add(field, "the default is not applied, so Pods must set it explicitly")
+	}
+	return u
+}
+
+// defaultedFields returns fields with which the PSP sets defaults on Pods
+func defaultedFields(spec policyv1.PodSecurityPolicySpec) []string {
+	fields := make([]string, 0)
+	if spec.RunAsUser.Rule == policyv1.RunAsUserStrategyMustRunAs || spec.RunAsUser.Rule == policyv1.RunAsUserStrategyMustRunAsNonRoot {
+		fields = append(fields, "runAsUser")
+	}
+	if spec.RunAsGroup != nil && spec.RunAsGroup.Rule == policyv1.RunAsGroupStrategyMustRunAs {
+		fields = append(fields, "runAsGroup")
+	}
+	if spec.FSGroup.Rule == policyv1.FSGroupStrategyMustRunAs {
+		fields = append(fields, "fsGroup")
+	}
+	if spec.SupplementalGroups.Rule == policyv1.SupplementalGroupsStrategyMustRunAs {
+		fields = append(fields, "supplementalGroups")
+	}
+	if spec.SELinux.Rule == policyv1.SELinuxStrategyMustRunAs {
+		fields = append(fields, "seLinux")
+	}
+	if len(spec.RequiredDropCapabilities) > 0 {
+		fields = append(fields, "requiredDropCapabilities")
+	}
+	if spec.ReadOnlyRootFilesystem {
+		fields = append(fields, "readOnlyRootFilesystem")
+	}
+	if !policy.AllowPrivilegeEscalation(spec) {
+		fields = append(fields, "allowPrivilegeEscalation")
+	}
+	return fields
+}
+
+func formatUnsupported(u []UnsupportedField) string {
+	fields := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, f := range u {
+		if !seen[f.Field] {
+			seen[f.Field] = true
+			fields = append(fields, f.Field)
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+func policyName(pspName string) string {
+	return "psp-" + pspName
+}
@@ -0,0 +1,347 @@
+package convert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jlandowner/psp-util/pkg/relations"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newRelationalPSP(crbSubjects []rbacv1.Subject, rbs ...*rbacv1.RoleBinding) relations.RelationalPodSecurityPolicy {
+	f := false
+	return relations.RelationalPodSecurityPolicy{
+		PodSecurityPolicy: policyv1.PodSecurityPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "restricted"},
+			Spec: policyv1.PodSecurityPolicySpec{
+				AllowPrivilegeEscalation: &f,
+				RequiredDropCapabilities: []corev1.Capability{"ALL"},
+				AllowedFlexVolumes:       []policyv1.AllowedFlexVolume{{Driver: "example/cifs"}},
+				Volumes:                  []policyv1.FSType{policyv1.ConfigMap, policyv1.Secret},
+				SELinux:                  policyv1.SELinuxStrategyOptions{Rule: policyv1.SELinuxStrategyRunAsAny},
+				RunAsUser:                policyv1.RunAsUserStrategyOptions{Rule: policyv1.RunAsUserStrategyRunAsAny},
+				SupplementalGroups:       policyv1.SupplementalGroupsStrategyOptions{Rule: policyv1.SupplementalGroupsStrategyRunAsAny},
+				FSGroup:                  policyv1.FSGroupStrategyOptions{Rule: policyv1.FSGroupStrategyMayRunAs, Ranges: []policyv1.IDRange{{Min: 1, Max: 65535}}},
+			},
+		},
+		ClusterRoles: []*relations.RelationalClusterRole{
+			{
+				ClusterRole: rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "cr"}},
+				ClusterRoleBindings: []*rbacv1.ClusterRoleBinding{
+					{ObjectMeta: metav1.ObjectMeta{Name: "crb"}, Subjects: crbSubjects},
+				},
+				RoleBindings: rbs,
+			},
+		},
+	}
+}
+
+func TestGetScope(t *testing.T) {
+	sa := rbacv1.Subject{Kind: "ServiceAccount", Namespace: "team-a", Name: "app"}
+	saUser := rbacv1.Subject{Kind: "User", Name: "system:serviceaccount:team-c:default"}
+	nsGroup := rbacv1.Subject{Kind: "Group", Name: "system:serviceaccounts:team-b"}
+	authenticated := rbacv1.Subject{Kind: "Group", Name: "system:authenticated"}
+	user := rbacv1.Subject{Kind: "User", Name: "alice"}
+
+	tests := []struct {
+		title             string
+		psp               relations.RelationalPodSecurityPolicy
+		expectScope       Scope
+		expectUnsupported int
+	}{
+		{
+			title: "service accounts and namespaces",
+			psp: newRelationalPSP([]rbacv1.Subject{sa, saUser, nsGroup},
+				&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "rb", Namespace: "team-d"}, Subjects: []rbacv1.Subject{user}}),
+			expectScope: Scope{
+				Namespaces:      []string{"team-b", "team-d"},
+				ServiceAccounts: []string{"team-a/app", "team-c/default"},
+			},
+		},
+		{
+			title:       "all namespaces and cluster-wide user",
+			psp:         newRelationalPSP([]rbacv1.Subject{authenticated, user}),
+			expectScope: Scope{AllNamespaces: true, Namespaces: []string{}, ServiceAccounts: []string{}},
+			// user cannot be scoped
+			expectUnsupported: 1,
+		},
+		{
+			title: "service account bound in another namespace",
+			psp: newRelationalPSP(nil,
+				&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "rb", Namespace: "team-b"}, Subjects: []rbacv1.Subject{sa}}),
+			expectScope: Scope{Namespaces: []string{}, ServiceAccounts: []string{}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		scope, unsupported := GetScope(test.psp)
+		assert.Equal(t, test.expectScope, scope)
+		assert.Len(t, unsupported, test.expectUnsupported)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	psp := newRelationalPSP([]rbacv1.Subject{{Kind: "ServiceAccount", Namespace: "team-a", Name: "app"}})
+
+	tests := []struct {
+		title         string
+		target        string
+		expectKinds   []string
+		expectRules   []string
+		expectMatchNS []string
+		expectErr     bool
+	}{
+		{
+			title:       "kyverno",
+			target:      TargetKyverno,
+			expectKinds: []string{"ClusterPolicy"},
+			expectRules: []string{
+				"privileged", "allow-privilege-escalation", "host-namespaces", "host-ports", "volumes", "fs-group",
+				"allowed-capabilities", "required-drop-capabilities", "unsafe-sysctls", "allowed-proc-mount-types",
+			},
+		},
+		{
+			title:         "gatekeeper",
+			target:        TargetGatekeeper,
+			expectKinds:   []string{"ConstraintTemplate", GatekeeperConstraintKind},
+			expectMatchNS: []string{"team-a"},
+		},
+		{
+			title:     "unknown",
+			target:    "psp",
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		res, err := Convert(psp, test.target)
+		if test.expectErr {
+			assert.Error(t, err)
+			continue
+		}
+		assert.NoError(t, err)
+
+		kinds := make([]string, len(res.Objects))
+		for i, obj := range res.Objects {
+			kinds[i] = obj.(map[string]interface{})["kind"].(string)
+		}
+		assert.Equal(t, test.expectKinds, kinds)
+
+		last := res.Objects[len(res.Objects)-1].(map[string]interface{})
+		metadata := last["metadata"].(map[string]interface{})
+		assert.Equal(t, "psp-restricted", metadata["name"])
+		assert.Equal(t, "allowedFlexVolumes,requiredDropCapabilities,allowPrivilegeEscalation",
+			metadata["annotations"].(map[string]interface{})[AnnotationKeyUnsupportedFields])
+
+		spec := last["spec"].(map[string]interface{})
+		if test.expectRules != nil {
+			names := make([]string, 0)
+			for _, r := range spec["rules"].([]interface{}) {
+				rule := r.(map[string]interface{})
+				names = append(names, rule["name"].(string))
+				assert.NotNil(t, rule["preconditions"])
+			}
+			assert.Equal(t, test.expectRules, names)
+		}
+		if test.expectMatchNS != nil {
+			assert.Equal(t, test.expectMatchNS, spec["match"].(map[string]interface{})["namespaces"])
+		}
+	}
+}
+
+func TestKyvernoRunAsUser(t *testing.T) {
+	pod := func(podSC, containerSC map[string]interface{}) map[string]interface{} {
+		container := map[string]interface{}{"name": "app"}
+		if containerSC != nil {
+			container["securityContext"] = containerSC
+		}
+		spec := map[string]interface{}{"containers": []interface{}{container}}
+		if podSC != nil {
+			spec["securityContext"] = podSC
+		}
+		return map[string]interface{}{"spec": spec}
+	}
+	sc := func(key string, value interface{}) map[string]interface{} {
+		return map[string]interface{}{key: value}
+	}
+
+	tests := []struct {
+		title       string
+		runAsUser   policyv1.RunAsUserStrategyOptions
+		runAsGroup  *policyv1.RunAsGroupStrategyOptions
+		rule        string
+		pod         map[string]interface{}
+		expectAdmit bool
+	}{
+		{
+			title:     "non-root rejects pod without securityContext",
+			runAsUser: policyv1.RunAsUserStrategyOptions{Rule: policyv1.RunAsUserStrategyMustRunAsNonRoot},
+			rule:      "run-as-non-root",
+			pod:       pod(nil, nil),
+		},
+		{
+			title:       "non-root admits pod-level runAsNonRoot",
+			runAsUser:   policyv1.RunAsUserStrategyOptions{Rule: policyv1.RunAsUserStrategyMustRunAsNonRoot},
+			rule:        "run-as-non-root",
+			pod:         pod(sc("runAsNonRoot", true), nil),
+			expectAdmit: true,
+		},
+		{
+			title:       "non-root admits container-level runAsUser",
+			runAsUser:   policyv1.RunAsUserStrategyOptions{Rule: policyv1.RunAsUserStrategyMustRunAsNonRoot},
+			rule:        "run-as-non-root",
+			pod:         pod(nil, sc("runAsUser", 1000)),
+			expectAdmit: true,
+		},
+		{
+			title:     "non-root rejects container overriding runAsUser to root",
+			runAsUser: policyv1.RunAsUserStrategyOptions{Rule: policyv1.RunAsUserStrategyMustRunAsNonRoot},
+			rule:      "run-as-non-root",
+			pod:       pod(sc("runAsNonRoot", true), sc("runAsUser", 0)),
+		},
+		{
+			title:     "non-root rejects pod-level root user",
+			runAsUser: policyv1.RunAsUserStrategyOptions{Rule: policyv1.RunAsUserStrategyMustRunAsNonRoot},
+			rule:      "run-as-non-root",
+			pod:       pod(sc("runAsUser", 0), nil),
+		},
+		{
+			title:     "must run as rejects pod without securityContext",
+			runAsUser: policyv1.RunAsUserStrategyOptions{Rule: policyv1.RunAsUserStrategyMustRunAs, Ranges: []policyv1.IDRange{{Min: 1000, Max: 2000}}},
+			rule:      "run-as-user",
+			pod:       pod(nil, nil),
+		},
+		{
+			title:       "must run as admits pod-level runAsUser in range",
+			runAsUser:   policyv1.RunAsUserStrategyOptions{Rule: policyv1.RunAsUserStrategyMustRunAs, Ranges: []policyv1.IDRange{{Min: 1000, Max: 2000}}},
+			rule:        "run-as-user",
+			pod:         pod(sc("runAsUser", 1500), nil),
+			expectAdmit: true,
+		},
+		{
+			title:     "must run as rejects container runAsUser out of range",
+			runAsUser: policyv1.RunAsUserStrategyOptions{Rule: policyv1.RunAsUserStrategyMustRunAs, Ranges: []policyv1.IDRange{{Min: 1000, Max: 2000}}},
+			rule:      "run-as-user",
+			pod:       pod(sc("runAsUser", 1500), sc("runAsUser", 3000)),
+		},
+		{
+			title:      "must run as group rejects pod without securityContext",
+			runAsUser:  policyv1.RunAsUserStrategyOptions{Rule: policyv1.RunAsUserStrategyRunAsAny},
+			runAsGroup: &policyv1.RunAsGroupStrategyOptions{Rule: policyv1.RunAsGroupStrategyMustRunAs, Ranges: []policyv1.IDRange{{Min: 1000, Max: 2000}}},
+			rule:       "run-as-group",
+			pod:        pod(nil, nil),
+		},
+		{
+			title:       "may run as group admits pod without securityContext",
+			runAsUser:   policyv1.RunAsUserStrategyOptions{Rule: policyv1.RunAsUserStrategyRunAsAny},
+			runAsGroup:  &policyv1.RunAsGroupStrategyOptions{Rule: policyv1.RunAsGroupStrategyMayRunAs, Ranges: []policyv1.IDRange{{Min: 1000, Max: 2000}}},
+			rule:        "run-as-group",
+			pod:         pod(nil, nil),
+			expectAdmit: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		psp := newRelationalPSP(nil).PodSecurityPolicy
+		psp.Spec.RunAsUser = test.runAsUser
+		psp.Spec.RunAsGroup = test.runAsGroup
+		objs, _ := ToKyverno(psp, Scope{AllNamespaces: true})
+
+		var validate map[string]interface{}
+		spec := objs[0].(map[string]interface{})["spec"].(map[string]interface{})
+		for _, r := range spec["rules"].([]interface{}) {
+			if rule := r.(map[string]interface{}); rule["name"] == test.rule {
+				validate = rule["validate"].(map[string]interface{})
+			}
+		}
+		if !assert.NotNil(t, validate) {
+			continue
+		}
+
+		admit := false
+		if p, ok := validate["pattern"]; ok {
+			admit = matchKyvernoPattern(p, test.pod)
+		}
+		if ps, ok := validate["anyPattern"]; ok {
+			for _, p := range ps.([]interface{}) {
+				admit = admit || matchKyvernoPattern(p, test.pod)
+			}
+		}
+		assert.Equal(t, test.expectAdmit, admit)
+	}
+}
+
+// matchKyvernoPattern evaluates the subset of Kyverno patterns generated by ToKyverno:
+// equality anchors =(key), list of a single element pattern, "|" alternatives, "!" negations and "min-max" ranges
+func matchKyvernoPattern(pattern, value interface{}) bool {
+	switch p := pattern.(type) {
+	case map[string]interface{}:
+		v, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, sub := range p {
+			optional := strings.HasPrefix(key, "=(") && strings.HasSuffix(key, ")")
+			if optional {
+				key = strings.TrimSuffix(strings.TrimPrefix(key, "=("), ")")
+			}
+			field, ok := v[key]
+			if !ok {
+				if optional {
+					continue
+				}
+				return false
+			}
+			if !matchKyvernoPattern(sub, field) {
+				return false
+			}
+		}
+		return true
+
+	case []interface{}:
+		v, ok := value.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, e := range v {
+			if !matchKyvernoPattern(p[0], e) {
+				return false
+			}
+		}
+		return true
+
+	case string:
+		actual := fmt.Sprint(value)
+		for _, alt := range strings.Split(p, "|") {
+			alt = strings.TrimSpace(alt)
+			if strings.HasPrefix(alt, "!") {
+				if actual != strings.TrimPrefix(alt, "!") {
+					return true
+				}
+				continue
+			}
+			if r := strings.SplitN(alt, "-", 2); len(r) == 2 {
+				min, _ := strconv.Atoi(r[0])
+				max, _ := strconv.Atoi(r[1])
+				if n, err := strconv.Atoi(actual); err == nil && min <= n && n <= max {
+					return true
+				}
+				continue
+			}
+			if actual == alt {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
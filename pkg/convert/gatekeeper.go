@@ -0,0 +1,457 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package convert
+
+import (
+	"encoding/json"
+	"strings"
+
+	policyv1 "k8s.io/api/policy/v1beta1"
+)
+
+const (
+	gatekeeperTemplateAPIVersion   = "templates.gatekeeper.sh/v1beta1"
+	gatekeeperConstraintAPIVersion = "constraints.gatekeeper.sh/v1beta1"
+	gatekeeperTarget               = "admission.k8s.gatekeeper.sh"
+
+	GatekeeperConstraintKind = "PSPUtilPodSecurityPolicy"
+	GatekeeperTemplateName   = "psputilpodsecuritypolicy"
+)
+
+// gatekeeperRego validates Pods by a PodSecurityPolicySpec given as the parameter in the same manner as PSP
+const gatekeeperRego = `package psputil
+
+pod := input.review.object
+
+spec := input.parameters.spec
+
+containers[c] {
+  c := pod.spec.containers[_]
+}
+
+containers[c] {
+  c := pod.spec.initContainers[_]
+}
+
+containers[c] {
+  c := pod.spec.ephemeralContainers[_]
+}
+
+in_scope {
+  input.parameters.scope.allNamespaces
+}
+
+in_scope {
+  input.review.namespace == input.parameters.scope.namespaces[_]
+}
+
+in_scope {
+  sa := object.get(pod.spec, "serviceAccountName", "default")
+  concat("/", [input.review.namespace, sa]) == input.parameters.scope.serviceAccounts[_]
+}
+
+security_context(c) = sc {
+  sc := c.securityContext
+} else = {} {
+  true
+}
+
+pod_security_context = sc {
+  sc := pod.spec.securityContext
+} else = {} {
+  true
+}
+
+effective(c, key) = v {
+  v := security_context(c)[key]
+} else = v {
+  v := pod_security_context[key]
+}
+
+in_ranges(v, ranges) {
+  r := ranges[_]
+  v >= r.min
+  v <= r.max
+}
+
+has_path_prefix(path, prefix) {
+  path == trim_suffix(prefix, "/")
+}
+
+has_path_prefix(path, prefix) {
+  startswith(path, concat("", [trim_suffix(prefix, "/"), "/"]))
+}
+
+violation[{"msg": msg}] {
+  in_scope
+  not spec.privileged
+  c := containers[_]
+  security_context(c).privileged
+  msg := sprintf("[%v] privileged container %v is not allowed", [input.parameters.psp, c.name])
+}
+
+violation[{"msg": msg}] {
+  in_scope
+  spec.allowPrivilegeEscalation == false
+  c := containers[_]
+  not security_context(c).allowPrivilegeEscalation == false
+  msg := sprintf("[%v] allowPrivilegeEscalation of container %v must be false", [input.parameters.psp, c.name])
+}
+
+violation[{"msg": msg}] {
+  in_scope
+  field := {"hostNetwork", "hostPID", "hostIPC"}[_]
+  pod.spec[field]
+  not spec[field]
+  msg := sprintf("[%v] %v is not allowed", [input.parameters.psp, field])
+}
+
+violation[{"msg": msg}] {
+  in_scope
+  c := containers[_]
+  port := c.ports[_].hostPort
+  port != 0
+  not in_ranges(port, object.get(spec, "hostPorts", []))
+  msg := sprintf("[%v] hostPort %v of container %v is not allowed", [input.parameters.psp, port, c.name])
+}
+
+allows_volume(t) {
+  spec.volumes[_] == "*"
+}
+
+allows_volume(t) {
+  spec.volumes[_] == t
+}
+
+violation[{"msg": msg}] {
+  in_scope
+  v := pod.spec.volumes[_]
+  t := [k | v[k]; k != "name"][0]
+  not allows_volume(t)
+  msg := sprintf("[%v] %v volume %v is not allowed", [input.parameters.psp, t, v.name])
+}
+
+host_path_allowed(path) {
+  has_path_prefix(path, spec.allowedHostPaths[_].pathPrefix)
+}
+
+host_path_writable(path) {
+  a := spec.allowedHostPaths[_]
+  has_path_prefix(path, a.pathPrefix)
+  not a.readOnly
+}
+
+violation[{"msg": msg}] {
+  in_scope
+  count(object.get(spec, "allowedHostPaths", [])) > 0
+  v := pod.spec.volumes[_]
+  not host_path_allowed(v.hostPath.path)
+  msg := sprintf("[%v] hostPath %v is not allowed", [input.parameters.psp, v.hostPath.path])
+}
+
+violation[{"msg": msg}] {
+  in_scope
+  count(object.get(spec, "allowedHostPaths", [])) > 0
+  v := pod.spec.volumes[_]
+  host_path_allowed(v.hostPath.path)
+  not host_path_writable(v.hostPath.path)
+  c := containers[_]
+  m := c.volumeMounts[_]
+  m.name == v.name
+  not m.readOnly
+  msg := sprintf("[%v] hostPath %v must be mounted readOnly in container %v", [input.parameters.psp, v.hostPath.path, c.name])
+}
+
+non_root(c) {
+  effective(c, "runAsUser") != 0
+}
+
+non_root(c) {
+  not effective(c, "runAsUser")
+  effective(c, "runAsNonRoot") == true
+}
+
+run_as_user_allowed(c) {
+  in_ranges(effective(c, "runAsUser"), spec.runAsUser.ranges)
+}
+
+violation[{"msg": msg}] {
+  in_scope
+  spec.runAsUser.rule == "MustRunAs"
+  c := containers[_]
+  not run_as_user_allowed(c)
+  msg := sprintf("[%v] runAsUser of container %v must be in the allowed ranges", [input.parameters.psp, c.name])
+}
+
+violation[{"msg": msg}] {
+  in_scope
+  spec.runAsUser.rule == "MustRunAsNonRoot"
+  c := containers[_]
+  not non_root(c)
+  msg := sprintf("[%v] container %v must run as non-root user", [input.parameters.psp, c.name])
+}
+
+run_as_group_allowed(c) {
+  in_ranges(effective(c, "runAsGroup"), spec.runAsGroup.ranges)
+}
+
+violation[{"msg": msg}] {
+  in_scope
+  spec.runAsGroup.rule == "MustRunAs"
+  c := containers[_]
+  not run_as_group_allowed(c)
+  msg := sprintf("[%v] runAsGroup of container %v must be in the allowed ranges", [input.parameters.psp, c.name])
+}
+
+violation[{"msg": msg}] {
+  in_scope
+  spec.runAsGroup.rule == "MayRunAs"
+  c := containers[_]
+  g := effective(c, "runAsGroup")
+  not in_ranges(g, spec.runAsGroup.ranges)
+  msg := sprintf("[%v] runAsGroup %v of container %v is not allowed", [input.parameters.psp, g, c.name])
+}
+
+fs_group_allowed {
+  in_ranges(pod_security_context.fsGroup, spec.fsGroup.ranges)
+}
+
+violation[{"msg": msg}] {
+  in_scope
+  spec.fsGroup.rule == "MustRunAs"
+  not fs_group_allowed
+  msg := sprintf("[%v] fsGroup must be in the allowed ranges", [input.parameters.psp])
+}
+
+violation[{"msg": msg}] {
+  in_scope
+  spec.fsGroup.rule == "MayRunAs"
+  g := pod_security_context.fsGroup
+  not in_ranges(g, spec.fsGroup.ranges)
+  msg := sprintf("[%v] fsGroup %v is not allowed", [input.parameters.psp, g])
+}
+
+violation[{"msg": msg}] {
+  in_scope
+  spec.supplementalGroups.rule == "MustRunAs"
+  count(object.get(pod_security_context, "supplementalGroups", [])) == 0
+  msg := sprintf("[%v] supplementalGroups must be set", [input.parameters.psp])
+}
+
+violation[{"msg": msg}] {
+  in_scope
+  rule := {"MustRunAs", "MayRunAs"}[_]
+  spec.supplementalGroups.rule == rule
+  g := pod_security_context.supplementalGroups[_]
+  not in_ranges(g, spec.supplementalGroups.ranges)
+  msg := sprintf("[%v] supplementalGroup %v is not allowed", [input.parameters.psp, g])
+}
+
+allowed_capability(cap) {
+  spec.allowedCapabilities[_] == "*"
+}
+
+allowed_capability(cap) {
+  spec.allowedCapabilities[_] == cap
+}
+
+allowed_capability(cap) {
+  spec.defaultAddCapabilities[_] == cap
+}
+
+violation[{"msg": msg}] {
+  in_scope
+  c := containers[_]
+  cap := c.securityContext.capabilities.add[_]
+  not allowed_capability(cap)
+  msg := sprintf("[%v] capability %v of container %v is not allowed", [input.parameters.psp, cap, c.name])
+}
+
+dropped(c, cap) {
+  c.securityContext.capabilities.drop[_] == cap
+}
+
+dropped(c, cap) {
+  c.securityContext.capabilities.drop[_] == "ALL"
+}
+
+violation[{"msg": msg}] {
+  in_scope
+  c := containers[_]
+  cap := spec.requiredDropCapabilities[_]
+  not dropped(c, cap)
+  msg := sprintf("[%v] capability %v must be dropped in container %v", [input.parameters.psp, cap, c.name])
+}
+
+selinux_matches(c, key, value) {
+  opts := effective(c, "seLinuxOptions")
+  opts[key] == value
+}
+
+violation[{"msg": msg}] {
+  in_scope
+  spec.seLinux.rule == "MustRunAs"
+  c := containers[_]
+  want := spec.seLinux.seLinuxOptions[key]
+  not selinux_matches(c, key, want)
+  msg := sprintf("[%v] seLinuxOptions.%v of container %v must be %v", [input.parameters.psp, key, c.name, want])
+}
+
+safe_sysctls := {
+  "kernel.shm_rmid_forced",
+  "net.ipv4.ip_local_port_range",
+  "net.ipv4.ip_unprivileged_port_start",
+  "net.ipv4.tcp_syncookies",
+  "net.ipv4.ping_group_range",
+}
+
+sysctl_matches(name, pattern) {
+  name == pattern
+}
+
+sysctl_matches(name, pattern) {
+  endswith(pattern, "*")
+  startswith(name, trim_suffix(pattern, "*"))
+}
+
+violation[{"msg": msg}] {
+  in_scope
+  name := pod_security_context.sysctls[_].name
+  sysctl_matches(name, spec.forbiddenSysctls[_])
+  msg := sprintf("[%v] sysctl %v is forbidden", [input.parameters.psp, name])
+}
+
+unsafe_sysctl_allowed(name) {
+  sysctl_matches(name, spec.allowedUnsafeSysctls[_])
+}
+
+violation[{"msg": msg}] {
+  in_scope
+  name := pod_security_context.sysctls[_].name
+  not safe_sysctls[name]
+  not unsafe_sysctl_allowed(name)
+  msg := sprintf("[%v] unsafe sysctl %v is not allowed", [input.parameters.psp, name])
+}
+
+allowed_proc_mount(t) {
+  spec.allowedProcMountTypes[_] == t
+}
+
+violation[{"msg": msg}] {
+  in_scope
+  c := containers[_]
+  t := c.securityContext.procMount
+  t != "Default"
+  not allowed_proc_mount(t)
+  msg := sprintf("[%v] procMount %v of container %v is not allowed", [input.parameters.psp, t, c.name])
+}
+
+violation[{"msg": msg}] {
+  in_scope
+  spec.readOnlyRootFilesystem
+  c := containers[_]
+  not security_context(c).readOnlyRootFilesystem == true
+  msg := sprintf("[%v] readOnlyRootFilesystem of container %v must be true", [input.parameters.psp, c.name])
+}
+`
+
+// GatekeeperConstraintTemplate returns the ConstraintTemplate shared by the Constraints converted from PSPs
+func GatekeeperConstraintTemplate() map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": gatekeeperTemplateAPIVersion,
+		"kind":       "ConstraintTemplate",
+		"metadata":   map[string]interface{}{"name": GatekeeperTemplateName},
+		"spec": map[string]interface{}{
+			"crd": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"names": map[string]interface{}{"kind": GatekeeperConstraintKind},
+					"validation": map[string]interface{}{
+						"openAPIV3Schema": map[string]interface{}{
+							"type":                                 "object",
+							"x-kubernetes-preserve-unknown-fields": true,
+						},
+					},
+				},
+			},
+			"targets": []interface{}{
+				map[string]interface{}{
+					"target": gatekeeperTarget,
+					"rego":   gatekeeperRego,
+				},
+			},
+		},
+	}
+}
+
+// ToGatekeeper translates the PSP into the ConstraintTemplate and a Constraint applied to Pods in the scope
+func ToGatekeeper(psp policyv1.PodSecurityPolicy, scope Scope) ([]interface{}, []UnsupportedField, error) {
+	unsupported := commonUnsupported(psp)
+
+	b, err := json.Marshal(psp.Spec)
+	if err != nil {
+		return nil, nil, err
+	}
+	var spec map[string]interface{}
+	if err := json.Unmarshal(b, &spec); err != nil {
+		return nil, nil, err
+	}
+
+	match := map[string]interface{}{
+		"kinds": []interface{}{
+			map[string]interface{}{"apiGroups": []string{""}, "kinds": []string{"Pod"}},
+		},
+	}
+	if !scope.AllNamespaces {
+		namespaces := make(map[string]bool)
+		for _, ns := range scope.Namespaces {
+			namespaces[ns] = true
+		}
+		for _, sa := range scope.ServiceAccounts {
+			if ns, _, ok := splitServiceAccount(sa); ok {
+				namespaces[ns] = true
+			}
+		}
+		if len(namespaces) > 0 {
+			match["namespaces"] = sortedKeys(namespaces)
+		}
+	}
+
+	constraint := map[string]interface{}{
+		"apiVersion": gatekeeperConstraintAPIVersion,
+		"kind":       GatekeeperConstraintKind,
+		"metadata": map[string]interface{}{
+			"name":        policyName(psp.Name),
+			"annotations": map[string]interface{}{AnnotationKeyConvertedFrom: psp.Name},
+		},
+		"spec": map[string]interface{}{
+			"match": match,
+			"parameters": map[string]interface{}{
+				"psp":   psp.Name,
+				"scope": scope,
+				"spec":  spec,
+			},
+		},
+	}
+	return []interface{}{GatekeeperConstraintTemplate(), constraint}, unsupported, nil
+}
+
+func splitServiceAccount(sa string) (namespace, name string, ok bool) {
+	s := strings.SplitN(sa, "/", 2)
+	if len(s) != 2 {
+		return "", "", false
+	}
+	return s[0], s[1], true
+}
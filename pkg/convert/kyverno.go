@@ -0,0 +1,381 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package convert
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jlandowner/psp-util/pkg/policy"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1beta1"
+)
+
+const (
+	kyvernoAPIVersion = "kyverno.io/v1"
+	kyvernoKind       = "ClusterPolicy"
+
+	kyvernoContainers = "request.object.spec.[ephemeralContainers, initContainers, containers][]"
+)
+
+type kyvernoRules struct {
+	scope Scope
+	rules []interface{}
+}
+
+// ToKyverno translates the PSP into a Kyverno ClusterPolicy applied to Pods in the scope
+func ToKyverno(psp policyv1.PodSecurityPolicy, scope Scope) ([]interface{}, []UnsupportedField) {
+	spec := psp.Spec
+	unsupported := commonUnsupported(psp)
+	k := &kyvernoRules{scope: scope, rules: make([]interface{}, 0)}
+
+	if !spec.Privileged {
+		k.pattern("privileged", "Privileged containers are not allowed",
+			containersPattern(false, map[string]interface{}{"=(privileged)": "false"}))
+	}
+	if !policy.AllowPrivilegeEscalation(spec) {
+		k.pattern("allow-privilege-escalation", "allowPrivilegeEscalation must be set to false",
+			containersPattern(true, map[string]interface{}{"allowPrivilegeEscalation": "false"}))
+	}
+
+	hostNamespaces := make(map[string]interface{})
+	if !spec.HostNetwork {
+		hostNamespaces["=(hostNetwork)"] = "false"
+	}
+	if !spec.HostPID {
+		hostNamespaces["=(hostPID)"] = "false"
+	}
+	if !spec.HostIPC {
+		hostNamespaces["=(hostIPC)"] = "false"
+	}
+	if len(hostNamespaces) > 0 {
+		k.pattern("host-namespaces", "Sharing the host namespaces is not allowed",
+			map[string]interface{}{"spec": hostNamespaces})
+	}
+
+	ports := []string{"0"}
+	for _, r := range spec.HostPorts {
+		ports = append(ports, fmt.Sprintf("%d-%d", r.Min, r.Max))
+	}
+	k.pattern("host-ports", "hostPort is not in the allowed ranges",
+		containersPatternWith("=(ports)", []interface{}{
+			map[string]interface{}{"=(hostPort)": strings.Join(ports, " | ")},
+		}))
+
+	if !policy.AllowsAllVolumes(spec) {
+		allowed := []string{"name"}
+		for _, v := range spec.Volumes {
+			allowed = append(allowed, string(v))
+		}
+		k.deny("volumes", "The volume type is not allowed", map[string]interface{}{
+			"any": []interface{}{
+				condition("{{ request.object.spec.volumes[].keys(@)[] || `[]` }}", "AnyNotIn", allowed),
+			},
+		})
+	}
+
+	if len(spec.AllowedHostPaths) > 0 && policy.AllowsVolume(spec, policyv1.HostPath) {
+		paths := make([]string, 0)
+		for _, p := range spec.AllowedHostPaths {
+			prefix := strings.TrimSuffix(p.PathPrefix, "/")
+			paths = append(paths, prefix, prefix+"/*")
+			if p.ReadOnly {
+				unsupported = append(unsupported, UnsupportedField{
+					Field:  "allowedHostPaths.readOnly",
+					Reason: fmt.Sprintf("readOnly mounts of %s are not enforced", p.PathPrefix),
+				})
+			}
+		}
+		k.foreach("allowed-host-paths", "The hostPath is not allowed", "request.object.spec.volumes[?hostPath]", map[string]interface{}{
+			"any": []interface{}{
+				condition("{{ element.hostPath.path }}", "AnyNotIn", paths),
+			},
+		})
+	}
+
+	switch spec.RunAsUser.Rule {
+	case policyv1.RunAsUserStrategyMustRunAs:
+		k.anyPattern("run-as-user", "runAsUser must be set in the allowed ranges",
+			requiredFieldPatterns("runAsUser", formatRanges(spec.RunAsUser.Ranges))...)
+	case policyv1.RunAsUserStrategyMustRunAsNonRoot:
+		// runAsNonRoot is effective only if runAsUser is not set in the container or the Pod
+		k.anyPattern("run-as-non-root", "runAsNonRoot must be true or runAsUser must be non-zero",
+			podLevelPattern(
+				map[string]interface{}{"runAsNonRoot": "true", "=(runAsUser)": "!0"},
+				map[string]interface{}{"=(runAsUser)": "!0", "=(runAsNonRoot)": "true"}),
+			podLevelPattern(
+				map[string]interface{}{"runAsUser": "!0"},
+				map[string]interface{}{"=(runAsUser)": "!0"}),
+			containerLevelPattern(
+				map[string]interface{}{"runAsNonRoot": "true", "=(runAsUser)": "!0"},
+				map[string]interface{}{"=(runAsUser)": "!0"}),
+			containerLevelPattern(
+				map[string]interface{}{"runAsUser": "!0"},
+				nil))
+	}
+
+	if spec.RunAsGroup != nil {
+		switch spec.RunAsGroup.Rule {
+		case policyv1.RunAsGroupStrategyMustRunAs:
+			k.anyPattern("run-as-group", "runAsGroup must be set in the allowed ranges",
+				requiredFieldPatterns("runAsGroup", formatRanges(spec.RunAsGroup.Ranges))...)
+		case policyv1.RunAsGroupStrategyMayRunAs:
+			k.pattern("run-as-group", "runAsGroup is not in the allowed ranges",
+				podAndContainersPattern(map[string]interface{}{"=(runAsGroup)": formatRanges(spec.RunAsGroup.Ranges)}))
+		}
+	}
+
+	switch spec.FSGroup.Rule {
+	case policyv1.FSGroupStrategyMustRunAs:
+		k.pattern("fs-group", "fsGroup must be set in the allowed ranges",
+			map[string]interface{}{"spec": map[string]interface{}{
+				"securityContext": map[string]interface{}{"fsGroup": formatRanges(spec.FSGroup.Ranges)},
+			}})
+	case policyv1.FSGroupStrategyMayRunAs:
+		k.pattern("fs-group", "fsGroup is not in the allowed ranges",
+			map[string]interface{}{"spec": map[string]interface{}{
+				"=(securityContext)": map[string]interface{}{"=(fsGroup)": formatRanges(spec.FSGroup.Ranges)},
+			}})
+	}
+
+	switch spec.SupplementalGroups.Rule {
+	case policyv1.SupplementalGroupsStrategyMustRunAs:
+		k.pattern("supplemental-groups", "supplementalGroups must be set in the allowed ranges",
+			map[string]interface{}{"spec": map[string]interface{}{
+				"securityContext": map[string]interface{}{"supplementalGroups": []interface{}{formatRanges(spec.SupplementalGroups.Ranges)}},
+			}})
+	case policyv1.SupplementalGroupsStrategyMayRunAs:
+		k.pattern("supplemental-groups", "supplementalGroups is not in the allowed ranges",
+			map[string]interface{}{"spec": map[string]interface{}{
+				"=(securityContext)": map[string]interface{}{"=(supplementalGroups)": []interface{}{formatRanges(spec.SupplementalGroups.Ranges)}},
+			}})
+	}
+
+	if !capsContain(spec.AllowedCapabilities, policy.AllowAllCapabilities) {
+		allowed := append(capStrings(spec.AllowedCapabilities), capStrings(spec.DefaultAddCapabilities)...)
+		k.foreach("allowed-capabilities", "Adding the capability is not allowed", kyvernoContainers, map[string]interface{}{
+			"any": []interface{}{
+				condition("{{ element.securityContext.capabilities.add[] || `[]` }}", "AnyNotIn", allowed),
+			},
+		})
+	}
+	if len(spec.RequiredDropCapabilities) > 0 {
+		k.foreach("required-drop-capabilities", "The capabilities must be dropped", kyvernoContainers, map[string]interface{}{
+			"all": []interface{}{
+				condition(capStrings(spec.RequiredDropCapabilities), "AnyNotIn", "{{ element.securityContext.capabilities.drop[] || `[]` }}"),
+				condition("ALL", "AnyNotIn", "{{ element.securityContext.capabilities.drop[] || `[]` }}"),
+			},
+		})
+	}
+
+	if spec.SELinux.Rule == policyv1.SELinuxStrategyMustRunAs && spec.SELinux.SELinuxOptions != nil {
+		opts := make(map[string]interface{})
+		for key, v := range map[string]string{
+			"=(user)":  spec.SELinux.SELinuxOptions.User,
+			"=(role)":  spec.SELinux.SELinuxOptions.Role,
+			"=(type)":  spec.SELinux.SELinuxOptions.Type,
+			"=(level)": spec.SELinux.SELinuxOptions.Level,
+		} {
+			if v != "" {
+				opts[key] = v
+			}
+		}
+		k.pattern("se-linux", "seLinuxOptions do not match",
+			podAndContainersPattern(map[string]interface{}{"=(seLinuxOptions)": opts}))
+	}
+
+	sysctls := "{{ request.object.spec.securityContext.sysctls[].name || `[]` }}"
+	if len(spec.ForbiddenSysctls) > 0 {
+		k.deny("forbidden-sysctls", "The sysctl is forbidden", map[string]interface{}{
+			"any": []interface{}{condition(sysctls, "AnyIn", spec.ForbiddenSysctls)},
+		})
+	}
+	k.deny("unsafe-sysctls", "The unsafe sysctl is not allowed", map[string]interface{}{
+		"any": []interface{}{condition(sysctls, "AnyNotIn", append(append([]string{}, policy.SafeSysctls...), spec.AllowedUnsafeSysctls...))},
+	})
+
+	procMounts := []string{string(corev1.DefaultProcMount)}
+	for _, t := range spec.AllowedProcMountTypes {
+		if t != corev1.DefaultProcMount {
+			procMounts = append(procMounts, string(t))
+		}
+	}
+	k.pattern("allowed-proc-mount-types", "The procMount type is not allowed",
+		containersPattern(false, map[string]interface{}{"=(procMount)": strings.Join(procMounts, " | ")}))
+
+	if spec.ReadOnlyRootFilesystem {
+		k.pattern("read-only-root-filesystem", "readOnlyRootFilesystem must be set to true",
+			containersPattern(true, map[string]interface{}{"readOnlyRootFilesystem": "true"}))
+	}
+
+	clusterPolicy := map[string]interface{}{
+		"apiVersion": kyvernoAPIVersion,
+		"kind":       kyvernoKind,
+		"metadata": map[string]interface{}{
+			"name":        policyName(psp.Name),
+			"annotations": map[string]interface{}{AnnotationKeyConvertedFrom: psp.Name},
+		},
+		"spec": map[string]interface{}{
+			"validationFailureAction": "enforce",
+			"background":              false,
+			"rules":                   k.rules,
+		},
+	}
+	return []interface{}{clusterPolicy}, unsupported
+}
+
+func (k *kyvernoRules) add(name string, validate map[string]interface{}) {
+	rule := map[string]interface{}{
+		"name": name,
+		"match": map[string]interface{}{
+			"resources": map[string]interface{}{"kinds": []string{"Pod"}},
+		},
+		"validate": validate,
+	}
+	if preconditions := k.preconditions(); preconditions != nil {
+		rule["preconditions"] = preconditions
+	}
+	k.rules = append(k.rules, rule)
+}
+
+func (k *kyvernoRules) pattern(name, message string, pattern map[string]interface{}) {
+	k.add(name, map[string]interface{}{"message": message, "pattern": pattern})
+}
+
+func (k *kyvernoRules) anyPattern(name, message string, patterns ...map[string]interface{}) {
+	anyPattern := make([]interface{}, len(patterns))
+	for i, p := range patterns {
+		anyPattern[i] = p
+	}
+	k.add(name, map[string]interface{}{"message": message, "anyPattern": anyPattern})
+}
+
+func (k *kyvernoRules) deny(name, message string, conditions map[string]interface{}) {
+	k.add(name, map[string]interface{}{
+		"message": message,
+		"deny":    map[string]interface{}{"conditions": conditions},
+	})
+}
+
+func (k *kyvernoRules) foreach(name, message, list string, conditions map[string]interface{}) {
+	k.add(name, map[string]interface{}{
+		"message": message,
+		"foreach": []interface{}{
+			map[string]interface{}{
+				"list": list,
+				"deny": map[string]interface{}{"conditions": conditions},
+			},
+		},
+	})
+}
+
+// preconditions limit the rule to Pods in the scope
+func (k *kyvernoRules) preconditions() map[string]interface{} {
+	if k.scope.AllNamespaces {
+		return nil
+	}
+	conditions := make([]interface{}, 0)
+	if len(k.scope.Namespaces) > 0 {
+		conditions = append(conditions, condition("{{ request.namespace }}", "AnyIn", k.scope.Namespaces))
+	}
+	if len(k.scope.ServiceAccounts) > 0 {
+		conditions = append(conditions, condition("{{ request.namespace }}/{{ request.object.spec.serviceAccountName || 'default' }}", "AnyIn", k.scope.ServiceAccounts))
+	}
+	if len(conditions) == 0 {
+		// no Pod can use the PSP
+		conditions = append(conditions, condition("{{ request.namespace }}", "AnyIn", []string{}))
+	}
+	return map[string]interface{}{"any": conditions}
+}
+
+func condition(key interface{}, operator string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{"key": key, "operator": operator, "value": value}
+}
+
+func containersPattern(required bool, securityContext map[string]interface{}) map[string]interface{} {
+	key := "=(securityContext)"
+	if required {
+		key = "securityContext"
+	}
+	return containersPatternWith(key, securityContext)
+}
+
+func containersPatternWith(key string, value interface{}) map[string]interface{} {
+	container := []interface{}{map[string]interface{}{key: value}}
+	return map[string]interface{}{
+		"spec": map[string]interface{}{
+			"=(ephemeralContainers)": container,
+			"=(initContainers)":      container,
+			"containers":             container,
+		},
+	}
+}
+
+func podAndContainersPattern(securityContext map[string]interface{}) map[string]interface{} {
+	p := containersPattern(false, securityContext)
+	p["spec"].(map[string]interface{})["=(securityContext)"] = securityContext
+	return p
+}
+
+// podLevelPattern requires the securityContext fields of the Pod and allows containers to override them only with the allowed values
+func podLevelPattern(pod, containers map[string]interface{}) map[string]interface{} {
+	p := containersPattern(false, containers)
+	p["spec"].(map[string]interface{})["securityContext"] = pod
+	return p
+}
+
+// containerLevelPattern requires the securityContext fields of every container.
+// The Pod fields are checked only if they are effective for the containers
+func containerLevelPattern(containers, pod map[string]interface{}) map[string]interface{} {
+	p := containersPattern(true, containers)
+	if pod != nil {
+		p["spec"].(map[string]interface{})["=(securityContext)"] = pod
+	}
+	return p
+}
+
+// requiredFieldPatterns requires the field in the Pod or every container securityContext to match the value
+func requiredFieldPatterns(field string, value interface{}) []map[string]interface{} {
+	return []map[string]interface{}{
+		podLevelPattern(map[string]interface{}{field: value}, map[string]interface{}{"=(" + field + ")": value}),
+		containerLevelPattern(map[string]interface{}{field: value}, nil),
+	}
+}
+
+func formatRanges(ranges []policyv1.IDRange) string {
+	s := make([]string, len(ranges))
+	for i, r := range ranges {
+		s[i] = fmt.Sprintf("%d-%d", r.Min, r.Max)
+	}
+	return strings.Join(s, " | ")
+}
+
+func capStrings(caps []corev1.Capability) []string {
+	s := make([]string, len(caps))
+	for i, c := range caps {
+		s[i] = string(c)
+	}
+	return s
+}
+
+func capsContain(caps []corev1.Capability, c string) bool {
+	for _, v := range caps {
+		if string(v) == c {
+			return true
+		}
+	}
+	return false
+}
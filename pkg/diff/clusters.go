@@ -0,0 +1,97 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diff
+
+import (
+	"sort"
+
+	"github.com/jlandowner/psp-util/pkg/relations"
+)
+
+// PSPPresence is a PSP existing in only one of the clusters
+type PSPPresence struct {
+	PSP string `json:"psp"`
+	A   bool   `json:"a"`
+	B   bool   `json:"b"`
+}
+
+// PSPDifference is the differences of a PSP existing in both clusters
+type PSPDifference struct {
+	PSP string `json:"psp"`
+	// Relation is how permissive the PSP in A is compared with the one in B
+	Relation Relation    `json:"relation"`
+	Fields   []FieldDiff `json:"fields,omitempty"`
+	// Subjects are the subjects permitted to use the PSP in only one of the clusters
+	Subjects []SubjectDiff `json:"subjects,omitempty"`
+}
+
+// ClusterComparison is the differences of PSPs and the subjects granted them between two clusters
+type ClusterComparison struct {
+	A string `json:"a"`
+	B string `json:"b"`
+	// Missing are the PSPs existing in only one of the clusters
+	Missing     []PSPPresence   `json:"missing"`
+	Differences []PSPDifference `json:"differences"`
+}
+
+// CompareClusters compares the relational PSPs of the cluster a and b by the PSP name
+func CompareClusters(a, b string, pspsA, pspsB []relations.RelationalPodSecurityPolicy) *ClusterComparison {
+	c := &ClusterComparison{A: a, B: b, Missing: make([]PSPPresence, 0), Differences: make([]PSPDifference, 0)}
+
+	names := make(map[string]bool)
+	for _, psp := range pspsA {
+		names[psp.Name] = true
+	}
+	for _, psp := range pspsB {
+		names[psp.Name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		pa, inA := relations.FindRelationalPSP(pspsA, name)
+		pb, inB := relations.FindRelationalPSP(pspsB, name)
+		if !inA || !inB {
+			c.Missing = append(c.Missing, PSPPresence{PSP: name, A: inA, B: inB})
+			continue
+		}
+
+		cmp := ComparePSPs(*pa, *pb)
+		d := PSPDifference{PSP: name, Relation: cmp.Relation, Fields: cmp.Fields}
+		for _, s := range cmp.Subjects {
+			if s.A != s.B {
+				d.Subjects = append(d.Subjects, s)
+			}
+		}
+		if len(d.Fields) > 0 || len(d.Subjects) > 0 {
+			c.Differences = append(c.Differences, d)
+		}
+	}
+	return c
+}
+
+// IsEmpty returns true if the clusters have the same PSPs granted to the same subjects
+func (c *ClusterComparison) IsEmpty() bool {
+	return len(c.Missing) == 0 && len(c.Differences) == 0
+}
+
+// Count returns the number of the PSPs differing between the clusters
+func (c *ClusterComparison) Count() int {
+	return len(c.Missing) + len(c.Differences)
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diff
+
+import (
+	"sort"
+
+	"github.com/jlandowner/psp-util/pkg/relations"
+	policyv1 "k8s.io/api/policy/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// Relation is how permissive a PSP is compared with another
+type Relation string
+
+const (
+	RelationEqual Relation = "Equal"
+	// RelationMorePermissive is that the first PSP admits everything the second one admits and more
+	RelationMorePermissive Relation = "MorePermissive"
+	// RelationLessPermissive is that the second PSP admits everything the first one admits and more
+	RelationLessPermissive Relation = "LessPermissive"
+	// RelationIncomparable is that each PSP admits something the other does not
+	RelationIncomparable Relation = "Incomparable"
+	// RelationDefaulting is a difference of the defaults set to Pods, which does not change what is admitted
+	RelationDefaulting Relation = "Defaulting"
+)
+
+// FieldDiff is a semantic difference of a PSP field
+type FieldDiff struct {
+	Field string `json:"field"`
+	A     string `json:"a"`
+	B     string `json:"b"`
+	// Relation is how permissive A is compared with B in the field
+	Relation Relation `json:"relation"`
+}
+
+// SubjectDiff is a subject and whether each PSP is granted to it
+type SubjectDiff struct {
+	Subject rbacv1.Subject `json:"subject"`
+	A       bool           `json:"a"`
+	B       bool           `json:"b"`
+}
+
+// Comparison is a semantic comparison of two PSPs
+type Comparison struct {
+	A string `json:"a"`
+	B string `json:"b"`
+	// Relation is how permissive A is compared with B
+	Relation Relation      `json:"relation"`
+	Fields   []FieldDiff   `json:"fields"`
+	Subjects []SubjectDiff `json:"subjects"`
+}
+
+// ComparePSPs compares the specs of the PSPs and the subjects permitted to use each
+func ComparePSPs(a, b relations.RelationalPodSecurityPolicy) *Comparison {
+	fields := CompareSpecs(a.PodSecurityPolicy, b.PodSecurityPolicy)
+	return &Comparison{
+		A:        a.Name,
+		B:        b.Name,
+		Relation: Summarize(fields),
+		Fields:   fields,
+		Subjects: CompareSubjects(a, b),
+	}
+}
+
+// CompareSpecs returns the fields in which the PSPs admit different Pods or set different defaults.
+// The fields semantically equal, e.g. the same capabilities in different order, are not returned
+func CompareSpecs(a, b policyv1.PodSecurityPolicy) []FieldDiff {
+	diffs := make([]FieldDiff, 0)
+	for _, f := range fields {
+		fa, fb := f.format(a), f.format(b)
+		d := FieldDiff{Field: f.name, A: fa, B: fb}
+		if f.covers == nil {
+			if fa == fb {
+				continue
+			}
+			d.Relation = RelationDefaulting
+		} else {
+			d.Relation = relationOf(f.covers(a, b), f.covers(b, a))
+			if d.Relation == RelationEqual {
+				continue
+			}
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs
+}
+
+// Summarize returns how permissive A is compared with B from the field differences
+func Summarize(diffs []FieldDiff) Relation {
+	more, less := false, false
+	for _, d := range diffs {
+		switch d.Relation {
+		case RelationMorePermissive:
+			more = true
+		case RelationLessPermissive:
+			less = true
+		case RelationIncomparable:
+			more, less = true, true
+		}
+	}
+	switch {
+	case more && less:
+		return RelationIncomparable
+	case more:
+		return RelationMorePermissive
+	case less:
+		return RelationLessPermissive
+	}
+	return RelationEqual
+}
+
+// CompareSubjects returns the subjects permitted to use either PSP ordered by the subject
+func CompareSubjects(a, b relations.RelationalPodSecurityPolicy) []SubjectDiff {
+	subs := make([]SubjectDiff, 0)
+	index := make(map[string]int)
+	add := func(sub rbacv1.Subject, inA bool) {
+		key := relations.SubjectKey(sub)
+		i, ok := index[key]
+		if !ok {
+			i = len(subs)
+			index[key] = i
+			subs = append(subs, SubjectDiff{Subject: sub})
+		}
+		if inA {
+			subs[i].A = true
+		} else {
+			subs[i].B = true
+		}
+	}
+	for _, g := range a.SubjectGrants() {
+		add(g.Subject, true)
+	}
+	for _, g := range b.SubjectGrants() {
+		add(g.Subject, false)
+	}
+
+	sort.SliceStable(subs, func(i, j int) bool {
+		return relations.SubjectKey(subs[i].Subject) < relations.SubjectKey(subs[j].Subject)
+	})
+	return subs
+}
+
+func relationOf(aCoversB, bCoversA bool) Relation {
+	switch {
+	case aCoversB && bCoversA:
+		return RelationEqual
+	case aCoversB:
+		return RelationMorePermissive
+	case bCoversA:
+		return RelationLessPermissive
+	}
+	return RelationIncomparable
+}
@@ -0,0 +1,353 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/jlandowner/psp-util/pkg/manifests"
+	"github.com/jlandowner/psp-util/pkg/relations"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func baseline() policyv1.PodSecurityPolicy {
+	f := false
+	return policyv1.PodSecurityPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "baseline"},
+		Spec: policyv1.PodSecurityPolicySpec{
+			AllowPrivilegeEscalation: &f,
+			RequiredDropCapabilities: []corev1.Capability{"NET_RAW"},
+			Volumes:                  []policyv1.FSType{policyv1.ConfigMap, policyv1.Secret, policyv1.HostPath},
+			AllowedHostPaths:         []policyv1.AllowedHostPath{{PathPrefix: "/var/log", ReadOnly: true}},
+			HostPorts:                []policyv1.HostPortRange{{Min: 8000, Max: 8080}},
+			RunAsUser:                policyv1.RunAsUserStrategyOptions{Rule: policyv1.RunAsUserStrategyMustRunAsNonRoot},
+			SupplementalGroups: policyv1.SupplementalGroupsStrategyOptions{
+				Rule:   policyv1.SupplementalGroupsStrategyMustRunAs,
+				Ranges: []policyv1.IDRange{{Min: 1, Max: 65535}},
+			},
+		},
+	}
+}
+
+func TestCompareSpecs(t *testing.T) {
+	tests := []struct {
+		title    string
+		modify   func(p *policyv1.PodSecurityPolicy)
+		fields   map[string]Relation
+		relation Relation
+	}{
+		{
+			title:    "same",
+			modify:   func(p *policyv1.PodSecurityPolicy) {},
+			fields:   map[string]Relation{},
+			relation: RelationEqual,
+		},
+		{
+			title: "same sets and ranges in different order",
+			modify: func(p *policyv1.PodSecurityPolicy) {
+				p.Spec.Volumes = []policyv1.FSType{policyv1.HostPath, policyv1.Secret, policyv1.ConfigMap}
+				p.Spec.HostPorts = []policyv1.HostPortRange{{Min: 8041, Max: 8080}, {Min: 8000, Max: 8040}}
+				p.Spec.RequiredDropCapabilities = []corev1.Capability{"CAP_NET_RAW"}
+			},
+			fields:   map[string]Relation{},
+			relation: RelationEqual,
+		},
+		{
+			title: "privileged",
+			modify: func(p *policyv1.PodSecurityPolicy) {
+				p.Spec.Privileged = true
+			},
+			fields:   map[string]Relation{"privileged": RelationMorePermissive},
+			relation: RelationMorePermissive,
+		},
+		{
+			title: "capabilities",
+			modify: func(p *policyv1.PodSecurityPolicy) {
+				p.Spec.AllowedCapabilities = []corev1.Capability{"*"}
+				p.Spec.RequiredDropCapabilities = []corev1.Capability{"ALL"}
+			},
+			fields: map[string]Relation{
+				"allowedCapabilities":      RelationMorePermissive,
+				"requiredDropCapabilities": RelationLessPermissive,
+			},
+			relation: RelationIncomparable,
+		},
+		{
+			title: "fewer volumes",
+			modify: func(p *policyv1.PodSecurityPolicy) {
+				p.Spec.Volumes = []policyv1.FSType{policyv1.Secret, policyv1.HostPath}
+			},
+			fields:   map[string]Relation{"volumes": RelationLessPermissive},
+			relation: RelationLessPermissive,
+		},
+		{
+			title: "all volumes",
+			modify: func(p *policyv1.PodSecurityPolicy) {
+				p.Spec.Volumes = []policyv1.FSType{policyv1.All}
+			},
+			fields:   map[string]Relation{"volumes": RelationMorePermissive},
+			relation: RelationMorePermissive,
+		},
+		{
+			title: "host path under the allowed prefix",
+			modify: func(p *policyv1.PodSecurityPolicy) {
+				p.Spec.AllowedHostPaths = []policyv1.AllowedHostPath{{PathPrefix: "/var/log/pods", ReadOnly: true}}
+			},
+			fields:   map[string]Relation{"allowedHostPaths": RelationLessPermissive},
+			relation: RelationLessPermissive,
+		},
+		{
+			title: "writable host path of the same prefix",
+			modify: func(p *policyv1.PodSecurityPolicy) {
+				p.Spec.AllowedHostPaths = []policyv1.AllowedHostPath{{PathPrefix: "/var/log"}}
+			},
+			fields:   map[string]Relation{"allowedHostPaths": RelationMorePermissive},
+			relation: RelationMorePermissive,
+		},
+		{
+			title: "host path of other prefix",
+			modify: func(p *policyv1.PodSecurityPolicy) {
+				p.Spec.AllowedHostPaths = []policyv1.AllowedHostPath{{PathPrefix: "/var/lib", ReadOnly: true}}
+			},
+			fields:   map[string]Relation{"allowedHostPaths": RelationIncomparable},
+			relation: RelationIncomparable,
+		},
+		{
+			title: "hostPath volumes not allowed",
+			modify: func(p *policyv1.PodSecurityPolicy) {
+				p.Spec.Volumes = []policyv1.FSType{policyv1.ConfigMap, policyv1.Secret}
+			},
+			fields: map[string]Relation{
+				"volumes":          RelationLessPermissive,
+				"allowedHostPaths": RelationLessPermissive,
+			},
+			relation: RelationLessPermissive,
+		},
+		{
+			title: "host ports in the range",
+			modify: func(p *policyv1.PodSecurityPolicy) {
+				p.Spec.HostPorts = []policyv1.HostPortRange{{Min: 8080, Max: 8080}}
+			},
+			fields:   map[string]Relation{"hostPorts": RelationLessPermissive},
+			relation: RelationLessPermissive,
+		},
+		{
+			title: "user IDs",
+			modify: func(p *policyv1.PodSecurityPolicy) {
+				p.Spec.RunAsUser = policyv1.RunAsUserStrategyOptions{
+					Rule:   policyv1.RunAsUserStrategyMustRunAs,
+					Ranges: []policyv1.IDRange{{Min: 1000, Max: 2000}},
+				}
+			},
+			fields:   map[string]Relation{"runAsUser": RelationLessPermissive},
+			relation: RelationLessPermissive,
+		},
+		{
+			title: "root user ID",
+			modify: func(p *policyv1.PodSecurityPolicy) {
+				p.Spec.RunAsUser = policyv1.RunAsUserStrategyOptions{
+					Rule:   policyv1.RunAsUserStrategyMustRunAs,
+					Ranges: []policyv1.IDRange{{Min: 0, Max: 0}},
+				}
+			},
+			fields:   map[string]Relation{"runAsUser": RelationIncomparable},
+			relation: RelationIncomparable,
+		},
+		{
+			title: "groups may be unset",
+			modify: func(p *policyv1.PodSecurityPolicy) {
+				p.Spec.SupplementalGroups.Rule = policyv1.SupplementalGroupsStrategyMayRunAs
+			},
+			fields:   map[string]Relation{"supplementalGroups": RelationMorePermissive},
+			relation: RelationMorePermissive,
+		},
+		{
+			title: "only defaults",
+			modify: func(p *policyv1.PodSecurityPolicy) {
+				p.Annotations = map[string]string{
+					"seccomp.security.alpha.kubernetes.io/allowedProfileNames": "runtime/default,docker/default",
+				}
+				p.Spec.DefaultAddCapabilities = []corev1.Capability{}
+			},
+			fields:   map[string]Relation{"seccomp allowedProfileNames": RelationMorePermissive},
+			relation: RelationMorePermissive,
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		a := baseline()
+		test.modify(&a)
+
+		diffs := CompareSpecs(a, baseline())
+		fields := make(map[string]Relation)
+		for _, d := range diffs {
+			fields[d.Field] = d.Relation
+		}
+		assert.Equal(t, test.fields, fields)
+		assert.Equal(t, test.relation, Summarize(diffs))
+
+		// swapped
+		reversed := CompareSpecs(baseline(), a)
+		assert.Equal(t, len(diffs), len(reversed))
+		assert.Equal(t, reverse(test.relation), Summarize(reversed))
+	}
+}
+
+func TestCompareSpecsDefaulting(t *testing.T) {
+	a, b := baseline(), baseline()
+	a.Annotations = map[string]string{
+		"seccomp.security.alpha.kubernetes.io/allowedProfileNames": "runtime/default,docker/default",
+		"seccomp.security.alpha.kubernetes.io/defaultProfileName":  "runtime/default",
+	}
+	b.Annotations = map[string]string{
+		"seccomp.security.alpha.kubernetes.io/allowedProfileNames": "docker/default,runtime/default",
+		"seccomp.security.alpha.kubernetes.io/defaultProfileName":  "docker/default",
+	}
+
+	diffs := CompareSpecs(a, b)
+	assert.Equal(t, []FieldDiff{
+		{Field: "seccomp defaultProfileName", A: "runtime/default", B: "docker/default", Relation: RelationDefaulting},
+	}, diffs)
+	assert.Equal(t, RelationEqual, Summarize(diffs))
+}
+
+func TestComparePSPs(t *testing.T) {
+	a, b := baseline(), baseline()
+	a.Name, b.Name = "restricted", "restricted-v2"
+	b.Spec.Privileged = true
+
+	objs := &manifests.Objects{
+		PodSecurityPolicies: policyv1.PodSecurityPolicyList{Items: []policyv1.PodSecurityPolicy{a, b}},
+		ClusterRoles: rbacv1.ClusterRoleList{Items: []rbacv1.ClusterRole{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "use-both"},
+				Rules: []rbacv1.PolicyRule{
+					{APIGroups: []string{"policy"}, Resources: []string{"podsecuritypolicies"}, ResourceNames: []string{"restricted", "restricted-v2"}, Verbs: []string{"use"}},
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "use-v2"},
+				Rules: []rbacv1.PolicyRule{
+					{APIGroups: []string{"policy"}, Resources: []string{"podsecuritypolicies"}, ResourceNames: []string{"restricted-v2"}, Verbs: []string{"use"}},
+				},
+			},
+		}},
+		ClusterRoleBindings: rbacv1.ClusterRoleBindingList{Items: []rbacv1.ClusterRoleBinding{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "use-both"},
+				RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: "use-both"},
+				Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "system:authenticated"}},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "use-v2"},
+				RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: "use-v2"},
+				Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "app", Namespace: "team-a"}},
+			},
+		}},
+	}
+	psps := relations.GetRelationalPSPsFromManifests(objs)
+	pa, _ := relations.FindRelationalPSP(psps, "restricted")
+	pb, _ := relations.FindRelationalPSP(psps, "restricted-v2")
+
+	c := ComparePSPs(*pa, *pb)
+	assert.Equal(t, "restricted", c.A)
+	assert.Equal(t, "restricted-v2", c.B)
+	assert.Equal(t, RelationLessPermissive, c.Relation)
+	assert.Equal(t, []FieldDiff{{Field: "privileged", A: "false", B: "true", Relation: RelationLessPermissive}}, c.Fields)
+	assert.Equal(t, []SubjectDiff{
+		{Subject: rbacv1.Subject{Kind: "Group", Name: "system:authenticated"}, A: true, B: true},
+		{Subject: rbacv1.Subject{Kind: "ServiceAccount", Name: "app", Namespace: "team-a"}, A: false, B: true},
+	}, c.Subjects)
+}
+
+// clusterOf returns the relational PSPs granted to the subjects by a ClusterRole
+func clusterOf(psps []policyv1.PodSecurityPolicy, subjects ...rbacv1.Subject) []relations.RelationalPodSecurityPolicy {
+	names := make([]string, len(psps))
+	for i, psp := range psps {
+		names[i] = psp.Name
+	}
+	return relations.GetRelationalPSPsFromManifests(&manifests.Objects{
+		PodSecurityPolicies: policyv1.PodSecurityPolicyList{Items: psps},
+		ClusterRoles: rbacv1.ClusterRoleList{Items: []rbacv1.ClusterRole{{
+			ObjectMeta: metav1.ObjectMeta{Name: "use-psp"},
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{"policy"}, Resources: []string{"podsecuritypolicies"}, ResourceNames: names, Verbs: []string{"use"}},
+			},
+		}}},
+		ClusterRoleBindings: rbacv1.ClusterRoleBindingList{Items: []rbacv1.ClusterRoleBinding{{
+			ObjectMeta: metav1.ObjectMeta{Name: "use-psp"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: "use-psp"},
+			Subjects:   subjects,
+		}}},
+	})
+}
+
+func TestCompareClusters(t *testing.T) {
+	named := func(name string, privileged bool) policyv1.PodSecurityPolicy {
+		p := baseline()
+		p.Name = name
+		p.Spec.Privileged = privileged
+		return p
+	}
+	authenticated := rbacv1.Subject{Kind: "Group", Name: "system:authenticated"}
+	admin := rbacv1.Subject{Kind: "User", Name: "admin"}
+
+	tests := []struct {
+		title  string
+		a      []relations.RelationalPodSecurityPolicy
+		b      []relations.RelationalPodSecurityPolicy
+		expect *ClusterComparison
+	}{
+		{
+			title: "same",
+			a:     clusterOf([]policyv1.PodSecurityPolicy{named("restricted", false), named("privileged", true)}, authenticated),
+			b:     clusterOf([]policyv1.PodSecurityPolicy{named("privileged", true), named("restricted", false)}, authenticated),
+			expect: &ClusterComparison{
+				A: "staging", B: "prod",
+				Missing:     []PSPPresence{},
+				Differences: []PSPDifference{},
+			},
+		},
+		{
+			title: "different",
+			a:     clusterOf([]policyv1.PodSecurityPolicy{named("restricted", false), named("staging-only", false)}, authenticated),
+			b:     clusterOf([]policyv1.PodSecurityPolicy{named("restricted", true), named("prod-only", false)}, authenticated, admin),
+			expect: &ClusterComparison{
+				A: "staging", B: "prod",
+				Missing: []PSPPresence{
+					{PSP: "prod-only", A: false, B: true},
+					{PSP: "staging-only", A: true, B: false},
+				},
+				Differences: []PSPDifference{
+					{
+						PSP:      "restricted",
+						Relation: RelationLessPermissive,
+						Fields:   []FieldDiff{{Field: "privileged", A: "false", B: "true", Relation: RelationLessPermissive}},
+						Subjects: []SubjectDiff{{Subject: admin, A: false, B: true}},
+					},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		c := CompareClusters("staging", "prod", test.a, test.b)
+		assert.Equal(t, test.expect, c)
+		assert.Equal(t, len(test.expect.Missing)+len(test.expect.Differences), c.Count())
+		assert.Equal(t, c.Count() == 0, c.IsEmpty())
+	}
+}
+
+func reverse(r Relation) Relation {
+	switch r {
+	case RelationMorePermissive:
+		return RelationLessPermissive
+	case RelationLessPermissive:
+		return RelationMorePermissive
+	}
+	return r
+}
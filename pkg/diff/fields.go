@@ -0,0 +1,486 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diff
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jlandowner/psp-util/pkg/policy"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// field is a PSP field compared semantically
+type field struct {
+	name   string
+	format func(psp policyv1.PodSecurityPolicy) string
+	// covers returns true if a admits everything b admits in the field. nil for the fields of defaults
+	covers func(a, b policyv1.PodSecurityPolicy) bool
+}
+
+var fields = []field{
+	allowField("privileged", func(p policyv1.PodSecurityPolicy) bool { return p.Spec.Privileged }),
+	allowField("hostNetwork", func(p policyv1.PodSecurityPolicy) bool { return p.Spec.HostNetwork }),
+	allowField("hostPID", func(p policyv1.PodSecurityPolicy) bool { return p.Spec.HostPID }),
+	allowField("hostIPC", func(p policyv1.PodSecurityPolicy) bool { return p.Spec.HostIPC }),
+	allowField("allowPrivilegeEscalation", func(p policyv1.PodSecurityPolicy) bool { return policy.AllowPrivilegeEscalation(p.Spec) }),
+	{
+		name:   "readOnlyRootFilesystem",
+		format: func(p policyv1.PodSecurityPolicy) string { return strconv.FormatBool(p.Spec.ReadOnlyRootFilesystem) },
+		covers: func(a, b policyv1.PodSecurityPolicy) bool {
+			return !a.Spec.ReadOnlyRootFilesystem || b.Spec.ReadOnlyRootFilesystem
+		},
+	},
+	setField("allowedCapabilities", allowedCapabilities),
+	{
+		name: "requiredDropCapabilities",
+		format: func(p policyv1.PodSecurityPolicy) string {
+			s := requiredDropCapabilities(p)
+			if s.all {
+				return "ALL"
+			}
+			return s.String()
+		},
+		// dropping more capabilities admits less
+		covers: func(a, b policyv1.PodSecurityPolicy) bool {
+			return requiredDropCapabilities(b).covers(requiredDropCapabilities(a))
+		},
+	},
+	setField("volumes", func(p policyv1.PodSecurityPolicy) permSet {
+		s := newPermSet()
+		for _, v := range p.Spec.Volumes {
+			s.add(string(v), v == policyv1.All)
+		}
+		return s
+	}),
+	{
+		name:   "allowedHostPaths",
+		format: formatHostPaths,
+		covers: coversHostPaths,
+	},
+	setField("allowedFlexVolumes", func(p policyv1.PodSecurityPolicy) permSet {
+		s := newPermSet()
+		s.all = len(p.Spec.AllowedFlexVolumes) == 0
+		for _, v := range p.Spec.AllowedFlexVolumes {
+			s.add(v.Driver, false)
+		}
+		return s
+	}),
+	setField("allowedCSIDrivers", func(p policyv1.PodSecurityPolicy) permSet {
+		s := newPermSet()
+		s.all = len(p.Spec.AllowedCSIDrivers) == 0
+		for _, v := range p.Spec.AllowedCSIDrivers {
+			s.add(v.Name, false)
+		}
+		return s
+	}),
+	{
+		name:   "hostPorts",
+		format: func(p policyv1.PodSecurityPolicy) string { return hostPorts(p).String() },
+		covers: func(a, b policyv1.PodSecurityPolicy) bool { return hostPorts(a).covers(hostPorts(b)) },
+	},
+	idField("runAsUser", func(p policyv1.PodSecurityPolicy) idSet {
+		opts := p.Spec.RunAsUser
+		switch opts.Rule {
+		case policyv1.RunAsUserStrategyMustRunAs:
+			return newIDSet(string(opts.Rule), idRangesOf(opts.Ranges), false)
+		case policyv1.RunAsUserStrategyMustRunAsNonRoot:
+			return newIDSet(string(opts.Rule), []idRange{{min: 1, max: math.MaxInt64}}, true)
+		}
+		return anyID(string(opts.Rule))
+	}),
+	idField("runAsGroup", func(p policyv1.PodSecurityPolicy) idSet {
+		opts := p.Spec.RunAsGroup
+		if opts == nil {
+			return anyID("")
+		}
+		switch opts.Rule {
+		case policyv1.RunAsGroupStrategyMustRunAs:
+			return newIDSet(string(opts.Rule), idRangesOf(opts.Ranges), false)
+		case policyv1.RunAsGroupStrategyMayRunAs:
+			return newIDSet(string(opts.Rule), idRangesOf(opts.Ranges), true)
+		}
+		return anyID(string(opts.Rule))
+	}),
+	idField("supplementalGroups", func(p policyv1.PodSecurityPolicy) idSet {
+		opts := p.Spec.SupplementalGroups
+		switch opts.Rule {
+		case policyv1.SupplementalGroupsStrategyMustRunAs:
+			return newIDSet(string(opts.Rule), idRangesOf(opts.Ranges), false)
+		case policyv1.SupplementalGroupsStrategyMayRunAs:
+			return newIDSet(string(opts.Rule), idRangesOf(opts.Ranges), true)
+		}
+		return anyID(string(opts.Rule))
+	}),
+	idField("fsGroup", func(p policyv1.PodSecurityPolicy) idSet {
+		opts := p.Spec.FSGroup
+		switch opts.Rule {
+		case policyv1.FSGroupStrategyMustRunAs:
+			return newIDSet(string(opts.Rule), idRangesOf(opts.Ranges), false)
+		case policyv1.FSGroupStrategyMayRunAs:
+			return newIDSet(string(opts.Rule), idRangesOf(opts.Ranges), true)
+		}
+		return anyID(string(opts.Rule))
+	}),
+	{
+		name:   "seLinux",
+		format: formatSELinux,
+		covers: func(a, b policyv1.PodSecurityPolicy) bool {
+			if a.Spec.SELinux.Rule != policyv1.SELinuxStrategyMustRunAs {
+				return true
+			}
+			return b.Spec.SELinux.Rule == policyv1.SELinuxStrategyMustRunAs && equality.Semantic.DeepEqual(a.Spec.SELinux.SELinuxOptions, b.Spec.SELinux.SELinuxOptions)
+		},
+	},
+	{
+		name:   "allowedUnsafeSysctls",
+		format: func(p policyv1.PodSecurityPolicy) string { return formatList(p.Spec.AllowedUnsafeSysctls) },
+		covers: func(a, b policyv1.PodSecurityPolicy) bool {
+			return coversSysctls(a.Spec.AllowedUnsafeSysctls, b.Spec.AllowedUnsafeSysctls)
+		},
+	},
+	{
+		name:   "forbiddenSysctls",
+		format: func(p policyv1.PodSecurityPolicy) string { return formatList(p.Spec.ForbiddenSysctls) },
+		// forbidding more sysctls admits less
+		covers: func(a, b policyv1.PodSecurityPolicy) bool {
+			return coversSysctls(b.Spec.ForbiddenSysctls, a.Spec.ForbiddenSysctls)
+		},
+	},
+	setField("allowedProcMountTypes", func(p policyv1.PodSecurityPolicy) permSet {
+		s := newPermSet()
+		s.add(string(corev1.DefaultProcMount), false)
+		for _, t := range p.Spec.AllowedProcMountTypes {
+			s.add(string(t), false)
+		}
+		return s
+	}),
+	setField("allowedRuntimeClassNames", func(p policyv1.PodSecurityPolicy) permSet {
+		s := newPermSet()
+		if p.Spec.RuntimeClass == nil {
+			s.all = true
+			return s
+		}
+		for _, n := range p.Spec.RuntimeClass.AllowedRuntimeClassNames {
+			s.add(n, n == policyv1.AllowAllRuntimeClassNames)
+		}
+		return s
+	}),
+	setField("seccomp allowedProfileNames", profilesField(policy.SeccompAllowedProfilesAnnotationKey, policy.SeccompDefaultProfileAnnotationKey)),
+	setField("apparmor allowedProfileNames", profilesField(policy.AppArmorAllowedProfilesAnnotationKey, policy.AppArmorDefaultProfileAnnotationKey)),
+
+	// defaults set to Pods
+	defaultField("defaultAddCapabilities", func(p policyv1.PodSecurityPolicy) string {
+		return capabilities(p.Spec.DefaultAddCapabilities).String()
+	}),
+	defaultField("defaultAllowPrivilegeEscalation", func(p policyv1.PodSecurityPolicy) string {
+		if p.Spec.DefaultAllowPrivilegeEscalation == nil {
+			return "-"
+		}
+		return strconv.FormatBool(*p.Spec.DefaultAllowPrivilegeEscalation)
+	}),
+	defaultField("seccomp defaultProfileName", annotationOf(policy.SeccompDefaultProfileAnnotationKey)),
+	defaultField("apparmor defaultProfileName", annotationOf(policy.AppArmorDefaultProfileAnnotationKey)),
+	defaultField("defaultRuntimeClassName", func(p policyv1.PodSecurityPolicy) string {
+		if p.Spec.RuntimeClass == nil || p.Spec.RuntimeClass.DefaultRuntimeClassName == nil {
+			return "-"
+		}
+		return *p.Spec.RuntimeClass.DefaultRuntimeClassName
+	}),
+}
+
+// allowField is a boolean field which admits more when true
+func allowField(name string, get func(p policyv1.PodSecurityPolicy) bool) field {
+	return field{
+		name:   name,
+		format: func(p policyv1.PodSecurityPolicy) string { return strconv.FormatBool(get(p)) },
+		covers: func(a, b policyv1.PodSecurityPolicy) bool { return get(a) || !get(b) },
+	}
+}
+
+func setField(name string, get func(p policyv1.PodSecurityPolicy) permSet) field {
+	return field{
+		name:   name,
+		format: func(p policyv1.PodSecurityPolicy) string { return get(p).String() },
+		covers: func(a, b policyv1.PodSecurityPolicy) bool { return get(a).covers(get(b)) },
+	}
+}
+
+func idField(name string, get func(p policyv1.PodSecurityPolicy) idSet) field {
+	return field{
+		name:   name,
+		format: func(p policyv1.PodSecurityPolicy) string { return get(p).String() },
+		covers: func(a, b policyv1.PodSecurityPolicy) bool { return get(a).covers(get(b)) },
+	}
+}
+
+func defaultField(name string, format func(p policyv1.PodSecurityPolicy) string) field {
+	return field{name: name, format: format}
+}
+
+// permSet is a set of allowed values. all is true if any value is allowed
+type permSet struct {
+	all   bool
+	items sets.String
+}
+
+func newPermSet() permSet {
+	return permSet{items: sets.NewString()}
+}
+
+func (s *permSet) add(item string, all bool) {
+	if all {
+		s.all = true
+		return
+	}
+	s.items.Insert(item)
+}
+
+func (s permSet) covers(o permSet) bool {
+	return s.all || (!o.all && s.items.IsSuperset(o.items))
+}
+
+func (s permSet) String() string {
+	if s.all {
+		return "*"
+	}
+	return formatList(s.items.List())
+}
+
+func capabilities(caps []corev1.Capability) permSet {
+	s := newPermSet()
+	for _, c := range caps {
+		name := strings.ToUpper(strings.TrimPrefix(string(c), "CAP_"))
+		s.add(name, name == policy.AllowAllCapabilities || name == "ALL")
+	}
+	return s
+}
+
+// allowedCapabilities are the capabilities which containers can add, including the ones added by default
+func allowedCapabilities(p policyv1.PodSecurityPolicy) permSet {
+	return capabilities(append(append([]corev1.Capability{}, p.Spec.AllowedCapabilities...), p.Spec.DefaultAddCapabilities...))
+}
+
+func requiredDropCapabilities(p policyv1.PodSecurityPolicy) permSet {
+	return capabilities(p.Spec.RequiredDropCapabilities)
+}
+
+// profilesField returns the allowed profiles including the default one. No profile is allowed if not annotated
+func profilesField(allowedKey, defaultKey string) func(p policyv1.PodSecurityPolicy) permSet {
+	return func(p policyv1.PodSecurityPolicy) permSet {
+		s := newPermSet()
+		for _, v := range strings.Split(p.Annotations[allowedKey], ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				s.add(v, v == "*")
+			}
+		}
+		if v, ok := p.Annotations[defaultKey]; ok {
+			s.add(v, false)
+		}
+		return s
+	}
+}
+
+func annotationOf(key string) func(p policyv1.PodSecurityPolicy) string {
+	return func(p policyv1.PodSecurityPolicy) string {
+		if v, ok := p.Annotations[key]; ok {
+			return v
+		}
+		return "-"
+	}
+}
+
+// idRange is an inclusive range of IDs or ports
+type idRange struct {
+	min, max int64
+}
+
+// idSet is the allowed IDs. unset is true if Pods can leave the ID unset
+type idSet struct {
+	rule   string
+	ranges []idRange
+	unset  bool
+}
+
+func newIDSet(rule string, ranges []idRange, unset bool) idSet {
+	return idSet{rule: rule, ranges: ranges, unset: unset}
+}
+
+func anyID(rule string) idSet {
+	return newIDSet(rule, []idRange{{min: 0, max: math.MaxInt64}}, true)
+}
+
+func idRangesOf(ranges []policyv1.IDRange) []idRange {
+	r := make([]idRange, len(ranges))
+	for i, v := range ranges {
+		r[i] = idRange{min: v.Min, max: v.Max}
+	}
+	return r
+}
+
+func hostPorts(p policyv1.PodSecurityPolicy) idSet {
+	r := make([]idRange, len(p.Spec.HostPorts))
+	for i, v := range p.Spec.HostPorts {
+		r[i] = idRange{min: int64(v.Min), max: int64(v.Max)}
+	}
+	return newIDSet("", r, true)
+}
+
+func (s idSet) covers(o idSet) bool {
+	if o.unset && !s.unset {
+		return false
+	}
+	merged := mergeRanges(s.ranges)
+	for _, r := range o.ranges {
+		covered := false
+		for _, m := range merged {
+			if m.min <= r.min && r.max <= m.max {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+func (s idSet) String() string {
+	ranges := make([]string, 0, len(s.ranges))
+	for _, r := range mergeRanges(s.ranges) {
+		switch {
+		case r.min == r.max:
+			ranges = append(ranges, fmt.Sprint(r.min))
+		case r.max == math.MaxInt64:
+			ranges = append(ranges, fmt.Sprintf("%d-", r.min))
+		default:
+			ranges = append(ranges, fmt.Sprintf("%d-%d", r.min, r.max))
+		}
+	}
+	if s.rule == "" {
+		return formatList(ranges)
+	}
+	if s.rule == string(policyv1.RunAsUserStrategyRunAsAny) || s.rule == string(policyv1.RunAsUserStrategyMustRunAsNonRoot) || len(ranges) == 0 {
+		return s.rule
+	}
+	return fmt.Sprintf("%s %s", s.rule, strings.Join(ranges, ","))
+}
+
+// mergeRanges returns the sorted ranges merging the overlapping and adjacent ones
+func mergeRanges(ranges []idRange) []idRange {
+	sorted := append([]idRange{}, ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].min < sorted[j].min })
+
+	merged := make([]idRange, 0, len(sorted))
+	for _, r := range sorted {
+		if n := len(merged); n > 0 && (r.min <= merged[n-1].max || r.min == merged[n-1].max+1) {
+			if r.max > merged[n-1].max {
+				merged[n-1].max = r.max
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// coversHostPaths returns true if a allows every host path b allows with the same or weaker readOnly restriction
+func coversHostPaths(a, b policyv1.PodSecurityPolicy) bool {
+	if !policy.AllowsVolume(b.Spec, policyv1.HostPath) {
+		return true
+	}
+	if !policy.AllowsVolume(a.Spec, policyv1.HostPath) {
+		return false
+	}
+	if len(a.Spec.AllowedHostPaths) == 0 {
+		return true
+	}
+	if len(b.Spec.AllowedHostPaths) == 0 {
+		return false
+	}
+	for _, bp := range b.Spec.AllowedHostPaths {
+		covered := false
+		for _, ap := range a.Spec.AllowedHostPaths {
+			if policy.HasPathPrefix(bp.PathPrefix, ap.PathPrefix) && (!ap.ReadOnly || bp.ReadOnly) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+func formatHostPaths(p policyv1.PodSecurityPolicy) string {
+	if !policy.AllowsVolume(p.Spec, policyv1.HostPath) {
+		return "-"
+	}
+	if len(p.Spec.AllowedHostPaths) == 0 {
+		return "*"
+	}
+	paths := make([]string, len(p.Spec.AllowedHostPaths))
+	for i, hp := range p.Spec.AllowedHostPaths {
+		paths[i] = hp.PathPrefix
+		if hp.ReadOnly {
+			paths[i] += "(ro)"
+		}
+	}
+	return formatList(paths)
+}
+
+// coversSysctls returns true if every pattern of b is matched by a pattern of a
+func coversSysctls(a, b []string) bool {
+	for _, bp := range b {
+		covered := false
+		for _, ap := range a {
+			if ap == bp || (strings.HasSuffix(ap, "*") && strings.HasPrefix(strings.TrimSuffix(bp, "*"), strings.TrimSuffix(ap, "*"))) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+func formatSELinux(p policyv1.PodSecurityPolicy) string {
+	opts := p.Spec.SELinux
+	if opts.Rule != policyv1.SELinuxStrategyMustRunAs || opts.SELinuxOptions == nil {
+		return string(opts.Rule)
+	}
+	o := opts.SELinuxOptions
+	return fmt.Sprintf("%s user=%s,role=%s,type=%s,level=%s", opts.Rule, o.User, o.Role, o.Type, o.Level)
+}
+
+func formatList(items []string) string {
+	if len(items) == 0 {
+		return "-"
+	}
+	sorted := append([]string{}, items...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package managed
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jlandowner/psp-util/pkg/rbac"
+	"github.com/jlandowner/psp-util/pkg/relations"
+	"github.com/jlandowner/psp-util/pkg/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Adoption is an existing RBAC resource granting a PSP which is not generated by psp-util
+type Adoption struct {
+	PSP       string
+	Kind      string
+	Namespace string
+	Name      string
+	// Backfill is true if the resource is already managed and only lacks the managed labels,
+	// e.g. generated by the older versions of psp-util
+	Backfill bool
+}
+
+// String returns the resource name as NAME or NAMESPACE/NAME
+func (a Adoption) String() string {
+	if a.Namespace != "" {
+		return a.Namespace + "/" + a.Name
+	}
+	return a.Name
+}
+
+// FindAdoptions returns the unmanaged ClusterRoles granting the PSPs and the bindings to them,
+// and the managed ones without the managed labels.
+// ClusterRoles granting multiple PSPs, granting by wildcard rules or through aggregation cannot be adopted as the annotation has a single PSP name,
+// and a PSP has at most one managed resource of each kind in a namespace,
+// so they are returned as skipped with the reason
+func FindAdoptions(psps []relations.RelationalPodSecurityPolicy) (adoptions []Adoption, skipped []string) {
+	adoptions = make([]Adoption, 0)
+	skipped = make([]string, 0)
+	seen := make(map[string]bool)
+
+	// names of the managed resources by PSP, kind and namespace
+	managed := make(map[string]string)
+	key := func(pspName, kind, namespace string) string {
+		return pspName + "/" + kind + "/" + namespace
+	}
+	register := func(kind string, meta metav1.ObjectMeta) {
+		if psp, ok := rbac.ManagedPSPName(meta); ok {
+			managed[key(psp, kind, meta.Namespace)] = meta.Name
+		}
+	}
+	for _, psp := range psps {
+		for _, cr := range psp.ClusterRoles {
+			register("ClusterRole", cr.ObjectMeta)
+			for _, crb := range cr.ClusterRoleBindings {
+				register("ClusterRoleBinding", crb.ObjectMeta)
+			}
+			for _, rb := range cr.RoleBindings {
+				register("RoleBinding", rb.ObjectMeta)
+			}
+		}
+	}
+	// adoptable returns the reason to skip if the PSP already has another managed resource of the kind in the namespace
+	adoptable := func(a Adoption) (string, bool) {
+		k := key(a.PSP, a.Kind, a.Namespace)
+		if name, ok := managed[k]; ok && name != a.Name {
+			return fmt.Sprintf("%s %s as %s %s is managed for PSP %s", a.Kind, a.String(), a.Kind, name, a.PSP), false
+		}
+		managed[k] = a.Name
+		return "", true
+	}
+
+	for _, psp := range psps {
+		for _, cr := range psp.ClusterRoles {
+			if seen[cr.Name] {
+				continue
+			}
+			seen[cr.Name] = true
+
+			// the role is skipped even if this PSP is granted by a literal rule, as it grants the others as well
+			if rbac.IsWildcardPSPRole(cr.ClusterRole) {
+				skipped = append(skipped, fmt.Sprintf("ClusterRole %s grants PSPs by a wildcard rule", cr.Name))
+				continue
+			}
+			if len(cr.AggregationPath) > 0 {
+				skipped = append(skipped, fmt.Sprintf("ClusterRole %s grants PSPs through aggregation: %s", cr.Name, strings.Join(cr.AggregationPath, ",")))
+				continue
+			}
+			if pspNames := utils.UniqueStrings(rbac.ExtractPSPFromGenericRole(cr.ClusterRole)); len(pspNames) > 1 {
+				skipped = append(skipped, fmt.Sprintf("ClusterRole %s grants multiple PSPs: %s", cr.Name, strings.Join(pspNames, ",")))
+				continue
+			}
+
+			if a, ok := newAdoption(psp.Name, "ClusterRole", cr.ObjectMeta); ok {
+				// the bindings are skipped as well since they are bound to the role
+				reason, ok := adoptable(a)
+				if !ok {
+					skipped = append(skipped, reason)
+					continue
+				}
+				adoptions = append(adoptions, a)
+			}
+			for _, crb := range cr.ClusterRoleBindings {
+				if a, ok := newAdoption(psp.Name, "ClusterRoleBinding", crb.ObjectMeta); ok {
+					if reason, ok := adoptable(a); ok {
+						adoptions = append(adoptions, a)
+					} else {
+						skipped = append(skipped, reason)
+					}
+				}
+			}
+			for _, rb := range cr.RoleBindings {
+				if a, ok := newAdoption(psp.Name, "RoleBinding", rb.ObjectMeta); ok {
+					if reason, ok := adoptable(a); ok {
+						adoptions = append(adoptions, a)
+					} else {
+						skipped = append(skipped, reason)
+					}
+				}
+			}
+		}
+	}
+	return adoptions, skipped
+}
+
+// newAdoption returns the adoption of the resource unless it is managed and has the managed labels.
+// The managed resources without the labels get them backfilled for the PSP in the annotation
+func newAdoption(pspName, kind string, meta metav1.ObjectMeta) (Adoption, bool) {
+	a := Adoption{PSP: pspName, Kind: kind, Namespace: meta.Namespace, Name: meta.Name}
+	if psp, ok := meta.Annotations[utils.AnnotaionKeyPSPName]; ok {
+		if utils.HasManagedLabels(meta.Labels, psp) {
+			return a, false
+		}
+		a.PSP = psp
+		a.Backfill = true
+	}
+	return a, true
+}
+
+// Adopt adds the managed annotation and labels to the resource by server-side apply.
+// The other fields are left as they are
+func (a Adoption) Adopt(ctx context.Context, k8sclient kubernetes.Interface, dryRun string) error {
+	if dryRun == DryRunClient {
+		return nil
+	}
+	opts := dryRunOptions(dryRun)
+
+	var err error
+	switch a.Kind {
+	case "ClusterRole":
+		_, err = rbac.AdoptClusterRole(ctx, k8sclient, a.Name, a.PSP, opts...)
+	case "ClusterRoleBinding":
+		_, err = rbac.AdoptClusterRoleBinding(ctx, k8sclient, a.Name, a.PSP, opts...)
+	case "RoleBinding":
+		_, err = rbac.AdoptRoleBinding(ctx, k8sclient, a.Namespace, a.Name, a.PSP, opts...)
+	default:
+		return fmt.Errorf("unsupported adoption of %s", a.Kind)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to adopt %s %s: %v", a.Kind, a.String(), err)
+	}
+	return nil
+}
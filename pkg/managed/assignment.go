@@ -0,0 +1,181 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package managed
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/jlandowner/psp-util/pkg/rbac"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	AssignmentAPIVersion = "psp-util.k8s.jlandowner.com/v1alpha1"
+	AssignmentKind       = "PSPAssignment"
+
+	ScopeCluster   = "cluster"
+	ScopeNamespace = "namespace"
+)
+
+// Assignment is a declarative list of subjects permitted to use each PSP
+type Assignment struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	// PSPs is a map of PSP name to the subjects
+	PSPs map[string][]AssignedSubject `json:"psps"`
+}
+
+// AssignedSubject is a subject and the scope where the PSP is granted
+type AssignedSubject struct {
+	Kind      string `json:"kind"`
+	APIGroup  string `json:"apiGroup,omitempty"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	// Scope is cluster (default) or namespace
+	Scope string `json:"scope,omitempty"`
+	// BindingNamespace is the namespace of RoleBinding when scope is namespace. Default is the ServiceAccount's namespace
+	BindingNamespace string `json:"bindingNamespace,omitempty"`
+}
+
+// Subject returns the RBAC subject in the same form as attach generates
+func (s AssignedSubject) Subject() rbacv1.Subject {
+	sub := rbacv1.Subject{Kind: s.Kind, APIGroup: s.APIGroup, Name: s.Name, Namespace: s.Namespace}
+	if (s.Kind == "User" || s.Kind == "Group") && sub.APIGroup == "" {
+		sub.APIGroup = rbac.APIGroup
+	}
+	return sub
+}
+
+// IsNamespaceScoped returns true if the PSP is granted by RoleBinding
+func (s AssignedSubject) IsNamespaceScoped() bool {
+	return s.Scope == ScopeNamespace
+}
+
+// GetBindingNamespace returns the namespace of RoleBinding for the namespace scope
+func (s AssignedSubject) GetBindingNamespace() string {
+	if s.BindingNamespace != "" {
+		return s.BindingNamespace
+	}
+	if s.Kind == "ServiceAccount" {
+		return s.Namespace
+	}
+	return ""
+}
+
+func (s AssignedSubject) validate() error {
+	switch s.Kind {
+	case "User", "Group":
+	case "ServiceAccount":
+		if s.Namespace == "" {
+			return fmt.Errorf("namespace is required for ServiceAccount %s", s.Name)
+		}
+	default:
+		return fmt.Errorf("Invalid kind %s: must be one of User|Group|ServiceAccount", s.Kind)
+	}
+	if s.Name == "" {
+		return fmt.Errorf("name is required for %s", s.Kind)
+	}
+	switch s.Scope {
+	case "", ScopeCluster:
+		if s.BindingNamespace != "" {
+			return fmt.Errorf("bindingNamespace is only used with scope %s", ScopeNamespace)
+		}
+	case ScopeNamespace:
+		if s.GetBindingNamespace() == "" {
+			return fmt.Errorf("bindingNamespace is required for %s %s with scope %s", s.Kind, s.Name, ScopeNamespace)
+		}
+	default:
+		return fmt.Errorf("Invalid scope %s: must be one of %s|%s", s.Scope, ScopeCluster, ScopeNamespace)
+	}
+	return nil
+}
+
+// Validate returns an error if the assignment is malformed
+func (a *Assignment) Validate() error {
+	if a.APIVersion != AssignmentAPIVersion || a.Kind != AssignmentKind {
+		return fmt.Errorf("Unsupported apiVersion %s and kind %s: must be %s %s", a.APIVersion, a.Kind, AssignmentAPIVersion, AssignmentKind)
+	}
+	for psp, subs := range a.PSPs {
+		for _, s := range subs {
+			if err := s.validate(); err != nil {
+				return fmt.Errorf("Invalid subject of PSP %s: %v", psp, err)
+			}
+		}
+	}
+	return nil
+}
+
+// PSPNames returns the sorted names of PSPs in the assignment
+func (a *Assignment) PSPNames() []string {
+	names := make([]string, 0, len(a.PSPs))
+	for name := range a.PSPs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DesiredBindings are subjects of the managed bindings of a PSP
+type DesiredBindings struct {
+	ClusterRoleBinding []rbacv1.Subject
+	// RoleBindings is a map of namespace to the subjects
+	RoleBindings map[string][]rbacv1.Subject
+}
+
+// Desired returns the subjects of the managed bindings for each PSP
+func (a *Assignment) Desired() map[string]*DesiredBindings {
+	desired := make(map[string]*DesiredBindings)
+	for psp, subs := range a.PSPs {
+		d := &DesiredBindings{
+			ClusterRoleBinding: make([]rbacv1.Subject, 0),
+			RoleBindings:       make(map[string][]rbacv1.Subject),
+		}
+		for _, s := range subs {
+			if s.IsNamespaceScoped() {
+				ns := s.GetBindingNamespace()
+				d.RoleBindings[ns], _ = rbac.AttachSubject(d.RoleBindings[ns], s.Subject())
+			} else {
+				d.ClusterRoleBinding, _ = rbac.AttachSubject(d.ClusterRoleBinding, s.Subject())
+			}
+		}
+		desired[psp] = d
+	}
+	return desired
+}
+
+// ParseAssignment decodes the assignment yaml or json
+func ParseAssignment(data []byte) (*Assignment, error) {
+	a := &Assignment{}
+	if err := yaml.UnmarshalStrict(data, a); err != nil {
+		return nil, err
+	}
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// LoadAssignment reads the assignment file
+func LoadAssignment(path string) (*Assignment, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseAssignment(data)
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package managed
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jlandowner/psp-util/pkg/core"
+	"github.com/jlandowner/psp-util/pkg/policy"
+	"github.com/jlandowner/psp-util/pkg/relations"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Existence is the names of the resources in cluster which the managed resources refer to
+type Existence struct {
+	PSPs       map[string]bool
+	Namespaces map[string]bool
+	// ServiceAccounts is a set of NAMESPACE/NAME
+	ServiceAccounts map[string]bool
+}
+
+// GetExistence returns the existing PSPs, namespaces and ServiceAccounts in cluster
+func GetExistence(ctx context.Context, k8sclient kubernetes.Interface) (*Existence, error) {
+	e := &Existence{
+		PSPs:            make(map[string]bool),
+		Namespaces:      make(map[string]bool),
+		ServiceAccounts: make(map[string]bool),
+	}
+
+	psps, err := policy.ListPSP(ctx, k8sclient)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list PSP: %v", err)
+	}
+	for _, psp := range psps.Items {
+		e.PSPs[psp.Name] = true
+	}
+
+	nss, err := core.ListNamespaces(ctx, k8sclient)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list Namespaces: %v", err)
+	}
+	for _, ns := range nss.Items {
+		e.Namespaces[ns.Name] = true
+	}
+
+	sas, err := core.ListServiceAccounts(ctx, k8sclient)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list ServiceAccounts: %v", err)
+	}
+	for _, sa := range sas.Items {
+		e.ServiceAccounts[sa.Namespace+"/"+sa.Name] = true
+	}
+	return e, nil
+}
+
+// IsDanglingSubject returns true if the subject is a deleted ServiceAccount
+// or the ServiceAccounts group of a deleted namespace
+func (e *Existence) IsDanglingSubject(sub rbacv1.Subject) bool {
+	switch sub.Kind {
+	case "ServiceAccount":
+		return !e.ServiceAccounts[sub.Namespace+"/"+sub.Name]
+	case "User":
+		// ServiceAccount authenticated as the user system:serviceaccount:NAMESPACE:NAME
+		if implicit := relations.ImplicitSubjects(sub); implicit[0].Kind == "ServiceAccount" {
+			return e.IsDanglingSubject(implicit[0])
+		}
+	case "Group":
+		if ns := strings.TrimPrefix(sub.Name, relations.GroupServiceAccounts+":"); ns != sub.Name {
+			return !e.Namespaces[ns]
+		}
+	}
+	return false
+}
+
+// GetGCPlan gets the managed resources and the existing resources in cluster and returns the changes to delete the garbage
+func GetGCPlan(ctx context.Context, k8sclient kubernetes.Interface) (*Plan, error) {
+	state, err := GetState(ctx, k8sclient)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get managed resources: %v", err)
+	}
+	existence, err := GetExistence(ctx, k8sclient)
+	if err != nil {
+		return nil, err
+	}
+	return NewGCPlan(state, existence), nil
+}
+
+// NewGCPlan returns the changes to delete the garbage of the managed resources.
+// The garbages are
+//   - all the managed resources of deleted PSPs
+//   - the subjects of deleted ServiceAccounts or namespaces in the managed bindings
+//   - the managed bindings without subjects
+//
+// The changes are applied only if the resources are not modified since planned
+func NewGCPlan(state State, e *Existence) *Plan {
+	plan := &Plan{Changes: make([]Change, 0)}
+	for _, psp := range state.PSPNames() {
+		res := state[psp]
+		if !e.PSPs[psp] {
+			for _, c := range NewCleanPlan(res, psp).Changes {
+				c.Reason = fmt.Sprintf("PSP %s is not found", psp)
+				plan.Changes = append(plan.Changes, c)
+			}
+			continue
+		}
+
+		if crb := res.ClusterRoleBinding; crb != nil {
+			kept, dangling := e.splitDanglingSubjects(crb.Subjects)
+			if c, ok := gcBinding(kept, dangling); ok {
+				c.PSP, c.Kind, c.Name = psp, "ClusterRoleBinding", crb.Name
+				if c.Action == ActionUpdate {
+					obj := crb.DeepCopy()
+					obj.Subjects = kept
+					c.Object = obj
+				} else {
+					c.Object = crb
+				}
+				plan.Changes = append(plan.Changes, c)
+			}
+		}
+		for _, ns := range res.namespaces() {
+			rb := res.RoleBindings[ns]
+			kept, dangling := e.splitDanglingSubjects(rb.Subjects)
+			if c, ok := gcBinding(kept, dangling); ok {
+				c.PSP, c.Kind, c.Namespace, c.Name = psp, "RoleBinding", ns, rb.Name
+				if c.Action == ActionUpdate {
+					obj := rb.DeepCopy()
+					obj.Subjects = kept
+					c.Object = obj
+				} else {
+					c.Object = rb
+				}
+				plan.Changes = append(plan.Changes, c)
+			}
+		}
+	}
+	return plan
+}
+
+// gcBinding returns the change of the binding without the dangling subjects,
+// which deletes the binding left without subjects
+func gcBinding(kept, dangling []rbacv1.Subject) (Change, bool) {
+	switch {
+	case len(kept) == 0:
+		reason := "no subjects"
+		if len(dangling) > 0 {
+			reason = "all subjects are deleted"
+		}
+		return Change{Action: ActionDelete, Removed: dangling, Reason: reason}, true
+	case len(dangling) > 0:
+		return Change{Action: ActionUpdate, Removed: dangling, Reason: "subjects are deleted"}, true
+	}
+	return Change{}, false
+}
+
+// splitDanglingSubjects returns the existing subjects and the dangling ones
+func (e *Existence) splitDanglingSubjects(subjects []rbacv1.Subject) (kept, dangling []rbacv1.Subject) {
+	kept = make([]rbacv1.Subject, 0)
+	dangling = make([]rbacv1.Subject, 0)
+	for _, s := range subjects {
+		if e.IsDanglingSubject(s) {
+			dangling = append(dangling, s)
+		} else {
+			kept = append(kept, s)
+		}
+	}
+	return kept, dangling
+}
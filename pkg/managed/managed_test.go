@@ -0,0 +1,954 @@
+package managed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jlandowner/psp-util/pkg/client/fake"
+	"github.com/jlandowner/psp-util/pkg/rbac"
+	"github.com/jlandowner/psp-util/pkg/relations"
+	"github.com/jlandowner/psp-util/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/util/retry"
+)
+
+func TestParseAssignment(t *testing.T) {
+	tests := []struct {
+		title         string
+		data          string
+		expectDesired map[string]*DesiredBindings
+		expectErr     bool
+	}{
+		{
+			title: "cluster and namespace scope",
+			data: `
+apiVersion: psp-util.k8s.jlandowner.com/v1alpha1
+kind: PSPAssignment
+psps:
+  restricted:
+  - kind: Group
+    name: system:authenticated
+  - kind: ServiceAccount
+    name: app
+    namespace: team-a
+    scope: namespace
+  - kind: Group
+    name: developers
+    scope: namespace
+    bindingNamespace: team-b
+`,
+			expectDesired: map[string]*DesiredBindings{
+				"restricted": {
+					ClusterRoleBinding: []rbacv1.Subject{{Kind: "Group", APIGroup: rbac.APIGroup, Name: "system:authenticated"}},
+					RoleBindings: map[string][]rbacv1.Subject{
+						"team-a": {{Kind: "ServiceAccount", Name: "app", Namespace: "team-a"}},
+						"team-b": {{Kind: "Group", APIGroup: rbac.APIGroup, Name: "developers"}},
+					},
+				},
+			},
+		},
+		{
+			title: "namespace scope without binding namespace",
+			data: `
+apiVersion: psp-util.k8s.jlandowner.com/v1alpha1
+kind: PSPAssignment
+psps:
+  restricted:
+  - kind: User
+    name: alice
+    scope: namespace
+`,
+			expectErr: true,
+		},
+		{
+			title: "unknown field",
+			data: `
+apiVersion: psp-util.k8s.jlandowner.com/v1alpha1
+kind: PSPAssignment
+psps:
+  restricted:
+  - kind: User
+    nmae: alice
+`,
+			expectErr: true,
+		},
+		{
+			title:     "invalid kind",
+			data:      "apiVersion: v1\nkind: ConfigMap\n",
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		a, err := ParseAssignment([]byte(test.data))
+		if test.expectErr {
+			assert.Error(t, err)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, test.expectDesired, a.Desired())
+	}
+}
+
+func TestNewPlan(t *testing.T) {
+	group := rbacv1.Subject{Kind: "Group", APIGroup: rbac.APIGroup, Name: "system:authenticated"}
+	user := rbacv1.Subject{Kind: "User", APIGroup: rbac.APIGroup, Name: "alice"}
+	sa := rbacv1.Subject{Kind: "ServiceAccount", Namespace: "team-a", Name: "app"}
+
+	crb := rbac.NewPSPRoleBinding("restricted")
+	crb.Subjects = []rbacv1.Subject{user}
+	rb := rbac.NewPSPNamespacedRoleBinding("restricted", "team-a")
+	rb.Subjects = []rbacv1.Subject{sa}
+
+	state := NewState(
+		[]rbacv1.ClusterRole{*rbac.NewPSPRole("restricted")},
+		[]rbacv1.ClusterRoleBinding{*crb},
+		[]rbacv1.RoleBinding{*rb})
+
+	desired := map[string]*DesiredBindings{
+		"restricted": {
+			ClusterRoleBinding: []rbacv1.Subject{group},
+			RoleBindings:       map[string][]rbacv1.Subject{},
+		},
+		"privileged": {
+			ClusterRoleBinding: []rbacv1.Subject{},
+			RoleBindings:       map[string][]rbacv1.Subject{"kube-system": {sa}},
+		},
+	}
+
+	type expectChange struct {
+		Action  Action
+		Kind    string
+		Name    string
+		Added   []rbacv1.Subject
+		Removed []rbacv1.Subject
+	}
+
+	tests := []struct {
+		title         string
+		prune         bool
+		expectChanges []expectChange
+	}{
+		{
+			title: "without prune",
+			prune: false,
+			expectChanges: []expectChange{
+				{Action: ActionCreate, Kind: "ClusterRole", Name: "psp-util.privileged"},
+				{Action: ActionCreate, Kind: "RoleBinding", Name: "kube-system/psp-util.privileged", Added: []rbacv1.Subject{sa}},
+				{Action: ActionUpdate, Kind: "ClusterRoleBinding", Name: "psp-util.restricted", Added: []rbacv1.Subject{group}, Removed: []rbacv1.Subject{}},
+			},
+		},
+		{
+			title: "with prune",
+			prune: true,
+			expectChanges: []expectChange{
+				{Action: ActionCreate, Kind: "ClusterRole", Name: "psp-util.privileged"},
+				{Action: ActionCreate, Kind: "RoleBinding", Name: "kube-system/psp-util.privileged", Added: []rbacv1.Subject{sa}},
+				{Action: ActionUpdate, Kind: "ClusterRoleBinding", Name: "psp-util.restricted", Added: []rbacv1.Subject{group}, Removed: []rbacv1.Subject{user}},
+				{Action: ActionDelete, Kind: "RoleBinding", Name: "team-a/psp-util.restricted", Removed: []rbacv1.Subject{sa}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		plan := NewPlan(state, desired, test.prune)
+		changes := make([]expectChange, len(plan.Changes))
+		for i, c := range plan.Changes {
+			changes[i] = expectChange{Action: c.Action, Kind: c.Kind, Name: c.String(), Added: c.Added, Removed: c.Removed}
+		}
+		assert.Equal(t, test.expectChanges, changes)
+	}
+
+	// the current state is not modified
+	assert.Equal(t, []rbacv1.Subject{user}, state["restricted"].ClusterRoleBinding.Subjects)
+	updated := NewPlan(state, desired, true).Changes[2].Object.(*rbacv1.ClusterRoleBinding)
+	assert.Equal(t, []rbacv1.Subject{group}, updated.Subjects)
+}
+
+func TestNewOperationPlans(t *testing.T) {
+	group := rbacv1.Subject{Kind: "Group", APIGroup: rbac.APIGroup, Name: "system:authenticated"}
+	user := rbacv1.Subject{Kind: "User", APIGroup: rbac.APIGroup, Name: "alice"}
+	sa := rbacv1.Subject{Kind: "ServiceAccount", Namespace: "team-a", Name: "app"}
+
+	crb := rbac.NewPSPRoleBinding("restricted")
+	crb.Subjects = []rbacv1.Subject{user}
+	rb := rbac.NewPSPNamespacedRoleBinding("restricted", "team-a")
+	rb.Subjects = []rbacv1.Subject{sa}
+
+	res := NewState(
+		[]rbacv1.ClusterRole{*rbac.NewPSPRole("restricted")},
+		[]rbacv1.ClusterRoleBinding{*crb},
+		[]rbacv1.RoleBinding{*rb})["restricted"]
+	empty := &Resources{RoleBindings: map[string]*rbacv1.RoleBinding{}}
+
+	type expectChange struct {
+		Action  Action
+		Kind    string
+		Name    string
+		Added   []rbacv1.Subject
+		Removed []rbacv1.Subject
+	}
+
+	tests := []struct {
+		title         string
+		plan          *Plan
+		expectChanges []expectChange
+	}{
+		{
+			title: "attach to new ClusterRoleBinding",
+			plan:  NewAttachPlan(empty, "restricted", group, ""),
+			expectChanges: []expectChange{
+				{Action: ActionCreate, Kind: "ClusterRole", Name: "psp-util.restricted"},
+				{Action: ActionCreate, Kind: "ClusterRoleBinding", Name: "psp-util.restricted", Added: []rbacv1.Subject{group}},
+			},
+		},
+		{
+			title: "attach to existing ClusterRoleBinding",
+			plan:  NewAttachPlan(res, "restricted", group, ""),
+			expectChanges: []expectChange{
+				{Action: ActionUpdate, Kind: "ClusterRoleBinding", Name: "psp-util.restricted", Added: []rbacv1.Subject{group}, Removed: []rbacv1.Subject{}},
+			},
+		},
+		{
+			title:         "attach already attached subject",
+			plan:          NewAttachPlan(res, "restricted", sa, "team-a"),
+			expectChanges: []expectChange{},
+		},
+		{
+			title: "attach to new RoleBinding",
+			plan:  NewAttachPlan(res, "restricted", sa, "team-b"),
+			expectChanges: []expectChange{
+				{Action: ActionCreate, Kind: "RoleBinding", Name: "team-b/psp-util.restricted", Added: []rbacv1.Subject{sa}},
+			},
+		},
+		{
+			title: "detach from ClusterRoleBinding",
+			plan:  NewDetachPlan(res, "restricted", user, ""),
+			expectChanges: []expectChange{
+				{Action: ActionUpdate, Kind: "ClusterRoleBinding", Name: "psp-util.restricted", Added: []rbacv1.Subject{}, Removed: []rbacv1.Subject{user}},
+			},
+		},
+		{
+			title: "detach last subject from RoleBinding",
+			plan:  NewDetachPlan(res, "restricted", sa, "team-a"),
+			expectChanges: []expectChange{
+				{Action: ActionDelete, Kind: "RoleBinding", Name: "team-a/psp-util.restricted", Removed: []rbacv1.Subject{sa}},
+			},
+		},
+		{
+			title:         "detach not attached subject",
+			plan:          NewDetachPlan(res, "restricted", group, ""),
+			expectChanges: []expectChange{},
+		},
+		{
+			title: "clean",
+			plan:  NewCleanPlan(res, "restricted"),
+			expectChanges: []expectChange{
+				{Action: ActionDelete, Kind: "ClusterRoleBinding", Name: "psp-util.restricted", Removed: []rbacv1.Subject{user}},
+				{Action: ActionDelete, Kind: "RoleBinding", Name: "team-a/psp-util.restricted", Removed: []rbacv1.Subject{sa}},
+				{Action: ActionDelete, Kind: "ClusterRole", Name: "psp-util.restricted"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		changes := make([]expectChange, len(test.plan.Changes))
+		for i, c := range test.plan.Changes {
+			changes[i] = expectChange{Action: c.Action, Kind: c.Kind, Name: c.String(), Added: c.Added, Removed: c.Removed}
+		}
+		assert.Equal(t, test.expectChanges, changes)
+	}
+
+	// the current resources are not modified
+	assert.Equal(t, []rbacv1.Subject{user}, res.ClusterRoleBinding.Subjects)
+	assert.Equal(t, []rbacv1.Subject{sa}, res.RoleBindings["team-a"].Subjects)
+}
+
+func TestPlanManifests(t *testing.T) {
+	user := rbacv1.Subject{Kind: "User", APIGroup: rbac.APIGroup, Name: "alice"}
+	sa := rbacv1.Subject{Kind: "ServiceAccount", Namespace: "team-a", Name: "app"}
+
+	cr := rbac.NewPSPRole("restricted")
+	cr.ResourceVersion = "10"
+	rb := rbac.NewPSPNamespacedRoleBinding("restricted", "team-a")
+	rb.Subjects = []rbacv1.Subject{sa}
+	rb.UID = "uid"
+
+	res := NewState([]rbacv1.ClusterRole{*cr}, []rbacv1.ClusterRoleBinding{}, []rbacv1.RoleBinding{*rb})["restricted"]
+
+	tests := []struct {
+		title           string
+		plan            *Plan
+		expectManifests []string
+		expectDeletions []string
+	}{
+		{
+			title:           "attach",
+			plan:            NewAttachPlan(res, "restricted", user, ""),
+			expectManifests: []string{"ClusterRole/psp-util.restricted", "ClusterRoleBinding/psp-util.restricted", "RoleBinding/team-a/psp-util.restricted"},
+			expectDeletions: []string{},
+		},
+		{
+			title:           "detach",
+			plan:            NewDetachPlan(res, "restricted", sa, "team-a"),
+			expectManifests: []string{"ClusterRole/psp-util.restricted"},
+			expectDeletions: []string{"RoleBinding/team-a/psp-util.restricted"},
+		},
+		{
+			title:           "clean",
+			plan:            NewCleanPlan(res, "restricted"),
+			expectManifests: []string{},
+			expectDeletions: []string{"RoleBinding/team-a/psp-util.restricted", "ClusterRole/psp-util.restricted"},
+		},
+	}
+
+	keyOf := func(obj interface{}) string {
+		o := obj.(metav1.Object)
+		key := obj.(runtime.Object).GetObjectKind().GroupVersionKind().Kind + "/"
+		if o.GetNamespace() != "" {
+			key += o.GetNamespace() + "/"
+		}
+		assert.Empty(t, o.GetResourceVersion())
+		assert.Empty(t, o.GetUID())
+		return key + o.GetName()
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		manifests := make([]string, 0)
+		for _, obj := range test.plan.Result(res).Manifests() {
+			manifests = append(manifests, keyOf(obj))
+		}
+		assert.Equal(t, test.expectManifests, manifests)
+
+		deletions := make([]string, 0)
+		for _, obj := range test.plan.Deletions() {
+			deletions = append(deletions, keyOf(obj))
+		}
+		assert.Equal(t, test.expectDeletions, deletions)
+	}
+}
+
+func TestApplyWithFakeClient(t *testing.T) {
+	ctx := context.Background()
+	k8sclient := fake.NewClientset(&policyv1.PodSecurityPolicy{ObjectMeta: metav1.ObjectMeta{Name: "restricted"}})
+
+	group := rbacv1.Subject{Kind: "Group", APIGroup: rbac.APIGroup, Name: "system:authenticated"}
+	sa := rbacv1.Subject{Kind: "ServiceAccount", Namespace: "team-a", Name: "app"}
+
+	// the steps are applied in order to the same cluster
+	tests := []struct {
+		title             string
+		newPlan           func(res *Resources) *Plan
+		dryRun            string
+		expectChanges     int
+		expectClusterRole bool
+		expectCRB         []rbacv1.Subject
+		expectRBs         map[string][]rbacv1.Subject
+	}{
+		{
+			title:             "attach in cluster scope",
+			newPlan:           func(res *Resources) *Plan { return NewAttachPlan(res, "restricted", group, "") },
+			dryRun:            DryRunNone,
+			expectChanges:     2,
+			expectClusterRole: true,
+			expectCRB:         []rbacv1.Subject{group},
+			expectRBs:         map[string][]rbacv1.Subject{},
+		},
+		{
+			title:             "attach in namespace scope with client dry-run",
+			newPlan:           func(res *Resources) *Plan { return NewAttachPlan(res, "restricted", sa, "team-a") },
+			dryRun:            DryRunClient,
+			expectChanges:     1,
+			expectClusterRole: true,
+			expectCRB:         []rbacv1.Subject{group},
+			expectRBs:         map[string][]rbacv1.Subject{},
+		},
+		{
+			title:             "attach in namespace scope",
+			newPlan:           func(res *Resources) *Plan { return NewAttachPlan(res, "restricted", sa, "team-a") },
+			dryRun:            DryRunNone,
+			expectChanges:     1,
+			expectClusterRole: true,
+			expectCRB:         []rbacv1.Subject{group},
+			expectRBs:         map[string][]rbacv1.Subject{"team-a": {sa}},
+		},
+		{
+			title:             "attach already attached subject",
+			newPlan:           func(res *Resources) *Plan { return NewAttachPlan(res, "restricted", group, "") },
+			dryRun:            DryRunNone,
+			expectChanges:     0,
+			expectClusterRole: true,
+			expectCRB:         []rbacv1.Subject{group},
+			expectRBs:         map[string][]rbacv1.Subject{"team-a": {sa}},
+		},
+		{
+			title:             "detach in cluster scope",
+			newPlan:           func(res *Resources) *Plan { return NewDetachPlan(res, "restricted", group, "") },
+			dryRun:            DryRunNone,
+			expectChanges:     1,
+			expectClusterRole: true,
+			expectCRB:         []rbacv1.Subject{},
+			expectRBs:         map[string][]rbacv1.Subject{"team-a": {sa}},
+		},
+		{
+			title:             "detach the last subject in namespace scope",
+			newPlan:           func(res *Resources) *Plan { return NewDetachPlan(res, "restricted", sa, "team-a") },
+			dryRun:            DryRunNone,
+			expectChanges:     1,
+			expectClusterRole: true,
+			expectCRB:         []rbacv1.Subject{},
+			expectRBs:         map[string][]rbacv1.Subject{},
+		},
+		{
+			title:             "clean",
+			newPlan:           func(res *Resources) *Plan { return NewCleanPlan(res, "restricted") },
+			dryRun:            DryRunNone,
+			expectChanges:     2,
+			expectClusterRole: false,
+			expectRBs:         map[string][]rbacv1.Subject{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		res, err := GetResources(ctx, k8sclient, "restricted")
+		assert.NoError(t, err)
+
+		plan := test.newPlan(res)
+		assert.Len(t, plan.Changes, test.expectChanges)
+		assert.NoError(t, plan.Apply(ctx, k8sclient, test.dryRun))
+
+		res, err = GetResources(ctx, k8sclient, "restricted")
+		assert.NoError(t, err)
+		assert.Equal(t, test.expectClusterRole, res.ClusterRole != nil)
+		if test.expectCRB == nil {
+			assert.Nil(t, res.ClusterRoleBinding)
+		} else if assert.NotNil(t, res.ClusterRoleBinding) {
+			assert.Equal(t, test.expectCRB, res.ClusterRoleBinding.Subjects)
+		}
+		rbs := make(map[string][]rbacv1.Subject)
+		for ns, rb := range res.RoleBindings {
+			rbs[ns] = rb.Subjects
+		}
+		assert.Equal(t, test.expectRBs, rbs)
+	}
+}
+
+func TestGetStateWithFakeClient(t *testing.T) {
+	ctx := context.Background()
+	group := rbacv1.Subject{Kind: "Group", APIGroup: rbac.APIGroup, Name: "system:authenticated"}
+
+	crb := rbac.NewPSPRoleBinding("restricted")
+	crb.Subjects = []rbacv1.Subject{group}
+	unmanaged := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "unmanaged"},
+		RoleRef:    crb.RoleRef,
+		Subjects:   []rbacv1.Subject{group},
+	}
+	k8sclient := fake.NewClientset(rbac.NewPSPRole("restricted"), crb, unmanaged)
+
+	state, err := GetState(ctx, k8sclient)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"restricted"}, state.PSPNames())
+	assert.Equal(t, "psp-util.restricted", state["restricted"].ClusterRoleBinding.Name)
+
+	// applying the same assignment makes no change
+	desired := map[string]*DesiredBindings{
+		"restricted": {ClusterRoleBinding: []rbacv1.Subject{group}, RoleBindings: map[string][]rbacv1.Subject{}},
+	}
+	assert.True(t, NewPlan(state, desired, true).IsEmpty())
+}
+
+func TestUnlabeledWithFakeClient(t *testing.T) {
+	ctx := context.Background()
+	group := rbacv1.Subject{Kind: "Group", APIGroup: rbac.APIGroup, Name: "system:authenticated"}
+	user := rbacv1.Subject{Kind: "User", APIGroup: rbac.APIGroup, Name: "alice"}
+
+	// generated by the older versions without the managed labels
+	cr := rbac.NewPSPRole("restricted")
+	cr.Labels = nil
+	crb := rbac.NewPSPRoleBinding("restricted")
+	crb.Labels = nil
+	crb.Subjects = []rbacv1.Subject{group}
+	garbage := rbac.NewPSPNamespacedRoleBinding("deleted", "team-a")
+	garbage.Labels = nil
+	garbage.Subjects = []rbacv1.Subject{group}
+	k8sclient := fake.NewClientset(&policyv1.PodSecurityPolicy{ObjectMeta: metav1.ObjectMeta{Name: "restricted"}}, cr, crb, garbage)
+
+	state, err := GetState(ctx, k8sclient)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"deleted", "restricted"}, state.PSPNames())
+
+	desired := map[string]*DesiredBindings{
+		"restricted": {ClusterRoleBinding: []rbacv1.Subject{group, user}, RoleBindings: map[string][]rbacv1.Subject{}},
+	}
+	plan := NewPlan(state, desired, false)
+	if assert.Len(t, plan.Changes, 2) {
+		assert.Equal(t, ActionUpdate, plan.Changes[0].Action)
+		assert.Equal(t, "ClusterRole", plan.Changes[0].Kind)
+		assert.Equal(t, ActionUpdate, plan.Changes[1].Action)
+		assert.Equal(t, "ClusterRoleBinding", plan.Changes[1].Kind)
+		assert.Equal(t, []rbacv1.Subject{user}, plan.Changes[1].Added)
+	}
+	assert.NoError(t, plan.Apply(ctx, k8sclient, DryRunNone))
+
+	gotCR, err := rbac.GetClusterRole(ctx, k8sclient, "psp-util.restricted")
+	assert.NoError(t, err)
+	assert.True(t, utils.HasManagedLabels(gotCR.Labels, "restricted"))
+	assert.Equal(t, cr.Rules, gotCR.Rules)
+	gotCRB, err := rbac.GetClusterRoleBinding(ctx, k8sclient, "psp-util.restricted")
+	assert.NoError(t, err)
+	assert.True(t, utils.HasManagedLabels(gotCRB.Labels, "restricted"))
+	assert.Equal(t, []rbacv1.Subject{group, user}, gotCRB.Subjects)
+
+	state, err = GetState(ctx, k8sclient)
+	assert.NoError(t, err)
+	assert.True(t, NewPlan(state, desired, false).IsEmpty())
+
+	// the unlabeled garbage is collected
+	gcPlan, err := GetGCPlan(ctx, k8sclient)
+	assert.NoError(t, err)
+	if assert.Len(t, gcPlan.Changes, 1) {
+		assert.Equal(t, ActionDelete, gcPlan.Changes[0].Action)
+		assert.Equal(t, "team-a/psp-util.deleted", gcPlan.Changes[0].String())
+	}
+}
+
+func TestApplyWithRetry(t *testing.T) {
+	ctx := context.Background()
+	group := rbacv1.Subject{Kind: "Group", APIGroup: rbac.APIGroup, Name: "system:authenticated"}
+	user := rbacv1.Subject{Kind: "User", APIGroup: rbac.APIGroup, Name: "alice"}
+	crbResource := schema.GroupResource{Group: rbac.APIGroup, Resource: "clusterrolebindings"}
+
+	tests := []struct {
+		title           string
+		conflicts       int
+		expectConflicts int
+		expectErr       bool
+	}{
+		{
+			title:           "no conflict",
+			conflicts:       0,
+			expectConflicts: 0,
+		},
+		{
+			title:           "retried after conflicts",
+			conflicts:       2,
+			expectConflicts: 2,
+		},
+		{
+			title:           "gave up after continuous conflicts",
+			conflicts:       100,
+			expectConflicts: retry.DefaultRetry.Steps,
+			expectErr:       true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		crb := rbac.NewPSPRoleBinding("restricted")
+		crb.Subjects = []rbacv1.Subject{user}
+		k8sclient := fake.NewClientset(rbac.NewPSPRole("restricted"), crb)
+
+		// another pipeline updates the ClusterRoleBinding between Get and Apply
+		updates := 0
+		k8sclient.PrependReactor("patch", "clusterrolebindings", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			updates++
+			if updates <= test.conflicts {
+				return true, nil, apierrs.NewConflict(crbResource, crb.Name, fmt.Errorf("the object has been modified"))
+			}
+			return false, nil, nil
+		})
+
+		conflicts := 0
+		newPlan := func(res *Resources) (*Plan, error) {
+			return NewAttachPlan(res, "restricted", group, ""), nil
+		}
+		plan, err := ApplyWithRetry(ctx, k8sclient, "restricted", newPlan, DryRunNone, func(err error) { conflicts++ })
+		assert.Equal(t, test.expectConflicts, conflicts)
+		if test.expectErr {
+			assert.True(t, IsConflict(err))
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Len(t, plan.Changes, 1)
+
+		res, err := GetResources(ctx, k8sclient, "restricted")
+		assert.NoError(t, err)
+		assert.Equal(t, []rbacv1.Subject{user, group}, res.ClusterRoleBinding.Subjects)
+	}
+}
+
+func TestDeleteWithPreconditions(t *testing.T) {
+	ctx := context.Background()
+	sa := rbacv1.Subject{Kind: "ServiceAccount", Name: "default", Namespace: "team-a"}
+	user := rbacv1.Subject{Kind: "User", APIGroup: rbac.APIGroup, Name: "alice"}
+
+	tests := []struct {
+		title     string
+		retry     bool
+		expectRBs []rbacv1.Subject
+	}{
+		{
+			title:     "deletion of the binding modified after planning is rejected",
+			retry:     false,
+			expectRBs: []rbacv1.Subject{sa, user},
+		},
+		{
+			title:     "binding modified after planning is re-planned with retry",
+			retry:     true,
+			expectRBs: []rbacv1.Subject{user},
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		rb := rbac.NewPSPNamespacedRoleBinding("restricted", "team-a")
+		rb.Subjects = []rbacv1.Subject{sa}
+		k8sclient := fake.NewClientset(rbac.NewPSPRole("restricted"), rb)
+
+		// another job attaches a subject after the last subject is planned to be detached
+		attached := false
+		newPlan := func(res *Resources) (*Plan, error) {
+			plan := NewDetachPlan(res, "restricted", sa, "team-a")
+			if !attached {
+				attached = true
+				latest, err := GetResources(ctx, k8sclient, "restricted")
+				assert.NoError(t, err)
+				assert.NoError(t, NewAttachPlan(latest, "restricted", user, "team-a").Apply(ctx, k8sclient, DryRunNone))
+			}
+			return plan, nil
+		}
+
+		if test.retry {
+			conflicts := 0
+			plan, err := ApplyWithRetry(ctx, k8sclient, "restricted", newPlan, DryRunNone, func(err error) { conflicts++ })
+			assert.NoError(t, err)
+			assert.Equal(t, 1, conflicts)
+			if assert.Len(t, plan.Changes, 1) {
+				assert.Equal(t, ActionUpdate, plan.Changes[0].Action)
+			}
+		} else {
+			res, err := GetResources(ctx, k8sclient, "restricted")
+			assert.NoError(t, err)
+			plan, _ := newPlan(res)
+			if assert.Len(t, plan.Changes, 1) {
+				assert.Equal(t, ActionDelete, plan.Changes[0].Action)
+			}
+			assert.True(t, IsConflict(plan.Apply(ctx, k8sclient, DryRunNone)))
+		}
+
+		res, err := GetResources(ctx, k8sclient, "restricted")
+		assert.NoError(t, err)
+		if assert.NotNil(t, res.RoleBindings["team-a"]) {
+			assert.Equal(t, test.expectRBs, res.RoleBindings["team-a"].Subjects)
+		}
+	}
+}
+
+func TestConcurrentCreates(t *testing.T) {
+	ctx := context.Background()
+	sa := rbacv1.Subject{Kind: "ServiceAccount", Name: "default", Namespace: "team-a"}
+	user := rbacv1.Subject{Kind: "User", APIGroup: rbac.APIGroup, Name: "alice"}
+
+	tests := []struct {
+		title     string
+		namespace string
+	}{
+		{
+			title:     "ClusterRoleBinding created by two jobs",
+			namespace: "",
+		},
+		{
+			title:     "RoleBinding created by two jobs",
+			namespace: "team-a",
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		k8sclient := fake.NewClientset(&policyv1.PodSecurityPolicy{ObjectMeta: metav1.ObjectMeta{Name: "restricted"}})
+
+		// both jobs plan to create the binding, and the other job creates it first
+		conflicts := make([]error, 0)
+		newPlan := func(res *Resources) (*Plan, error) {
+			plan := NewAttachPlan(res, "restricted", sa, test.namespace)
+			if len(conflicts) == 0 {
+				assert.NoError(t, NewAttachPlan(res, "restricted", user, test.namespace).Apply(ctx, k8sclient, DryRunNone))
+			}
+			return plan, nil
+		}
+		plan, err := ApplyWithRetry(ctx, k8sclient, "restricted", newPlan, DryRunNone, func(err error) { conflicts = append(conflicts, err) })
+		assert.NoError(t, err)
+		if assert.Len(t, conflicts, 1) {
+			var ce *ChangeError
+			if assert.True(t, errors.As(conflicts[0], &ce)) {
+				assert.True(t, apierrs.IsAlreadyExists(ce.Err))
+			}
+		}
+		if assert.Len(t, plan.Changes, 1) {
+			assert.Equal(t, ActionUpdate, plan.Changes[0].Action)
+		}
+
+		res, err := GetResources(ctx, k8sclient, "restricted")
+		assert.NoError(t, err)
+		if test.namespace == "" {
+			if assert.NotNil(t, res.ClusterRoleBinding) {
+				assert.Equal(t, []rbacv1.Subject{user, sa}, res.ClusterRoleBinding.Subjects)
+			}
+		} else if assert.NotNil(t, res.RoleBindings[test.namespace]) {
+			assert.Equal(t, []rbacv1.Subject{user, sa}, res.RoleBindings[test.namespace].Subjects)
+		}
+	}
+}
+
+func TestAdoptWithFakeClient(t *testing.T) {
+	ctx := context.Background()
+	group := rbacv1.Subject{Kind: "Group", APIGroup: rbac.APIGroup, Name: "system:authenticated"}
+	useRule := func(pspNames ...string) []rbacv1.PolicyRule {
+		return []rbacv1.PolicyRule{{APIGroups: []string{"policy"}, Resources: []string{"podsecuritypolicies"}, ResourceNames: pspNames, Verbs: []string{"use"}}}
+	}
+	roleRef := func(name string) rbacv1.RoleRef {
+		return rbacv1.RoleRef{APIGroup: rbac.APIGroup, Kind: "ClusterRole", Name: name}
+	}
+
+	managedCRB := rbac.NewPSPRoleBinding("restricted")
+	managedCRB.Subjects = []rbacv1.Subject{group}
+	// generated by the older versions without the managed labels
+	unlabeledRB := rbac.NewPSPNamespacedRoleBinding("restricted", "team-b")
+	unlabeledRB.Labels = nil
+	unlabeledRB.Subjects = []rbacv1.Subject{group}
+	objects := []runtime.Object{
+		&policyv1.PodSecurityPolicy{ObjectMeta: metav1.ObjectMeta{Name: "privileged"}},
+		&policyv1.PodSecurityPolicy{ObjectMeta: metav1.ObjectMeta{Name: "restricted"}},
+		rbac.NewPSPRole("restricted"),
+		managedCRB,
+		unlabeledRB,
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "psp:privileged"}, Rules: useRule("privileged")},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "psp:both"}, Rules: useRule("privileged", "restricted")},
+		&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "psp:privileged"}, RoleRef: roleRef("psp:privileged"), Subjects: []rbacv1.Subject{group}},
+		&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "psp:privileged", Namespace: "kube-system"}, RoleRef: roleRef("psp:privileged"), Subjects: []rbacv1.Subject{group}},
+		&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "hand-written", Namespace: "team-a"}, RoleRef: roleRef("psp-util.restricted"), Subjects: []rbacv1.Subject{group}},
+		// skipped as the managed ClusterRoleBinding of the PSP exists
+		&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "hand-written"}, RoleRef: roleRef("psp-util.restricted"), Subjects: []rbacv1.Subject{group}},
+	}
+
+	tests := []struct {
+		title           string
+		dryRun          string
+		expectAdoptions []Adoption
+		expectSkipped   int
+	}{
+		{
+			title:  "dry-run",
+			dryRun: DryRunClient,
+			expectAdoptions: []Adoption{
+				{PSP: "privileged", Kind: "ClusterRole", Name: "psp:privileged"},
+				{PSP: "privileged", Kind: "ClusterRoleBinding", Name: "psp:privileged"},
+				{PSP: "privileged", Kind: "RoleBinding", Namespace: "kube-system", Name: "psp:privileged"},
+				{PSP: "restricted", Kind: "RoleBinding", Namespace: "team-b", Name: "psp-util.restricted", Backfill: true},
+				{PSP: "restricted", Kind: "RoleBinding", Namespace: "team-a", Name: "hand-written"},
+			},
+			expectSkipped: 2,
+		},
+		{
+			title:  "adopt",
+			dryRun: DryRunNone,
+			expectAdoptions: []Adoption{
+				{PSP: "privileged", Kind: "ClusterRole", Name: "psp:privileged"},
+				{PSP: "privileged", Kind: "ClusterRoleBinding", Name: "psp:privileged"},
+				{PSP: "privileged", Kind: "RoleBinding", Namespace: "kube-system", Name: "psp:privileged"},
+				{PSP: "restricted", Kind: "RoleBinding", Namespace: "team-b", Name: "psp-util.restricted", Backfill: true},
+				{PSP: "restricted", Kind: "RoleBinding", Namespace: "team-a", Name: "hand-written"},
+			},
+			expectSkipped: 2,
+		},
+		{
+			title:           "already adopted",
+			dryRun:          DryRunNone,
+			expectAdoptions: []Adoption{},
+			expectSkipped:   2,
+		},
+	}
+
+	// the steps are applied in order to the same cluster
+	k8sclient := fake.NewClientset(objects...)
+	for _, test := range tests {
+		t.Log(test.title)
+		psps, err := relations.GetRelationalPSPs(ctx, k8sclient)
+		assert.NoError(t, err)
+
+		adoptions, skipped := FindAdoptions(psps)
+		assert.Equal(t, test.expectAdoptions, adoptions)
+		assert.Len(t, skipped, test.expectSkipped)
+		for _, a := range adoptions {
+			assert.NoError(t, a.Adopt(ctx, k8sclient, test.dryRun))
+		}
+	}
+
+	res, err := GetResources(ctx, k8sclient, "restricted")
+	assert.NoError(t, err)
+	assert.Contains(t, res.RoleBindings, "team-b")
+	assert.Equal(t, "hand-written", res.RoleBindings["team-a"].Name)
+	assert.Equal(t, "psp-util.restricted", res.ClusterRoleBinding.Name)
+
+	crb, err := rbac.GetClusterRoleBinding(ctx, k8sclient, "psp:privileged")
+	assert.NoError(t, err)
+	assert.Equal(t, []rbacv1.Subject{group}, crb.Subjects)
+	assert.Equal(t, "privileged", crb.Labels["psp-util.k8s.jlandowner.com/psp"])
+}
+
+func TestGCWithFakeClient(t *testing.T) {
+	ctx := context.Background()
+	app := rbacv1.Subject{Kind: "ServiceAccount", Namespace: "team-a", Name: "app"}
+	deletedSA := rbacv1.Subject{Kind: "ServiceAccount", Namespace: "team-a", Name: "deleted"}
+	deletedSAUser := rbacv1.Subject{Kind: "User", APIGroup: rbac.APIGroup, Name: "system:serviceaccount:team-a:deleted"}
+	deletedNSGroup := rbacv1.Subject{Kind: "Group", APIGroup: rbac.APIGroup, Name: "system:serviceaccounts:team-deleted"}
+	user := rbacv1.Subject{Kind: "User", APIGroup: rbac.APIGroup, Name: "alice"}
+
+	restrictedCRB := rbac.NewPSPRoleBinding("restricted")
+	restrictedCRB.Subjects = []rbacv1.Subject{app, deletedSA, deletedSAUser, deletedNSGroup, user}
+	restrictedRB := rbac.NewPSPNamespacedRoleBinding("restricted", "team-b")
+	restrictedRB.Subjects = []rbacv1.Subject{{Kind: "ServiceAccount", Namespace: "team-b", Name: "deleted"}}
+	deletedCRB := rbac.NewPSPRoleBinding("deleted")
+	deletedCRB.Subjects = []rbacv1.Subject{user}
+
+	k8sclient := fake.NewClientset(
+		&policyv1.PodSecurityPolicy{ObjectMeta: metav1.ObjectMeta{Name: "restricted"}},
+		&policyv1.PodSecurityPolicy{ObjectMeta: metav1.ObjectMeta{Name: "privileged"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a"}},
+		rbac.NewPSPRole("restricted"), restrictedCRB, restrictedRB,
+		rbac.NewPSPRole("privileged"), rbac.NewPSPRoleBinding("privileged"),
+		rbac.NewPSPRole("deleted"), deletedCRB,
+	)
+
+	state, err := GetState(ctx, k8sclient)
+	assert.NoError(t, err)
+	existence, err := GetExistence(ctx, k8sclient)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		title  string
+		sub    rbacv1.Subject
+		expect bool
+	}{
+		{title: "existing ServiceAccount", sub: app, expect: false},
+		{title: "deleted ServiceAccount", sub: deletedSA, expect: true},
+		{title: "deleted ServiceAccount as User", sub: deletedSAUser, expect: true},
+		{title: "ServiceAccounts group of deleted namespace", sub: deletedNSGroup, expect: true},
+		{title: "User", sub: user, expect: false},
+	}
+	for _, test := range tests {
+		t.Log(test.title)
+		assert.Equal(t, test.expect, existence.IsDanglingSubject(test.sub))
+	}
+
+	plan := NewGCPlan(state, existence)
+	changes := make([]string, 0)
+	for _, c := range plan.Changes {
+		changes = append(changes, fmt.Sprintf("%s %s %s", c.Action, c.Kind, c.String()))
+	}
+	assert.Equal(t, []string{
+		"delete ClusterRoleBinding psp-util.deleted",
+		"delete ClusterRole psp-util.deleted",
+		"delete ClusterRoleBinding psp-util.privileged",
+		"update ClusterRoleBinding psp-util.restricted",
+		"delete RoleBinding team-b/psp-util.restricted",
+	}, changes)
+
+	assert.NoError(t, plan.Apply(ctx, k8sclient, DryRunNone))
+	state, err = GetState(ctx, k8sclient)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"privileged", "restricted"}, state.PSPNames())
+	assert.Equal(t, []rbacv1.Subject{app, user}, state["restricted"].ClusterRoleBinding.Subjects)
+	assert.Empty(t, state["restricted"].RoleBindings)
+	assert.True(t, NewGCPlan(state, existence).IsEmpty())
+}
+
+func TestGCModifiedAfterPlanning(t *testing.T) {
+	ctx := context.Background()
+	deletedSA := rbacv1.Subject{Kind: "ServiceAccount", Namespace: "team-a", Name: "deleted"}
+	user := rbacv1.Subject{Kind: "User", APIGroup: rbac.APIGroup, Name: "alice"}
+	attached := rbacv1.Subject{Kind: "User", APIGroup: rbac.APIGroup, Name: "bob"}
+
+	tests := []struct {
+		title      string
+		namespace  string
+		expectSame bool
+		expectCRB  []rbacv1.Subject
+		expectRB   []rbacv1.Subject
+	}{
+		{
+			title:      "subject attached to the ClusterRoleBinding to be updated after planning",
+			namespace:  "",
+			expectSame: true,
+			expectCRB:  []rbacv1.Subject{user, attached},
+			expectRB:   nil,
+		},
+		{
+			title:      "subject attached to the RoleBinding to be deleted after planning",
+			namespace:  "team-a",
+			expectSame: false,
+			// the update of the ClusterRoleBinding is applied before the deletion is rejected
+			expectCRB: []rbacv1.Subject{user},
+			expectRB:  []rbacv1.Subject{deletedSA, attached},
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		crb := rbac.NewPSPRoleBinding("restricted")
+		crb.Subjects = []rbacv1.Subject{user, deletedSA}
+		rb := rbac.NewPSPNamespacedRoleBinding("restricted", "team-a")
+		rb.Subjects = []rbacv1.Subject{deletedSA}
+		k8sclient := fake.NewClientset(
+			&policyv1.PodSecurityPolicy{ObjectMeta: metav1.ObjectMeta{Name: "restricted"}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+			rbac.NewPSPRole("restricted"), crb, rb,
+		)
+
+		plan, err := GetGCPlan(ctx, k8sclient)
+		assert.NoError(t, err)
+		assert.Len(t, plan.Changes, 2)
+
+		// a subject is attached while waiting for the confirmation
+		res, err := GetResources(ctx, k8sclient, "restricted")
+		assert.NoError(t, err)
+		assert.NoError(t, NewAttachPlan(res, "restricted", attached, test.namespace).Apply(ctx, k8sclient, DryRunNone))
+
+		// the stale plan is rejected
+		assert.True(t, IsConflict(plan.Apply(ctx, k8sclient, DryRunNone)))
+
+		latest, err := GetGCPlan(ctx, k8sclient)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expectSame, latest.HasSameChanges(plan))
+		if test.expectSame {
+			assert.NoError(t, latest.Apply(ctx, k8sclient, DryRunNone))
+		}
+
+		res, err = GetResources(ctx, k8sclient, "restricted")
+		assert.NoError(t, err)
+		assert.Equal(t, test.expectCRB, res.ClusterRoleBinding.Subjects)
+		if test.expectRB == nil {
+			assert.NotContains(t, res.RoleBindings, "team-a")
+		} else if assert.NotNil(t, res.RoleBindings["team-a"]) {
+			assert.Equal(t, test.expectRB, res.RoleBindings["team-a"].Subjects)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package managed
+
+import (
+	"context"
+	"sort"
+
+	"github.com/jlandowner/psp-util/pkg/manifests"
+	"github.com/jlandowner/psp-util/pkg/rbac"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GetResources returns the managed RBAC resources of the PSP in cluster
+func GetResources(ctx context.Context, k8sclient kubernetes.Interface, pspName string) (*Resources, error) {
+	crs, err := rbac.ListManagedClusterRoles(ctx, k8sclient, pspName)
+	if err != nil {
+		return nil, err
+	}
+	crbs, err := rbac.ListManagedClusterRoleBindings(ctx, k8sclient, pspName)
+	if err != nil {
+		return nil, err
+	}
+	rbs, err := rbac.ListManagedRoleBindings(ctx, k8sclient, pspName)
+	if err != nil {
+		return nil, err
+	}
+	if res, ok := NewState(crs, crbs, rbs)[pspName]; ok {
+		return res, nil
+	}
+	return &Resources{RoleBindings: make(map[string]*rbacv1.RoleBinding)}, nil
+}
+
+// GetResourcesFromManifests returns the managed RBAC resources of the PSP in the objects loaded from manifest files
+func GetResourcesFromManifests(objs *manifests.Objects, pspName string) *Resources {
+	state := NewState(objs.ClusterRoles.Items, objs.ClusterRoleBindings.Items, objs.RoleBindings.Items)
+	if res, ok := state[pspName]; ok {
+		return res
+	}
+	return &Resources{RoleBindings: make(map[string]*rbacv1.RoleBinding)}
+}
+
+// IsEmpty returns true if no managed resource exists
+func (r *Resources) IsEmpty() bool {
+	return r.ClusterRole == nil && r.ClusterRoleBinding == nil && len(r.RoleBindings) == 0
+}
+
+// namespaces returns the sorted namespaces of the managed RoleBindings
+func (r *Resources) namespaces() []string {
+	namespaces := make([]string, 0, len(r.RoleBindings))
+	for ns := range r.RoleBindings {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// bindings returns the subjects currently bound by the managed bindings
+func (r *Resources) bindings() *DesiredBindings {
+	b := &DesiredBindings{
+		ClusterRoleBinding: make([]rbacv1.Subject, 0),
+		RoleBindings:       make(map[string][]rbacv1.Subject),
+	}
+	if r.ClusterRoleBinding != nil {
+		b.ClusterRoleBinding = append(b.ClusterRoleBinding, r.ClusterRoleBinding.Subjects...)
+	}
+	for ns, rb := range r.RoleBindings {
+		b.RoleBindings[ns] = append(make([]rbacv1.Subject, 0), rb.Subjects...)
+	}
+	return b
+}
+
+// NewAttachPlan returns the changes to attach the subject to the PSP.
+// The subject is bound by the managed RoleBinding in the namespace, or by the managed ClusterRoleBinding if namespace is empty
+func NewAttachPlan(res *Resources, pspName string, subject rbacv1.Subject, namespace string) *Plan {
+	want := res.bindings()
+	if namespace == "" {
+		want.ClusterRoleBinding, _ = rbac.AttachSubject(want.ClusterRoleBinding, subject)
+	} else {
+		want.RoleBindings[namespace], _ = rbac.AttachSubject(want.RoleBindings[namespace], subject)
+	}
+	return NewPlan(State{pspName: res}, map[string]*DesiredBindings{pspName: want}, false)
+}
+
+// NewDetachPlan returns the changes to detach the subject from the PSP.
+// The managed RoleBinding left without subjects is deleted
+func NewDetachPlan(res *Resources, pspName string, subject rbacv1.Subject, namespace string) *Plan {
+	want := res.bindings()
+	if namespace == "" {
+		want.ClusterRoleBinding = subtractSubjects(want.ClusterRoleBinding, []rbacv1.Subject{subject})
+	} else {
+		want.RoleBindings[namespace] = subtractSubjects(want.RoleBindings[namespace], []rbacv1.Subject{subject})
+	}
+	return NewPlan(State{pspName: res}, map[string]*DesiredBindings{pspName: want}, true)
+}
+
+// NewCleanPlan returns the changes to delete all the managed resources of the PSP
+func NewCleanPlan(res *Resources, pspName string) *Plan {
+	plan := &Plan{Changes: make([]Change, 0)}
+
+	if crb := res.ClusterRoleBinding; crb != nil {
+		plan.Changes = append(plan.Changes, Change{
+			Action: ActionDelete, PSP: pspName, Kind: "ClusterRoleBinding", Name: crb.Name,
+			Removed: crb.Subjects, Object: crb,
+		})
+	}
+	for _, ns := range res.namespaces() {
+		rb := res.RoleBindings[ns]
+		plan.Changes = append(plan.Changes, Change{
+			Action: ActionDelete, PSP: pspName, Kind: "RoleBinding", Namespace: ns, Name: rb.Name,
+			Removed: rb.Subjects, Object: rb,
+		})
+	}
+	if cr := res.ClusterRole; cr != nil {
+		plan.Changes = append(plan.Changes, Change{
+			Action: ActionDelete, PSP: pspName, Kind: "ClusterRole", Name: cr.Name,
+			Object: cr,
+		})
+	}
+	return plan
+}
+
+// Result returns the managed resources of the PSP after the changes are applied
+func (p *Plan) Result(res *Resources) *Resources {
+	result := &Resources{
+		ClusterRole:        res.ClusterRole,
+		ClusterRoleBinding: res.ClusterRoleBinding,
+		RoleBindings:       make(map[string]*rbacv1.RoleBinding),
+	}
+	for ns, rb := range res.RoleBindings {
+		result.RoleBindings[ns] = rb
+	}
+
+	for _, c := range p.Changes {
+		switch obj := c.Object.(type) {
+		case *rbacv1.ClusterRole:
+			if c.Action == ActionDelete {
+				obj = nil
+			}
+			result.ClusterRole = obj
+		case *rbacv1.ClusterRoleBinding:
+			if c.Action == ActionDelete {
+				obj = nil
+			}
+			result.ClusterRoleBinding = obj
+		case *rbacv1.RoleBinding:
+			if c.Action == ActionDelete {
+				delete(result.RoleBindings, c.Namespace)
+				continue
+			}
+			result.RoleBindings[c.Namespace] = obj
+		}
+	}
+	return result
+}
+
+// Manifests returns the managed resources as manifests without the fields populated by the API server
+func (r *Resources) Manifests() []interface{} {
+	objs := make([]interface{}, 0)
+	if r.ClusterRole != nil {
+		cr := &rbacv1.ClusterRole{
+			TypeMeta:        metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+			ObjectMeta:      manifestMeta(r.ClusterRole.ObjectMeta),
+			Rules:           r.ClusterRole.Rules,
+			AggregationRule: r.ClusterRole.AggregationRule,
+		}
+		objs = append(objs, cr)
+	}
+	if r.ClusterRoleBinding != nil {
+		crb := &rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRoleBinding"},
+			ObjectMeta: manifestMeta(r.ClusterRoleBinding.ObjectMeta),
+			Subjects:   r.ClusterRoleBinding.Subjects,
+			RoleRef:    r.ClusterRoleBinding.RoleRef,
+		}
+		objs = append(objs, crb)
+	}
+	for _, ns := range r.namespaces() {
+		rb := &rbacv1.RoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "RoleBinding"},
+			ObjectMeta: manifestMeta(r.RoleBindings[ns].ObjectMeta),
+			Subjects:   r.RoleBindings[ns].Subjects,
+			RoleRef:    r.RoleBindings[ns].RoleRef,
+		}
+		objs = append(objs, rb)
+	}
+	return objs
+}
+
+// Deletions returns the manifests identifying the resources deleted by the changes
+func (p *Plan) Deletions() []interface{} {
+	objs := make([]interface{}, 0)
+	for _, c := range p.Changes {
+		if c.Action != ActionDelete {
+			continue
+		}
+		objs = append(objs, &metav1.PartialObjectMetadata{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: c.Kind},
+			ObjectMeta: metav1.ObjectMeta{Name: c.Name, Namespace: c.Namespace},
+		})
+	}
+	return objs
+}
+
+// manifestMeta returns the metadata without the fields populated by the API server
+func manifestMeta(meta metav1.ObjectMeta) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:        meta.Name,
+		Namespace:   meta.Namespace,
+		Labels:      meta.Labels,
+		Annotations: meta.Annotations,
+	}
+}
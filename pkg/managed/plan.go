@@ -0,0 +1,375 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package managed
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/jlandowner/psp-util/pkg/rbac"
+	"github.com/jlandowner/psp-util/pkg/utils"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+// Change is an operation on a managed RBAC resource
+type Change struct {
+	Action    Action
+	PSP       string
+	Kind      string
+	Namespace string
+	Name      string
+	// Added and Removed are the subjects changed in the binding
+	Added   []rbacv1.Subject
+	Removed []rbacv1.Subject
+	// Object is the resource to create or update, or the current resource to delete
+	Object runtime.Object
+	// Reason is why the change is required if not obvious, e.g. garbage collection
+	Reason string
+}
+
+// Plan is the ordered changes to reconcile the managed RBAC resources
+type Plan struct {
+	Changes []Change
+}
+
+// Counts returns the number of resources to create, update and delete
+func (p *Plan) Counts() (create, update, destroy int) {
+	for _, c := range p.Changes {
+		switch c.Action {
+		case ActionCreate:
+			create++
+		case ActionUpdate:
+			update++
+		case ActionDelete:
+			destroy++
+		}
+	}
+	return create, update, destroy
+}
+
+// IsEmpty returns true if there is nothing to change
+func (p *Plan) IsEmpty() bool {
+	return len(p.Changes) == 0
+}
+
+// HasSameChanges returns true if the plans make the same changes on the same resources.
+// The objects are not compared, so that the resource versions may differ
+func (p *Plan) HasSameChanges(other *Plan) bool {
+	if len(p.Changes) != len(other.Changes) {
+		return false
+	}
+	for i, c := range p.Changes {
+		o := other.Changes[i]
+		c.Object, o.Object = nil, nil
+		if !reflect.DeepEqual(c, o) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewPlan computes the changes from the current state to the desired bindings.
+// Subjects not in desired are kept unless prune is true,
+// and managed RoleBindings left without subjects by prune are deleted
+func NewPlan(state State, desired map[string]*DesiredBindings, prune bool) *Plan {
+	plan := &Plan{Changes: make([]Change, 0)}
+
+	names := make(map[string]bool)
+	for name := range desired {
+		names[name] = true
+	}
+	if prune {
+		for name := range state {
+			names[name] = true
+		}
+	}
+	pspNames := make([]string, 0, len(names))
+	for name := range names {
+		pspNames = append(pspNames, name)
+	}
+	sort.Strings(pspNames)
+
+	for _, psp := range pspNames {
+		cur, ok := state[psp]
+		if !ok {
+			cur = &Resources{RoleBindings: make(map[string]*rbacv1.RoleBinding)}
+		}
+		want, ok := desired[psp]
+		if !ok {
+			want = &DesiredBindings{RoleBindings: make(map[string][]rbacv1.Subject)}
+		}
+		// new bindings are generated by the name, and bound to the managed ClusterRole which may be adopted
+		name := utils.GenerateName(psp)
+		roleName := name
+		if cur.ClusterRole != nil {
+			roleName = cur.ClusterRole.Name
+			if c, ok := labelBackfill(psp, "ClusterRole", cur.ClusterRole.DeepCopy()); ok {
+				plan.Changes = append(plan.Changes, c)
+			}
+		} else if len(want.ClusterRoleBinding) > 0 || len(want.RoleBindings) > 0 {
+			plan.Changes = append(plan.Changes, Change{
+				Action: ActionCreate, PSP: psp, Kind: "ClusterRole", Name: name,
+				Object: rbac.NewPSPRole(psp),
+			})
+		}
+
+		// ClusterRoleBinding
+		if cur.ClusterRoleBinding == nil {
+			if len(want.ClusterRoleBinding) > 0 {
+				crb := rbac.NewPSPRoleBinding(psp)
+				crb.RoleRef.Name = roleName
+				crb.Subjects = want.ClusterRoleBinding
+				plan.Changes = append(plan.Changes, Change{
+					Action: ActionCreate, PSP: psp, Kind: "ClusterRoleBinding", Name: name,
+					Added: want.ClusterRoleBinding, Object: crb,
+				})
+			}
+		} else {
+			subjects, added, removed := diffSubjects(cur.ClusterRoleBinding.Subjects, want.ClusterRoleBinding, prune)
+			if len(added) > 0 || len(removed) > 0 {
+				crb := cur.ClusterRoleBinding.DeepCopy()
+				crb.Subjects = subjects
+				plan.Changes = append(plan.Changes, Change{
+					Action: ActionUpdate, PSP: psp, Kind: "ClusterRoleBinding", Name: crb.Name,
+					Added: added, Removed: removed, Object: crb,
+				})
+			} else if c, ok := labelBackfill(psp, "ClusterRoleBinding", cur.ClusterRoleBinding.DeepCopy()); ok {
+				plan.Changes = append(plan.Changes, c)
+			}
+		}
+
+		// RoleBindings
+		nsSet := make(map[string]bool)
+		for ns := range want.RoleBindings {
+			nsSet[ns] = true
+		}
+		for ns := range cur.RoleBindings {
+			nsSet[ns] = true
+		}
+		namespaces := make([]string, 0, len(nsSet))
+		for ns := range nsSet {
+			namespaces = append(namespaces, ns)
+		}
+		sort.Strings(namespaces)
+
+		for _, ns := range namespaces {
+			curRB := cur.RoleBindings[ns]
+			wantSubs := want.RoleBindings[ns]
+
+			if curRB == nil {
+				if len(wantSubs) > 0 {
+					rb := rbac.NewPSPNamespacedRoleBinding(psp, ns)
+					rb.RoleRef.Name = roleName
+					rb.Subjects = wantSubs
+					plan.Changes = append(plan.Changes, Change{
+						Action: ActionCreate, PSP: psp, Kind: "RoleBinding", Namespace: ns, Name: name,
+						Added: wantSubs, Object: rb,
+					})
+				}
+				continue
+			}
+
+			subjects, added, removed := diffSubjects(curRB.Subjects, wantSubs, prune)
+			if len(added) == 0 && len(removed) == 0 {
+				if c, ok := labelBackfill(psp, "RoleBinding", curRB.DeepCopy()); ok {
+					plan.Changes = append(plan.Changes, c)
+				}
+				continue
+			}
+			if len(subjects) == 0 {
+				plan.Changes = append(plan.Changes, Change{
+					Action: ActionDelete, PSP: psp, Kind: "RoleBinding", Namespace: ns, Name: curRB.Name,
+					Removed: removed, Object: curRB,
+				})
+				continue
+			}
+			rb := curRB.DeepCopy()
+			rb.Subjects = subjects
+			plan.Changes = append(plan.Changes, Change{
+				Action: ActionUpdate, PSP: psp, Kind: "RoleBinding", Namespace: ns, Name: rb.Name,
+				Added: added, Removed: removed, Object: rb,
+			})
+		}
+	}
+	return plan
+}
+
+// managedObject is a managed RBAC resource
+type managedObject interface {
+	metav1.Object
+	runtime.Object
+}
+
+// labelBackfill returns the change to add the managed labels to the resource generated by the older versions of psp-util,
+// which is managed by the annotation and the generated name
+func labelBackfill(pspName, kind string, obj managedObject) (Change, bool) {
+	if utils.HasManagedLabels(obj.GetLabels(), pspName) {
+		return Change{}, false
+	}
+	labels := make(map[string]string)
+	for k, v := range obj.GetLabels() {
+		labels[k] = v
+	}
+	for k, v := range utils.GenerateLabels(pspName) {
+		labels[k] = v
+	}
+	obj.SetLabels(labels)
+	return Change{
+		Action: ActionUpdate, PSP: pspName, Kind: kind, Namespace: obj.GetNamespace(), Name: obj.GetName(),
+		Object: obj, Reason: "managed labels are missing",
+	}, true
+}
+
+// diffSubjects returns the resulting subjects and the added and removed ones
+func diffSubjects(current, desired []rbacv1.Subject, prune bool) (subjects, added, removed []rbacv1.Subject) {
+	added = subtractSubjects(desired, current)
+	removed = make([]rbacv1.Subject, 0)
+	if prune {
+		removed = subtractSubjects(current, desired)
+	}
+	subjects = append(subtractSubjects(current, removed), added...)
+	return subjects, added, removed
+}
+
+// Dry-run modes of Apply
+const (
+	DryRunNone   = "none"
+	DryRunClient = "client"
+	DryRunServer = "server"
+)
+
+// Apply executes the changes in order.
+// With DryRunClient nothing is sent to the API server, and with DryRunServer
+// the requests are processed by the API server without persisting.
+// The objects of created or updated changes are replaced with the ones returned by the API server
+func (p *Plan) Apply(ctx context.Context, k8sclient kubernetes.Interface, dryRun string) error {
+	if dryRun == DryRunClient {
+		return nil
+	}
+	opts := dryRunOptions(dryRun)
+	for i, c := range p.Changes {
+		obj, err := c.apply(ctx, k8sclient, opts)
+		if err != nil {
+			return &ChangeError{Change: c, Err: err}
+		}
+		if obj != nil {
+			// TypeMeta is dropped when the response is decoded
+			obj.GetObjectKind().SetGroupVersionKind(c.Object.GetObjectKind().GroupVersionKind())
+			p.Changes[i].Object = obj
+		}
+	}
+	return nil
+}
+
+// dryRunOptions returns the dry-run option of the requests to the API server
+func dryRunOptions(dryRun string) []string {
+	if dryRun == DryRunServer {
+		return []string{metav1.DryRunAll}
+	}
+	return nil
+}
+
+// ChangeError is an error returned by the API server on applying the change
+type ChangeError struct {
+	Change Change
+	Err    error
+}
+
+func (e *ChangeError) Error() string {
+	return fmt.Sprintf("Failed to %s %s %s: %v", e.Change.Action, e.Change.Kind, e.Change.String(), e.Err)
+}
+
+func (e *ChangeError) Unwrap() error {
+	return e.Err
+}
+
+// apply sends the change to the API server.
+// Updated resources are applied by server-side apply with the field manager of psp-util.
+// Bindings are created by create requests failing with AlreadyExists if created concurrently,
+// because the forced apply would replace the subjects attached by the others.
+// Deleted resources are deleted only if they are not modified since planned, so that the subjects added meanwhile are not lost
+func (c Change) apply(ctx context.Context, k8sclient kubernetes.Interface, dryRun []string) (runtime.Object, error) {
+	switch obj := c.Object.(type) {
+	case *rbacv1.ClusterRole:
+		switch c.Action {
+		case ActionCreate:
+			return rbac.ApplyClusterRole(ctx, k8sclient, rbac.NewPSPRole(c.PSP), dryRun...)
+		case ActionUpdate:
+			// only the managed annotation and labels are applied as the rules may be adopted
+			return rbac.AdoptClusterRole(ctx, k8sclient, obj.Name, c.PSP, dryRun...)
+		case ActionDelete:
+			return nil, rbac.DeleteClusterRole(ctx, k8sclient, obj.Name, rbac.Preconditions(obj.ObjectMeta), dryRun...)
+		}
+	case *rbacv1.ClusterRoleBinding:
+		switch c.Action {
+		case ActionCreate:
+			crb := rbac.NewPSPRoleBinding(c.PSP)
+			crb.RoleRef = obj.RoleRef
+			crb.Subjects = obj.Subjects
+			return rbac.CreateClusterRoleBinding(ctx, k8sclient, crb, dryRun...)
+		case ActionUpdate:
+			// only the managed fields are applied so that the fields set by the others are kept.
+			// The name and roleRef are of the current binding which may be adopted
+			crb := rbac.NewPSPRoleBinding(c.PSP)
+			crb.Name = obj.Name
+			crb.ResourceVersion = obj.ResourceVersion
+			crb.RoleRef = obj.RoleRef
+			crb.Subjects = obj.Subjects
+			return rbac.ApplyClusterRoleBinding(ctx, k8sclient, crb, dryRun...)
+		case ActionDelete:
+			return nil, rbac.DeleteClusterRoleBindings(ctx, k8sclient, obj.Name, rbac.Preconditions(obj.ObjectMeta), dryRun...)
+		}
+	case *rbacv1.RoleBinding:
+		switch c.Action {
+		case ActionCreate:
+			rb := rbac.NewPSPNamespacedRoleBinding(c.PSP, c.Namespace)
+			rb.RoleRef = obj.RoleRef
+			rb.Subjects = obj.Subjects
+			return rbac.CreateRoleBinding(ctx, k8sclient, rb, dryRun...)
+		case ActionUpdate:
+			rb := rbac.NewPSPNamespacedRoleBinding(c.PSP, c.Namespace)
+			rb.Name = obj.Name
+			rb.ResourceVersion = obj.ResourceVersion
+			rb.RoleRef = obj.RoleRef
+			rb.Subjects = obj.Subjects
+			return rbac.ApplyRoleBinding(ctx, k8sclient, rb, dryRun...)
+		case ActionDelete:
+			return nil, rbac.DeleteRoleBinding(ctx, k8sclient, obj.Namespace, obj.Name, rbac.Preconditions(obj.ObjectMeta), dryRun...)
+		}
+	}
+	return nil, fmt.Errorf("unsupported %s of %T", c.Action, c.Object)
+}
+
+// String returns the resource name as NAME or NAMESPACE/NAME
+func (c Change) String() string {
+	if c.Namespace != "" {
+		return c.Namespace + "/" + c.Name
+	}
+	return c.Name
+}
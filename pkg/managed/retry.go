@@ -0,0 +1,61 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package managed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// IsConflict returns true if the change failed because the resource was modified or created concurrently
+func IsConflict(err error) bool {
+	var ce *ChangeError
+	if errors.As(err, &ce) {
+		err = ce.Err
+	}
+	return apierrs.IsConflict(err) || apierrs.IsAlreadyExists(err)
+}
+
+// ApplyWithRetry gets the managed resources of the PSP, computes the changes by newPlan and applies them.
+// When the resources are modified concurrently, onConflict is called if not nil
+// and it is retried from re-reading the latest resources
+func ApplyWithRetry(ctx context.Context, k8sclient kubernetes.Interface, pspName string,
+	newPlan func(res *Resources) (*Plan, error), dryRun string, onConflict func(err error),
+) (*Plan, error) {
+	var plan *Plan
+	err := retry.OnError(retry.DefaultRetry, IsConflict, func() error {
+		res, err := GetResources(ctx, k8sclient, pspName)
+		if err != nil {
+			return fmt.Errorf("Failed to get managed resources: %v", err)
+		}
+		plan, err = newPlan(res)
+		if err != nil {
+			return err
+		}
+
+		err = plan.Apply(ctx, k8sclient, dryRun)
+		if IsConflict(err) && onConflict != nil {
+			onConflict(err)
+		}
+		return err
+	})
+	return plan, err
+}
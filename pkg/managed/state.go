@@ -0,0 +1,136 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package managed
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	"github.com/jlandowner/psp-util/pkg/rbac"
+	"github.com/jlandowner/psp-util/pkg/utils"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Resources are the managed RBAC resources generated for a PSP
+type Resources struct {
+	ClusterRole        *rbacv1.ClusterRole
+	ClusterRoleBinding *rbacv1.ClusterRoleBinding
+	// RoleBindings is a map of namespace to the managed RoleBinding
+	RoleBindings map[string]*rbacv1.RoleBinding
+}
+
+// State is the managed RBAC resources in cluster keyed by PSP name
+type State map[string]*Resources
+
+func (s State) get(pspName string) *Resources {
+	r, ok := s[pspName]
+	if !ok {
+		r = &Resources{RoleBindings: make(map[string]*rbacv1.RoleBinding)}
+		s[pspName] = r
+	}
+	return r
+}
+
+// PSPNames returns the sorted names of PSPs having managed resources
+func (s State) PSPNames() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewState returns the state from the managed resources in the given lists.
+// If a PSP has multiple managed resources of a kind in a namespace, the one of the generated name is preferred,
+// and otherwise the first one in name order
+func NewState(crs []rbacv1.ClusterRole, crbs []rbacv1.ClusterRoleBinding, rbs []rbacv1.RoleBinding) State {
+	state := make(State)
+	for i, cr := range crs {
+		if psp, ok := rbac.ManagedPSPName(cr.ObjectMeta); ok {
+			r := state.get(psp)
+			if r.ClusterRole == nil || preferred(cr.ObjectMeta, r.ClusterRole.ObjectMeta, psp) {
+				r.ClusterRole = &crs[i]
+			}
+		}
+	}
+	for i, crb := range crbs {
+		if psp, ok := rbac.ManagedPSPName(crb.ObjectMeta); ok {
+			r := state.get(psp)
+			if r.ClusterRoleBinding == nil || preferred(crb.ObjectMeta, r.ClusterRoleBinding.ObjectMeta, psp) {
+				r.ClusterRoleBinding = &crbs[i]
+			}
+		}
+	}
+	for i, rb := range rbs {
+		if psp, ok := rbac.ManagedPSPName(rb.ObjectMeta); ok {
+			r := state.get(psp)
+			if cur, ok := r.RoleBindings[rb.Namespace]; !ok || preferred(rb.ObjectMeta, cur.ObjectMeta, psp) {
+				r.RoleBindings[rb.Namespace] = &rbs[i]
+			}
+		}
+	}
+	return state
+}
+
+// preferred returns true if the resource a is preferred to b as the managed resource of the PSP
+func preferred(a, b metav1.ObjectMeta, pspName string) bool {
+	name := utils.GenerateName(pspName)
+	if a.Name == name || b.Name == name {
+		return a.Name == name
+	}
+	return a.Name < b.Name
+}
+
+// GetState returns the managed RBAC resources in cluster listed by the managed labels
+func GetState(ctx context.Context, k8sclient kubernetes.Interface) (State, error) {
+	crs, err := rbac.ListManagedClusterRoles(ctx, k8sclient, "")
+	if err != nil {
+		return nil, err
+	}
+	crbs, err := rbac.ListManagedClusterRoleBindings(ctx, k8sclient, "")
+	if err != nil {
+		return nil, err
+	}
+	rbs, err := rbac.ListManagedRoleBindings(ctx, k8sclient, "")
+	if err != nil {
+		return nil, err
+	}
+	return NewState(crs, crbs, rbs), nil
+}
+
+func containsSubject(subjects []rbacv1.Subject, subject rbacv1.Subject) bool {
+	for _, s := range subjects {
+		if reflect.DeepEqual(s, subject) {
+			return true
+		}
+	}
+	return false
+}
+
+// subtractSubjects returns subjects in a but not in b
+func subtractSubjects(a, b []rbacv1.Subject) []rbacv1.Subject {
+	subs := make([]rbacv1.Subject, 0)
+	for _, s := range a {
+		if !containsSubject(b, s) {
+			subs = append(subs, s)
+		}
+	}
+	return subs
+}
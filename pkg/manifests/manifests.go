@@ -0,0 +1,277 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manifests
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	policyv1 "k8s.io/api/policy/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	rbacv1alpha1 "k8s.io/api/rbac/v1alpha1"
+	rbacv1beta1 "k8s.io/api/rbac/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// Objects is a set of Kubernetes objects loaded from manifest files
+type Objects struct {
+	PodSecurityPolicies policyv1.PodSecurityPolicyList
+	ClusterRoles        rbacv1.ClusterRoleList
+	ClusterRoleBindings rbacv1.ClusterRoleBindingList
+	Roles               rbacv1.RoleList
+	RoleBindings        rbacv1.RoleBindingList
+	Namespaces          corev1.NamespaceList
+	ServiceAccounts     corev1.ServiceAccountList
+
+	// Others are objects of the other kinds. e.g. Pods and Deployments
+	// Kinds not registered in client-go scheme are kept as *unstructured.Unstructured
+	Others []runtime.Object
+}
+
+// Add appends the given object to the list of its kind.
+// PodSecurityPolicies of extensions/v1beta1 and RBAC resources of rbac.authorization.k8s.io/v1beta1
+// are converted to policy/v1beta1 and rbac.authorization.k8s.io/v1
+func (o *Objects) Add(obj runtime.Object) error {
+	obj, err := convert(obj)
+	if err != nil {
+		return err
+	}
+
+	switch obj := obj.(type) {
+	case *policyv1.PodSecurityPolicy:
+		o.PodSecurityPolicies.Items = append(o.PodSecurityPolicies.Items, *obj)
+	case *rbacv1.ClusterRole:
+		o.ClusterRoles.Items = append(o.ClusterRoles.Items, *obj)
+	case *rbacv1.ClusterRoleBinding:
+		o.ClusterRoleBindings.Items = append(o.ClusterRoleBindings.Items, *obj)
+	case *rbacv1.Role:
+		o.Roles.Items = append(o.Roles.Items, *obj)
+	case *rbacv1.RoleBinding:
+		o.RoleBindings.Items = append(o.RoleBindings.Items, *obj)
+	case *corev1.Namespace:
+		o.Namespaces.Items = append(o.Namespaces.Items, *obj)
+	case *corev1.ServiceAccount:
+		o.ServiceAccounts.Items = append(o.ServiceAccounts.Items, *obj)
+	default:
+		o.Others = append(o.Others, obj)
+	}
+	return nil
+}
+
+// convert returns the object converted to the API version which psp-util reads if it is in the older one
+func convert(obj runtime.Object) (runtime.Object, error) {
+	var out runtime.Object
+	switch obj.(type) {
+	case *extv1beta1.PodSecurityPolicy:
+		out = &policyv1.PodSecurityPolicy{}
+	case *rbacv1beta1.ClusterRole:
+		out = &rbacv1.ClusterRole{}
+	case *rbacv1beta1.ClusterRoleBinding:
+		out = &rbacv1.ClusterRoleBinding{}
+	case *rbacv1beta1.Role:
+		out = &rbacv1.Role{}
+	case *rbacv1beta1.RoleBinding:
+		out = &rbacv1.RoleBinding{}
+	case *rbacv1alpha1.ClusterRole, *rbacv1alpha1.ClusterRoleBinding, *rbacv1alpha1.Role, *rbacv1alpha1.RoleBinding:
+		gvk := obj.GetObjectKind().GroupVersionKind()
+		return nil, fmt.Errorf("%s of %s is not supported. Use %s", gvk.Kind, gvk.GroupVersion(), rbacv1.SchemeGroupVersion)
+	default:
+		return obj, nil
+	}
+
+	if err := scheme.Scheme.Convert(obj, out, nil); err != nil {
+		return nil, err
+	}
+	gvks, _, err := scheme.Scheme.ObjectKinds(out)
+	if err != nil {
+		return nil, err
+	}
+	out.GetObjectKind().SetGroupVersionKind(gvks[0])
+	return out, nil
+}
+
+// NamespaceNames returns the sorted names of the Namespaces and the namespaces of Roles and RoleBindings
+func (o *Objects) NamespaceNames() []string {
+	set := make(map[string]bool)
+	for _, ns := range o.Namespaces.Items {
+		set[ns.Name] = true
+	}
+	for _, r := range o.Roles.Items {
+		set[r.Namespace] = true
+	}
+	for _, rb := range o.RoleBindings.Items {
+		set[rb.Namespace] = true
+	}
+	delete(set, "")
+
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Load reads all the manifests in the given file or directory. "-" reads from stdin
+func Load(path string) (*Objects, error) {
+	objs := &Objects{}
+
+	if path == "-" {
+		if err := objs.Decode(os.Stdin); err != nil {
+			return nil, fmt.Errorf("Failed to decode stdin: %v", err)
+		}
+		return objs, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return objs, loadFile(objs, path)
+	}
+
+	err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !isManifestFile(p) {
+			return nil
+		}
+		return loadFile(objs, p)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objs, nil
+}
+
+// Decode reads multi-document YAML or JSON manifests and adds the objects
+func (o *Objects) Decode(r io.Reader) error {
+	reader := yaml.NewYAMLReader(bufio.NewReader(r))
+	decoder := scheme.Codecs.UniversalDeserializer()
+
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if isEmptyDocument(doc) {
+			continue
+		}
+
+		obj, err := decode(decoder, doc)
+		if err != nil {
+			return err
+		}
+		if err := o.addAll(decoder, obj); err != nil {
+			return err
+		}
+	}
+}
+
+// addAll adds the object or the items if it is a list. e.g. `kubectl get -o yaml` output
+func (o *Objects) addAll(decoder runtime.Decoder, obj runtime.Object) error {
+	if !meta.IsListType(obj) {
+		return o.Add(obj)
+	}
+
+	items, err := meta.ExtractList(obj)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		// items of v1 List are kept as raw data
+		if u, ok := item.(*runtime.Unknown); ok {
+			item, err = decode(decoder, u.Raw)
+			if err != nil {
+				return err
+			}
+		}
+		if err := o.addAll(decoder, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decode(decoder runtime.Decoder, doc []byte) (runtime.Object, error) {
+	obj, _, err := decoder.Decode(doc, nil, nil)
+	if runtime.IsNotRegisteredError(err) {
+		// keep custom resources and the other unknown kinds as unstructured
+		obj, err = decodeUnstructured(doc)
+	}
+	return obj, err
+}
+
+func decodeUnstructured(doc []byte) (runtime.Object, error) {
+	data, err := yaml.ToJSON(doc)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{}
+	if err := u.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func loadFile(objs *Objects, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := objs.Decode(f); err != nil {
+		return fmt.Errorf("Failed to decode %s: %v", path, err)
+	}
+	return nil
+}
+
+func isManifestFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// isEmptyDocument returns true if the document has only comments or blank lines
+func isEmptyDocument(doc []byte) bool {
+	for _, line := range bytes.Split(doc, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) != 0 && !bytes.HasPrefix(line, []byte("#")) && !bytes.Equal(line, []byte("---")) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,248 @@
+package manifests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	policyv1 "k8s.io/api/policy/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	rbacv1beta1 "k8s.io/api/rbac/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		title                     string
+		data                      string
+		expectPSPs                int
+		expectClusterRoles        int
+		expectClusterRoleBindings int
+		expectRoles               int
+		expectRoleBindings        int
+		expectOthers              int
+		expectNamespaces          []string
+		expectErr                 bool
+	}{
+		{
+			title: "helm rendered multi-document",
+			data: `
+---
+# Source: chart/templates/psp.yaml
+apiVersion: policy/v1beta1
+kind: PodSecurityPolicy
+metadata:
+  name: restricted
+---
+# Source: chart/templates/empty.yaml
+---
+# Source: chart/templates/rolebinding.yaml
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: use-restricted
+  namespace: team-a
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: use-restricted
+`,
+			expectPSPs:         1,
+			expectRoleBindings: 1,
+			expectNamespaces:   []string{"team-a"},
+		},
+		{
+			title: "kubectl get -o yaml dump",
+			data: `
+apiVersion: v1
+kind: List
+items:
+- apiVersion: rbac.authorization.k8s.io/v1
+  kind: ClusterRole
+  metadata:
+    name: use-restricted
+- apiVersion: v1
+  kind: Namespace
+  metadata:
+    name: team-b
+- apiVersion: example.com/v1
+  kind: Foo
+  metadata:
+    name: foo
+`,
+			expectClusterRoles: 1,
+			expectOthers:       1,
+			expectNamespaces:   []string{"team-b"},
+		},
+		{
+			title: "typed list in json",
+			data: `{
+  "apiVersion": "rbac.authorization.k8s.io/v1",
+  "kind": "ClusterRoleBindingList",
+  "items": [
+    {"metadata": {"name": "a"}, "roleRef": {"apiGroup": "rbac.authorization.k8s.io", "kind": "ClusterRole", "name": "x"}},
+    {"metadata": {"name": "b"}, "roleRef": {"apiGroup": "rbac.authorization.k8s.io", "kind": "ClusterRole", "name": "x"}}
+  ]
+}`,
+			expectClusterRoleBindings: 2,
+			expectNamespaces:          []string{},
+		},
+		{
+			title: "PodSecurityPolicy of extensions/v1beta1",
+			data: `
+apiVersion: extensions/v1beta1
+kind: PodSecurityPolicy
+metadata:
+  name: privileged
+spec:
+  privileged: true
+`,
+			expectPSPs:       1,
+			expectNamespaces: []string{},
+		},
+		{
+			title: "RBAC of rbac.authorization.k8s.io/v1beta1",
+			data: `
+apiVersion: rbac.authorization.k8s.io/v1beta1
+kind: ClusterRole
+metadata:
+  name: use-privileged
+rules:
+- apiGroups: ["policy"]
+  resources: ["podsecuritypolicies"]
+  resourceNames: ["privileged"]
+  verbs: ["use"]
+---
+apiVersion: rbac.authorization.k8s.io/v1beta1
+kind: ClusterRoleBinding
+metadata:
+  name: use-privileged
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: use-privileged
+---
+apiVersion: rbac.authorization.k8s.io/v1beta1
+kind: Role
+metadata:
+  name: use-privileged
+  namespace: team-a
+---
+apiVersion: rbac.authorization.k8s.io/v1beta1
+kind: RoleBinding
+metadata:
+  name: use-privileged
+  namespace: team-b
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: use-privileged
+`,
+			expectClusterRoles:        1,
+			expectClusterRoleBindings: 1,
+			expectRoles:               1,
+			expectRoleBindings:        1,
+			expectNamespaces:          []string{"team-a", "team-b"},
+		},
+		{
+			title: "RBAC of rbac.authorization.k8s.io/v1alpha1",
+			data: `
+apiVersion: rbac.authorization.k8s.io/v1alpha1
+kind: ClusterRole
+metadata:
+  name: use-privileged
+`,
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		objs := &Objects{}
+		err := objs.Decode(strings.NewReader(test.data))
+		if test.expectErr {
+			assert.Error(t, err)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Len(t, objs.PodSecurityPolicies.Items, test.expectPSPs)
+		assert.Len(t, objs.ClusterRoles.Items, test.expectClusterRoles)
+		assert.Len(t, objs.ClusterRoleBindings.Items, test.expectClusterRoleBindings)
+		assert.Len(t, objs.Roles.Items, test.expectRoles)
+		assert.Len(t, objs.RoleBindings.Items, test.expectRoleBindings)
+		assert.Len(t, objs.Others, test.expectOthers)
+		assert.Equal(t, test.expectNamespaces, objs.NamespaceNames())
+	}
+}
+
+func TestAddOlderVersions(t *testing.T) {
+	tests := []struct {
+		title  string
+		obj    runtime.Object
+		expect func(t *testing.T, objs *Objects)
+	}{
+		{
+			title: "PodSecurityPolicy of extensions/v1beta1",
+			obj: &extv1beta1.PodSecurityPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "privileged"},
+				Spec: extv1beta1.PodSecurityPolicySpec{
+					Privileged: true,
+					Volumes:    []extv1beta1.FSType{extv1beta1.All},
+					RunAsUser:  extv1beta1.RunAsUserStrategyOptions{Rule: extv1beta1.RunAsUserStrategyRunAsAny},
+				},
+			},
+			expect: func(t *testing.T, objs *Objects) {
+				if assert.Len(t, objs.PodSecurityPolicies.Items, 1) {
+					psp := objs.PodSecurityPolicies.Items[0]
+					assert.Equal(t, "policy/v1beta1", psp.APIVersion)
+					assert.Equal(t, "privileged", psp.Name)
+					assert.True(t, psp.Spec.Privileged)
+					assert.Equal(t, []policyv1.FSType{policyv1.All}, psp.Spec.Volumes)
+					assert.Equal(t, policyv1.RunAsUserStrategyRunAsAny, psp.Spec.RunAsUser.Rule)
+				}
+			},
+		},
+		{
+			title: "RoleBinding of rbac.authorization.k8s.io/v1beta1",
+			obj: &rbacv1beta1.RoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "use-privileged", Namespace: "team-a"},
+				Subjects:   []rbacv1beta1.Subject{{Kind: "ServiceAccount", Name: "default", Namespace: "team-a"}},
+				RoleRef:    rbacv1beta1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: "use-privileged"},
+			},
+			expect: func(t *testing.T, objs *Objects) {
+				if assert.Len(t, objs.RoleBindings.Items, 1) {
+					rb := objs.RoleBindings.Items[0]
+					assert.Equal(t, "rbac.authorization.k8s.io/v1", rb.APIVersion)
+					assert.Equal(t, []rbacv1.Subject{{Kind: "ServiceAccount", Name: "default", Namespace: "team-a"}}, rb.Subjects)
+					assert.Equal(t, rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: "use-privileged"}, rb.RoleRef)
+				}
+			},
+		},
+		{
+			title: "ClusterRole of rbac.authorization.k8s.io/v1beta1",
+			obj: &rbacv1beta1.ClusterRole{
+				ObjectMeta: metav1.ObjectMeta{Name: "use-privileged"},
+				Rules: []rbacv1beta1.PolicyRule{
+					{APIGroups: []string{"policy"}, Resources: []string{"podsecuritypolicies"}, ResourceNames: []string{"privileged"}, Verbs: []string{"use"}},
+				},
+			},
+			expect: func(t *testing.T, objs *Objects) {
+				if assert.Len(t, objs.ClusterRoles.Items, 1) {
+					assert.Equal(t, []rbacv1.PolicyRule{
+						{APIGroups: []string{"policy"}, Resources: []string{"podsecuritypolicies"}, ResourceNames: []string{"privileged"}, Verbs: []string{"use"}},
+					}, objs.ClusterRoles.Items[0].Rules)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		objs := &Objects{}
+		assert.NoError(t, objs.Add(test.obj))
+		assert.Empty(t, objs.Others)
+		test.expect(t, objs)
+	}
+}
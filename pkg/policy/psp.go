@@ -23,10 +23,10 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
-func ListPSP(ctx context.Context, k8sclient *kubernetes.Clientset) (*policyv1.PodSecurityPolicyList, error) {
+func ListPSP(ctx context.Context, k8sclient kubernetes.Interface) (*policyv1.PodSecurityPolicyList, error) {
 	return k8sclient.PolicyV1beta1().PodSecurityPolicies().List(ctx, metav1.ListOptions{})
 }
 
-func GetPSP(ctx context.Context, k8sclient *kubernetes.Clientset, name string) (*policyv1.PodSecurityPolicy, error) {
+func GetPSP(ctx context.Context, k8sclient kubernetes.Interface, name string) (*policyv1.PodSecurityPolicy, error) {
 	return k8sclient.PolicyV1beta1().PodSecurityPolicies().Get(ctx, name, metav1.GetOptions{})
 }
@@ -0,0 +1,184 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1beta1"
+)
+
+// Annotation keys of PSP and Pod for seccomp and AppArmor
+const (
+	SeccompDefaultProfileAnnotationKey  = "seccomp.security.alpha.kubernetes.io/defaultProfileName"
+	SeccompAllowedProfilesAnnotationKey = "seccomp.security.alpha.kubernetes.io/allowedProfileNames"
+	SeccompPodAnnotationKey             = "seccomp.security.alpha.kubernetes.io/pod"
+	SeccompContainerAnnotationKeyPrefix = "container.seccomp.security.alpha.kubernetes.io/"
+
+	AppArmorDefaultProfileAnnotationKey  = "apparmor.security.beta.kubernetes.io/defaultProfileName"
+	AppArmorAllowedProfilesAnnotationKey = "apparmor.security.beta.kubernetes.io/allowedProfileNames"
+	AppArmorContainerAnnotationKeyPrefix = "container.apparmor.security.beta.kubernetes.io/"
+
+	AllowAllCapabilities = "*"
+)
+
+// SafeSysctls are the sysctls allowed without allowedUnsafeSysctls
+var SafeSysctls = []string{
+	"kernel.shm_rmid_forced",
+	"net.ipv4.ip_local_port_range",
+	"net.ipv4.ip_unprivileged_port_start",
+	"net.ipv4.tcp_syncookies",
+	"net.ipv4.ping_group_range",
+}
+
+// AllowPrivilegeEscalation returns the value of allowPrivilegeEscalation which is true when not set
+func AllowPrivilegeEscalation(spec policyv1.PodSecurityPolicySpec) bool {
+	return spec.AllowPrivilegeEscalation == nil || *spec.AllowPrivilegeEscalation
+}
+
+// AllowsAllVolumes returns true if the PSP allows all volume types
+func AllowsAllVolumes(spec policyv1.PodSecurityPolicySpec) bool {
+	return AllowsVolume(spec, policyv1.All)
+}
+
+// AllowsVolume returns true if the PSP allows the volume type
+func AllowsVolume(spec policyv1.PodSecurityPolicySpec, fsType policyv1.FSType) bool {
+	for _, v := range spec.Volumes {
+		if v == fsType || v == policyv1.All {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsHostPath returns whether the host path is allowed and whether it must be mounted read-only
+func AllowsHostPath(spec policyv1.PodSecurityPolicySpec, hostPath string) (allowed, mustBeReadOnly bool) {
+	if len(spec.AllowedHostPaths) == 0 {
+		return true, false
+	}
+	for _, p := range spec.AllowedHostPaths {
+		if HasPathPrefix(hostPath, p.PathPrefix) {
+			if !p.ReadOnly {
+				return true, false
+			}
+			allowed = true
+			mustBeReadOnly = true
+		}
+	}
+	return allowed, mustBeReadOnly
+}
+
+// HasPathPrefix returns true if the path is the prefix itself or under the prefix at a path segment boundary.
+// e.g. "/foo" is a prefix of "/foo/bar" but not of "/foobar"
+func HasPathPrefix(s, prefix string) bool {
+	s = path.Clean(s)
+	prefix = path.Clean(prefix)
+	if s == prefix {
+		return true
+	}
+	if !strings.HasPrefix(s, prefix) {
+		return false
+	}
+	// cleaned paths have a trailing slash only if they are exactly "/"
+	if strings.HasSuffix(prefix, "/") {
+		return true
+	}
+	return s[len(prefix)] == '/'
+}
+
+// MatchSysctl returns true if the sysctl name matches the pattern. A pattern ending with "*" matches by prefix
+func MatchSysctl(pattern, name string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(name, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == name
+}
+
+// IsSafeSysctl returns true if the sysctl is in SafeSysctls
+func IsSafeSysctl(name string) bool {
+	for _, s := range SafeSysctls {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// GetVolumeFSType returns the PSP volume type of the volume
+func GetVolumeFSType(v corev1.Volume) (policyv1.FSType, error) {
+	switch {
+	case v.HostPath != nil:
+		return policyv1.HostPath, nil
+	case v.EmptyDir != nil:
+		return policyv1.EmptyDir, nil
+	case v.GCEPersistentDisk != nil:
+		return policyv1.GCEPersistentDisk, nil
+	case v.AWSElasticBlockStore != nil:
+		return policyv1.AWSElasticBlockStore, nil
+	case v.GitRepo != nil:
+		return policyv1.GitRepo, nil
+	case v.Secret != nil:
+		return policyv1.Secret, nil
+	case v.NFS != nil:
+		return policyv1.NFS, nil
+	case v.ISCSI != nil:
+		return policyv1.ISCSI, nil
+	case v.Glusterfs != nil:
+		return policyv1.Glusterfs, nil
+	case v.PersistentVolumeClaim != nil:
+		return policyv1.PersistentVolumeClaim, nil
+	case v.RBD != nil:
+		return policyv1.RBD, nil
+	case v.FlexVolume != nil:
+		return policyv1.FlexVolume, nil
+	case v.Cinder != nil:
+		return policyv1.Cinder, nil
+	case v.CephFS != nil:
+		return policyv1.CephFS, nil
+	case v.Flocker != nil:
+		return policyv1.Flocker, nil
+	case v.DownwardAPI != nil:
+		return policyv1.DownwardAPI, nil
+	case v.FC != nil:
+		return policyv1.FC, nil
+	case v.AzureFile != nil:
+		return policyv1.AzureFile, nil
+	case v.ConfigMap != nil:
+		return policyv1.ConfigMap, nil
+	case v.VsphereVolume != nil:
+		return policyv1.VsphereVolume, nil
+	case v.Quobyte != nil:
+		return policyv1.Quobyte, nil
+	case v.AzureDisk != nil:
+		return policyv1.AzureDisk, nil
+	case v.PhotonPersistentDisk != nil:
+		return policyv1.PhotonPersistentDisk, nil
+	case v.StorageOS != nil:
+		return policyv1.StorageOS, nil
+	case v.Projected != nil:
+		return policyv1.Projected, nil
+	case v.PortworxVolume != nil:
+		return policyv1.PortworxVolume, nil
+	case v.ScaleIO != nil:
+		return policyv1.ScaleIO, nil
+	case v.CSI != nil:
+		return policyv1.CSI, nil
+	}
+	return "", fmt.Errorf("unknown volume type for volume: %s", v.Name)
+}
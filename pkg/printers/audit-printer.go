@@ -0,0 +1,153 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package printers
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/jlandowner/psp-util/pkg/audit"
+)
+
+var (
+	AuditResultHeader  = []string{"PSP", "Score", "Exposure", "Findings"}
+	AuditFindingHeader = []string{"Severity", "Rule", "PSP", "Exposure", "Status", "Message"}
+)
+
+// AuditReport is the structured output schema of audit results
+type AuditReport struct {
+	APIVersion string         `json:"apiVersion"`
+	Kind       string         `json:"kind"`
+	Results    []audit.Result `json:"results"`
+	Warnings   []string       `json:"warnings,omitempty"`
+}
+
+// NewAuditReport converts the audit report into the structured output schema
+func NewAuditReport(r *audit.Report) *AuditReport {
+	return &AuditReport{
+		APIVersion: SchemaAPIVersion,
+		Kind:       AuditReportKind,
+		Results:    r.Results,
+		Warnings:   r.Warnings,
+	}
+}
+
+// ClusterAuditReport is the audit report of a cluster
+type ClusterAuditReport struct {
+	Cluster string
+	Report  *audit.Report
+}
+
+// PrintAuditReport prints the PSP scores and the findings ordered from the most severe.
+// The waived findings are printed with the justifications rather than hidden
+func PrintAuditReport(out io.Writer, r *audit.Report, noHeader bool) {
+	printAuditReports(out, []ClusterAuditReport{{Report: r}}, noHeader, false)
+}
+
+// PrintClusterAuditReports prints the audit reports of every cluster with the cluster column
+func PrintClusterAuditReports(out io.Writer, reports []ClusterAuditReport, noHeader bool) {
+	printAuditReports(out, reports, noHeader, true)
+}
+
+func printAuditReports(out io.Writer, reports []ClusterAuditReport, noHeader, withCluster bool) {
+	// line prepends the cluster column if printed
+	line := func(cluster string, columns ...string) []string {
+		if withCluster {
+			return append([]string{cluster}, columns...)
+		}
+		return columns
+	}
+	// prefix prepends the cluster to the messages if printed
+	prefix := func(cluster string) string {
+		if withCluster {
+			return cluster + ": "
+		}
+		return ""
+	}
+
+	fmt.Fprintln(out, "PodSecurityPolicies:")
+	w := GetNewTabWriter(out)
+	if !noHeader {
+		PrintLine(w, line("Cluster", AuditResultHeader...))
+	}
+	for _, c := range reports {
+		for _, res := range c.Report.Results {
+			PrintLine(w, line(c.Cluster, res.PSP, fmt.Sprint(res.Score), string(res.Exposure), formatFindingCount(res.Findings)))
+		}
+	}
+	w.Flush()
+
+	hasFindings := false
+	for _, c := range reports {
+		if len(c.Report.Findings()) > 0 {
+			hasFindings = true
+		}
+	}
+	if hasFindings {
+		fmt.Fprintln(out, "\nFindings:")
+		w = GetNewTabWriter(out)
+		if !noHeader {
+			PrintLine(w, line("Cluster", AuditFindingHeader...))
+		}
+		for _, c := range reports {
+			for _, f := range c.Report.Findings() {
+				PrintLine(w, line(c.Cluster, string(f.Severity), f.RuleID, f.PSP, string(f.Exposure), formatFindingStatus(f), f.Message))
+			}
+		}
+		w.Flush()
+	}
+
+	waivers := make([]string, 0)
+	for _, c := range reports {
+		for _, f := range c.Report.Findings() {
+			if f.IsWaived() {
+				waivers = append(waivers, fmt.Sprintf("%s%s %s: %s (expires %s)", prefix(c.Cluster), f.RuleID, f.PSP, f.Waiver.Justification, f.Waiver.Expires))
+			}
+		}
+	}
+	if len(waivers) > 0 {
+		fmt.Fprintln(out, "\nWaivers:")
+		for _, waiver := range waivers {
+			fmt.Fprintf(out, "  %s\n", waiver)
+		}
+	}
+
+	for _, c := range reports {
+		for _, warning := range c.Report.Warnings {
+			fmt.Fprintf(out, "WARNING: %s%s\n", prefix(c.Cluster), warning)
+		}
+	}
+}
+
+func formatFindingCount(findings []audit.Finding) string {
+	waived := 0
+	for _, f := range findings {
+		if f.IsWaived() {
+			waived++
+		}
+	}
+	if waived > 0 {
+		return fmt.Sprintf("%d (%d waived)", len(findings), waived)
+	}
+	return fmt.Sprint(len(findings))
+}
+
+func formatFindingStatus(f audit.Finding) string {
+	if f.IsWaived() {
+		return "waived"
+	}
+	return "open"
+}
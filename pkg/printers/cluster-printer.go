@@ -0,0 +1,60 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package printers
+
+import (
+	"fmt"
+	"io"
+)
+
+// ClusterResultList is the structured output schema of a command run against multiple clusters
+type ClusterResultList struct {
+	APIVersion string          `json:"apiVersion"`
+	Kind       string          `json:"kind"`
+	Items      []ClusterResult `json:"items"`
+}
+
+// ClusterResult is the output of a command in a cluster, or the error failed in it
+type ClusterResult struct {
+	Cluster string      `json:"cluster"`
+	Result  interface{} `json:"result,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+func NewClusterResultList(items []ClusterResult) *ClusterResultList {
+	return &ClusterResultList{
+		APIVersion: SchemaAPIVersion,
+		Kind:       ClusterResultListKind,
+		Items:      items,
+	}
+}
+
+// NewClusterError returns the result of the cluster failed
+func NewClusterError(cluster string, err error) ClusterResult {
+	return ClusterResult{Cluster: cluster, Error: err.Error()}
+}
+
+// PrintClusterErrors prints the errors of the clusters failed and returns the number of them
+func PrintClusterErrors(out io.Writer, results []ClusterResult) int {
+	failed := 0
+	for _, res := range results {
+		if res.Error != "" {
+			fmt.Fprintf(out, "ERROR: %s: %s\n", res.Cluster, res.Error)
+			failed++
+		}
+	}
+	return failed
+}
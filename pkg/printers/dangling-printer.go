@@ -0,0 +1,66 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package printers
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jlandowner/psp-util/pkg/relations"
+)
+
+var DanglingHeader = []string{"Kind", "NS/Name", "Missing"}
+
+// DanglingLines returns the dangling references as table lines of DanglingHeader
+func DanglingLines(d *relations.DanglingReferences) [][]string {
+	lines := make([][]string, 0, d.Count())
+	for _, r := range d.Roles {
+		missing := make([]string, len(r.PSPs))
+		for i, psp := range r.PSPs {
+			missing[i] = "PodSecurityPolicy/" + psp
+		}
+		lines = append(lines, []string{r.Kind, namespacedName(r.Namespace, r.Name), strings.Join(missing, ",")})
+	}
+	for _, b := range d.Bindings {
+		ns := ""
+		if b.RoleRef.Kind == "Role" {
+			ns = b.Namespace
+		}
+		lines = append(lines, []string{b.Kind, namespacedName(b.Namespace, b.Name), b.RoleRef.Kind + "/" + namespacedName(ns, b.RoleRef.Name)})
+	}
+	return lines
+}
+
+// PrintDanglingReferences prints the dangling references in table format
+func PrintDanglingReferences(out io.Writer, d *relations.DanglingReferences, noHeader bool) {
+	w := GetNewTabWriter(out)
+	defer w.Flush()
+
+	if !noHeader {
+		PrintLine(w, DanglingHeader)
+	}
+	for _, l := range DanglingLines(d) {
+		PrintLine(w, l)
+	}
+}
+
+func namespacedName(namespace, name string) string {
+	if namespace != "" {
+		return fmt.Sprintf("%s/%s", namespace, name)
+	}
+	return name
+}
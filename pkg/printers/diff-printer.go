@@ -0,0 +1,200 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package printers
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/jlandowner/psp-util/pkg/diff"
+)
+
+// PSPComparison is the structured output schema of the comparison of two PSPs
+type PSPComparison struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	diff.Comparison
+}
+
+// NewPSPComparison converts the comparison into the structured output schema
+func NewPSPComparison(c *diff.Comparison) *PSPComparison {
+	return &PSPComparison{
+		APIVersion: SchemaAPIVersion,
+		Kind:       PSPComparisonKind,
+		Comparison: *c,
+	}
+}
+
+// PrintComparison prints the differing fields with the PSP more permissive in each,
+// which PSP is more permissive in total and the subjects granted each PSP
+func PrintComparison(out io.Writer, c *diff.Comparison, noHeader bool) {
+	if len(c.Fields) > 0 {
+		w := GetNewTabWriter(out)
+		if !noHeader {
+			PrintLine(w, []string{"Field", c.A, c.B, "More permissive"})
+		}
+		for _, f := range c.Fields {
+			PrintLine(w, []string{f.Field, f.A, f.B, formatMorePermissive(c.A, c.B, f.Relation)})
+		}
+		w.Flush()
+		fmt.Fprintln(out)
+	}
+	fmt.Fprintln(out, FormatComparisonResult(c))
+
+	if len(c.Subjects) > 0 {
+		fmt.Fprintln(out, "\nSubjects:")
+		w := GetNewTabWriter(out)
+		if !noHeader {
+			PrintLine(w, []string{"Subject", c.A, c.B})
+		}
+		for _, s := range c.Subjects {
+			PrintLine(w, []string{FormatSubject(s.Subject), formatYesNo(s.A), formatYesNo(s.B)})
+		}
+		w.Flush()
+	}
+}
+
+// FormatComparisonResult returns a sentence telling which PSP is strictly more permissive
+func FormatComparisonResult(c *diff.Comparison) string {
+	switch c.Relation {
+	case diff.RelationMorePermissive:
+		return fmt.Sprintf("%s is strictly more permissive than %s", c.A, c.B)
+	case diff.RelationLessPermissive:
+		return fmt.Sprintf("%s is strictly more permissive than %s", c.B, c.A)
+	case diff.RelationIncomparable:
+		return fmt.Sprintf("Neither is strictly more permissive: %s and %s each admit Pods the other rejects", c.A, c.B)
+	}
+	if len(c.Fields) > 0 {
+		return fmt.Sprintf("%s and %s admit the same Pods with different defaults", c.A, c.B)
+	}
+	return fmt.Sprintf("%s and %s admit the same Pods", c.A, c.B)
+}
+
+// formatMorePermissive returns the name of a or b more permissive in the relation
+func formatMorePermissive(a, b string, r diff.Relation) string {
+	switch r {
+	case diff.RelationMorePermissive:
+		return a
+	case diff.RelationLessPermissive:
+		return b
+	case diff.RelationIncomparable:
+		return "neither"
+	case diff.RelationDefaulting:
+		return "(default only)"
+	}
+	return "-"
+}
+
+func formatYesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// ClusterComparison is the structured output schema of the comparison of PSPs between two clusters
+type ClusterComparison struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	diff.ClusterComparison
+}
+
+// NewClusterComparison converts the comparison into the structured output schema
+func NewClusterComparison(c *diff.ClusterComparison) *ClusterComparison {
+	return &ClusterComparison{
+		APIVersion:        SchemaAPIVersion,
+		Kind:              ClusterComparisonKind,
+		ClusterComparison: *c,
+	}
+}
+
+// PrintClusterComparison prints the PSPs existing in only one cluster, the spec differences
+// and the subjects granted the PSPs in only one cluster
+func PrintClusterComparison(out io.Writer, c *diff.ClusterComparison, noHeader bool) {
+	sections := make([]func(), 0)
+
+	if len(c.Missing) > 0 {
+		sections = append(sections, func() {
+			fmt.Fprintln(out, "PSPs in only one cluster:")
+			w := GetNewTabWriter(out)
+			if !noHeader {
+				PrintLine(w, []string{"PSP", c.A, c.B})
+			}
+			for _, m := range c.Missing {
+				PrintLine(w, []string{m.PSP, formatYesNo(m.A), formatYesNo(m.B)})
+			}
+			w.Flush()
+		})
+	}
+
+	specs := make([]diff.PSPDifference, 0)
+	subjects := make([]diff.PSPDifference, 0)
+	for _, d := range c.Differences {
+		if len(d.Fields) > 0 {
+			specs = append(specs, d)
+		}
+		if len(d.Subjects) > 0 {
+			subjects = append(subjects, d)
+		}
+	}
+
+	if len(specs) > 0 {
+		sections = append(sections, func() {
+			fmt.Fprintln(out, "Spec differences:")
+			w := GetNewTabWriter(out)
+			if !noHeader {
+				PrintLine(w, []string{"PSP", "Field", c.A, c.B, "More permissive"})
+			}
+			for _, d := range specs {
+				for _, f := range d.Fields {
+					PrintLine(w, []string{d.PSP, f.Field, f.A, f.B, formatMorePermissive(c.A, c.B, f.Relation)})
+				}
+			}
+			w.Flush()
+			for _, d := range specs {
+				fmt.Fprintln(out, FormatComparisonResult(&diff.Comparison{
+					A:        fmt.Sprintf("%s in %s", d.PSP, c.A),
+					B:        fmt.Sprintf("%s in %s", d.PSP, c.B),
+					Relation: d.Relation,
+					Fields:   d.Fields,
+				}))
+			}
+		})
+	}
+
+	if len(subjects) > 0 {
+		sections = append(sections, func() {
+			fmt.Fprintln(out, "Subjects granted in only one cluster:")
+			w := GetNewTabWriter(out)
+			if !noHeader {
+				PrintLine(w, []string{"PSP", "Subject", c.A, c.B})
+			}
+			for _, d := range subjects {
+				for _, s := range d.Subjects {
+					PrintLine(w, []string{d.PSP, FormatSubject(s.Subject), formatYesNo(s.A), formatYesNo(s.B)})
+				}
+			}
+			w.Flush()
+		})
+	}
+
+	for i, section := range sections {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		section()
+	}
+}
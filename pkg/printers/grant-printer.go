@@ -0,0 +1,153 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package printers
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jlandowner/psp-util/pkg/relations"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+var (
+	GrantHeader    = []string{"Subject", "Scope", "Binding", "Role"}
+	PSPGrantHeader = []string{"PSP", "Via", "Scope", "Binding", "Role"}
+)
+
+// ClusterSubjectGrants is the subjects granted a PSP in a cluster
+type ClusterSubjectGrants struct {
+	Cluster string
+	Grants  []relations.SubjectGrant
+}
+
+// PrintSubjectGrants prints a line per path through which each subject is granted.
+// The ServiceAccounts expanded from the groups follow the group, and the warnings are printed at the end
+func PrintSubjectGrants(output io.Writer, grants []relations.SubjectGrant, noHeader bool) error {
+	return printSubjectGrants(output, []ClusterSubjectGrants{{Grants: grants}}, noHeader, false)
+}
+
+// PrintClusterSubjectGrants prints the subject grants of every cluster with the cluster column
+func PrintClusterSubjectGrants(output io.Writer, clusters []ClusterSubjectGrants, noHeader bool) error {
+	return printSubjectGrants(output, clusters, noHeader, true)
+}
+
+func printSubjectGrants(output io.Writer, clusters []ClusterSubjectGrants, noHeader, withCluster bool) error {
+	w := GetNewTabWriter(output)
+
+	// line prepends the cluster column if printed
+	line := func(cluster string, columns ...string) []string {
+		if withCluster {
+			return append([]string{cluster}, columns...)
+		}
+		return columns
+	}
+
+	if !noHeader {
+		PrintLine(w, line("Cluster", GrantHeader...))
+	}
+	warnings := make([]string, 0)
+	for _, c := range clusters {
+		for _, g := range c.Grants {
+			for _, p := range g.Paths {
+				PrintLine(w, line(c.Cluster, FormatSubject(g.Subject), FormatGrantScope(p), FormatGrantBinding(p), FormatGrantRole(p)))
+			}
+			for _, sa := range g.Expanded {
+				for _, p := range g.Paths {
+					PrintLine(w, line(c.Cluster, FormatExpandedSubject(sa, g.Subject), FormatGrantScope(p), FormatGrantBinding(p), FormatGrantRole(p)))
+				}
+			}
+			if g.Warning != "" {
+				warning := FormatSubject(g.Subject) + " is " + g.Warning
+				if withCluster {
+					warning = c.Cluster + ": " + warning
+				}
+				warnings = append(warnings, warning)
+			}
+		}
+	}
+	w.Flush()
+
+	for _, warning := range warnings {
+		fmt.Fprintf(output, "WARNING: %s\n", warning)
+	}
+	return nil
+}
+
+// PrintPSPGrants prints a line per path through which each PSP is granted
+func PrintPSPGrants(output io.Writer, pspGrants []relations.PSPGrant, noHeader bool) error {
+	w := GetNewTabWriter(output)
+	defer w.Flush()
+
+	if !noHeader {
+		PrintLine(w, PSPGrantHeader)
+	}
+	for _, pg := range pspGrants {
+		for _, g := range pg.Grants {
+			for _, p := range g.Paths {
+				PrintLine(w, []string{pg.PSP, FormatSubject(g.Subject), FormatGrantScope(p), FormatGrantBinding(p), FormatGrantRole(p)})
+			}
+		}
+	}
+	return nil
+}
+
+// FormatExpandedSubject returns the ServiceAccount with the group it is expanded from
+func FormatExpandedSubject(sa, group rbacv1.Subject) string {
+	return fmt.Sprintf("%s (via %s)", FormatSubject(sa), FormatSubject(group))
+}
+
+// FormatGrantScope returns Cluster or Namespace/NAMESPACE
+func FormatGrantScope(p relations.GrantPath) string {
+	if p.Scope == relations.GrantScopeNamespace {
+		return fmt.Sprintf("%s/%s", p.Scope, p.Namespace)
+	}
+	return string(p.Scope)
+}
+
+// FormatGrantBinding returns the binding as Kind/Name or Kind/Namespace/Name
+func FormatGrantBinding(p relations.GrantPath) string {
+	if p.Namespace != "" {
+		return fmt.Sprintf("%s/%s/%s", p.BindingKind, p.Namespace, p.BindingName)
+	}
+	return fmt.Sprintf("%s/%s", p.BindingKind, p.BindingName)
+}
+
+// FormatGrantRole returns the role as Kind/Name or Kind/Namespace/Name, marked if it is a wildcard grant
+func FormatGrantRole(p relations.GrantPath) string {
+	role := fmt.Sprintf("%s/%s", p.RoleKind, p.RoleName)
+	if p.RoleKind == "Role" {
+		role = fmt.Sprintf("%s/%s/%s", p.RoleKind, p.Namespace, p.RoleName)
+	}
+	return role + WildcardMark(p.Wildcard)
+}
+
+// WildcardMark returns a marker for roles granting PSPs by wildcard rules
+func WildcardMark(wildcard bool) string {
+	if wildcard {
+		return " (wildcard)"
+	}
+	return ""
+}
+
+// AggregationMark returns a marker for aggregated ClusterRoles granting PSPs through the component ClusterRoles
+func AggregationMark(path []string) string {
+	if len(path) == 0 {
+		return ""
+	}
+	return " (aggregated via " + strings.Join(path, " -> ") + ")"
+}
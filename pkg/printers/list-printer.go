@@ -18,28 +18,35 @@ package printers
 import (
 	"io"
 	"reflect"
+	"strings"
 
+	"github.com/jlandowner/psp-util/pkg/relations"
 	"github.com/liggitt/tabwriter"
+	rbacv1 "k8s.io/api/rbac/v1"
 )
 
-var ListHeader = []string{"PSP", "ClusterRole", "ClusterRoleBinding", "NS/Role", "NS/RoleBinding", "Managed"}
+var ListHeader = []string{"Cluster", "PSP", "ClusterRole", "ClusterRoleBinding", "NS/Role", "NS/RoleBinding", "Managed", "Subjects"}
 
 type ListPrinterLine struct {
+	Cluster            string
 	PSP                string
 	ClusterRole        string
 	ClusterRoleBinding string
 	Role               string
 	RoleBinding        string
 	PSPUtilManaged     string
+	Subjects           string
 }
 
 type ListPrinterOptions struct {
+	Cluster            bool
 	PSP                bool
 	ClusterRole        bool
 	ClusterRoleBinding bool
 	Role               bool
 	RoleBinding        bool
 	PSPUtilManaged     bool
+	Subjects           bool
 }
 
 type ListPrinter struct {
@@ -85,3 +92,17 @@ func (l *ListPrinter) PrintLine(line ListPrinterLine) {
 func (l *ListPrinter) Flush() {
 	l.w.Flush()
 }
+
+// FormatSubjects returns a comma separated list of subjects for a table column
+func FormatSubjects(subjects []rbacv1.Subject) string {
+	subs := make([]string, len(subjects))
+	for i, sub := range subjects {
+		subs[i] = FormatSubject(sub)
+	}
+	return strings.Join(subs, ",")
+}
+
+// FormatSubject returns a subject as Kind/Name or Kind/Namespace/Name
+func FormatSubject(sub rbacv1.Subject) string {
+	return relations.FormatSubject(sub)
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package printers
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jlandowner/psp-util/pkg/managed"
+	"github.com/jlandowner/psp-util/pkg/rbac"
+	"sigs.k8s.io/yaml"
+)
+
+// PrintPlan prints the changes of managed RBAC resources in Terraform-like format
+func PrintPlan(out io.Writer, plan *managed.Plan) {
+	if plan.IsEmpty() {
+		fmt.Fprintln(out, "No changes. Managed RBAC resources are up-to-date.")
+		return
+	}
+
+	fmt.Fprintln(out, "psp-util will perform the following actions:")
+	for _, c := range plan.Changes {
+		fmt.Fprintln(out)
+		switch c.Action {
+		case managed.ActionCreate:
+			fmt.Fprintf(out, "  # %s %s will be created\n", c.Kind, c.String())
+			fmt.Fprintf(out, "  "+GreenString+" %s %s\n", "+", c.Kind, c.String())
+		case managed.ActionUpdate:
+			fmt.Fprintf(out, "  # %s %s will be updated in-place%s\n", c.Kind, c.String(), reason(c))
+			fmt.Fprintf(out, "  "+CianString+" %s %s\n", "~", c.Kind, c.String())
+		case managed.ActionDelete:
+			fmt.Fprintf(out, "  # %s %s will be destroyed%s\n", c.Kind, c.String(), reason(c))
+			fmt.Fprintf(out, "  "+RedString+" %s %s\n", "-", c.Kind, c.String())
+		}
+		for _, s := range c.Added {
+			fmt.Fprintf(out, "      "+GreenString+" %s\n", "+", FormatSubject(s))
+		}
+		for _, s := range c.Removed {
+			fmt.Fprintf(out, "      "+RedString+" %s\n", "-", FormatSubject(s))
+		}
+	}
+	create, update, destroy := plan.Counts()
+	fmt.Fprintf(out, "\nPlan: %d to add, %d to change, %d to destroy.\n", create, update, destroy)
+}
+
+func reason(c managed.Change) string {
+	if c.Reason == "" {
+		return ""
+	}
+	return " (" + c.Reason + ")"
+}
+
+// PrintChanges prints the applied changes in kubectl-like format.
+// In dry-run, the objects to be created and the subjects changed in bindings are printed as well
+func PrintChanges(out io.Writer, plan *managed.Plan, dryRun string) error {
+	suffix := dryRunSuffix(dryRun)
+	for _, c := range plan.Changes {
+		fmt.Fprintf(out, "%s.%s/%s %s%s\n", strings.ToLower(c.Kind), rbac.APIGroup, c.String(), actionResult(c.Action), suffix)
+		if suffix == "" {
+			continue
+		}
+
+		if c.Action == managed.ActionCreate {
+			buf, err := yaml.Marshal(c.Object)
+			if err != nil {
+				return err
+			}
+			for _, l := range strings.Split(strings.TrimSuffix(string(buf), "\n"), "\n") {
+				fmt.Fprintf(out, "    %s\n", l)
+			}
+			continue
+		}
+		for _, s := range c.Added {
+			fmt.Fprintf(out, "    "+GreenString+" %s\n", "+", FormatSubject(s))
+		}
+		for _, s := range c.Removed {
+			fmt.Fprintf(out, "    "+RedString+" %s\n", "-", FormatSubject(s))
+		}
+	}
+	return nil
+}
+
+// PrintAdoption prints the adopted or labeled resource in kubectl-like format
+func PrintAdoption(out io.Writer, a managed.Adoption, dryRun string) {
+	result := "adopted"
+	if a.Backfill {
+		result = "labeled"
+	}
+	fmt.Fprintf(out, "%s.%s/%s %s%s\n", strings.ToLower(a.Kind), rbac.APIGroup, a.String(), result, dryRunSuffix(dryRun))
+}
+
+func dryRunSuffix(dryRun string) string {
+	switch dryRun {
+	case managed.DryRunClient:
+		return " (dry run)"
+	case managed.DryRunServer:
+		return " (server dry run)"
+	}
+	return ""
+}
+
+func actionResult(action managed.Action) string {
+	switch action {
+	case managed.ActionCreate:
+		return "created"
+	case managed.ActionUpdate:
+		return "configured"
+	case managed.ActionDelete:
+		return "deleted"
+	}
+	return string(action)
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package printers
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jlandowner/psp-util/pkg/pss"
+)
+
+var (
+	PSSClassificationHeader = []string{"PSP", "Level", "Mutates", "Reasons"}
+	NamespacePlanHeader     = []string{"Namespace", "PSPs", "Required", "Enforce", "Audit", "Warn", "Status"}
+)
+
+// PrintPSSMigrationReport prints PSP classifications, the proposed namespace labels and the grants in missing namespaces
+func PrintPSSMigrationReport(out io.Writer, classes []pss.Classification, plans []pss.NamespacePlan, dangling []pss.DanglingGrant) {
+	fmt.Fprintln(out, "PodSecurityPolicies:")
+	w := GetNewTabWriter(out)
+	PrintLine(w, PSSClassificationHeader)
+	for _, c := range classes {
+		reasons := c.BaselineViolations
+		if c.Level == pss.LevelBaseline {
+			reasons = c.RestrictedViolations
+		}
+		mutates := "-"
+		if c.IsMutating() {
+			mutates = strings.Join(c.MutatingFields, ",")
+		}
+		PrintLine(w, []string{c.PSP, string(c.Level), mutates, strings.Join(reasons, "; ")})
+	}
+	w.Flush()
+	fmt.Fprintln(out)
+
+	fmt.Fprintln(out, "Namespaces:")
+	w = GetNewTabWriter(out)
+	PrintLine(w, NamespacePlanHeader)
+	for _, p := range plans {
+		psps := make([]string, len(p.PSPs))
+		for i, np := range p.PSPs {
+			psps[i] = np.PSP
+		}
+		status := "OK"
+		switch {
+		case p.LosesPermissions():
+			status = "LosesPermissions"
+		case len(p.PSPs) == 0:
+			status = "NoPSP"
+		}
+		PrintLine(w, []string{p.Namespace, strings.Join(psps, ","), string(p.Required), string(p.Enforce), string(p.Audit), string(p.Warn), status})
+	}
+	w.Flush()
+
+	for _, p := range plans {
+		if !p.LosesPermissions() && len(p.MutatingPSPs) == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "\nNamespace %s:\n", p.Namespace)
+		for _, np := range p.PSPs {
+			for _, lost := range p.LostPSPs {
+				if np.PSP == lost {
+					fmt.Fprintf(out, "  "+RedString+" PSP %s (%s) exceeds enforce level %s. Granted via:\n", "LosesPermissions", np.PSP, np.Level, p.Enforce)
+					for _, via := range np.Via {
+						fmt.Fprintf(out, "    - %s\n", via)
+					}
+				}
+			}
+		}
+		for _, m := range p.MutatingPSPs {
+			fmt.Fprintf(out, "  Warning: PSP %s mutates Pods. Pod Security Admission does not, so set the defaults in Pod specs explicitly\n", m)
+		}
+	}
+
+	if len(dangling) > 0 {
+		fmt.Fprintln(out, "\nDangling grants:")
+		for _, d := range dangling {
+			fmt.Fprintf(out, "  Namespace %s is not found. PSP %s is granted via:\n", d.Namespace, d.PSP)
+			for _, via := range d.Via {
+				fmt.Fprintf(out, "    - %s\n", via)
+			}
+		}
+	}
+}
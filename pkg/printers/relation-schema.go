@@ -0,0 +1,225 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package printers
+
+import (
+	"github.com/jlandowner/psp-util/pkg/relations"
+	"github.com/jlandowner/psp-util/pkg/utils"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// Structured output schema of PSP relations.
+// Fields are only added to a version, never renamed or removed.
+const (
+	SchemaAPIVersion = "psp-util.k8s.jlandowner.com/v1alpha1"
+	RelationListKind = "RelationList"
+
+	SubjectGrantListKind  = "SubjectGrantList"
+	DanglingListKind      = "DanglingReferenceList"
+	PSPGrantListKind      = "PSPGrantList"
+	AuditReportKind       = "AuditReport"
+	PSPComparisonKind     = "PSPComparison"
+	ClusterComparisonKind = "ClusterComparison"
+	ClusterResultListKind = "ClusterResultList"
+)
+
+type RelationList struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Items      []PSPRelation `json:"items"`
+	// Dangling are the RBAC resources referring to missing PSPs or roles
+	Dangling *relations.DanglingReferences `json:"dangling,omitempty"`
+}
+
+type SubjectGrantList struct {
+	APIVersion string                   `json:"apiVersion"`
+	Kind       string                   `json:"kind"`
+	PSP        string                   `json:"psp"`
+	Items      []relations.SubjectGrant `json:"items"`
+}
+
+type PSPGrantList struct {
+	APIVersion string               `json:"apiVersion"`
+	Kind       string               `json:"kind"`
+	Subject    rbacv1.Subject       `json:"subject"`
+	Items      []relations.PSPGrant `json:"items"`
+}
+
+type DanglingReferenceList struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	relations.DanglingReferences
+}
+
+type PSPRelation struct {
+	Name         string                `json:"name"`
+	ClusterRoles []ClusterRoleRelation `json:"clusterRoles"`
+	Roles        []RoleRelation        `json:"roles"`
+}
+
+type ClusterRoleRelation struct {
+	Name                string            `json:"name"`
+	Managed             bool              `json:"managed"`
+	Wildcard            bool              `json:"wildcard,omitempty"`
+	AggregationPath     []string          `json:"aggregationPath,omitempty"`
+	ClusterRoleBindings []BindingRelation `json:"clusterRoleBindings"`
+	RoleBindings        []BindingRelation `json:"roleBindings"`
+}
+
+type RoleRelation struct {
+	Name         string            `json:"name"`
+	Namespace    string            `json:"namespace"`
+	Managed      bool              `json:"managed"`
+	Wildcard     bool              `json:"wildcard,omitempty"`
+	RoleBindings []BindingRelation `json:"roleBindings"`
+}
+
+type BindingRelation struct {
+	Name      string           `json:"name"`
+	Namespace string           `json:"namespace,omitempty"`
+	Managed   bool             `json:"managed"`
+	Subjects  []rbacv1.Subject `json:"subjects"`
+	// ExpandedSubjects is the ServiceAccounts included in the ServiceAccounts groups in Subjects
+	ExpandedSubjects []rbacv1.Subject `json:"expandedSubjects,omitempty"`
+	Warnings         []string         `json:"warnings,omitempty"`
+}
+
+// NewRelationList converts relational PSPs into the structured output schema
+func NewRelationList(psps []relations.RelationalPodSecurityPolicy, clusterRole, role bool) *RelationList {
+	list := &RelationList{
+		APIVersion: SchemaAPIVersion,
+		Kind:       RelationListKind,
+		Items:      make([]PSPRelation, 0, len(psps)),
+	}
+
+	for _, psp := range psps {
+		item := PSPRelation{
+			Name:         psp.Name,
+			ClusterRoles: make([]ClusterRoleRelation, 0),
+			Roles:        make([]RoleRelation, 0),
+		}
+
+		if clusterRole {
+			for _, cr := range psp.ClusterRoles {
+				rcr := ClusterRoleRelation{
+					Name:                cr.Name,
+					Managed:             cr.IsManaged(),
+					Wildcard:            cr.Wildcard,
+					AggregationPath:     cr.AggregationPath,
+					ClusterRoleBindings: make([]BindingRelation, 0, len(cr.ClusterRoleBindings)),
+					RoleBindings:        make([]BindingRelation, 0, len(cr.RoleBindings)),
+				}
+				for _, crb := range cr.ClusterRoleBindings {
+					rcr.ClusterRoleBindings = append(rcr.ClusterRoleBindings, newBindingRelation(crb.Name, "", crb.Annotations, crb.Subjects))
+				}
+				for _, rb := range cr.RoleBindings {
+					rcr.RoleBindings = append(rcr.RoleBindings, newBindingRelation(rb.Name, rb.Namespace, rb.Annotations, rb.Subjects))
+				}
+				item.ClusterRoles = append(item.ClusterRoles, rcr)
+			}
+		}
+
+		if role {
+			for _, r := range psp.Roles {
+				rr := RoleRelation{
+					Name:         r.Name,
+					Namespace:    r.Namespace,
+					Managed:      utils.IsManaged(r.Annotations),
+					Wildcard:     r.Wildcard,
+					RoleBindings: make([]BindingRelation, 0, len(r.RoleBindings)),
+				}
+				for _, rb := range r.RoleBindings {
+					rr.RoleBindings = append(rr.RoleBindings, newBindingRelation(rb.Name, rb.Namespace, rb.Annotations, rb.Subjects))
+				}
+				item.Roles = append(item.Roles, rr)
+			}
+		}
+		list.Items = append(list.Items, item)
+	}
+	return list
+}
+
+func newBindingRelation(name, namespace string, annotations map[string]string, subjects []rbacv1.Subject) BindingRelation {
+	b := BindingRelation{
+		Name:      name,
+		Namespace: namespace,
+		Managed:   utils.IsManaged(annotations),
+		Subjects:  make([]rbacv1.Subject, len(subjects)),
+	}
+	copy(b.Subjects, subjects)
+	return b
+}
+
+// Expand sets the ServiceAccounts included in the ServiceAccounts groups and the warnings of every user groups to the bindings
+func (l *RelationList) Expand(e *relations.SubjectExpander) {
+	for i := range l.Items {
+		for j := range l.Items[i].ClusterRoles {
+			cr := &l.Items[i].ClusterRoles[j]
+			for k := range cr.ClusterRoleBindings {
+				cr.ClusterRoleBindings[k].expand(e)
+			}
+			for k := range cr.RoleBindings {
+				cr.RoleBindings[k].expand(e)
+			}
+		}
+		for j := range l.Items[i].Roles {
+			r := &l.Items[i].Roles[j]
+			for k := range r.RoleBindings {
+				r.RoleBindings[k].expand(e)
+			}
+		}
+	}
+}
+
+func (b *BindingRelation) expand(e *relations.SubjectExpander) {
+	for _, sub := range b.Subjects {
+		if subs, ok := e.Expand(sub); ok {
+			b.ExpandedSubjects = append(b.ExpandedSubjects, subs...)
+		}
+		if warning := relations.ImplicitGroupWarning(sub); warning != "" {
+			b.Warnings = append(b.Warnings, FormatSubject(sub)+" is "+warning)
+		}
+	}
+}
+
+// NewSubjectGrantList converts the subjects permitted to use the PSP into the structured output schema
+func NewSubjectGrantList(psp relations.RelationalPodSecurityPolicy) *SubjectGrantList {
+	return &SubjectGrantList{
+		APIVersion: SchemaAPIVersion,
+		Kind:       SubjectGrantListKind,
+		PSP:        psp.Name,
+		Items:      psp.SubjectGrants(),
+	}
+}
+
+// NewPSPGrantList converts the PSPs permitted to the subject into the structured output schema
+func NewPSPGrantList(psps []relations.RelationalPodSecurityPolicy, sub rbacv1.Subject) *PSPGrantList {
+	return &PSPGrantList{
+		APIVersion: SchemaAPIVersion,
+		Kind:       PSPGrantListKind,
+		Subject:    sub,
+		Items:      relations.GetPSPGrantsForSubject(psps, sub),
+	}
+}
+
+// NewDanglingReferenceList converts the dangling references into the structured output schema
+func NewDanglingReferenceList(d *relations.DanglingReferences) *DanglingReferenceList {
+	return &DanglingReferenceList{
+		APIVersion:         SchemaAPIVersion,
+		Kind:               DanglingListKind,
+		DanglingReferences: *d,
+	}
+}
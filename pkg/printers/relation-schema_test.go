@@ -0,0 +1,150 @@
+package printers
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/jlandowner/psp-util/pkg/relations"
+	"github.com/jlandowner/psp-util/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	policyv1 "k8s.io/api/policy/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testRelationalPSPs() []relations.RelationalPodSecurityPolicy {
+	sub := rbacv1.Subject{Kind: "Group", APIGroup: "rbac.authorization.k8s.io", Name: "system:authenticated"}
+	return []relations.RelationalPodSecurityPolicy{
+		{
+			PodSecurityPolicy: policyv1.PodSecurityPolicy{ObjectMeta: metav1.ObjectMeta{Name: "restricted"}},
+			ClusterRoles: []*relations.RelationalClusterRole{
+				{
+					ClusterRole: rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{
+						Name: "psp-util.restricted", Annotations: utils.GenerateAnotations("restricted")}},
+					ClusterRoleBindings: []*rbacv1.ClusterRoleBinding{
+						{
+							ObjectMeta: metav1.ObjectMeta{Name: "psp-util.restricted", Annotations: utils.GenerateAnotations("restricted")},
+							Subjects:   []rbacv1.Subject{sub},
+						},
+					},
+				},
+			},
+			Roles: []*relations.RelationalRole{
+				{
+					Role: rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "myapp", Namespace: "default"}},
+				},
+			},
+		},
+		{
+			PodSecurityPolicy: policyv1.PodSecurityPolicy{ObjectMeta: metav1.ObjectMeta{Name: "unused"}},
+		},
+	}
+}
+
+func TestNewRelationList(t *testing.T) {
+	tests := []struct {
+		title            string
+		clusterRole      bool
+		role             bool
+		expectCRCount    int
+		expectRoleCount  int
+		expectCRBManaged bool
+	}{
+		{
+			title:            "all",
+			clusterRole:      true,
+			role:             true,
+			expectCRCount:    1,
+			expectRoleCount:  1,
+			expectCRBManaged: true,
+		},
+		{
+			title:           "only roles",
+			clusterRole:     false,
+			role:            true,
+			expectCRCount:   0,
+			expectRoleCount: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		list := NewRelationList(testRelationalPSPs(), test.clusterRole, test.role)
+		assert.Equal(t, SchemaAPIVersion, list.APIVersion)
+		assert.Equal(t, RelationListKind, list.Kind)
+		assert.Len(t, list.Items, 2)
+		assert.Len(t, list.Items[0].ClusterRoles, test.expectCRCount)
+		assert.Len(t, list.Items[0].Roles, test.expectRoleCount)
+		if test.expectCRCount > 0 {
+			assert.True(t, list.Items[0].ClusterRoles[0].Managed)
+			assert.Equal(t, test.expectCRBManaged, list.Items[0].ClusterRoles[0].ClusterRoleBindings[0].Managed)
+		}
+		assert.NotNil(t, list.Items[1].ClusterRoles)
+		assert.NotNil(t, list.Items[1].Roles)
+	}
+}
+
+func TestPrintObject(t *testing.T) {
+	tests := []struct {
+		title  string
+		format string
+		expect string
+		err    bool
+	}{
+		{
+			title:  "json",
+			format: OutputFormatJSON,
+			expect: "{\n    \"apiVersion\": \"psp-util.k8s.jlandowner.com/v1alpha1\",\n    \"kind\": \"RelationList\",\n    \"items\": []\n}\n",
+		},
+		{
+			title:  "yaml",
+			format: OutputFormatYAML,
+			expect: "apiVersion: psp-util.k8s.jlandowner.com/v1alpha1\nitems: []\nkind: RelationList\n",
+		},
+		{
+			title:  "unsupported",
+			format: OutputFormatWide,
+			err:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		buf := &bytes.Buffer{}
+		err := PrintObject(buf, NewRelationList(nil, true, true), test.format)
+		if test.err {
+			assert.NotNil(t, err)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, test.expect, buf.String())
+	}
+}
+
+func TestClusterResultList(t *testing.T) {
+	results := []ClusterResult{
+		{Cluster: "staging", Result: NewRelationList(nil, true, true)},
+		NewClusterError("prod", fmt.Errorf("Failed to list PSP: forbidden")),
+	}
+
+	buf := &bytes.Buffer{}
+	err := PrintObject(buf, NewClusterResultList(results), OutputFormatYAML)
+	assert.Nil(t, err)
+	assert.Equal(t, `apiVersion: psp-util.k8s.jlandowner.com/v1alpha1
+items:
+- cluster: staging
+  result:
+    apiVersion: psp-util.k8s.jlandowner.com/v1alpha1
+    items: []
+    kind: RelationList
+- cluster: prod
+  error: 'Failed to list PSP: forbidden'
+kind: ClusterResultList
+`, buf.String())
+
+	buf.Reset()
+	failed := PrintClusterErrors(buf, results)
+	assert.Equal(t, 1, failed)
+	assert.Equal(t, "ERROR: prod: Failed to list PSP: forbidden\n", buf.String())
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package printers
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jlandowner/psp-util/pkg/admission"
+)
+
+const SimulationResultListKind = "SimulationResultList"
+
+type SimulationResultList struct {
+	APIVersion string             `json:"apiVersion"`
+	Kind       string             `json:"kind"`
+	Items      []SimulationResult `json:"items"`
+}
+
+type SimulationResult struct {
+	Target         admission.Target `json:"target"`
+	ServiceAccount string           `json:"serviceAccount"`
+	User           string           `json:"user,omitempty"`
+	Groups         []string         `json:"groups,omitempty"`
+	// NotPermittedPSPs are PSPs neither the service account nor the user is permitted to use
+	NotPermittedPSPs []string          `json:"notPermittedPSPs"`
+	Result           *admission.Result `json:"result"`
+}
+
+// NewSimulationResultList returns the structured output of the simulation results
+func NewSimulationResultList(results []SimulationResult) *SimulationResultList {
+	return &SimulationResultList{
+		APIVersion: SchemaAPIVersion,
+		Kind:       SimulationResultListKind,
+		Items:      results,
+	}
+}
+
+// PrintSimulationResults prints a human readable report of the simulation results
+func PrintSimulationResults(out io.Writer, results []SimulationResult) {
+	for _, r := range results {
+		target := fmt.Sprintf("%s %s/%s", r.Target.Kind, r.Target.Namespace, r.Target.Name)
+		if r.Target.TemplatePath != "" {
+			target += " " + r.Target.TemplatePath
+		}
+
+		if r.Result.Allowed {
+			fmt.Fprintf(out, "✅ %s would be admitted by PSP "+GreenString+"\n", target, r.Result.PSP)
+		} else {
+			fmt.Fprintf(out, "❌ %s would be "+RedString+"\n", target, "rejected")
+		}
+
+		fmt.Fprintf(out, "   ServiceAccount: %s\n", r.ServiceAccount)
+		if r.User != "" {
+			fmt.Fprintf(out, "   User: %s %v\n", r.User, r.Groups)
+		}
+
+		if len(r.Result.Mutations) > 0 {
+			fmt.Fprintf(out, "   Mutations:\n")
+			for _, m := range r.Result.Mutations {
+				fmt.Fprintf(out, "     - %s: %s\n", m.Field, m.Value)
+			}
+		}
+
+		if len(r.Result.Evaluations) == 0 {
+			fmt.Fprintf(out, "   No PSP is permitted to the ServiceAccount or the User\n")
+		} else {
+			fmt.Fprintf(out, "   Evaluated PSPs:\n")
+		}
+		for _, e := range r.Result.Evaluations {
+			switch {
+			case !e.Allowed:
+				fmt.Fprintf(out, "     - %s: "+RedString+"\n", e.PSP, "rejected")
+				for _, v := range e.Violations {
+					fmt.Fprintf(out, "         %s\n", v)
+				}
+			case len(e.Mutations) > 0:
+				fmt.Fprintf(out, "     - %s: allowed with %d mutations\n", e.PSP, len(e.Mutations))
+			default:
+				fmt.Fprintf(out, "     - %s: allowed\n", e.PSP)
+			}
+		}
+
+		if len(r.NotPermittedPSPs) > 0 {
+			fmt.Fprintf(out, "   Not permitted PSPs: %s\n", strings.Join(r.NotPermittedPSPs, ", "))
+		}
+		fmt.Fprintln(out)
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package printers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	OutputFormatJSON = "json"
+	OutputFormatYAML = "yaml"
+	OutputFormatWide = "wide"
+	OutputFormatName = "name"
+)
+
+// IsStructuredOutput returns true if the given output format is a serialization format
+func IsStructuredOutput(format string) bool {
+	return format == OutputFormatJSON || format == OutputFormatYAML
+}
+
+// PrintObject serializes the given object in json or yaml
+func PrintObject(out io.Writer, obj interface{}, format string) error {
+	var buf []byte
+	var err error
+
+	switch format {
+	case OutputFormatJSON:
+		buf, err = json.MarshalIndent(obj, "", "    ")
+		if err == nil {
+			buf = append(buf, '\n')
+		}
+	case OutputFormatYAML:
+		buf, err = yaml.Marshal(obj)
+	default:
+		return fmt.Errorf("Unsupported output format: %s", format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(buf)
+	return err
+}
+
+// PrintObjects serializes Kubernetes manifests as multi-document yaml or a json List
+func PrintObjects(out io.Writer, objs []interface{}, format string) error {
+	switch format {
+	case OutputFormatJSON:
+		list := map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "List",
+			"items":      objs,
+		}
+		return PrintObject(out, list, format)
+
+	case OutputFormatYAML:
+		for i, obj := range objs {
+			if i > 0 {
+				if _, err := fmt.Fprintln(out, "---"); err != nil {
+					return err
+				}
+			}
+			if err := PrintObject(out, obj, format); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("Unsupported output format: %s", format)
+}
@@ -0,0 +1,338 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pss
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jlandowner/psp-util/pkg/policy"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Level is a Pod Security Standards level
+type Level string
+
+const (
+	LevelPrivileged Level = "privileged"
+	LevelBaseline   Level = "baseline"
+	LevelRestricted Level = "restricted"
+)
+
+// Levels are all the levels ordered from the most permissive
+var Levels = []Level{LevelPrivileged, LevelBaseline, LevelRestricted}
+
+// ParseLevel returns the level of the given name
+func ParseLevel(s string) (Level, error) {
+	for _, l := range Levels {
+		if string(l) == s {
+			return l, nil
+		}
+	}
+	return "", fmt.Errorf("Invalid level %s: must be one of privileged|baseline|restricted", s)
+}
+
+// Permissiveness returns larger value for the more permissive level
+func (l Level) Permissiveness() int {
+	for i, v := range Levels {
+		if v == l {
+			return len(Levels) - i
+		}
+	}
+	return 0
+}
+
+// Stricter returns the next stricter level or the level itself if it is the strictest
+func (l Level) Stricter() Level {
+	for i, v := range Levels {
+		if v == l && i+1 < len(Levels) {
+			return Levels[i+1]
+		}
+	}
+	return l
+}
+
+// Capabilities which containers can add in baseline level
+var BaselineCapabilities = []string{
+	"AUDIT_WRITE", "CHOWN", "DAC_OVERRIDE", "FOWNER", "FSETID", "KILL", "MKNOD",
+	"NET_BIND_SERVICE", "SETFCAP", "SETGID", "SETPCAP", "SETUID", "SYS_CHROOT",
+}
+
+// Capabilities which containers can add in restricted level
+var RestrictedCapabilities = []string{"NET_BIND_SERVICE"}
+
+// Volume types which pods can use in restricted level
+var RestrictedVolumes = []policyv1.FSType{
+	policyv1.ConfigMap, policyv1.CSI, policyv1.DownwardAPI, policyv1.EmptyDir,
+	policyv1.PersistentVolumeClaim, policyv1.Projected, policyv1.Secret, "ephemeral",
+}
+
+// SELinux types which pods can use in baseline level
+var BaselineSELinuxTypes = []string{"", "container_t", "container_init_t", "container_kvm_t"}
+
+// Classification is a result of mapping a PSP to the Pod Security Standards
+type Classification struct {
+	PSP   string `json:"psp"`
+	Level Level  `json:"level"`
+	// BaselineViolations are fields which the PSP allows beyond baseline level
+	BaselineViolations []string `json:"baselineViolations"`
+	// RestrictedViolations are fields which the PSP allows beyond restricted level
+	RestrictedViolations []string `json:"restrictedViolations"`
+	// MutatingFields are fields with which the PSP modifies Pods. Pod Security Admission never mutates Pods
+	MutatingFields []string `json:"mutatingFields"`
+}
+
+// IsMutating returns true if the PSP modifies Pods on admission
+func (c Classification) IsMutating() bool {
+	return len(c.MutatingFields) > 0
+}
+
+// Classify maps the PSP to the strictest Pod Security Standards level which admits all Pods the PSP admits
+func Classify(psp policyv1.PodSecurityPolicy) Classification {
+	spec := psp.Spec
+	c := Classification{
+		PSP:                  psp.Name,
+		BaselineViolations:   make([]string, 0),
+		RestrictedViolations: make([]string, 0),
+		MutatingFields:       mutatingFields(psp),
+	}
+	baseline := func(format string, a ...interface{}) {
+		c.BaselineViolations = append(c.BaselineViolations, fmt.Sprintf(format, a...))
+	}
+	restricted := func(format string, a ...interface{}) {
+		c.RestrictedViolations = append(c.RestrictedViolations, fmt.Sprintf(format, a...))
+	}
+
+	// baseline
+	if spec.Privileged {
+		baseline("privileged: true")
+	}
+	if spec.HostNetwork {
+		baseline("hostNetwork: true")
+	}
+	if spec.HostPID {
+		baseline("hostPID: true")
+	}
+	if spec.HostIPC {
+		baseline("hostIPC: true")
+	}
+	if len(spec.HostPorts) > 0 {
+		baseline("hostPorts: %s", formatHostPorts(spec.HostPorts))
+	}
+	if policy.AllowsAllVolumes(spec) {
+		baseline("volumes: %s", policyv1.All)
+	} else if policy.AllowsVolume(spec, policyv1.HostPath) {
+		baseline("volumes: %s", policyv1.HostPath)
+	}
+	if caps := capsNotIn(spec.AllowedCapabilities, BaselineCapabilities); len(caps) > 0 {
+		baseline("allowedCapabilities: %s", strings.Join(caps, ","))
+	}
+	if caps := capsNotIn(spec.DefaultAddCapabilities, BaselineCapabilities); len(caps) > 0 {
+		baseline("defaultAddCapabilities: %s", strings.Join(caps, ","))
+	}
+	for _, t := range spec.AllowedProcMountTypes {
+		if t != corev1.DefaultProcMount {
+			baseline("allowedProcMountTypes: %s", t)
+		}
+	}
+	if len(spec.AllowedUnsafeSysctls) > 0 {
+		baseline("allowedUnsafeSysctls: %s", strings.Join(spec.AllowedUnsafeSysctls, ","))
+	}
+	if v := seLinuxViolation(spec.SELinux); v != "" {
+		baseline("seLinux: %s", v)
+	}
+	if profiles, ok := psp.Annotations[policy.SeccompAllowedProfilesAnnotationKey]; ok {
+		for _, p := range splitProfiles(profiles) {
+			if p == "*" || p == "unconfined" {
+				baseline("seccomp allowedProfileNames: %s", p)
+			}
+		}
+	}
+	if profiles, ok := psp.Annotations[policy.AppArmorAllowedProfilesAnnotationKey]; ok {
+		for _, p := range splitProfiles(profiles) {
+			if p != "runtime/default" && !strings.HasPrefix(p, "localhost/") {
+				baseline("apparmor allowedProfileNames: %s", p)
+			}
+		}
+	}
+
+	// restricted
+	if !policy.AllowsAllVolumes(spec) {
+		allowed := sets.NewString()
+		for _, v := range RestrictedVolumes {
+			allowed.Insert(string(v))
+		}
+		for _, v := range spec.Volumes {
+			if !allowed.Has(string(v)) && v != policyv1.HostPath {
+				restricted("volumes: %s", v)
+			}
+		}
+	}
+	if policy.AllowPrivilegeEscalation(spec) {
+		restricted("allowPrivilegeEscalation: true")
+	}
+	if !runAsNonRoot(spec.RunAsUser) {
+		restricted("runAsUser: %s", formatRunAsUser(spec.RunAsUser))
+	}
+	if !capSet(spec.RequiredDropCapabilities).Has("ALL") {
+		restricted("requiredDropCapabilities: ALL is not included")
+	}
+	if caps := capsNotIn(spec.AllowedCapabilities, RestrictedCapabilities); len(caps) > 0 {
+		restricted("allowedCapabilities: %s", strings.Join(caps, ","))
+	}
+	if profiles, ok := psp.Annotations[policy.SeccompAllowedProfilesAnnotationKey]; !ok || profiles == "" {
+		restricted("seccomp allowedProfileNames: not set")
+	} else {
+		for _, p := range splitProfiles(profiles) {
+			if p != "runtime/default" && p != "docker/default" && !strings.HasPrefix(p, "localhost/") && p != "*" && p != "unconfined" {
+				restricted("seccomp allowedProfileNames: %s", p)
+			}
+		}
+	}
+
+	switch {
+	case len(c.BaselineViolations) > 0:
+		c.Level = LevelPrivileged
+	case len(c.RestrictedViolations) > 0:
+		c.Level = LevelBaseline
+	default:
+		c.Level = LevelRestricted
+	}
+	return c
+}
+
+func mutatingFields(psp policyv1.PodSecurityPolicy) []string {
+	spec := psp.Spec
+	fields := make([]string, 0)
+	if len(spec.DefaultAddCapabilities) > 0 {
+		fields = append(fields, "defaultAddCapabilities")
+	}
+	if len(spec.RequiredDropCapabilities) > 0 {
+		fields = append(fields, "requiredDropCapabilities")
+	}
+	if spec.SELinux.Rule == policyv1.SELinuxStrategyMustRunAs {
+		fields = append(fields, "seLinux")
+	}
+	switch spec.RunAsUser.Rule {
+	case policyv1.RunAsUserStrategyMustRunAs, policyv1.RunAsUserStrategyMustRunAsNonRoot:
+		fields = append(fields, "runAsUser")
+	}
+	if spec.RunAsGroup != nil && spec.RunAsGroup.Rule == policyv1.RunAsGroupStrategyMustRunAs {
+		fields = append(fields, "runAsGroup")
+	}
+	if spec.SupplementalGroups.Rule == policyv1.SupplementalGroupsStrategyMustRunAs {
+		fields = append(fields, "supplementalGroups")
+	}
+	if spec.FSGroup.Rule == policyv1.FSGroupStrategyMustRunAs {
+		fields = append(fields, "fsGroup")
+	}
+	if spec.ReadOnlyRootFilesystem {
+		fields = append(fields, "readOnlyRootFilesystem")
+	}
+	if spec.DefaultAllowPrivilegeEscalation != nil || !policy.AllowPrivilegeEscalation(spec) {
+		fields = append(fields, "allowPrivilegeEscalation")
+	}
+	if _, ok := psp.Annotations[policy.SeccompDefaultProfileAnnotationKey]; ok {
+		fields = append(fields, "seccomp defaultProfileName")
+	}
+	if _, ok := psp.Annotations[policy.AppArmorDefaultProfileAnnotationKey]; ok {
+		fields = append(fields, "apparmor defaultProfileName")
+	}
+	return fields
+}
+
+func seLinuxViolation(opts policyv1.SELinuxStrategyOptions) string {
+	if opts.Rule != policyv1.SELinuxStrategyMustRunAs {
+		return fmt.Sprintf("rule %s allows any SELinux options", opts.Rule)
+	}
+	if opts.SELinuxOptions == nil {
+		return ""
+	}
+	if opts.SELinuxOptions.User != "" {
+		return fmt.Sprintf("user %s", opts.SELinuxOptions.User)
+	}
+	if opts.SELinuxOptions.Role != "" {
+		return fmt.Sprintf("role %s", opts.SELinuxOptions.Role)
+	}
+	if !sets.NewString(BaselineSELinuxTypes...).Has(opts.SELinuxOptions.Type) {
+		return fmt.Sprintf("type %s", opts.SELinuxOptions.Type)
+	}
+	return ""
+}
+
+func runAsNonRoot(opts policyv1.RunAsUserStrategyOptions) bool {
+	switch opts.Rule {
+	case policyv1.RunAsUserStrategyMustRunAsNonRoot:
+		return true
+	case policyv1.RunAsUserStrategyMustRunAs:
+		if len(opts.Ranges) == 0 {
+			return false
+		}
+		for _, r := range opts.Ranges {
+			if r.Min == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func formatRunAsUser(opts policyv1.RunAsUserStrategyOptions) string {
+	if opts.Rule == policyv1.RunAsUserStrategyMustRunAs {
+		return fmt.Sprintf("rule %s allows root user", opts.Rule)
+	}
+	return fmt.Sprintf("rule %s", opts.Rule)
+}
+
+func formatHostPorts(ranges []policyv1.HostPortRange) string {
+	s := make([]string, len(ranges))
+	for i, r := range ranges {
+		if r.Min == r.Max {
+			s[i] = fmt.Sprint(r.Min)
+		} else {
+			s[i] = fmt.Sprintf("%d-%d", r.Min, r.Max)
+		}
+	}
+	return strings.Join(s, ",")
+}
+
+func splitProfiles(profiles string) []string {
+	s := make([]string, 0)
+	for _, p := range strings.Split(profiles, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			s = append(s, p)
+		}
+	}
+	return s
+}
+
+func capSet(caps []corev1.Capability) sets.String {
+	s := sets.NewString()
+	for _, c := range caps {
+		s.Insert(strings.ToUpper(strings.TrimPrefix(string(c), "CAP_")))
+	}
+	return s
+}
+
+func capsNotIn(caps []corev1.Capability, allowed []string) []string {
+	s := capSet(caps).Difference(sets.NewString(allowed...)).List()
+	sort.Strings(s)
+	return s
+}
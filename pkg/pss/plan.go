@@ -0,0 +1,223 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pss
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jlandowner/psp-util/pkg/relations"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// Namespace label keys of Pod Security Admission
+const (
+	LabelEnforce = "pod-security.kubernetes.io/enforce"
+	LabelAudit   = "pod-security.kubernetes.io/audit"
+	LabelWarn    = "pod-security.kubernetes.io/warn"
+)
+
+// NamespacePSP is a PSP which workloads in a namespace rely on
+type NamespacePSP struct {
+	PSP   string `json:"psp"`
+	Level Level  `json:"level"`
+	// Via are subjects and bindings granting the PSP in the namespace
+	Via []string `json:"via"`
+}
+
+// DanglingGrant is a PSP granted to workloads in a namespace which does not exist
+type DanglingGrant struct {
+	Namespace string `json:"namespace"`
+	PSP       string `json:"psp"`
+	// Via are subjects and bindings granting the PSP in the namespace
+	Via []string `json:"via"`
+}
+
+// NamespacePlan is a proposed set of Pod Security Admission labels for a namespace
+type NamespacePlan struct {
+	Namespace string         `json:"namespace"`
+	PSPs      []NamespacePSP `json:"psps"`
+	// Required is the level which admits all the Pods admitted by the PSPs today
+	Required Level `json:"required"`
+	Enforce  Level `json:"enforce"`
+	Audit    Level `json:"audit"`
+	Warn     Level `json:"warn"`
+	// LostPSPs are PSPs whose permissions are not covered by the enforce level
+	LostPSPs []string `json:"lostPSPs"`
+	// MutatingPSPs are PSPs whose defaults Pods may rely on
+	MutatingPSPs []string `json:"mutatingPSPs"`
+}
+
+// LosesPermissions returns true if some Pods admitted today would be rejected by the enforce level
+func (p NamespacePlan) LosesPermissions() bool {
+	return len(p.LostPSPs) > 0
+}
+
+// Labels returns Pod Security Admission labels to set on the namespace
+func (p NamespacePlan) Labels() map[string]string {
+	return map[string]string{
+		LabelEnforce: string(p.Enforce),
+		LabelAudit:   string(p.Audit),
+		LabelWarn:    string(p.Warn),
+	}
+}
+
+// NamespacePatch returns a Namespace manifest which applies the labels
+func (p NamespacePlan) NamespacePatch() map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata": map[string]interface{}{
+			"name":   p.Namespace,
+			"labels": p.Labels(),
+		},
+	}
+}
+
+// PlanNamespaces proposes labels for each namespace from the PSPs granted to its workloads.
+// Workloads are ServiceAccounts in the namespace and subjects bound by RoleBindings in the namespace.
+// Enforce level is capped at maxLevel, and audit and warn are set one level stricter than enforce.
+// Plans are proposed only for the given namespaces. PSPs granted in other namespaces (e.g. to ServiceAccounts of deleted namespaces)
+// are returned as dangling grants.
+func PlanNamespaces(psps []relations.RelationalPodSecurityPolicy, namespaces []string, maxLevel Level) ([]NamespacePlan, []DanglingGrant) {
+	nsSet := make(map[string]map[string]*NamespacePSP)
+	for _, ns := range namespaces {
+		nsSet[ns] = make(map[string]*NamespacePSP)
+	}
+	danglingSet := make(map[string]map[string]*DanglingGrant)
+
+	classes := make(map[string]Classification)
+	for _, psp := range psps {
+		c := Classify(psp.PodSecurityPolicy)
+		classes[psp.Name] = c
+
+		for _, g := range psp.SubjectGrants() {
+			for _, path := range g.Paths {
+				for _, ns := range grantedNamespaces(g.Subject, path, namespaces) {
+					if _, ok := nsSet[ns]; !ok {
+						if _, ok := danglingSet[ns]; !ok {
+							danglingSet[ns] = make(map[string]*DanglingGrant)
+						}
+						dg, ok := danglingSet[ns][psp.Name]
+						if !ok {
+							dg = &DanglingGrant{Namespace: ns, PSP: psp.Name, Via: make([]string, 0)}
+							danglingSet[ns][psp.Name] = dg
+						}
+						dg.Via = append(dg.Via, formatVia(g.Subject, path))
+						continue
+					}
+					np, ok := nsSet[ns][psp.Name]
+					if !ok {
+						np = &NamespacePSP{PSP: psp.Name, Level: c.Level, Via: make([]string, 0)}
+						nsSet[ns][psp.Name] = np
+					}
+					np.Via = append(np.Via, formatVia(g.Subject, path))
+				}
+			}
+		}
+	}
+
+	plans := make([]NamespacePlan, 0, len(nsSet))
+	for ns, nsPSPs := range nsSet {
+		plan := NamespacePlan{
+			Namespace:    ns,
+			PSPs:         make([]NamespacePSP, 0, len(nsPSPs)),
+			Required:     LevelRestricted,
+			LostPSPs:     make([]string, 0),
+			MutatingPSPs: make([]string, 0),
+		}
+		for _, np := range nsPSPs {
+			plan.PSPs = append(plan.PSPs, *np)
+			if np.Level.Permissiveness() > plan.Required.Permissiveness() {
+				plan.Required = np.Level
+			}
+		}
+		sort.Slice(plan.PSPs, func(i, j int) bool { return plan.PSPs[i].PSP < plan.PSPs[j].PSP })
+
+		plan.Enforce = plan.Required
+		if plan.Enforce.Permissiveness() > maxLevel.Permissiveness() {
+			plan.Enforce = maxLevel
+		}
+		plan.Audit = plan.Enforce.Stricter()
+		plan.Warn = plan.Enforce.Stricter()
+
+		for _, np := range plan.PSPs {
+			if np.Level.Permissiveness() > plan.Enforce.Permissiveness() {
+				plan.LostPSPs = append(plan.LostPSPs, np.PSP)
+			}
+			if classes[np.PSP].IsMutating() {
+				plan.MutatingPSPs = append(plan.MutatingPSPs, np.PSP)
+			}
+		}
+		plans = append(plans, plan)
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i].Namespace < plans[j].Namespace })
+
+	dangling := make([]DanglingGrant, 0)
+	for _, dgs := range danglingSet {
+		for _, dg := range dgs {
+			dangling = append(dangling, *dg)
+		}
+	}
+	sort.Slice(dangling, func(i, j int) bool {
+		if dangling[i].Namespace != dangling[j].Namespace {
+			return dangling[i].Namespace < dangling[j].Namespace
+		}
+		return dangling[i].PSP < dangling[j].PSP
+	})
+	return plans, dangling
+}
+
+// grantedNamespaces returns namespaces in which Pods created by workloads of the subject can use the PSP through the path.
+// Users and Groups bound cluster-wide are not regarded as namespace workloads
+func grantedNamespaces(sub rbacv1.Subject, path relations.GrantPath, namespaces []string) []string {
+	inScope := func(ns string) []string {
+		if path.Scope == relations.GrantScopeCluster || path.Namespace == ns {
+			return []string{ns}
+		}
+		return nil
+	}
+
+	if sa, ok := relations.ServiceAccountSubject(sub); ok {
+		return inScope(sa.Namespace)
+	}
+
+	if sub.Kind == "Group" {
+		if strings.HasPrefix(sub.Name, relations.GroupServiceAccounts+":") {
+			return inScope(strings.TrimPrefix(sub.Name, relations.GroupServiceAccounts+":"))
+		}
+		switch sub.Name {
+		case relations.GroupServiceAccounts, relations.GroupAuthenticated, relations.GroupUnauthenticated:
+			if path.Scope == relations.GrantScopeCluster {
+				return namespaces
+			}
+		}
+	}
+
+	if path.Scope == relations.GrantScopeNamespace {
+		return []string{path.Namespace}
+	}
+	return nil
+}
+
+func formatVia(sub rbacv1.Subject, path relations.GrantPath) string {
+	binding := path.BindingKind + "/" + path.BindingName
+	if path.Scope == relations.GrantScopeNamespace {
+		binding = path.BindingKind + "/" + path.Namespace + "/" + path.BindingName
+	}
+	return fmt.Sprintf("%s (%s)", relations.FormatSubject(sub), binding)
+}
@@ -0,0 +1,166 @@
+package pss
+
+import (
+	"testing"
+
+	"github.com/jlandowner/psp-util/pkg/policy"
+	"github.com/jlandowner/psp-util/pkg/relations"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func restrictedPSP(name string) policyv1.PodSecurityPolicy {
+	f := false
+	return policyv1.PodSecurityPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Annotations: map[string]string{
+				policy.SeccompAllowedProfilesAnnotationKey: "runtime/default,docker/default",
+			},
+		},
+		Spec: policyv1.PodSecurityPolicySpec{
+			AllowPrivilegeEscalation: &f,
+			RequiredDropCapabilities: []corev1.Capability{"ALL"},
+			Volumes:                  []policyv1.FSType{policyv1.ConfigMap, policyv1.EmptyDir, policyv1.Secret},
+			SELinux:                  policyv1.SELinuxStrategyOptions{Rule: policyv1.SELinuxStrategyMustRunAs},
+			RunAsUser:                policyv1.RunAsUserStrategyOptions{Rule: policyv1.RunAsUserStrategyMustRunAsNonRoot},
+			SupplementalGroups:       policyv1.SupplementalGroupsStrategyOptions{Rule: policyv1.SupplementalGroupsStrategyRunAsAny},
+			FSGroup:                  policyv1.FSGroupStrategyOptions{Rule: policyv1.FSGroupStrategyRunAsAny},
+		},
+	}
+}
+
+func TestClassify(t *testing.T) {
+	baseline := restrictedPSP("baseline")
+	baseline.Spec.AllowPrivilegeEscalation = nil
+	baseline.Spec.RequiredDropCapabilities = nil
+	baseline.Spec.RunAsUser.Rule = policyv1.RunAsUserStrategyRunAsAny
+	baseline.Spec.AllowedCapabilities = []corev1.Capability{"KILL"}
+
+	privileged := restrictedPSP("privileged")
+	privileged.Spec.Privileged = true
+	privileged.Spec.HostNetwork = true
+	privileged.Spec.Volumes = []policyv1.FSType{policyv1.All}
+	privileged.Spec.SELinux.Rule = policyv1.SELinuxStrategyRunAsAny
+
+	tests := []struct {
+		title                string
+		psp                  policyv1.PodSecurityPolicy
+		expectLevel          Level
+		expectBaseline       []string
+		expectRestricted     []string
+		expectMutatingFields []string
+	}{
+		{
+			title:                "restricted",
+			psp:                  restrictedPSP("restricted"),
+			expectLevel:          LevelRestricted,
+			expectBaseline:       []string{},
+			expectRestricted:     []string{},
+			expectMutatingFields: []string{"requiredDropCapabilities", "seLinux", "runAsUser", "allowPrivilegeEscalation"},
+		},
+		{
+			title:                "baseline",
+			psp:                  baseline,
+			expectLevel:          LevelBaseline,
+			expectBaseline:       []string{},
+			expectRestricted:     []string{"allowPrivilegeEscalation: true", "runAsUser: rule RunAsAny", "requiredDropCapabilities: ALL is not included", "allowedCapabilities: KILL"},
+			expectMutatingFields: []string{"seLinux"},
+		},
+		{
+			title:                "privileged",
+			psp:                  privileged,
+			expectLevel:          LevelPrivileged,
+			expectBaseline:       []string{"privileged: true", "hostNetwork: true", "volumes: *", "seLinux: rule RunAsAny allows any SELinux options"},
+			expectRestricted:     []string{},
+			expectMutatingFields: []string{"requiredDropCapabilities", "runAsUser", "allowPrivilegeEscalation"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		c := Classify(test.psp)
+		assert.Equal(t, test.psp.Name, c.PSP)
+		assert.Equal(t, test.expectLevel, c.Level)
+		assert.Equal(t, test.expectBaseline, c.BaselineViolations)
+		assert.Equal(t, test.expectRestricted, c.RestrictedViolations)
+		assert.Equal(t, test.expectMutatingFields, c.MutatingFields)
+	}
+}
+
+func TestPlanNamespaces(t *testing.T) {
+	newPSP := func(psp policyv1.PodSecurityPolicy, crbSubjects []rbacv1.Subject, rbs ...*rbacv1.RoleBinding) relations.RelationalPodSecurityPolicy {
+		return relations.RelationalPodSecurityPolicy{
+			PodSecurityPolicy: psp,
+			ClusterRoles: []*relations.RelationalClusterRole{
+				{
+					ClusterRole: rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "cr-" + psp.Name}},
+					ClusterRoleBindings: []*rbacv1.ClusterRoleBinding{
+						{ObjectMeta: metav1.ObjectMeta{Name: "crb-" + psp.Name}, Subjects: crbSubjects},
+					},
+					RoleBindings: rbs,
+				},
+			},
+		}
+	}
+	privileged := restrictedPSP("privileged")
+	privileged.Spec.HostPID = true
+	authenticated := rbacv1.Subject{Kind: "Group", Name: relations.GroupAuthenticated}
+	admins := rbacv1.Subject{Kind: "Group", Name: "system:masters"}
+	deletedSA := rbacv1.Subject{Kind: "User", Name: "system:serviceaccount:deleted:default"}
+
+	psps := []relations.RelationalPodSecurityPolicy{
+		newPSP(restrictedPSP("restricted"), []rbacv1.Subject{authenticated}),
+		newPSP(privileged, []rbacv1.Subject{admins, deletedSA},
+			&rbacv1.RoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "rb", Namespace: "kube-system"},
+				Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "system:serviceaccounts:kube-system"}},
+			}),
+	}
+
+	tests := []struct {
+		title          string
+		maxLevel       Level
+		expectEnforce  []Level
+		expectAudit    []Level
+		expectLostPSPs [][]string
+	}{
+		{
+			title:          "privileged max level keeps permissions",
+			maxLevel:       LevelPrivileged,
+			expectEnforce:  []Level{LevelPrivileged, LevelRestricted},
+			expectAudit:    []Level{LevelBaseline, LevelRestricted},
+			expectLostPSPs: [][]string{{}, {}},
+		},
+		{
+			title:          "baseline max level loses privileged PSP",
+			maxLevel:       LevelBaseline,
+			expectEnforce:  []Level{LevelBaseline, LevelRestricted},
+			expectAudit:    []Level{LevelRestricted, LevelRestricted},
+			expectLostPSPs: [][]string{{"privileged"}, {}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		plans, dangling := PlanNamespaces(psps, []string{"team-a", "kube-system"}, test.maxLevel)
+		assert.Len(t, plans, 2)
+		assert.Equal(t, "kube-system", plans[0].Namespace)
+		assert.Equal(t, "team-a", plans[1].Namespace)
+
+		for i, p := range plans {
+			assert.Equal(t, test.expectEnforce[i], p.Enforce)
+			assert.Equal(t, test.expectAudit[i], p.Audit)
+			assert.Equal(t, test.expectLostPSPs[i], p.LostPSPs)
+		}
+		assert.Equal(t, LevelPrivileged, plans[0].Required)
+		assert.Equal(t, []string{"Group/system:serviceaccounts:kube-system (RoleBinding/kube-system/rb)"}, plans[0].PSPs[0].Via)
+		assert.Len(t, plans[1].PSPs, 1)
+		assert.Equal(t, []DanglingGrant{
+			{Namespace: "deleted", PSP: "privileged", Via: []string{"User/system:serviceaccount:deleted:default (ClusterRoleBinding/crb-privileged)"}},
+		}, dangling)
+	}
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jlandowner/psp-util/pkg/utils"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// FieldManager is the field manager of server-side apply by psp-util
+	FieldManager = "psp-util"
+)
+
+// applyOptions returns the options of server-side apply taking over the fields from the other managers
+func applyOptions(dryRun []string) metav1.PatchOptions {
+	force := true
+	return metav1.PatchOptions{FieldManager: FieldManager, Force: &force, DryRun: dryRun}
+}
+
+// applyConfiguration returns the apply configuration of the object with the given fields.
+// The metadata other than name, namespace, labels, annotations and resourceVersion is dropped
+// so that psp-util does not own the fields populated by the API server
+func applyConfiguration(kind string, meta metav1.ObjectMeta, fields map[string]interface{}) ([]byte, error) {
+	metadata := map[string]interface{}{"name": meta.Name}
+	if meta.Namespace != "" {
+		metadata["namespace"] = meta.Namespace
+	}
+	if len(meta.Labels) > 0 {
+		metadata["labels"] = meta.Labels
+	}
+	if len(meta.Annotations) > 0 {
+		metadata["annotations"] = meta.Annotations
+	}
+	// resourceVersion makes the apply fail with Conflict when the object has been modified
+	if meta.ResourceVersion != "" {
+		metadata["resourceVersion"] = meta.ResourceVersion
+	}
+
+	config := map[string]interface{}{
+		"apiVersion": rbacv1.SchemeGroupVersion.String(),
+		"kind":       kind,
+		"metadata":   metadata,
+	}
+	for k, v := range fields {
+		config[k] = v
+	}
+	return json.Marshal(config)
+}
+
+// ApplyClusterRole creates or updates the ClusterRole by server-side apply
+func ApplyClusterRole(ctx context.Context, k8sclient kubernetes.Interface, clusterRole *rbacv1.ClusterRole, dryRun ...string) (*rbacv1.ClusterRole, error) {
+	fields := map[string]interface{}{"rules": clusterRole.Rules}
+	if clusterRole.AggregationRule != nil {
+		fields["aggregationRule"] = clusterRole.AggregationRule
+	}
+	data, err := applyConfiguration("ClusterRole", clusterRole.ObjectMeta, fields)
+	if err != nil {
+		return nil, err
+	}
+	return k8sclient.RbacV1().ClusterRoles().Patch(ctx, clusterRole.Name, types.ApplyPatchType, data, applyOptions(dryRun))
+}
+
+// ApplyClusterRoleBinding creates or updates the ClusterRoleBinding by server-side apply
+func ApplyClusterRoleBinding(ctx context.Context, k8sclient kubernetes.Interface, clusterRoleBinding *rbacv1.ClusterRoleBinding, dryRun ...string) (*rbacv1.ClusterRoleBinding, error) {
+	data, err := applyConfiguration("ClusterRoleBinding", clusterRoleBinding.ObjectMeta, bindingFields(clusterRoleBinding.RoleRef, clusterRoleBinding.Subjects))
+	if err != nil {
+		return nil, err
+	}
+	return k8sclient.RbacV1().ClusterRoleBindings().Patch(ctx, clusterRoleBinding.Name, types.ApplyPatchType, data, applyOptions(dryRun))
+}
+
+// ApplyRoleBinding creates or updates the RoleBinding by server-side apply
+func ApplyRoleBinding(ctx context.Context, k8sclient kubernetes.Interface, roleBinding *rbacv1.RoleBinding, dryRun ...string) (*rbacv1.RoleBinding, error) {
+	data, err := applyConfiguration("RoleBinding", roleBinding.ObjectMeta, bindingFields(roleBinding.RoleRef, roleBinding.Subjects))
+	if err != nil {
+		return nil, err
+	}
+	return k8sclient.RbacV1().RoleBindings(roleBinding.Namespace).Patch(ctx, roleBinding.Name, types.ApplyPatchType, data, applyOptions(dryRun))
+}
+
+// bindingFields returns the fields of the binding.
+// Empty subjects are sent explicitly to remove the subjects owned by psp-util
+func bindingFields(roleRef rbacv1.RoleRef, subjects []rbacv1.Subject) map[string]interface{} {
+	if subjects == nil {
+		subjects = []rbacv1.Subject{}
+	}
+	return map[string]interface{}{"roleRef": roleRef, "subjects": subjects}
+}
+
+// adoptionConfiguration returns the apply configuration which only has the managed annotation and labels of the PSP
+func adoptionConfiguration(kind, namespace, name, pspName string) ([]byte, error) {
+	meta := metav1.ObjectMeta{
+		Name:        name,
+		Namespace:   namespace,
+		Labels:      utils.GenerateLabels(pspName),
+		Annotations: utils.GenerateAnotations(pspName),
+	}
+	return applyConfiguration(kind, meta, nil)
+}
+
+// AdoptClusterRole adds the managed annotation and labels of the PSP to the existing ClusterRole by server-side apply
+func AdoptClusterRole(ctx context.Context, k8sclient kubernetes.Interface, name, pspName string, dryRun ...string) (*rbacv1.ClusterRole, error) {
+	data, err := adoptionConfiguration("ClusterRole", "", name, pspName)
+	if err != nil {
+		return nil, err
+	}
+	return k8sclient.RbacV1().ClusterRoles().Patch(ctx, name, types.ApplyPatchType, data, applyOptions(dryRun))
+}
+
+// AdoptClusterRoleBinding adds the managed annotation and labels of the PSP to the existing ClusterRoleBinding by server-side apply
+func AdoptClusterRoleBinding(ctx context.Context, k8sclient kubernetes.Interface, name, pspName string, dryRun ...string) (*rbacv1.ClusterRoleBinding, error) {
+	data, err := adoptionConfiguration("ClusterRoleBinding", "", name, pspName)
+	if err != nil {
+		return nil, err
+	}
+	return k8sclient.RbacV1().ClusterRoleBindings().Patch(ctx, name, types.ApplyPatchType, data, applyOptions(dryRun))
+}
+
+// AdoptRoleBinding adds the managed annotation and labels of the PSP to the existing RoleBinding by server-side apply
+func AdoptRoleBinding(ctx context.Context, k8sclient kubernetes.Interface, namespace, name, pspName string, dryRun ...string) (*rbacv1.RoleBinding, error) {
+	data, err := adoptionConfiguration("RoleBinding", namespace, name, pspName)
+	if err != nil {
+		return nil, err
+	}
+	return k8sclient.RbacV1().RoleBindings(namespace).Patch(ctx, name, types.ApplyPatchType, data, applyOptions(dryRun))
+}
@@ -22,6 +22,7 @@ import (
 	policyv1 "k8s.io/api/policy/v1beta1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -29,36 +30,97 @@ const (
 	APIGroup = "rbac.authorization.k8s.io"
 )
 
-func GetClusterRole(ctx context.Context, k8sclient *kubernetes.Clientset, name string) (*rbacv1.ClusterRole, error) {
+func GetClusterRole(ctx context.Context, k8sclient kubernetes.Interface, name string) (*rbacv1.ClusterRole, error) {
 	return k8sclient.RbacV1().ClusterRoles().Get(ctx, name, metav1.GetOptions{})
 }
 
-func CreateClusterRole(ctx context.Context, k8sclient *kubernetes.Clientset, clusterRole *rbacv1.ClusterRole) (*rbacv1.ClusterRole, error) {
-	return k8sclient.RbacV1().ClusterRoles().Create(ctx, clusterRole, metav1.CreateOptions{})
+func CreateClusterRole(ctx context.Context, k8sclient kubernetes.Interface, clusterRole *rbacv1.ClusterRole, dryRun ...string) (*rbacv1.ClusterRole, error) {
+	return k8sclient.RbacV1().ClusterRoles().Create(ctx, clusterRole, metav1.CreateOptions{DryRun: dryRun})
 }
 
-func DeleteClusterRole(ctx context.Context, k8sclient *kubernetes.Clientset, name string) error {
-	return k8sclient.RbacV1().ClusterRoles().Delete(ctx, name, metav1.DeleteOptions{})
+// DeleteClusterRole deletes the ClusterRole. The deletion is rejected with Conflict if the preconditions do not match
+func DeleteClusterRole(ctx context.Context, k8sclient kubernetes.Interface, name string, preconditions *metav1.Preconditions, dryRun ...string) error {
+	return k8sclient.RbacV1().ClusterRoles().Delete(ctx, name, metav1.DeleteOptions{Preconditions: preconditions, DryRun: dryRun})
 }
 
-func CreatePSPRole(ctx context.Context, k8sclient *kubernetes.Clientset, psp *policyv1.PodSecurityPolicy) (*rbacv1.ClusterRole, error) {
+func CreatePSPRole(ctx context.Context, k8sclient kubernetes.Interface, psp *policyv1.PodSecurityPolicy) (*rbacv1.ClusterRole, error) {
+	return CreateClusterRole(ctx, k8sclient, NewPSPRole(psp.Name))
+}
+
+// NewPSPRole returns a managed ClusterRole permitted to use the PSP
+func NewPSPRole(pspName string) *rbacv1.ClusterRole {
 	clusterRole := &rbacv1.ClusterRole{
+		TypeMeta: metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
 		Rules: []rbacv1.PolicyRule{
 			{
 				APIGroups:     []string{"policy"},
-				ResourceNames: []string{psp.Name},
+				ResourceNames: []string{pspName},
 				Resources:     []string{"podsecuritypolicies"},
 				Verbs:         []string{"use"},
 			},
 		},
 	}
-	clusterRole.SetName(utils.GenerateName(psp.Name))
-	clusterRole.SetAnnotations(utils.GenerateAnotations(psp.Name))
+	clusterRole.SetName(utils.GenerateName(pspName))
+	clusterRole.SetAnnotations(utils.GenerateAnotations(pspName))
+	clusterRole.SetLabels(utils.GenerateLabels(pspName))
+	return clusterRole
+}
+
+// ListManagedClusterRoles returns ClusterRoles generated for the PSP.
+// They are listed by the managed labels, and all the managed ClusterRoles are returned if pspName is empty.
+// The unlabeled ones generated by the older versions of psp-util are listed as well
+func ListManagedClusterRoles(ctx context.Context, k8sclient kubernetes.Interface, pspName string) ([]rbacv1.ClusterRole, error) {
+	managed := make([]rbacv1.ClusterRole, 0)
+	for _, opts := range managedListOptions(pspName) {
+		list, err := k8sclient.RbacV1().ClusterRoles().List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, cr := range list.Items {
+			if isManagedBy(cr.ObjectMeta, pspName) {
+				managed = append(managed, cr)
+			}
+		}
+	}
+	return managed, nil
+}
+
+// ManagedPSPName returns the PSP name of the managed resource.
+// The resources generated or adopted by psp-util have the annotation and the managed labels of the PSP regardless of the name.
+// The resources generated by the older versions of psp-util have the annotation and the generated name without the labels
+func ManagedPSPName(meta metav1.ObjectMeta) (string, bool) {
+	psp, ok := meta.Annotations[utils.AnnotaionKeyPSPName]
+	if !ok {
+		return "", false
+	}
+	if !utils.HasManagedLabels(meta.Labels, psp) && meta.Name != utils.GenerateName(psp) {
+		return "", false
+	}
+	return psp, true
+}
+
+// managedListOptions returns the options to list the managed resources of the PSP by the labels,
+// and the ones to list the unlabeled resources generated by the older versions of psp-util as a fallback.
+// The resources of all the PSPs are listed if pspName is empty
+func managedListOptions(pspName string) []metav1.ListOptions {
+	unlabeled := metav1.ListOptions{LabelSelector: utils.UnlabeledSelector()}
+	if pspName != "" {
+		unlabeled.FieldSelector = fields.OneTermEqualSelector("metadata.name", utils.GenerateName(pspName)).String()
+	}
+	return []metav1.ListOptions{{LabelSelector: utils.ManagedSelector(pspName)}, unlabeled}
+}
+
+// isManagedBy returns true if the resource is managed for the PSP, or for any PSP if pspName is empty
+func isManagedBy(meta metav1.ObjectMeta, pspName string) bool {
+	psp, ok := ManagedPSPName(meta)
+	return ok && (pspName == "" || psp == pspName)
+}
 
-	return CreateClusterRole(ctx, k8sclient, clusterRole)
+func ListClusterRoles(ctx context.Context, k8sclient kubernetes.Interface) (*rbacv1.ClusterRoleList, error) {
+	return k8sclient.RbacV1().ClusterRoles().List(ctx, metav1.ListOptions{})
 }
 
-func ListClusterRolesWithPSP(ctx context.Context, k8sclient *kubernetes.Clientset) (*rbacv1.ClusterRoleList, error) {
+func ListClusterRolesWithPSP(ctx context.Context, k8sclient kubernetes.Interface) (*rbacv1.ClusterRoleList, error) {
 	clusterRoleList, err := k8sclient.RbacV1().ClusterRoles().List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return nil, err
@@ -68,8 +130,7 @@ func ListClusterRolesWithPSP(ctx context.Context, k8sclient *kubernetes.Clientse
 	pspClusterRoleList.Items = make([]rbacv1.ClusterRole, 0)
 
 	for _, cr := range clusterRoleList.Items {
-		pspNames := ExtractPSPFromGenericRole(cr)
-		if len(pspNames) != 0 {
+		if GrantsPSP(cr) {
 			pspClusterRoleList.Items = append(pspClusterRoleList.Items, cr)
 		}
 	}
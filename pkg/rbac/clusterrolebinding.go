@@ -26,64 +26,98 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
-func GetClusterRoleBinding(ctx context.Context, k8sclient *kubernetes.Clientset, name string) (*rbacv1.ClusterRoleBinding, error) {
+func GetClusterRoleBinding(ctx context.Context, k8sclient kubernetes.Interface, name string) (*rbacv1.ClusterRoleBinding, error) {
 	return k8sclient.RbacV1().ClusterRoleBindings().Get(ctx, name, metav1.GetOptions{})
 }
 
-func CreateClusterRoleBinding(ctx context.Context, k8sclient *kubernetes.Clientset, clusterRoleBinding *rbacv1.ClusterRoleBinding) (*rbacv1.ClusterRoleBinding, error) {
-	return k8sclient.RbacV1().ClusterRoleBindings().Create(ctx, clusterRoleBinding, metav1.CreateOptions{})
+func CreateClusterRoleBinding(ctx context.Context, k8sclient kubernetes.Interface, clusterRoleBinding *rbacv1.ClusterRoleBinding, dryRun ...string) (*rbacv1.ClusterRoleBinding, error) {
+	return k8sclient.RbacV1().ClusterRoleBindings().Create(ctx, clusterRoleBinding, metav1.CreateOptions{FieldManager: FieldManager, DryRun: dryRun})
 }
 
-func UpdateClusterRoleBinding(ctx context.Context, k8sclient *kubernetes.Clientset, clusterRoleBinding *rbacv1.ClusterRoleBinding) (*rbacv1.ClusterRoleBinding, error) {
-	return k8sclient.RbacV1().ClusterRoleBindings().Update(ctx, clusterRoleBinding, metav1.UpdateOptions{})
+func ListClusterRoleBindings(ctx context.Context, k8sclient kubernetes.Interface) (*rbacv1.ClusterRoleBindingList, error) {
+	return k8sclient.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
 }
 
-func ListClusterRoleBindings(ctx context.Context, k8sclient *kubernetes.Clientset) (*rbacv1.ClusterRoleBindingList, error) {
-	return k8sclient.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+// ListManagedClusterRoleBindings returns ClusterRoleBindings generated for the PSP.
+// They are listed by the managed labels, and all the managed ClusterRoleBindings are returned if pspName is empty.
+// The unlabeled ones generated by the older versions of psp-util are listed as well
+func ListManagedClusterRoleBindings(ctx context.Context, k8sclient kubernetes.Interface, pspName string) ([]rbacv1.ClusterRoleBinding, error) {
+	managed := make([]rbacv1.ClusterRoleBinding, 0)
+	for _, opts := range managedListOptions(pspName) {
+		list, err := k8sclient.RbacV1().ClusterRoleBindings().List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, crb := range list.Items {
+			if isManagedBy(crb.ObjectMeta, pspName) {
+				managed = append(managed, crb)
+			}
+		}
+	}
+	return managed, nil
 }
 
-func DeleteClusterRoleBindings(ctx context.Context, k8sclient *kubernetes.Clientset, name string) error {
-	return k8sclient.RbacV1().ClusterRoleBindings().Delete(ctx, name, metav1.DeleteOptions{})
+// DeleteClusterRoleBindings deletes the ClusterRoleBinding. The deletion is rejected with Conflict if the preconditions do not match
+func DeleteClusterRoleBindings(ctx context.Context, k8sclient kubernetes.Interface, name string, preconditions *metav1.Preconditions, dryRun ...string) error {
+	return k8sclient.RbacV1().ClusterRoleBindings().Delete(ctx, name, metav1.DeleteOptions{Preconditions: preconditions, DryRun: dryRun})
 }
 
-func CreatePSPRoleBinding(ctx context.Context, k8sclient *kubernetes.Clientset, psp *policyv1.PodSecurityPolicy) (*rbacv1.ClusterRoleBinding, error) {
+func CreatePSPRoleBinding(ctx context.Context, k8sclient kubernetes.Interface, psp *policyv1.PodSecurityPolicy) (*rbacv1.ClusterRoleBinding, error) {
+	return CreateClusterRoleBinding(ctx, k8sclient, NewPSPRoleBinding(psp.Name))
+}
+
+// NewPSPRoleBinding returns a managed ClusterRoleBinding without subjects bound to the managed ClusterRole
+func NewPSPRoleBinding(pspName string) *rbacv1.ClusterRoleBinding {
 	clusterRoleBinding := &rbacv1.ClusterRoleBinding{
+		TypeMeta: metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRoleBinding"},
 		RoleRef: rbacv1.RoleRef{
 			APIGroup: APIGroup,
 			Kind:     "ClusterRole",
-			Name:     utils.GenerateName(psp.Name),
+			Name:     utils.GenerateName(pspName),
 		},
 	}
-	clusterRoleBinding.SetName(utils.GenerateName(psp.Name))
-	clusterRoleBinding.SetAnnotations(utils.GenerateAnotations(psp.Name))
-
-	return CreateClusterRoleBinding(ctx, k8sclient, clusterRoleBinding)
+	clusterRoleBinding.SetName(utils.GenerateName(pspName))
+	clusterRoleBinding.SetAnnotations(utils.GenerateAnotations(pspName))
+	clusterRoleBinding.SetLabels(utils.GenerateLabels(pspName))
+	return clusterRoleBinding
 }
 
 func AttachSubjectToClusterRoleBinding(clusterRoleBinding *rbacv1.ClusterRoleBinding, subject rbacv1.Subject) (hasGivenSubject bool) {
-	hasGivenSubject = false
-	for _, s := range clusterRoleBinding.Subjects {
+	clusterRoleBinding.Subjects, hasGivenSubject = AttachSubject(clusterRoleBinding.Subjects, subject)
+	return hasGivenSubject
+}
+
+func DetachSubjectToClusterRoleBinding(clusterRoleBinding *rbacv1.ClusterRoleBinding, subject rbacv1.Subject) (hasGivenSubject bool) {
+	clusterRoleBinding.Subjects, hasGivenSubject = DetachSubject(clusterRoleBinding.Subjects, subject)
+	return hasGivenSubject
+}
+
+// AttachSubject returns the subjects with the given subject appended unless it is already included.
+// The bool is true if the subjects already have the given subject
+func AttachSubject(subjects []rbacv1.Subject, subject rbacv1.Subject) ([]rbacv1.Subject, bool) {
+	hasGivenSubject := false
+	for _, s := range subjects {
 		if reflect.DeepEqual(s, subject) {
 			hasGivenSubject = true
 		}
 	}
 	if !hasGivenSubject {
-		clusterRoleBinding.Subjects = append(clusterRoleBinding.Subjects, subject)
+		subjects = append(subjects, subject)
 	}
-	return hasGivenSubject
+	return subjects, hasGivenSubject
 }
 
-func DetachSubjectToClusterRoleBinding(clusterRoleBinding *rbacv1.ClusterRoleBinding, subject rbacv1.Subject) (hasGivenSubject bool) {
-	hasGivenSubject = false
+// DetachSubject returns the subjects without the given subject.
+// The bool is true if the subjects had the given subject
+func DetachSubject(subjects []rbacv1.Subject, subject rbacv1.Subject) ([]rbacv1.Subject, bool) {
 	pos := -1
-	for i, s := range clusterRoleBinding.Subjects {
+	for i, s := range subjects {
 		if reflect.DeepEqual(s, subject) {
 			pos = i
 		}
 	}
-	if pos >= 0 {
-		hasGivenSubject = true
-		clusterRoleBinding.Subjects = append(clusterRoleBinding.Subjects[:pos], clusterRoleBinding.Subjects[pos+1:]...)
+	if pos < 0 {
+		return subjects, false
 	}
-	return hasGivenSubject
+	return append(subjects[:pos], subjects[pos+1:]...), true
 }
@@ -19,19 +19,11 @@ import (
 	rbacv1 "k8s.io/api/rbac/v1"
 )
 
+// ExtractPSPFromGenericRole returns the names of PSPs which the ClusterRole or Role grants use of by resourceNames
 func ExtractPSPFromGenericRole(r interface{}) []string {
-	var rules []rbacv1.PolicyRule
-
-	switch r := r.(type) {
-	case rbacv1.ClusterRole:
-		rules = r.Rules
-	case rbacv1.Role:
-		rules = r.Rules
-	}
-
 	pspNames := make([]string, 0)
-	for _, rule := range rules {
-		if hasAPIGroupsPolicy(rule) && hasResourcePSP(rule) && hasVerbUse(rule) {
+	for _, rule := range rulesOf(r) {
+		if isPSPUseRule(rule) {
 			for _, resourceName := range rule.ResourceNames {
 				pspNames = append(pspNames, resourceName)
 			}
@@ -40,9 +32,71 @@ func ExtractPSPFromGenericRole(r interface{}) []string {
 	return pspNames
 }
 
+// GrantsAllPSPs returns true if the ClusterRole or Role grants use of every PSP by a rule without resourceNames.
+// e.g. cluster-admin
+func GrantsAllPSPs(r interface{}) bool {
+	for _, rule := range rulesOf(r) {
+		if isPSPUseRule(rule) && len(rule.ResourceNames) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// IsWildcardPSPRole returns true if the ClusterRole or Role grants use of any PSP by a wildcard rule,
+// which has no resourceNames or "*" in apiGroups, resources or verbs
+func IsWildcardPSPRole(r interface{}) bool {
+	for _, rule := range rulesOf(r) {
+		if isPSPUseRule(rule) && isWildcardRule(rule) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsWildcardPSPGrant returns true if the ClusterRole or Role grants use of the PSP only by wildcard rules.
+// The PSP named by a literal rule is not granted by wildcard even if the role has the other wildcard rules
+func IsWildcardPSPGrant(r interface{}, pspName string) bool {
+	wildcard := false
+	for _, rule := range rulesOf(r) {
+		if !isPSPUseRule(rule) || (len(rule.ResourceNames) > 0 && !contains(rule.ResourceNames, pspName)) {
+			continue
+		}
+		if !isWildcardRule(rule) {
+			return false
+		}
+		wildcard = true
+	}
+	return wildcard
+}
+
+func isWildcardRule(rule rbacv1.PolicyRule) bool {
+	return len(rule.ResourceNames) == 0 ||
+		contains(rule.APIGroups, rbacv1.APIGroupAll) || contains(rule.Resources, rbacv1.ResourceAll) || contains(rule.Verbs, rbacv1.VerbAll)
+}
+
+// GrantsPSP returns true if the ClusterRole or Role grants use of any PSP
+func GrantsPSP(r interface{}) bool {
+	return len(ExtractPSPFromGenericRole(r)) > 0 || GrantsAllPSPs(r)
+}
+
+func rulesOf(r interface{}) []rbacv1.PolicyRule {
+	switch r := r.(type) {
+	case rbacv1.ClusterRole:
+		return r.Rules
+	case rbacv1.Role:
+		return r.Rules
+	}
+	return nil
+}
+
+func isPSPUseRule(rule rbacv1.PolicyRule) bool {
+	return hasAPIGroupsPolicy(rule) && hasResourcePSP(rule) && hasVerbUse(rule)
+}
+
 func hasAPIGroupsPolicy(rule rbacv1.PolicyRule) bool {
 	for _, apiGroups := range rule.APIGroups {
-		if apiGroups == "policy" || apiGroups == "extensions" {
+		if apiGroups == "policy" || apiGroups == "extensions" || apiGroups == rbacv1.APIGroupAll {
 			return true
 		}
 	}
@@ -51,7 +105,7 @@ func hasAPIGroupsPolicy(rule rbacv1.PolicyRule) bool {
 
 func hasResourcePSP(rule rbacv1.PolicyRule) bool {
 	for _, resource := range rule.Resources {
-		if resource == "podsecuritypolicies" {
+		if resource == "podsecuritypolicies" || resource == rbacv1.ResourceAll {
 			return true
 		}
 	}
@@ -60,7 +114,16 @@ func hasResourcePSP(rule rbacv1.PolicyRule) bool {
 
 func hasVerbUse(rule rbacv1.PolicyRule) bool {
 	for _, verb := range rule.Verbs {
-		if verb == "use" {
+		if verb == "use" || verb == rbacv1.VerbAll {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
 			return true
 		}
 	}
@@ -0,0 +1,35 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Preconditions returns the preconditions of deletion that the object is not recreated nor modified
+// since it was read. Empty UID and resourceVersion are not checked
+func Preconditions(meta metav1.ObjectMeta) *metav1.Preconditions {
+	pre := &metav1.Preconditions{}
+	if meta.UID != "" {
+		uid := meta.UID
+		pre.UID = &uid
+	}
+	if meta.ResourceVersion != "" {
+		rv := meta.ResourceVersion
+		pre.ResourceVersion = &rv
+	}
+	return pre
+}
@@ -0,0 +1,317 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jlandowner/psp-util/pkg/client/fake"
+	"github.com/stretchr/testify/assert"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+func TestExtractPSPFromGenericRole(t *testing.T) {
+	tests := []struct {
+		title  string
+		role   interface{}
+		expect []string
+	}{
+		{
+			title: "ClusterRole using PSPs",
+			role: rbacv1.ClusterRole{Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{"policy"}, Resources: []string{"podsecuritypolicies"}, ResourceNames: []string{"a", "b"}, Verbs: []string{"use"}},
+			}},
+			expect: []string{"a", "b"},
+		},
+		{
+			title: "Role using PSP in extensions",
+			role: rbacv1.Role{Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{"extensions"}, Resources: []string{"podsecuritypolicies"}, ResourceNames: []string{"a"}, Verbs: []string{"use"}},
+			}},
+			expect: []string{"a"},
+		},
+		{
+			title: "ClusterRole without use verb",
+			role: rbacv1.ClusterRole{Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{"policy"}, Resources: []string{"podsecuritypolicies"}, ResourceNames: []string{"a"}, Verbs: []string{"get"}},
+			}},
+			expect: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		assert.Equal(t, test.expect, ExtractPSPFromGenericRole(test.role))
+	}
+}
+
+func TestWildcardPSPRole(t *testing.T) {
+	tests := []struct {
+		title          string
+		role           interface{}
+		expectPSPs     []string
+		expectAll      bool
+		expectWildcard bool
+	}{
+		{
+			title: "named PSP",
+			role: rbacv1.ClusterRole{Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{"policy"}, Resources: []string{"podsecuritypolicies"}, ResourceNames: []string{"a"}, Verbs: []string{"use"}},
+			}},
+			expectPSPs: []string{"a"},
+		},
+		{
+			title: "cluster-admin",
+			role: rbacv1.ClusterRole{Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}},
+				{NonResourceURLs: []string{"*"}, Verbs: []string{"*"}},
+			}},
+			expectPSPs:     []string{},
+			expectAll:      true,
+			expectWildcard: true,
+		},
+		{
+			title: "no resourceNames",
+			role: rbacv1.Role{Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{"policy"}, Resources: []string{"podsecuritypolicies"}, Verbs: []string{"use"}},
+			}},
+			expectPSPs:     []string{},
+			expectAll:      true,
+			expectWildcard: true,
+		},
+		{
+			title: "wildcard verbs on named PSP",
+			role: rbacv1.ClusterRole{Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{"policy"}, Resources: []string{"podsecuritypolicies"}, ResourceNames: []string{"a"}, Verbs: []string{"*"}},
+			}},
+			expectPSPs:     []string{"a"},
+			expectWildcard: true,
+		},
+		{
+			title: "wildcard resources in another group",
+			role: rbacv1.ClusterRole{Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{"apps"}, Resources: []string{"*"}, Verbs: []string{"*"}},
+			}},
+			expectPSPs: []string{},
+		},
+		{
+			title: "named PSP and wildcard rule",
+			role: rbacv1.ClusterRole{Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{"policy"}, Resources: []string{"podsecuritypolicies"}, ResourceNames: []string{"a"}, Verbs: []string{"use"}},
+				{APIGroups: []string{"policy"}, Resources: []string{"*"}, Verbs: []string{"use"}},
+			}},
+			expectPSPs:     []string{"a"},
+			expectAll:      true,
+			expectWildcard: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		assert.Equal(t, test.expectPSPs, ExtractPSPFromGenericRole(test.role))
+		assert.Equal(t, test.expectAll, GrantsAllPSPs(test.role))
+		assert.Equal(t, test.expectWildcard, IsWildcardPSPRole(test.role))
+		assert.Equal(t, len(test.expectPSPs) > 0 || test.expectAll, GrantsPSP(test.role))
+	}
+}
+
+func TestIsWildcardPSPGrant(t *testing.T) {
+	named := rbacv1.PolicyRule{APIGroups: []string{"policy"}, Resources: []string{"podsecuritypolicies"}, ResourceNames: []string{"a"}, Verbs: []string{"use"}}
+	all := rbacv1.PolicyRule{APIGroups: []string{"policy"}, Resources: []string{"*"}, Verbs: []string{"use"}}
+	namedWildcardVerbs := rbacv1.PolicyRule{APIGroups: []string{"policy"}, Resources: []string{"podsecuritypolicies"}, ResourceNames: []string{"a"}, Verbs: []string{"*"}}
+
+	tests := []struct {
+		title  string
+		role   interface{}
+		psp    string
+		expect bool
+	}{
+		{
+			title:  "named PSP",
+			role:   rbacv1.ClusterRole{Rules: []rbacv1.PolicyRule{named}},
+			psp:    "a",
+			expect: false,
+		},
+		{
+			title:  "PSP granted by rule without resourceNames",
+			role:   rbacv1.Role{Rules: []rbacv1.PolicyRule{all}},
+			psp:    "a",
+			expect: true,
+		},
+		{
+			title:  "named PSP with wildcard verbs",
+			role:   rbacv1.ClusterRole{Rules: []rbacv1.PolicyRule{namedWildcardVerbs}},
+			psp:    "a",
+			expect: true,
+		},
+		{
+			title:  "named PSP in role with another wildcard rule",
+			role:   rbacv1.ClusterRole{Rules: []rbacv1.PolicyRule{named, all}},
+			psp:    "a",
+			expect: false,
+		},
+		{
+			title:  "other PSP in role with named and wildcard rules",
+			role:   rbacv1.ClusterRole{Rules: []rbacv1.PolicyRule{named, all}},
+			psp:    "b",
+			expect: true,
+		},
+		{
+			title:  "PSP not granted",
+			role:   rbacv1.ClusterRole{Rules: []rbacv1.PolicyRule{named}},
+			psp:    "b",
+			expect: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		assert.Equal(t, test.expect, IsWildcardPSPGrant(test.role, test.psp))
+	}
+}
+
+func TestAttachDetachSubject(t *testing.T) {
+	group := rbacv1.Subject{Kind: "Group", APIGroup: APIGroup, Name: "system:authenticated"}
+	sa := rbacv1.Subject{Kind: "ServiceAccount", Namespace: "team-a", Name: "app"}
+
+	crb := NewPSPRoleBinding("restricted")
+	assert.False(t, AttachSubjectToClusterRoleBinding(crb, group))
+	assert.True(t, AttachSubjectToClusterRoleBinding(crb, group))
+	assert.False(t, AttachSubjectToClusterRoleBinding(crb, sa))
+	assert.Equal(t, []rbacv1.Subject{group, sa}, crb.Subjects)
+	assert.True(t, DetachSubjectToClusterRoleBinding(crb, group))
+	assert.False(t, DetachSubjectToClusterRoleBinding(crb, group))
+	assert.Equal(t, []rbacv1.Subject{sa}, crb.Subjects)
+
+	subjects, hasGivenSubject := AttachSubject(nil, sa)
+	assert.False(t, hasGivenSubject)
+	subjects, hasGivenSubject = DetachSubject(subjects, sa)
+	assert.True(t, hasGivenSubject)
+	assert.Empty(t, subjects)
+}
+
+func TestListWithFakeClient(t *testing.T) {
+	ctx := context.Background()
+	managedRB := NewPSPNamespacedRoleBinding("restricted", "team-a")
+	otherRB := NewPSPNamespacedRoleBinding("privileged", "team-a")
+	unmanagedRB := &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "psp-util.restricted", Namespace: "team-b"}}
+	// generated by the older versions without the managed labels
+	unlabeledRB := NewPSPNamespacedRoleBinding("restricted", "team-d")
+	unlabeledRB.Labels = nil
+	view := &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}}
+
+	k8sclient := fake.NewClientset(NewPSPRole("restricted"), NewPSPRole("privileged"), view, managedRB, otherRB, unmanagedRB, unlabeledRB)
+
+	crs, err := ListClusterRolesWithPSP(ctx, k8sclient)
+	assert.NoError(t, err)
+	assert.Len(t, crs.Items, 2)
+
+	managedCRs, err := ListManagedClusterRoles(ctx, k8sclient, "restricted")
+	assert.NoError(t, err)
+	if assert.Len(t, managedCRs, 1) {
+		assert.Equal(t, "psp-util.restricted", managedCRs[0].Name)
+	}
+	managedCRs, err = ListManagedClusterRoles(ctx, k8sclient, "")
+	assert.NoError(t, err)
+	assert.Len(t, managedCRs, 2)
+
+	// the unlabeled one is listed as a fallback
+	allRBs, err := ListManagedRoleBindings(ctx, k8sclient, "")
+	assert.NoError(t, err)
+	assert.Len(t, allRBs, 3)
+
+	rbs, err := ListManagedRoleBindings(ctx, k8sclient, "restricted")
+	assert.NoError(t, err)
+	if assert.Len(t, rbs, 2) {
+		assert.Equal(t, "team-a", rbs[0].Namespace)
+		assert.Equal(t, "team-d", rbs[1].Namespace)
+	}
+
+	_, err = CreateRoleBinding(ctx, k8sclient, NewPSPNamespacedRoleBinding("restricted", "team-c"))
+	assert.NoError(t, err)
+	rbs, err = ListManagedRoleBindings(ctx, k8sclient, "restricted")
+	assert.NoError(t, err)
+	assert.Len(t, rbs, 3)
+
+	assert.NoError(t, DeleteRoleBinding(ctx, k8sclient, "team-a", "psp-util.restricted", nil))
+	_, err = GetRoleBinding(ctx, k8sclient, "team-a", "psp-util.restricted")
+	assert.Error(t, err)
+}
+
+func TestApplyWithFakeClient(t *testing.T) {
+	ctx := context.Background()
+	group := rbacv1.Subject{Kind: "Group", APIGroup: APIGroup, Name: "system:authenticated"}
+	user := rbacv1.Subject{Kind: "User", APIGroup: APIGroup, Name: "alice"}
+
+	edited := NewPSPRoleBinding("restricted")
+	edited.Labels = map[string]string{"team": "a"}
+	edited.Subjects = []rbacv1.Subject{group}
+	handWritten := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "hand-written", Labels: map[string]string{"team": "b"}},
+		RoleRef:    rbacv1.RoleRef{APIGroup: APIGroup, Kind: "ClusterRole", Name: "psp:restricted"},
+		Subjects:   []rbacv1.Subject{user},
+	}
+
+	tests := []struct {
+		title             string
+		apply             func(k8sclient kubernetes.Interface) (*rbacv1.ClusterRoleBinding, error)
+		expectSubjects    []rbacv1.Subject
+		expectLabels      map[string]string
+		expectAnnotations map[string]string
+	}{
+		{
+			title: "create",
+			apply: func(k8sclient kubernetes.Interface) (*rbacv1.ClusterRoleBinding, error) {
+				crb := NewPSPRoleBinding("privileged")
+				crb.Subjects = []rbacv1.Subject{user}
+				return ApplyClusterRoleBinding(ctx, k8sclient, crb)
+			},
+			expectSubjects: []rbacv1.Subject{user},
+			expectLabels: map[string]string{
+				"app.kubernetes.io/managed-by":    "psp-util",
+				"psp-util.k8s.jlandowner.com/psp": "privileged",
+			},
+			expectAnnotations: map[string]string{"psp-util.k8s.jlandowner.com/psp": "privileged"},
+		},
+		{
+			title: "update keeps the labels added by the others",
+			apply: func(k8sclient kubernetes.Interface) (*rbacv1.ClusterRoleBinding, error) {
+				return ApplyClusterRoleBinding(ctx, k8sclient, NewPSPRoleBinding("restricted"))
+			},
+			expectSubjects: []rbacv1.Subject{},
+			expectLabels: map[string]string{
+				"team":                            "a",
+				"app.kubernetes.io/managed-by":    "psp-util",
+				"psp-util.k8s.jlandowner.com/psp": "restricted",
+			},
+			expectAnnotations: map[string]string{"psp-util.k8s.jlandowner.com/psp": "restricted"},
+		},
+		{
+			title: "adopt only adds the annotation and labels",
+			apply: func(k8sclient kubernetes.Interface) (*rbacv1.ClusterRoleBinding, error) {
+				return AdoptClusterRoleBinding(ctx, k8sclient, "hand-written", "restricted")
+			},
+			expectSubjects: []rbacv1.Subject{user},
+			expectLabels: map[string]string{
+				"team":                            "b",
+				"app.kubernetes.io/managed-by":    "psp-util",
+				"psp-util.k8s.jlandowner.com/psp": "restricted",
+			},
+			expectAnnotations: map[string]string{"psp-util.k8s.jlandowner.com/psp": "restricted"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		k8sclient := fake.NewClientset(edited.DeepCopy(), handWritten.DeepCopy())
+		applied, err := test.apply(k8sclient)
+		assert.NoError(t, err)
+
+		crb, err := GetClusterRoleBinding(ctx, k8sclient, applied.Name)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expectSubjects, crb.Subjects)
+		assert.Equal(t, test.expectLabels, crb.Labels)
+		assert.Equal(t, test.expectAnnotations, crb.Annotations)
+	}
+}
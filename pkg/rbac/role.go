@@ -23,7 +23,11 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
-func ListRolesWithPSP(ctx context.Context, k8sclient *kubernetes.Clientset) (*rbacv1.RoleList, error) {
+func ListRoles(ctx context.Context, k8sclient kubernetes.Interface) (*rbacv1.RoleList, error) {
+	return k8sclient.RbacV1().Roles("").List(ctx, metav1.ListOptions{})
+}
+
+func ListRolesWithPSP(ctx context.Context, k8sclient kubernetes.Interface) (*rbacv1.RoleList, error) {
 	roleList, err := k8sclient.RbacV1().Roles("").List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return nil, err
@@ -33,14 +37,13 @@ func ListRolesWithPSP(ctx context.Context, k8sclient *kubernetes.Clientset) (*rb
 	pspRoleList.Items = make([]rbacv1.Role, 0)
 
 	for _, r := range roleList.Items {
-		pspNames := ExtractPSPFromGenericRole(r)
-		if len(pspNames) != 0 {
+		if GrantsPSP(r) {
 			pspRoleList.Items = append(pspRoleList.Items, r)
 		}
 	}
 	return pspRoleList, nil
 }
 
-func ListRoleBindings(ctx context.Context, k8sclient *kubernetes.Clientset) (*rbacv1.RoleBindingList, error) {
+func ListRoleBindings(ctx context.Context, k8sclient kubernetes.Interface) (*rbacv1.RoleBindingList, error) {
 	return k8sclient.RbacV1().RoleBindings("").List(ctx, metav1.ListOptions{})
 }
@@ -0,0 +1,74 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"context"
+
+	"github.com/jlandowner/psp-util/pkg/utils"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+func GetRoleBinding(ctx context.Context, k8sclient kubernetes.Interface, namespace, name string) (*rbacv1.RoleBinding, error) {
+	return k8sclient.RbacV1().RoleBindings(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+func CreateRoleBinding(ctx context.Context, k8sclient kubernetes.Interface, roleBinding *rbacv1.RoleBinding, dryRun ...string) (*rbacv1.RoleBinding, error) {
+	return k8sclient.RbacV1().RoleBindings(roleBinding.Namespace).Create(ctx, roleBinding, metav1.CreateOptions{FieldManager: FieldManager, DryRun: dryRun})
+}
+
+// DeleteRoleBinding deletes the RoleBinding. The deletion is rejected with Conflict if the preconditions do not match
+func DeleteRoleBinding(ctx context.Context, k8sclient kubernetes.Interface, namespace, name string, preconditions *metav1.Preconditions, dryRun ...string) error {
+	return k8sclient.RbacV1().RoleBindings(namespace).Delete(ctx, name, metav1.DeleteOptions{Preconditions: preconditions, DryRun: dryRun})
+}
+
+// ListManagedRoleBindings returns RoleBindings in all namespaces generated for the PSP.
+// They are listed by the managed labels, and all the managed RoleBindings are returned if pspName is empty.
+// The unlabeled ones generated by the older versions of psp-util are listed as well
+func ListManagedRoleBindings(ctx context.Context, k8sclient kubernetes.Interface, pspName string) ([]rbacv1.RoleBinding, error) {
+	managed := make([]rbacv1.RoleBinding, 0)
+	for _, opts := range managedListOptions(pspName) {
+		list, err := k8sclient.RbacV1().RoleBindings("").List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, rb := range list.Items {
+			if isManagedBy(rb.ObjectMeta, pspName) {
+				managed = append(managed, rb)
+			}
+		}
+	}
+	return managed, nil
+}
+
+// NewPSPNamespacedRoleBinding returns a managed RoleBinding without subjects bound to the managed ClusterRole
+func NewPSPNamespacedRoleBinding(pspName, namespace string) *rbacv1.RoleBinding {
+	roleBinding := &rbacv1.RoleBinding{
+		TypeMeta: metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "RoleBinding"},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: APIGroup,
+			Kind:     "ClusterRole",
+			Name:     utils.GenerateName(pspName),
+		},
+	}
+	roleBinding.SetName(utils.GenerateName(pspName))
+	roleBinding.SetNamespace(namespace)
+	roleBinding.SetAnnotations(utils.GenerateAnotations(pspName))
+	roleBinding.SetLabels(utils.GenerateLabels(pspName))
+	return roleBinding
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package relations
+
+import (
+	"sort"
+
+	"github.com/jlandowner/psp-util/pkg/rbac"
+	"github.com/jlandowner/psp-util/pkg/utils"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// aggregation resolves PSPs granted to aggregated ClusterRoles through their component ClusterRoles
+type aggregation struct {
+	// components is the sorted component ClusterRole names selected by the aggregationRule of each ClusterRole
+	components map[string][]string
+	// all is true for the ClusterRoles whose own rules grant all PSPs
+	all map[string]bool
+	// names is the PSP names granted by the own rules of each ClusterRole
+	names map[string][]string
+	// roles is the ClusterRoles by name to tell whether their own rules grant each PSP by wildcard
+	roles map[string]rbacv1.ClusterRole
+}
+
+func newAggregation(crs []rbacv1.ClusterRole) *aggregation {
+	a := &aggregation{
+		components: make(map[string][]string),
+		all:        make(map[string]bool),
+		names:      make(map[string][]string),
+		roles:      make(map[string]rbacv1.ClusterRole),
+	}
+
+	for _, cr := range crs {
+		a.all[cr.Name] = rbac.GrantsAllPSPs(cr)
+		a.names[cr.Name] = rbac.ExtractPSPFromGenericRole(cr)
+		a.roles[cr.Name] = cr
+
+		if cr.AggregationRule == nil {
+			continue
+		}
+		components := make([]string, 0)
+		for i := range cr.AggregationRule.ClusterRoleSelectors {
+			selector, err := metav1.LabelSelectorAsSelector(&cr.AggregationRule.ClusterRoleSelectors[i])
+			if err != nil || selector.Empty() {
+				continue
+			}
+			for _, c := range crs {
+				if c.Name != cr.Name && selector.Matches(labels.Set(c.Labels)) {
+					components = append(components, c.Name)
+				}
+			}
+		}
+		components = utils.UniqueStrings(components)
+		sort.Strings(components)
+		a.components[cr.Name] = components
+	}
+	return a
+}
+
+// grants returns whether the ClusterRole grants the PSP by its own rules or through its components.
+// path is the component ClusterRole names from the aggregate down to the one granting the PSP by its own rules,
+// which is empty if the ClusterRole grants it directly. wildcard is true if the granting rule is a wildcard rule
+func (a *aggregation) grants(name, pspName string) (path []string, wildcard, ok bool) {
+	return a.resolve(name, pspName, make(map[string]bool))
+}
+
+func (a *aggregation) resolve(name, pspName string, visited map[string]bool) ([]string, bool, bool) {
+	if visited[name] {
+		return nil, false, false
+	}
+	visited[name] = true
+
+	// the aggregated rules are also copied into the aggregate by the controller,
+	// so prefer the components to show where the PSP actually comes from
+	for _, c := range a.components[name] {
+		if path, wildcard, ok := a.resolve(c, pspName, visited); ok {
+			return append([]string{c}, path...), wildcard, true
+		}
+	}
+
+	if a.all[name] {
+		return []string{}, rbac.IsWildcardPSPGrant(a.roles[name], pspName), true
+	}
+	for _, n := range a.names[name] {
+		if n == pspName {
+			return []string{}, rbac.IsWildcardPSPGrant(a.roles[name], pspName), true
+		}
+	}
+	return nil, false, false
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package relations
+
+import (
+	"github.com/jlandowner/psp-util/pkg/manifests"
+	"github.com/jlandowner/psp-util/pkg/rbac"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// DanglingReferences are the RBAC resources referring to the resources which do not exist
+type DanglingReferences struct {
+	// Roles are the ClusterRoles and Roles granting use of PSPs which do not exist
+	Roles []DanglingRole `json:"roles"`
+	// Bindings are the ClusterRoleBindings and RoleBindings whose roleRef targets a role which does not exist
+	Bindings []DanglingBinding `json:"bindings"`
+}
+
+type DanglingRole struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	// PSPs are the names of PSPs not found
+	PSPs []string `json:"psps"`
+}
+
+type DanglingBinding struct {
+	Kind      string         `json:"kind"`
+	Name      string         `json:"name"`
+	Namespace string         `json:"namespace,omitempty"`
+	RoleRef   rbacv1.RoleRef `json:"roleRef"`
+}
+
+// IsEmpty returns true if there are no dangling references
+func (d *DanglingReferences) IsEmpty() bool {
+	return len(d.Roles) == 0 && len(d.Bindings) == 0
+}
+
+// Count returns the number of dangling references
+func (d *DanglingReferences) Count() int {
+	return len(d.Roles) + len(d.Bindings)
+}
+
+// GetDanglingReferences returns the roles granting use of missing PSPs and the bindings to missing roles.
+// The objects must have all the ClusterRoles and Roles, not only the ones granting PSPs
+func GetDanglingReferences(objs *manifests.Objects) *DanglingReferences {
+	d := &DanglingReferences{
+		Roles:    make([]DanglingRole, 0),
+		Bindings: make([]DanglingBinding, 0),
+	}
+
+	psps := make(map[string]bool)
+	for _, psp := range objs.PodSecurityPolicies.Items {
+		psps[psp.Name] = true
+	}
+	missingPSPs := func(pspNames []string) []string {
+		missing := make([]string, 0)
+		for _, name := range pspNames {
+			if !psps[name] {
+				missing = append(missing, name)
+			}
+		}
+		return missing
+	}
+
+	clusterRoles := make(map[string]bool)
+	for _, cr := range objs.ClusterRoles.Items {
+		clusterRoles[cr.Name] = true
+		if missing := missingPSPs(rbac.ExtractPSPFromGenericRole(cr)); len(missing) > 0 {
+			d.Roles = append(d.Roles, DanglingRole{Kind: "ClusterRole", Name: cr.Name, PSPs: missing})
+		}
+	}
+	roles := make(map[string]bool)
+	for _, r := range objs.Roles.Items {
+		roles[r.Namespace+"/"+r.Name] = true
+		if missing := missingPSPs(rbac.ExtractPSPFromGenericRole(r)); len(missing) > 0 {
+			d.Roles = append(d.Roles, DanglingRole{Kind: "Role", Name: r.Name, Namespace: r.Namespace, PSPs: missing})
+		}
+	}
+
+	for _, crb := range objs.ClusterRoleBindings.Items {
+		if crb.RoleRef.Kind == "ClusterRole" && !clusterRoles[crb.RoleRef.Name] {
+			d.Bindings = append(d.Bindings, DanglingBinding{Kind: "ClusterRoleBinding", Name: crb.Name, RoleRef: crb.RoleRef})
+		}
+	}
+	for _, rb := range objs.RoleBindings.Items {
+		if (rb.RoleRef.Kind == "ClusterRole" && !clusterRoles[rb.RoleRef.Name]) ||
+			(rb.RoleRef.Kind == "Role" && !roles[rb.Namespace+"/"+rb.RoleRef.Name]) {
+			d.Bindings = append(d.Bindings, DanglingBinding{Kind: "RoleBinding", Name: rb.Name, Namespace: rb.Namespace, RoleRef: rb.RoleRef})
+		}
+	}
+	return d
+}
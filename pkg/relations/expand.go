@@ -0,0 +1,91 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package relations
+
+import (
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// SubjectExpander enumerates the ServiceAccounts implicitly included in the ServiceAccounts groups
+type SubjectExpander struct {
+	serviceAccounts []rbacv1.Subject
+}
+
+// NewSubjectExpander returns SubjectExpander with the ServiceAccounts existing in cluster or manifests
+func NewSubjectExpander(sas []corev1.ServiceAccount) *SubjectExpander {
+	subs := make([]rbacv1.Subject, 0, len(sas))
+	for _, sa := range sas {
+		subs = append(subs, rbacv1.Subject{Kind: "ServiceAccount", Namespace: sa.Namespace, Name: sa.Name})
+	}
+	sort.SliceStable(subs, func(i, j int) bool {
+		return SubjectKey(subs[i]) < SubjectKey(subs[j])
+	})
+	return &SubjectExpander{serviceAccounts: subs}
+}
+
+// Expand returns the ServiceAccounts included in the group system:serviceaccounts or system:serviceaccounts:NAMESPACE.
+// ok is false if the subject is not such a group
+func (e *SubjectExpander) Expand(sub rbacv1.Subject) (subs []rbacv1.Subject, ok bool) {
+	if sub.Kind != "Group" {
+		return nil, false
+	}
+
+	subs = make([]rbacv1.Subject, 0)
+	switch {
+	case sub.Name == GroupServiceAccounts:
+		subs = append(subs, e.serviceAccounts...)
+	case strings.HasPrefix(sub.Name, GroupServiceAccounts+":"):
+		ns := strings.TrimPrefix(sub.Name, GroupServiceAccounts+":")
+		for _, sa := range e.serviceAccounts {
+			if sa.Namespace == ns {
+				subs = append(subs, sa)
+			}
+		}
+	default:
+		return nil, false
+	}
+	return subs, true
+}
+
+// ExpandGrants returns the grants with the ServiceAccounts included in the groups and the warnings
+func (e *SubjectExpander) ExpandGrants(grants []SubjectGrant) []SubjectGrant {
+	expanded := make([]SubjectGrant, len(grants))
+	for i, g := range grants {
+		if subs, ok := e.Expand(g.Subject); ok {
+			g.Expanded = subs
+		}
+		g.Warning = ImplicitGroupWarning(g.Subject)
+		expanded[i] = g
+	}
+	return expanded
+}
+
+// ImplicitGroupWarning returns a warning if the subject is every authenticated or unauthenticated user, otherwise empty
+func ImplicitGroupWarning(sub rbacv1.Subject) string {
+	switch {
+	case sub.Kind == "Group" && sub.Name == GroupAuthenticated:
+		return "granted to all authenticated users"
+	case sub.Kind == "Group" && sub.Name == GroupUnauthenticated:
+		return "granted to all unauthenticated users"
+	case sub.Kind == "User" && sub.Name == UserAnonymous:
+		return "granted to anonymous users"
+	}
+	return ""
+}
@@ -19,6 +19,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/jlandowner/psp-util/pkg/manifests"
 	"github.com/jlandowner/psp-util/pkg/policy"
 	"github.com/jlandowner/psp-util/pkg/rbac"
 	"github.com/jlandowner/psp-util/pkg/utils"
@@ -36,31 +37,46 @@ type RelationalPodSecurityPolicy struct {
 type RelationalClusterRole struct {
 	ClusterRoleBindings []*rbacv1.ClusterRoleBinding
 	RoleBindings        []*rbacv1.RoleBinding
+	// Wildcard is true if the PSP is granted by a wildcard rule. See rbac.IsWildcardPSPGrant
+	Wildcard bool
+	// AggregationPath is the component ClusterRoles through which the aggregated ClusterRole grants the PSP,
+	// from the one selected by its aggregationRule down to the one granting the PSP by its own rules
+	AggregationPath []string
 	rbacv1.ClusterRole
 }
 
 type RelationalRole struct {
 	RoleBindings []*rbacv1.RoleBinding
+	// Wildcard is true if the PSP is granted by a wildcard rule. See rbac.IsWildcardPSPGrant
+	Wildcard bool
 	rbacv1.Role
 }
 
 func (r RelationalClusterRole) IsManaged() bool {
-	_, ok := r.Annotations[utils.AnnotaionKeyPSPName]
-	return ok
+	return utils.IsManaged(r.Annotations)
 }
 
-func GetRelationalPSPs(ctx context.Context, k8sclient *kubernetes.Clientset) ([]RelationalPodSecurityPolicy, error) {
+func GetRelationalPSPs(ctx context.Context, k8sclient kubernetes.Interface) ([]RelationalPodSecurityPolicy, error) {
+	objs, err := GetObjects(ctx, k8sclient)
+	if err != nil {
+		return nil, err
+	}
+	return GetRelationalPSPsFromManifests(objs), nil
+}
+
+// GetObjects returns PSPs and all the RBAC resources in cluster
+func GetObjects(ctx context.Context, k8sclient kubernetes.Interface) (*manifests.Objects, error) {
 	psps, err := policy.ListPSP(ctx, k8sclient)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to list PSP: %v", err.Error())
 	}
 
-	crs, err := rbac.ListClusterRolesWithPSP(ctx, k8sclient)
+	crs, err := rbac.ListClusterRoles(ctx, k8sclient)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to list ClusterRole: %v", err.Error())
 	}
 
-	rs, err := rbac.ListRolesWithPSP(ctx, k8sclient)
+	rs, err := rbac.ListRoles(ctx, k8sclient)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to list Role: %v", err.Error())
 	}
@@ -75,9 +91,18 @@ func GetRelationalPSPs(ctx context.Context, k8sclient *kubernetes.Clientset) ([]
 		return nil, fmt.Errorf("Failed to list RoleBindings: %v", err.Error())
 	}
 
-	rpsps := generateRelationalPSP(psps, crs, crbs, rs, rbs)
+	return &manifests.Objects{
+		PodSecurityPolicies: *psps,
+		ClusterRoles:        *crs,
+		ClusterRoleBindings: *crbs,
+		Roles:               *rs,
+		RoleBindings:        *rbs,
+	}, nil
+}
 
-	return rpsps, nil
+// GetRelationalPSPsFromManifests returns relational PSPs of the objects loaded from manifest files
+func GetRelationalPSPsFromManifests(objs *manifests.Objects) []RelationalPodSecurityPolicy {
+	return generateRelationalPSP(&objs.PodSecurityPolicies, &objs.ClusterRoles, &objs.ClusterRoleBindings, &objs.Roles, &objs.RoleBindings)
 }
 
 func generateRelationalPSP(psps *policyv1.PodSecurityPolicyList,
@@ -93,43 +118,59 @@ func generateRelationalPSP(psps *policyv1.PodSecurityPolicyList,
 		rpspByName[rpsp.Name] = &rpsps[i]
 	}
 
+	// pspNamesOf returns the PSPs granted by the role. The role granting all PSPs is related to every PSP
+	pspNamesOf := func(r interface{}) []string {
+		if rbac.GrantsAllPSPs(r) {
+			names := make([]string, len(psps.Items))
+			for i, psp := range psps.Items {
+				names[i] = psp.Name
+			}
+			return names
+		}
+		return utils.UniqueStrings(rbac.ExtractPSPFromGenericRole(r))
+	}
+
 	// build PSP to RelationalClusterRole references
-	crByName := make(map[string]*RelationalClusterRole)
+	// a ClusterRole granting multiple PSPs has a RelationalClusterRole for each PSP
+	// an aggregated ClusterRole grants the PSPs granted by the components selected by its aggregationRule
+	agg := newAggregation(crs.Items)
+	crByName := make(map[string][]*RelationalClusterRole)
 	for _, cr := range crs.Items {
-		pspNames := rbac.ExtractPSPFromGenericRole(cr)
-		for _, pspName := range pspNames {
-			if rpsp, ok := rpspByName[pspName]; ok {
-				rcr := &RelationalClusterRole{ClusterRole: cr}
-				rpsp.ClusterRoles = append(rpsp.ClusterRoles, rcr)
-				crByName[cr.Name] = rcr
+		for i, psp := range psps.Items {
+			path, wildcard, ok := agg.grants(cr.Name, psp.Name)
+			if !ok {
+				continue
+			}
+			rcr := &RelationalClusterRole{ClusterRole: cr, Wildcard: wildcard}
+			if len(path) > 0 {
+				rcr.AggregationPath = path
 			}
+			rpsps[i].ClusterRoles = append(rpsps[i].ClusterRoles, rcr)
+			crByName[cr.Name] = append(crByName[cr.Name], rcr)
 		}
 	}
 
 	// build PSP to RelationalRole references
-	rByName := make(map[string]*RelationalRole)
+	rByName := make(map[string][]*RelationalRole)
 	for _, r := range rs.Items {
-		pspNames := rbac.ExtractPSPFromGenericRole(r)
-		for _, pspName := range pspNames {
+		for _, pspName := range pspNamesOf(r) {
 			if rpsp, ok := rpspByName[pspName]; ok {
-				rr := &RelationalRole{Role: r}
+				rr := &RelationalRole{Role: r, Wildcard: rbac.IsWildcardPSPGrant(r, pspName)}
 				rpsp.Roles = append(rpsp.Roles, rr)
-				rByName[r.Name] = rr
+				key := r.Namespace + "/" + r.Name
+				rByName[key] = append(rByName[key], rr)
 			}
 		}
 	}
 
 	// build RelationalClusterRole to ClusterRoleBindings references
 	for i, crb := range crbs.Items {
-		cr, ok := crByName[crb.RoleRef.Name]
-		if !ok {
-			continue
-		}
 		if crb.RoleRef.APIGroup != "rbac.authorization.k8s.io" || crb.RoleRef.Kind != "ClusterRole" {
 			continue
 		}
-
-		cr.ClusterRoleBindings = append(cr.ClusterRoleBindings, &crbs.Items[i])
+		for _, cr := range crByName[crb.RoleRef.Name] {
+			cr.ClusterRoleBindings = append(cr.ClusterRoleBindings, &crbs.Items[i])
+		}
 	}
 
 	// build RelationalRole and RelationalClusterRole to RoleBindings references
@@ -140,19 +181,25 @@ func generateRelationalPSP(psps *policyv1.PodSecurityPolicyList,
 
 		switch rb.RoleRef.Kind {
 		case "ClusterRole":
-			cr, ok := crByName[rb.RoleRef.Name]
-			if !ok {
-				continue
+			for _, cr := range crByName[rb.RoleRef.Name] {
+				cr.RoleBindings = append(cr.RoleBindings, &rbs.Items[i])
 			}
-			cr.RoleBindings = append(cr.RoleBindings, &rbs.Items[i])
 		case "Role":
-			r, ok := rByName[rb.RoleRef.Name]
-			if !ok {
-				continue
+			for _, r := range rByName[rb.Namespace+"/"+rb.RoleRef.Name] {
+				r.RoleBindings = append(r.RoleBindings, &rbs.Items[i])
 			}
-			r.RoleBindings = append(r.RoleBindings, &rbs.Items[i])
 		}
 	}
 
 	return rpsps
 }
+
+// FindRelationalPSP returns the relational PSP of the given name
+func FindRelationalPSP(psps []RelationalPodSecurityPolicy, name string) (*RelationalPodSecurityPolicy, bool) {
+	for i := range psps {
+		if psps[i].Name == name {
+			return &psps[i], true
+		}
+	}
+	return nil, false
+}
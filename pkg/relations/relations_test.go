@@ -0,0 +1,278 @@
+package relations
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jlandowner/psp-util/pkg/client/fake"
+	"github.com/jlandowner/psp-util/pkg/manifests"
+	"github.com/stretchr/testify/assert"
+	policyv1 "k8s.io/api/policy/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestGetRelationalPSPs(t *testing.T) {
+	useRule := func(psp string) []rbacv1.PolicyRule {
+		return []rbacv1.PolicyRule{{APIGroups: []string{"policy"}, Resources: []string{"podsecuritypolicies"}, ResourceNames: []string{psp}, Verbs: []string{"use"}}}
+	}
+	roleRef := func(kind, name string) rbacv1.RoleRef {
+		return rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: kind, Name: name}
+	}
+	group := rbacv1.Subject{Kind: "Group", APIGroup: "rbac.authorization.k8s.io", Name: "system:authenticated"}
+	sa := rbacv1.Subject{Kind: "ServiceAccount", Namespace: "team-a", Name: "app"}
+	masters := rbacv1.Subject{Kind: "Group", APIGroup: "rbac.authorization.k8s.io", Name: "system:masters"}
+
+	objs := []runtime.Object{
+		&policyv1.PodSecurityPolicy{ObjectMeta: metav1.ObjectMeta{Name: "privileged"}},
+		&policyv1.PodSecurityPolicy{ObjectMeta: metav1.ObjectMeta{Name: "restricted"}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "use-restricted"}, Rules: useRule("restricted")},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}, Rules: []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}}}},
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "use-privileged", Namespace: "team-a"}, Rules: useRule("privileged")},
+		&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "crb"}, RoleRef: roleRef("ClusterRole", "use-restricted"), Subjects: []rbacv1.Subject{group}},
+		&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "view"}, RoleRef: roleRef("ClusterRole", "view"), Subjects: []rbacv1.Subject{group}},
+		&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "rb-cr", Namespace: "team-a"}, RoleRef: roleRef("ClusterRole", "use-restricted"), Subjects: []rbacv1.Subject{sa}},
+		&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "rb-r", Namespace: "team-a"}, RoleRef: roleRef("Role", "use-privileged"), Subjects: []rbacv1.Subject{sa}},
+		// the Role of the same name does not exist in team-b
+		&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "rb-r", Namespace: "team-b"}, RoleRef: roleRef("Role", "use-privileged"), Subjects: []rbacv1.Subject{sa}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"}, Rules: []rbacv1.PolicyRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}}},
+		&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"}, RoleRef: roleRef("ClusterRole", "cluster-admin"), Subjects: []rbacv1.Subject{masters}},
+		// grants restricted by the literal rule and the others by the wildcard rule
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "use-any", Namespace: "team-b"}, Rules: append(useRule("restricted"),
+			rbacv1.PolicyRule{APIGroups: []string{"policy"}, Resources: []string{"*"}, Verbs: []string{"use"}})},
+	}
+
+	psps, err := GetRelationalPSPs(context.Background(), fake.NewClientset(objs...))
+	assert.NoError(t, err)
+	assert.Len(t, psps, 2)
+
+	tests := []struct {
+		title              string
+		psp                string
+		expectClusterRoles []string
+		expectCRBs         []string
+		expectRoles        []string
+		expectRoleBindings []string
+		expectSubjects     []rbacv1.Subject
+	}{
+		{
+			title:              "PSP granted by ClusterRoleBinding and RoleBinding to ClusterRole",
+			psp:                "restricted",
+			expectClusterRoles: []string{"use-restricted", "cluster-admin (wildcard)"},
+			expectCRBs:         []string{"crb", "cluster-admin"},
+			expectRoles:        []string{"team-b/use-any"},
+			expectRoleBindings: []string{"team-a/rb-cr"},
+			expectSubjects:     []rbacv1.Subject{group, masters, sa},
+		},
+		{
+			title:              "PSP granted by RoleBinding to Role",
+			psp:                "privileged",
+			expectClusterRoles: []string{"cluster-admin (wildcard)"},
+			expectCRBs:         []string{"cluster-admin"},
+			expectRoles:        []string{"team-a/use-privileged", "team-b/use-any (wildcard)"},
+			expectRoleBindings: []string{"team-a/rb-r"},
+			expectSubjects:     []rbacv1.Subject{masters, sa},
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		psp, ok := FindRelationalPSP(psps, test.psp)
+		if !assert.True(t, ok) {
+			continue
+		}
+
+		crs, crbs, rs, rbs := []string{}, []string{}, []string{}, []string{}
+		for _, cr := range psp.ClusterRoles {
+			name := cr.Name
+			if cr.Wildcard {
+				name += " (wildcard)"
+			}
+			crs = append(crs, name)
+			for _, crb := range cr.ClusterRoleBindings {
+				crbs = append(crbs, crb.Name)
+			}
+			for _, rb := range cr.RoleBindings {
+				rbs = append(rbs, rb.Namespace+"/"+rb.Name)
+			}
+		}
+		for _, r := range psp.Roles {
+			name := r.Namespace + "/" + r.Name
+			if r.Wildcard {
+				name += " (wildcard)"
+			}
+			rs = append(rs, name)
+			for _, rb := range r.RoleBindings {
+				rbs = append(rbs, rb.Namespace+"/"+rb.Name)
+			}
+		}
+		assert.Equal(t, test.expectClusterRoles, crs)
+		assert.Equal(t, test.expectCRBs, crbs)
+		assert.Equal(t, test.expectRoles, rs)
+		assert.Equal(t, test.expectRoleBindings, rbs)
+
+		subjects := make([]rbacv1.Subject, 0)
+		for _, g := range psp.SubjectGrants() {
+			subjects = append(subjects, g.Subject)
+		}
+		assert.Equal(t, test.expectSubjects, subjects)
+	}
+}
+
+func TestGetDanglingReferences(t *testing.T) {
+	useRule := func(psps ...string) []rbacv1.PolicyRule {
+		return []rbacv1.PolicyRule{{APIGroups: []string{"policy"}, Resources: []string{"podsecuritypolicies"}, ResourceNames: psps, Verbs: []string{"use"}}}
+	}
+	roleRef := func(kind, name string) rbacv1.RoleRef {
+		return rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: kind, Name: name}
+	}
+
+	tests := []struct {
+		title          string
+		objs           []runtime.Object
+		expectRoles    []DanglingRole
+		expectBindings []DanglingBinding
+	}{
+		{
+			title: "no dangling references",
+			objs: []runtime.Object{
+				&policyv1.PodSecurityPolicy{ObjectMeta: metav1.ObjectMeta{Name: "restricted"}},
+				&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "use-restricted"}, Rules: useRule("restricted")},
+				&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "crb"}, RoleRef: roleRef("ClusterRole", "use-restricted")},
+				&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "rb", Namespace: "team-a"}, RoleRef: roleRef("ClusterRole", "use-restricted")},
+			},
+			expectRoles:    []DanglingRole{},
+			expectBindings: []DanglingBinding{},
+		},
+		{
+			title: "roles granting missing PSPs",
+			objs: []runtime.Object{
+				&policyv1.PodSecurityPolicy{ObjectMeta: metav1.ObjectMeta{Name: "restricted"}},
+				&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "typo"}, Rules: useRule("restricted", "restriced")},
+				&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "deleted", Namespace: "team-a"}, Rules: useRule("deleted")},
+			},
+			expectRoles: []DanglingRole{
+				{Kind: "ClusterRole", Name: "typo", PSPs: []string{"restriced"}},
+				{Kind: "Role", Name: "deleted", Namespace: "team-a", PSPs: []string{"deleted"}},
+			},
+			expectBindings: []DanglingBinding{},
+		},
+		{
+			title: "bindings to missing roles",
+			objs: []runtime.Object{
+				&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "role", Namespace: "team-a"}},
+				&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "crb"}, RoleRef: roleRef("ClusterRole", "deleted")},
+				&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "rb-r", Namespace: "team-a"}, RoleRef: roleRef("Role", "role")},
+				&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "rb-r", Namespace: "team-b"}, RoleRef: roleRef("Role", "role")},
+			},
+			expectRoles: []DanglingRole{},
+			expectBindings: []DanglingBinding{
+				{Kind: "ClusterRoleBinding", Name: "crb", RoleRef: roleRef("ClusterRole", "deleted")},
+				{Kind: "RoleBinding", Name: "rb-r", Namespace: "team-b", RoleRef: roleRef("Role", "role")},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		objs, err := GetObjects(context.Background(), fake.NewClientset(test.objs...))
+		assert.NoError(t, err)
+
+		d := GetDanglingReferences(objs)
+		assert.Equal(t, test.expectRoles, d.Roles)
+		assert.Equal(t, test.expectBindings, d.Bindings)
+		assert.Equal(t, len(test.expectRoles)+len(test.expectBindings) == 0, d.IsEmpty())
+	}
+}
+
+func TestAggregatedClusterRoles(t *testing.T) {
+	roleRef := func(kind, name string) rbacv1.RoleRef {
+		return rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: kind, Name: name}
+	}
+	aggregationRule := func(key string) *rbacv1.AggregationRule {
+		return &rbacv1.AggregationRule{ClusterRoleSelectors: []metav1.LabelSelector{{MatchLabels: map[string]string{key: "true"}}}}
+	}
+	sa := rbacv1.Subject{Kind: "ServiceAccount", Namespace: "team-a", Name: "app"}
+
+	objs := &manifests.Objects{
+		PodSecurityPolicies: policyv1.PodSecurityPolicyList{Items: []policyv1.PodSecurityPolicy{
+			{ObjectMeta: metav1.ObjectMeta{Name: "restricted"}},
+		}},
+		ClusterRoles: rbacv1.ClusterRoleList{Items: []rbacv1.ClusterRole{
+			{
+				ObjectMeta:      metav1.ObjectMeta{Name: "admin"},
+				AggregationRule: aggregationRule("aggregate-to-admin"),
+			},
+			{
+				ObjectMeta:      metav1.ObjectMeta{Name: "edit", Labels: map[string]string{"aggregate-to-admin": "true"}},
+				AggregationRule: aggregationRule("aggregate-to-edit"),
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "use-restricted", Labels: map[string]string{"aggregate-to-edit": "true"}},
+				Rules:      []rbacv1.PolicyRule{{APIGroups: []string{"policy"}, Resources: []string{"podsecuritypolicies"}, ResourceNames: []string{"restricted"}, Verbs: []string{"use"}}},
+			},
+			// aggregating each other must not loop
+			{
+				ObjectMeta:      metav1.ObjectMeta{Name: "loop-a", Labels: map[string]string{"loop-b": "true"}},
+				AggregationRule: aggregationRule("loop-a"),
+			},
+			{
+				ObjectMeta:      metav1.ObjectMeta{Name: "loop-b", Labels: map[string]string{"loop-a": "true"}},
+				AggregationRule: aggregationRule("loop-b"),
+			},
+		}},
+		RoleBindings: rbacv1.RoleBindingList{Items: []rbacv1.RoleBinding{
+			{ObjectMeta: metav1.ObjectMeta{Name: "admin", Namespace: "team-a"}, RoleRef: roleRef("ClusterRole", "admin"), Subjects: []rbacv1.Subject{sa}},
+		}},
+	}
+
+	psps := GetRelationalPSPsFromManifests(objs)
+	psp, ok := FindRelationalPSP(psps, "restricted")
+	if !assert.True(t, ok) {
+		return
+	}
+
+	tests := []struct {
+		title      string
+		index      int
+		expectName string
+		expectPath []string
+	}{
+		{
+			title:      "aggregated twice",
+			index:      0,
+			expectName: "admin",
+			expectPath: []string{"edit", "use-restricted"},
+		},
+		{
+			title:      "aggregated once",
+			index:      1,
+			expectName: "edit",
+			expectPath: []string{"use-restricted"},
+		},
+		{
+			title:      "granted by own rules",
+			index:      2,
+			expectName: "use-restricted",
+			expectPath: nil,
+		},
+	}
+
+	assert.Len(t, psp.ClusterRoles, len(tests))
+	for _, test := range tests {
+		t.Log(test.title)
+		if !assert.True(t, test.index < len(psp.ClusterRoles)) {
+			continue
+		}
+		cr := psp.ClusterRoles[test.index]
+		assert.Equal(t, test.expectName, cr.Name)
+		assert.Equal(t, test.expectPath, cr.AggregationPath)
+	}
+
+	grants := psp.SubjectGrants()
+	if assert.Len(t, grants, 1) {
+		assert.Equal(t, sa, grants[0].Subject)
+		assert.Equal(t, []string{"edit", "use-restricted"}, grants[0].Paths[0].AggregationPath)
+	}
+}
@@ -0,0 +1,259 @@
+/*
+Copyright 2020 jlandowner.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package relations
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/jlandowner/psp-util/pkg/rbac"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+type GrantScope string
+
+const (
+	GrantScopeCluster   GrantScope = "Cluster"
+	GrantScopeNamespace GrantScope = "Namespace"
+)
+
+// GrantPath is a route through which a subject is permitted to use a PSP
+type GrantPath struct {
+	Scope       GrantScope `json:"scope"`
+	Namespace   string     `json:"namespace,omitempty"`
+	RoleKind    string     `json:"roleKind"`
+	RoleName    string     `json:"roleName"`
+	BindingKind string     `json:"bindingKind"`
+	BindingName string     `json:"bindingName"`
+	// Wildcard is true if the role grants the PSP by a wildcard rule
+	Wildcard bool `json:"wildcard,omitempty"`
+	// AggregationPath is the component ClusterRoles through which the aggregated ClusterRole grants the PSP
+	AggregationPath []string `json:"aggregationPath,omitempty"`
+}
+
+// SubjectGrant is a subject permitted to use a PSP with all the paths granting it
+type SubjectGrant struct {
+	Subject rbacv1.Subject `json:"subject"`
+	Paths   []GrantPath    `json:"paths"`
+	// Expanded is the ServiceAccounts included in the ServiceAccounts group. See SubjectExpander
+	Expanded []rbacv1.Subject `json:"expanded,omitempty"`
+	// Warning is set if the subject is every authenticated or unauthenticated user. See ImplicitGroupWarning
+	Warning string `json:"warning,omitempty"`
+}
+
+// IsClusterWide returns true if any of the paths grants the PSP in all namespaces
+func (g SubjectGrant) IsClusterWide() bool {
+	for _, p := range g.Paths {
+		if p.Scope == GrantScopeCluster {
+			return true
+		}
+	}
+	return false
+}
+
+// SubjectGrants returns de-duplicated subjects permitted to use the PSP
+func (r RelationalPodSecurityPolicy) SubjectGrants() []SubjectGrant {
+	grants := make([]SubjectGrant, 0)
+	grantByKey := make(map[string]int)
+
+	add := func(sub rbacv1.Subject, path GrantPath) {
+		key := SubjectKey(sub)
+		i, ok := grantByKey[key]
+		if !ok {
+			i = len(grants)
+			grantByKey[key] = i
+			grants = append(grants, SubjectGrant{Subject: sub, Paths: make([]GrantPath, 0)})
+		}
+		grants[i].Paths = append(grants[i].Paths, path)
+	}
+
+	for _, cr := range r.ClusterRoles {
+		for _, crb := range cr.ClusterRoleBindings {
+			path := GrantPath{
+				Scope:           GrantScopeCluster,
+				RoleKind:        "ClusterRole",
+				RoleName:        cr.Name,
+				BindingKind:     "ClusterRoleBinding",
+				BindingName:     crb.Name,
+				Wildcard:        cr.Wildcard,
+				AggregationPath: cr.AggregationPath,
+			}
+			for _, sub := range crb.Subjects {
+				add(sub, path)
+			}
+		}
+		for _, rb := range cr.RoleBindings {
+			path := GrantPath{
+				Scope:           GrantScopeNamespace,
+				Namespace:       rb.Namespace,
+				RoleKind:        "ClusterRole",
+				RoleName:        cr.Name,
+				BindingKind:     "RoleBinding",
+				BindingName:     rb.Name,
+				Wildcard:        cr.Wildcard,
+				AggregationPath: cr.AggregationPath,
+			}
+			for _, sub := range rb.Subjects {
+				add(sub, path)
+			}
+		}
+	}
+
+	for _, r := range r.Roles {
+		for _, rb := range r.RoleBindings {
+			path := GrantPath{
+				Scope:       GrantScopeNamespace,
+				Namespace:   rb.Namespace,
+				RoleKind:    "Role",
+				RoleName:    r.Name,
+				BindingKind: "RoleBinding",
+				BindingName: rb.Name,
+				Wildcard:    r.Wildcard,
+			}
+			for _, sub := range rb.Subjects {
+				add(sub, path)
+			}
+		}
+	}
+
+	sort.SliceStable(grants, func(i, j int) bool {
+		return SubjectKey(grants[i].Subject) < SubjectKey(grants[j].Subject)
+	})
+	return grants
+}
+
+// SubjectKey identifies a subject regardless of the APIGroup which is optional for User and Group
+func SubjectKey(sub rbacv1.Subject) string {
+	return sub.Kind + "/" + sub.Namespace + "/" + sub.Name
+}
+
+// FormatSubject returns a subject as Kind/Name or Kind/Namespace/Name
+func FormatSubject(sub rbacv1.Subject) string {
+	if sub.Namespace != "" {
+		return sub.Kind + "/" + sub.Namespace + "/" + sub.Name
+	}
+	return sub.Kind + "/" + sub.Name
+}
+
+const (
+	GroupAuthenticated   = "system:authenticated"
+	GroupUnauthenticated = "system:unauthenticated"
+	GroupServiceAccounts = "system:serviceaccounts"
+	UserAnonymous        = "system:anonymous"
+
+	serviceAccountUsernamePrefix = "system:serviceaccount:"
+)
+
+// PSPGrant is a PSP permitted to a subject with the subjects in bindings granting it
+type PSPGrant struct {
+	PSP    string         `json:"psp"`
+	Grants []SubjectGrant `json:"grants"`
+}
+
+// ImplicitSubjects returns the subject itself and all the subjects it is implicitly included in.
+// e.g. ServiceAccount team-a/default is a member of Group system:serviceaccounts:team-a
+func ImplicitSubjects(sub rbacv1.Subject) []rbacv1.Subject {
+	if sa, ok := ServiceAccountSubject(sub); ok {
+		sub = sa
+	}
+
+	subs := []rbacv1.Subject{sub}
+	switch sub.Kind {
+	case "ServiceAccount":
+		subs = append(subs,
+			rbacv1.Subject{Kind: "User", APIGroup: rbac.APIGroup, Name: serviceAccountUsernamePrefix + sub.Namespace + ":" + sub.Name},
+			rbacv1.Subject{Kind: "Group", APIGroup: rbac.APIGroup, Name: GroupServiceAccounts},
+			rbacv1.Subject{Kind: "Group", APIGroup: rbac.APIGroup, Name: GroupServiceAccounts + ":" + sub.Namespace},
+			rbacv1.Subject{Kind: "Group", APIGroup: rbac.APIGroup, Name: GroupAuthenticated})
+	case "User":
+		if sub.Name == UserAnonymous {
+			subs = append(subs, rbacv1.Subject{Kind: "Group", APIGroup: rbac.APIGroup, Name: GroupUnauthenticated})
+		} else {
+			subs = append(subs, rbacv1.Subject{Kind: "Group", APIGroup: rbac.APIGroup, Name: GroupAuthenticated})
+		}
+	}
+	return subs
+}
+
+// ServiceAccountSubject returns the ServiceAccount subject if the subject is a ServiceAccount
+// or a User named by the username of a ServiceAccount e.g. system:serviceaccount:team-a:default
+func ServiceAccountSubject(sub rbacv1.Subject) (rbacv1.Subject, bool) {
+	switch sub.Kind {
+	case "ServiceAccount":
+		return sub, true
+	case "User":
+		if strings.HasPrefix(sub.Name, serviceAccountUsernamePrefix) {
+			s := strings.SplitN(strings.TrimPrefix(sub.Name, serviceAccountUsernamePrefix), ":", 2)
+			if len(s) == 2 {
+				return rbacv1.Subject{Kind: "ServiceAccount", Namespace: s[0], Name: s[1]}, true
+			}
+		}
+	}
+	return rbacv1.Subject{}, false
+}
+
+// GetPSPGrantsForSubject returns PSPs the subject is permitted to use directly or via implicit groups
+func GetPSPGrantsForSubject(psps []RelationalPodSecurityPolicy, sub rbacv1.Subject) []PSPGrant {
+	keys := make(map[string]bool)
+	for _, s := range ImplicitSubjects(sub) {
+		keys[SubjectKey(s)] = true
+	}
+
+	pspGrants := make([]PSPGrant, 0)
+	for _, psp := range psps {
+		grants := make([]SubjectGrant, 0)
+		for _, g := range psp.SubjectGrants() {
+			if keys[SubjectKey(g.Subject)] {
+				grants = append(grants, g)
+			}
+		}
+		if len(grants) > 0 {
+			pspGrants = append(pspGrants, PSPGrant{PSP: psp.Name, Grants: grants})
+		}
+	}
+	return pspGrants
+}
+
+// IsGrantedIn returns true if any of the paths grants the PSP in the namespace
+func (g SubjectGrant) IsGrantedIn(namespace string) bool {
+	for _, p := range g.Paths {
+		if p.Scope == GrantScopeCluster || p.Namespace == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// GetUsablePSPs returns PSPs any of the subjects is permitted to use in the namespace
+func GetUsablePSPs(psps []RelationalPodSecurityPolicy, subjects []rbacv1.Subject, namespace string) []RelationalPodSecurityPolicy {
+	usable := make([]RelationalPodSecurityPolicy, 0)
+	for _, psp := range psps {
+		granted := false
+		for _, sub := range subjects {
+			for _, pg := range GetPSPGrantsForSubject([]RelationalPodSecurityPolicy{psp}, sub) {
+				for _, g := range pg.Grants {
+					if g.IsGrantedIn(namespace) {
+						granted = true
+					}
+				}
+			}
+		}
+		if granted {
+			usable = append(usable, psp)
+		}
+	}
+	return usable
+}
@@ -0,0 +1,214 @@
+package relations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSubjectGrants(t *testing.T) {
+	sa := rbacv1.Subject{Kind: "ServiceAccount", Name: "default", Namespace: "team-a"}
+	group := rbacv1.Subject{Kind: "Group", APIGroup: "rbac.authorization.k8s.io", Name: "system:authenticated"}
+	groupWithoutAPIGroup := rbacv1.Subject{Kind: "Group", Name: "system:authenticated"}
+
+	rpsp := RelationalPodSecurityPolicy{
+		ClusterRoles: []*RelationalClusterRole{
+			{
+				ClusterRole: rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "cr"}},
+				ClusterRoleBindings: []*rbacv1.ClusterRoleBinding{
+					{ObjectMeta: metav1.ObjectMeta{Name: "crb"}, Subjects: []rbacv1.Subject{group, sa}},
+				},
+				RoleBindings: []*rbacv1.RoleBinding{
+					{ObjectMeta: metav1.ObjectMeta{Name: "rb1", Namespace: "team-a"}, Subjects: []rbacv1.Subject{sa}},
+				},
+			},
+		},
+		Roles: []*RelationalRole{
+			{
+				Role: rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "r", Namespace: "team-b"}},
+				RoleBindings: []*rbacv1.RoleBinding{
+					{ObjectMeta: metav1.ObjectMeta{Name: "rb2", Namespace: "team-b"}, Subjects: []rbacv1.Subject{groupWithoutAPIGroup}},
+				},
+			},
+		},
+	}
+
+	grants := rpsp.SubjectGrants()
+	assert.Len(t, grants, 2)
+
+	tests := []struct {
+		title             string
+		grant             SubjectGrant
+		expectSubject     rbacv1.Subject
+		expectPaths       []GrantPath
+		expectClusterWide bool
+	}{
+		{
+			title:         "group is merged regardless of APIGroup",
+			grant:         grants[0],
+			expectSubject: group,
+			expectPaths: []GrantPath{
+				{Scope: GrantScopeCluster, RoleKind: "ClusterRole", RoleName: "cr", BindingKind: "ClusterRoleBinding", BindingName: "crb"},
+				{Scope: GrantScopeNamespace, Namespace: "team-b", RoleKind: "Role", RoleName: "r", BindingKind: "RoleBinding", BindingName: "rb2"},
+			},
+			expectClusterWide: true,
+		},
+		{
+			title:         "serviceaccount",
+			grant:         grants[1],
+			expectSubject: sa,
+			expectPaths: []GrantPath{
+				{Scope: GrantScopeCluster, RoleKind: "ClusterRole", RoleName: "cr", BindingKind: "ClusterRoleBinding", BindingName: "crb"},
+				{Scope: GrantScopeNamespace, Namespace: "team-a", RoleKind: "ClusterRole", RoleName: "cr", BindingKind: "RoleBinding", BindingName: "rb1"},
+			},
+			expectClusterWide: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		assert.Equal(t, test.expectSubject, test.grant.Subject)
+		assert.Equal(t, test.expectPaths, test.grant.Paths)
+		assert.Equal(t, test.expectClusterWide, test.grant.IsClusterWide())
+	}
+}
+
+func TestGetPSPGrantsForSubject(t *testing.T) {
+	newPSP := func(name string, subs ...rbacv1.Subject) RelationalPodSecurityPolicy {
+		rpsp := RelationalPodSecurityPolicy{
+			ClusterRoles: []*RelationalClusterRole{
+				{
+					ClusterRole: rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: name}},
+					ClusterRoleBindings: []*rbacv1.ClusterRoleBinding{
+						{ObjectMeta: metav1.ObjectMeta{Name: name}, Subjects: subs},
+					},
+				},
+			},
+		}
+		rpsp.Name = name
+		return rpsp
+	}
+	psps := []RelationalPodSecurityPolicy{
+		newPSP("authenticated", rbacv1.Subject{Kind: "Group", Name: "system:authenticated"}),
+		newPSP("all-sa", rbacv1.Subject{Kind: "Group", Name: "system:serviceaccounts"}),
+		newPSP("team-a-sa", rbacv1.Subject{Kind: "Group", Name: "system:serviceaccounts:team-a"}),
+		newPSP("sa", rbacv1.Subject{Kind: "ServiceAccount", Name: "default", Namespace: "team-a"}),
+		newPSP("sa-user", rbacv1.Subject{Kind: "User", Name: "system:serviceaccount:team-a:default"}),
+		newPSP("user", rbacv1.Subject{Kind: "User", Name: "alice"}),
+		newPSP("unauthenticated", rbacv1.Subject{Kind: "Group", Name: "system:unauthenticated"}),
+	}
+
+	tests := []struct {
+		title     string
+		subject   rbacv1.Subject
+		expectPSP []string
+	}{
+		{
+			title:     "serviceaccount",
+			subject:   rbacv1.Subject{Kind: "ServiceAccount", Name: "default", Namespace: "team-a"},
+			expectPSP: []string{"authenticated", "all-sa", "team-a-sa", "sa", "sa-user"},
+		},
+		{
+			title:     "serviceaccount in other namespace",
+			subject:   rbacv1.Subject{Kind: "ServiceAccount", Name: "default", Namespace: "team-b"},
+			expectPSP: []string{"authenticated", "all-sa"},
+		},
+		{
+			title:     "serviceaccount as user",
+			subject:   rbacv1.Subject{Kind: "User", Name: "system:serviceaccount:team-a:default"},
+			expectPSP: []string{"authenticated", "all-sa", "team-a-sa", "sa", "sa-user"},
+		},
+		{
+			title:     "user",
+			subject:   rbacv1.Subject{Kind: "User", Name: "alice", APIGroup: "rbac.authorization.k8s.io"},
+			expectPSP: []string{"authenticated", "user"},
+		},
+		{
+			title:     "anonymous",
+			subject:   rbacv1.Subject{Kind: "User", Name: "system:anonymous"},
+			expectPSP: []string{"unauthenticated"},
+		},
+		{
+			title:     "group",
+			subject:   rbacv1.Subject{Kind: "Group", Name: "system:serviceaccounts:team-a"},
+			expectPSP: []string{"team-a-sa"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		pspNames := make([]string, 0)
+		for _, g := range GetPSPGrantsForSubject(psps, test.subject) {
+			pspNames = append(pspNames, g.PSP)
+		}
+		assert.Equal(t, test.expectPSP, pspNames)
+	}
+}
+
+func TestSubjectExpander(t *testing.T) {
+	sa := func(ns, name string) corev1.ServiceAccount {
+		return corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns}}
+	}
+	saSubject := func(ns, name string) rbacv1.Subject {
+		return rbacv1.Subject{Kind: "ServiceAccount", Namespace: ns, Name: name}
+	}
+	group := func(name string) rbacv1.Subject {
+		return rbacv1.Subject{Kind: "Group", APIGroup: "rbac.authorization.k8s.io", Name: name}
+	}
+	e := NewSubjectExpander([]corev1.ServiceAccount{sa("team-b", "default"), sa("team-a", "default"), sa("team-a", "app")})
+
+	tests := []struct {
+		title         string
+		subject       rbacv1.Subject
+		expectOK      bool
+		expectSubs    []rbacv1.Subject
+		expectWarning string
+	}{
+		{
+			title:      "all ServiceAccounts",
+			subject:    group("system:serviceaccounts"),
+			expectOK:   true,
+			expectSubs: []rbacv1.Subject{saSubject("team-a", "app"), saSubject("team-a", "default"), saSubject("team-b", "default")},
+		},
+		{
+			title:      "ServiceAccounts in namespace",
+			subject:    group("system:serviceaccounts:team-a"),
+			expectOK:   true,
+			expectSubs: []rbacv1.Subject{saSubject("team-a", "app"), saSubject("team-a", "default")},
+		},
+		{
+			title:      "ServiceAccounts in namespace without ServiceAccounts",
+			subject:    group("system:serviceaccounts:team-c"),
+			expectOK:   true,
+			expectSubs: []rbacv1.Subject{},
+		},
+		{
+			title:         "all authenticated users",
+			subject:       group("system:authenticated"),
+			expectWarning: "granted to all authenticated users",
+		},
+		{
+			title:         "all unauthenticated users",
+			subject:       group("system:unauthenticated"),
+			expectWarning: "granted to all unauthenticated users",
+		},
+		{
+			title:   "ServiceAccount",
+			subject: saSubject("team-a", "app"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		subs, ok := e.Expand(test.subject)
+		assert.Equal(t, test.expectOK, ok)
+		assert.Equal(t, test.expectSubs, subs)
+
+		grants := e.ExpandGrants([]SubjectGrant{{Subject: test.subject}})
+		assert.Equal(t, test.expectWarning, grants[0].Warning)
+		assert.Equal(t, len(test.expectSubs), len(grants[0].Expanded))
+	}
+}
@@ -17,10 +17,17 @@ package utils
 
 import (
 	"fmt"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 var (
 	AnnotaionKeyPSPName = "psp-util.k8s.jlandowner.com/psp"
+
+	LabelKeyManagedBy   = "app.kubernetes.io/managed-by"
+	LabelValueManagedBy = "psp-util"
+	LabelKeyPSPName     = "psp-util.k8s.jlandowner.com/psp"
 )
 
 func GenerateName(pspName string) string {
@@ -31,3 +38,57 @@ func GenerateAnotations(pspName string) map[string]string {
 	anotation := map[string]string{AnnotaionKeyPSPName: pspName}
 	return anotation
 }
+
+// GenerateLabels returns the labels of the managed resources.
+// The PSP name label is omitted when the name is not a valid label value
+func GenerateLabels(pspName string) map[string]string {
+	labels := map[string]string{LabelKeyManagedBy: LabelValueManagedBy}
+	if len(validation.IsValidLabelValue(pspName)) == 0 {
+		labels[LabelKeyPSPName] = pspName
+	}
+	return labels
+}
+
+// HasManagedLabels returns true if the labels have all the managed labels of the PSP
+func HasManagedLabels(l map[string]string, pspName string) bool {
+	for k, v := range GenerateLabels(pspName) {
+		if l[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// ManagedSelector returns the label selector of the managed resources of the PSP.
+// It selects the managed resources of all PSPs if pspName is empty
+func ManagedSelector(pspName string) string {
+	set := labels.Set{LabelKeyManagedBy: LabelValueManagedBy}
+	if pspName != "" {
+		set = GenerateLabels(pspName)
+	}
+	return labels.SelectorFromSet(set).String()
+}
+
+// UnlabeledSelector returns the label selector of the resources without the managed labels,
+// which include the managed resources generated by the older versions of psp-util
+func UnlabeledSelector() string {
+	return "!" + LabelKeyManagedBy
+}
+
+func IsManaged(annotations map[string]string) bool {
+	_, ok := annotations[AnnotaionKeyPSPName]
+	return ok
+}
+
+// UniqueStrings returns the values without duplicates in the original order
+func UniqueStrings(values []string) []string {
+	seen := make(map[string]bool)
+	unique := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
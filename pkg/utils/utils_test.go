@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -26,3 +27,53 @@ func TestGenerateAnotations(t *testing.T) {
 		assert.Equal(t, test.expect, val)
 	}
 }
+
+func TestGenerateLabels(t *testing.T) {
+	tests := []struct {
+		title  string
+		name   string
+		expect map[string]string
+	}{
+		{
+			title:  "valid label value",
+			name:   "restricted",
+			expect: map[string]string{LabelKeyManagedBy: "psp-util", LabelKeyPSPName: "restricted"},
+		},
+		{
+			title:  "too long for label value",
+			name:   "psp-" + strings.Repeat("x", 60),
+			expect: map[string]string{LabelKeyManagedBy: "psp-util"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		assert.Equal(t, test.expect, GenerateLabels(test.name))
+	}
+}
+
+func TestManagedSelector(t *testing.T) {
+	tests := []struct {
+		title  string
+		name   string
+		expect string
+	}{
+		{
+			title:  "all PSPs",
+			name:   "",
+			expect: "app.kubernetes.io/managed-by=psp-util",
+		},
+		{
+			title:  "a PSP",
+			name:   "restricted",
+			expect: "app.kubernetes.io/managed-by=psp-util,psp-util.k8s.jlandowner.com/psp=restricted",
+		},
+	}
+
+	for _, test := range tests {
+		t.Log(test.title)
+		assert.Equal(t, test.expect, ManagedSelector(test.name))
+		assert.True(t, HasManagedLabels(GenerateLabels("restricted"), test.name) || test.name == "")
+	}
+	assert.False(t, HasManagedLabels(map[string]string{LabelKeyManagedBy: LabelValueManagedBy}, "restricted"))
+}